@@ -0,0 +1,124 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"github.com/aarondever/linko/internal/config"
+	"github.com/aarondever/linko/internal/database"
+	"github.com/aarondever/linko/internal/models"
+	"github.com/aarondever/linko/internal/services"
+	"log/slog"
+	"os"
+	"os/signal"
+	"os/user"
+	"syscall"
+	"time"
+)
+
+// errUsage is returned by commands invoked with invalid arguments once their usage is printed
+var errUsage = errors.New("invalid usage")
+
+// cliEnv holds the dependencies of an administrative command
+type cliEnv struct {
+	cfg      *config.Config
+	db       *database.Database
+	services *services.Services
+}
+
+// newFlagSet creates the flag set of a command, including the configuration flags
+func newFlagSet(name, arguments string) (*flag.FlagSet, *string) {
+	flagSet := flag.NewFlagSet(name, flag.ExitOnError)
+	flagSet.Usage = func() {
+		fmt.Fprintf(flagSet.Output(), "Usage: linko %s [flags] %s\n\nFlags:\n", name, arguments)
+		flagSet.PrintDefaults()
+	}
+
+	return flagSet, config.RegisterFlags(flagSet)
+}
+
+// loadCLIConfig loads the configuration for a command. Logs go to stderr so that
+// command output on stdout stays machine readable.
+func loadCLIConfig(configFile string) (*config.Config, error) {
+	cfg, err := config.LoadConfig(configFile, os.Stderr)
+	if err != nil {
+		return nil, fmt.Errorf("Configuration loading failed: %w", err)
+	}
+
+	return cfg, nil
+}
+
+// bootstrap loads the configuration, connects to the database and initializes the
+// service layer the same way the server does
+func bootstrap(configFile string) (*cliEnv, error) {
+	cfg, err := loadCLIConfig(configFile)
+	if err != nil {
+		return nil, err
+	}
+
+	db, err := database.InitializeDatabase(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("Database initialization failed: %w", err)
+	}
+
+	return &cliEnv{
+		cfg:      cfg,
+		db:       db,
+		services: services.InitializeServices(db, cfg),
+	}, nil
+}
+
+func (env *cliEnv) close() {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	if err := env.db.Mongo.Disconnect(ctx); err != nil {
+		slog.Error("Database disconnection error", "error", err)
+	}
+}
+
+// commandContext returns a context that is cancelled on interrupt. The changes made by the
+// command are audited as made by the OS user running it.
+func commandContext() (context.Context, context.CancelFunc) {
+	actor := models.AuditActor{Type: models.AuditActorCLI}
+	if current, err := user.Current(); err == nil {
+		actor.ID = current.Username
+	}
+
+	ctx := services.WithAuditActor(context.Background(), actor, "", "")
+	return signal.NotifyContext(ctx, os.Interrupt, syscall.SIGTERM)
+}
+
+// printJSON writes value to stdout as indented JSON
+func printJSON(value any) error {
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(value); err != nil {
+		return fmt.Errorf("Failed writing output: %w", err)
+	}
+
+	return nil
+}
+
+// requireSubcommand splits args into a subcommand and its arguments, printing usage and
+// returning errUsage if missing
+func requireSubcommand(command string, args []string, subcommands string) (string, []string, error) {
+	if len(args) == 0 {
+		fmt.Fprintf(os.Stderr, "Usage: linko %s <%s> [flags] [arguments]\n", command, subcommands)
+		return "", nil, errUsage
+	}
+
+	return args[0], args[1:], nil
+}
+
+// requireArgs prints usage and returns errUsage unless flagSet has exactly count positional arguments
+func requireArgs(flagSet *flag.FlagSet, count int) error {
+	if flagSet.NArg() != count {
+		flagSet.Usage()
+		return errUsage
+	}
+
+	return nil
+}
@@ -0,0 +1,32 @@
+package main
+
+import (
+	"fmt"
+	"github.com/aarondever/linko/internal/config"
+	"os"
+)
+
+func runConfig(args []string) error {
+	subcommand, args, err := requireSubcommand("config", args, "validate")
+	if err != nil {
+		return err
+	}
+
+	switch subcommand {
+	case "validate":
+		flagSet, configFile := newFlagSet("config validate", "")
+		flagSet.Parse(args)
+		if err = requireArgs(flagSet, 0); err != nil {
+			return err
+		}
+
+		if _, err = config.LoadConfig(*configFile, os.Stderr); err != nil {
+			return fmt.Errorf("Configuration is invalid:\n%w", err)
+		}
+
+		fmt.Fprintln(os.Stderr, "Configuration is valid")
+		return nil
+	default:
+		return fmt.Errorf("Unknown config command %q", subcommand)
+	}
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+)
+
+func runKeys(args []string) error {
+	subcommand, args, err := requireSubcommand("keys", args, "create|list|revoke")
+	if err != nil {
+		return err
+	}
+
+	switch subcommand {
+	case "create":
+		flagSet, configFile := newFlagSet("keys create", "")
+		name := flagSet.String("name", "", "Name describing what the key is used for")
+		workspace := flagSet.String("workspace", "", "Workspace whose custom domains the key can use")
+		admin := flagSet.Bool("admin", false, "Allow the key to use the configuration, audit log, export, import and webhook routes")
+		flagSet.Parse(args)
+		if err = requireArgs(flagSet, 0); err != nil {
+			return err
+		}
+
+		if *name == "" {
+			return errors.New("A key name is required, set it with -name")
+		}
+
+		env, err := bootstrap(*configFile)
+		if err != nil {
+			return err
+		}
+		defer env.close()
+		ctx, cancel := commandContext()
+		defer cancel()
+
+		apiKey, err := env.services.APIKeyService.CreateAPIKey(ctx, *name, *workspace, *admin)
+		if err != nil {
+			return fmt.Errorf("Failed creating API key: %w", err)
+		}
+
+		if err = printJSON(apiKey); err != nil {
+			return err
+		}
+		fmt.Fprintln(os.Stderr, "Store the key now, it cannot be shown again")
+		return nil
+	case "list":
+		flagSet, configFile := newFlagSet("keys list", "")
+		flagSet.Parse(args)
+		if err = requireArgs(flagSet, 0); err != nil {
+			return err
+		}
+
+		env, err := bootstrap(*configFile)
+		if err != nil {
+			return err
+		}
+		defer env.close()
+		ctx, cancel := commandContext()
+		defer cancel()
+
+		apiKeys, err := env.services.APIKeyService.ListAPIKeys(ctx)
+		if err != nil {
+			return fmt.Errorf("Failed listing API keys: %w", err)
+		}
+
+		return printJSON(apiKeys)
+	case "revoke":
+		flagSet, configFile := newFlagSet("keys revoke", "<id>")
+		flagSet.Parse(args)
+		if err = requireArgs(flagSet, 1); err != nil {
+			return err
+		}
+		id := flagSet.Arg(0)
+
+		env, err := bootstrap(*configFile)
+		if err != nil {
+			return err
+		}
+		defer env.close()
+		ctx, cancel := commandContext()
+		defer cancel()
+
+		apiKey, err := env.services.APIKeyService.RevokeAPIKey(ctx, id)
+		if err != nil {
+			return fmt.Errorf("Failed revoking API key %q: %w", id, err)
+		}
+
+		return printJSON(apiKey)
+	default:
+		return fmt.Errorf("Unknown keys command %q", subcommand)
+	}
+}
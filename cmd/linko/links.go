@@ -0,0 +1,126 @@
+package main
+
+import (
+	"fmt"
+	"github.com/aarondever/linko/internal/models"
+	"github.com/aarondever/linko/internal/utils"
+	"os"
+)
+
+func runLinks(args []string) error {
+	subcommand, args, err := requireSubcommand("links", args, "create|get|list|delete")
+	if err != nil {
+		return err
+	}
+
+	switch subcommand {
+	case "create":
+		flagSet, configFile := newFlagSet("links create", "<url>")
+		domain := flagSet.String("domain", "", "Verified custom domain to serve the link from")
+		flagSet.Parse(args)
+		if err = requireArgs(flagSet, 1); err != nil {
+			return err
+		}
+		url := flagSet.Arg(0)
+
+		if err = utils.ValidateStruct(models.ShortenURLRequest{URL: url}); err != nil {
+			return fmt.Errorf("Invalid URL %q", url)
+		}
+
+		env, err := bootstrap(*configFile)
+		if err != nil {
+			return err
+		}
+		defer env.close()
+		ctx, cancel := commandContext()
+		defer cancel()
+
+		shortCode, err := env.services.URLService.ShortenURL(ctx, *domain, url)
+		if err != nil {
+			return fmt.Errorf("Failed shortening URL: %w", err)
+		}
+
+		return printJSON(models.ShortenURLResponse{
+			ShortCode: shortCode,
+			Domain:    *domain,
+			ShortURL:  env.services.URLService.ShortURL("", *domain, shortCode),
+		})
+	case "get":
+		flagSet, configFile := newFlagSet("links get", "<short_code>")
+		domain := flagSet.String("domain", "", "Custom domain of the link")
+		flagSet.Parse(args)
+		if err = requireArgs(flagSet, 1); err != nil {
+			return err
+		}
+		shortCode := flagSet.Arg(0)
+
+		env, err := bootstrap(*configFile)
+		if err != nil {
+			return err
+		}
+		defer env.close()
+		ctx, cancel := commandContext()
+		defer cancel()
+
+		mapping, err := env.services.URLService.GetURLMapping(ctx, *domain, shortCode)
+		if err != nil {
+			return fmt.Errorf("Failed getting link %q: %w", shortCode, err)
+		}
+		mapping.ShortURL = env.services.URLService.ShortURL("", mapping.Domain, mapping.ShortCode)
+
+		return printJSON(mapping)
+	case "list":
+		flagSet, configFile := newFlagSet("links list", "")
+		limit := flagSet.Int64("limit", 50, "Maximum number of links to list")
+		offset := flagSet.Int64("offset", 0, "Number of links to skip")
+		broken := flagSet.Bool("broken", false, "Only list links whose destination is broken")
+		domain := flagSet.String("domain", "", "Only list links on this custom domain")
+		flagSet.Parse(args)
+		if err = requireArgs(flagSet, 0); err != nil {
+			return err
+		}
+
+		env, err := bootstrap(*configFile)
+		if err != nil {
+			return err
+		}
+		defer env.close()
+		ctx, cancel := commandContext()
+		defer cancel()
+
+		mappings, err := env.services.URLService.ListURLMappings(ctx, models.URLFilter{Domain: *domain, Broken: *broken}, *offset, *limit)
+		if err != nil {
+			return fmt.Errorf("Failed listing links: %w", err)
+		}
+		for i := range mappings {
+			mappings[i].ShortURL = env.services.URLService.ShortURL("", mappings[i].Domain, mappings[i].ShortCode)
+		}
+
+		return printJSON(mappings)
+	case "delete":
+		flagSet, configFile := newFlagSet("links delete", "<short_code>")
+		domain := flagSet.String("domain", "", "Custom domain of the link")
+		flagSet.Parse(args)
+		if err = requireArgs(flagSet, 1); err != nil {
+			return err
+		}
+		shortCode := flagSet.Arg(0)
+
+		env, err := bootstrap(*configFile)
+		if err != nil {
+			return err
+		}
+		defer env.close()
+		ctx, cancel := commandContext()
+		defer cancel()
+
+		if err = env.services.URLService.DeleteURL(ctx, *domain, shortCode); err != nil {
+			return fmt.Errorf("Failed deleting link %q: %w", shortCode, err)
+		}
+
+		fmt.Fprintf(os.Stderr, "Deleted %s\n", shortCode)
+		return nil
+	default:
+		return fmt.Errorf("Unknown links command %q", subcommand)
+	}
+}
@@ -1,142 +1,76 @@
 package main
 
 import (
-	"context"
 	"errors"
 	"fmt"
-	"github.com/aarondever/linko/internal/config"
-	"github.com/aarondever/linko/internal/database"
-	"github.com/aarondever/linko/internal/handlers"
-	"github.com/aarondever/linko/internal/models"
-	"github.com/aarondever/linko/internal/services"
-	"github.com/aarondever/linko/internal/utils"
-	"github.com/go-chi/chi/v5/middleware"
-	"log/slog"
-	"net/http"
 	"os"
-	"os/signal"
-	"syscall"
-	"time"
-
-	"github.com/go-chi/chi/v5"
+	"strings"
 )
 
-type Application struct {
-	webServer *http.Server
-	metrics   *models.ApplicationMetrics
-}
+const usage = `Usage: linko <command> [flags] [arguments]
+
+Commands:
+  serve                       Start the web server (default when no command is given)
+  links create <url>          Shorten a URL
+  links get <short_code>      Show a short link
+  links list                  List short links, newest first
+  links delete <short_code>   Delete a short link
+  keys create                 Create an API key
+  keys list                   List API keys
+  keys revoke <id>            Revoke an API key
+  migrate                     Create missing collections and indexes and update validators
+  export                      Export all links as NDJSON or CSV
+  import [file]               Import links from NDJSON or CSV
+  config validate             Validate the configuration
+  openapi print               Print the OpenAPI document
+  openapi check               Check that the OpenAPI document matches the served routes
+
+Every command accepts -config.file and -config.reference, which prints all configuration
+settings with their environment variables and defaults. Run "linko <command> -h" for its flags.
+Flags must come before positional arguments.
+`
 
 func main() {
-	// Load configuration
-	cfg, err := config.LoadConfig()
-	if err != nil {
-		slog.Error("Configuration loading failed", "error", err)
-		os.Exit(1)
-	}
-
-	// Initialize database connection pool
-	db, err := database.InitializeDatabase(cfg)
-	if err != nil {
-		slog.Error("Database initialization failed", "error", err)
-		os.Exit(1)
-	}
-	defer func() {
-		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
-		defer cancel()
+	args := os.Args[1:]
 
-		if err = db.Mongo.Disconnect(ctx); err != nil {
-			slog.Error("Database disconnection error", "error", err)
-		}
-	}()
-
-	// Initialize all services with dependency injection
-	allServices := services.InitializeServices(db, cfg)
-
-	// Initialize all handlers with service dependencies
-	allHandlers := handlers.InitializeHandlers(allServices)
-
-	// Configure middleware
-	router := chi.NewRouter()
-	router.Use(middleware.Logger)                    // Request logging
-	router.Use(middleware.Recoverer)                 // Panic recovery
-	router.Use(middleware.Compress(5))               // Response compression
-	router.Use(middleware.Timeout(30 * time.Second)) // Request timeout
-
-	// Setup routers
-	allHandlers.SetupRouters(router)
-
-	app := &Application{
-		metrics: &models.ApplicationMetrics{StartTime: time.Now()},
+	// Without a command, flags are passed to serve to keep existing invocations working
+	var err error
+	command := "serve"
+	if len(args) > 0 && (!strings.HasPrefix(args[0], "-") || args[0] == "-h" || args[0] == "-help") {
+		command, args = args[0], args[1:]
 	}
 
-	// Configure server
-	app.webServer = &http.Server{
-		Addr:         fmt.Sprintf("%s:%d", cfg.Server.Host, cfg.Server.Port),
-		Handler:      router,
-		ReadTimeout:  15 * time.Second,
-		WriteTimeout: 15 * time.Second,
-		IdleTimeout:  60 * time.Second,
+	switch command {
+	case "serve":
+		err = runServe(args)
+	case "links":
+		err = runLinks(args)
+	case "keys":
+		err = runKeys(args)
+	case "migrate":
+		err = runMigrate(args)
+	case "export":
+		err = runExport(args)
+	case "import":
+		err = runImport(args)
+	case "config":
+		err = runConfig(args)
+	case "openapi":
+		err = runOpenAPI(args)
+	case "help", "-h", "-help":
+		fmt.Print(usage)
+	default:
+		fmt.Fprintf(os.Stderr, "Unknown command %q\n\n%s", command, usage)
+		err = errUsage
 	}
 
-	// Setup app routers
-	router.Route("/api", func(r chi.Router) {
-		router.Get("/health", app.getHealth)
-		router.Get("/metrics", app.getMetrics)
-	})
-
-	// Setup graceful shutdown handling
-	sigChan := make(chan os.Signal, 1)
-	signal.Notify(sigChan, os.Interrupt, syscall.SIGTERM, syscall.SIGINT)
-	go func() {
-		sig := <-sigChan
-		slog.Info("Received shutdown signal", "signal", sig)
-		slog.Info("Initiating graceful shutdown...")
-
-		app.initiateShutdown()
-	}()
-
-	slog.Info("Starting web server", "address", app.webServer.Addr)
-
-	// Start server (blocking call)
-	if err = app.webServer.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
-		slog.Error("Web server failed to start", "error", err)
+	// Commands return instead of exiting so that their deferred cleanup, like disconnecting
+	// from the database, has run by now
+	switch {
+	case errors.Is(err, errUsage):
+		os.Exit(2)
+	case err != nil:
+		fmt.Fprintln(os.Stderr, err)
 		os.Exit(1)
 	}
 }
-
-// initiateShutdown begins the graceful shutdown process for all application components
-func (app *Application) initiateShutdown() {
-	shutdownStart := time.Now()
-
-	// Stop web server with timeout
-	if app.webServer != nil {
-		slog.Info("Stopping web server...")
-
-		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
-		defer cancel()
-
-		if err := app.webServer.Shutdown(ctx); err != nil {
-			slog.Error("Web server shutdown error", "error", err)
-		} else {
-			slog.Info("Web server stopped gracefully")
-		}
-	}
-
-	slog.Info("Graceful shutdown completed", "duration", time.Since(shutdownStart))
-	slog.Info("Final application metrics",
-		"start_time", app.metrics.StartTime.Format(time.RFC3339),
-		"total_uptime", app.metrics.TotalUptime)
-}
-
-func (app *Application) getHealth(w http.ResponseWriter, _ *http.Request) {
-	healthResponse := map[string]interface{}{
-		"status":  "healthy",
-		"service": "linko",
-	}
-	utils.RespondWithJSON(w, healthResponse, http.StatusOK)
-}
-
-func (app *Application) getMetrics(w http.ResponseWriter, _ *http.Request) {
-	app.metrics.TotalUptime = time.Since(app.metrics.StartTime)
-	utils.RespondWithJSON(w, app.metrics, http.StatusOK)
-}
@@ -0,0 +1,26 @@
+package main
+
+import "fmt"
+
+func runMigrate(args []string) error {
+	flagSet, configFile := newFlagSet("migrate", "")
+	flagSet.Parse(args)
+	if err := requireArgs(flagSet, 0); err != nil {
+		return err
+	}
+
+	// Connecting creates missing collections and indexes
+	env, err := bootstrap(*configFile)
+	if err != nil {
+		return err
+	}
+	defer env.close()
+	ctx, cancel := commandContext()
+	defer cancel()
+
+	if err = env.db.Migrate(ctx); err != nil {
+		return fmt.Errorf("Migration failed: %w", err)
+	}
+
+	return nil
+}
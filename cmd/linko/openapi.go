@@ -0,0 +1,58 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"github.com/aarondever/linko/internal/config"
+	"github.com/aarondever/linko/internal/handlers"
+	"github.com/aarondever/linko/internal/models"
+	"github.com/aarondever/linko/internal/services"
+	"os"
+	"time"
+)
+
+func runOpenAPI(args []string) error {
+	subcommand, args, err := requireSubcommand("openapi", args, "print|check")
+	if err != nil {
+		return err
+	}
+
+	flagSet, configFile := newFlagSet("openapi "+subcommand, "")
+	flagSet.Parse(args)
+	if err = requireArgs(flagSet, 0); err != nil {
+		return err
+	}
+
+	// Building the router needs no database connection
+	cfg, err := loadCLIConfig(*configFile)
+	if err != nil {
+		return err
+	}
+	reloader := config.NewReloader(*configFile, cfg)
+	allHandlers := handlers.InitializeHandlers(services.InitializeServices(nil, cfg), reloader)
+	app := &Application{cfg: cfg, metrics: &models.ApplicationMetrics{StartTime: time.Now()}}
+	router := app.newRouter(allHandlers)
+	document := allHandlers.OpenAPIHandler.Document
+
+	switch subcommand {
+	case "print":
+		return printJSON(document)
+	case "check":
+		problems, err := document.CheckRoutes(router)
+		if err != nil {
+			return fmt.Errorf("Failed checking routes: %w", err)
+		}
+
+		for _, problem := range problems {
+			fmt.Fprintln(os.Stderr, problem)
+		}
+		if len(problems) > 0 {
+			return errors.New("OpenAPI document does not match the served routes")
+		}
+
+		fmt.Fprintln(os.Stderr, "OpenAPI document matches the served routes")
+		return nil
+	default:
+		return fmt.Errorf("Unknown openapi command %q", subcommand)
+	}
+}
@@ -0,0 +1,32 @@
+package main
+
+import (
+	"github.com/aarondever/linko/internal/config"
+	"github.com/aarondever/linko/internal/handlers"
+	"github.com/aarondever/linko/internal/models"
+	"github.com/aarondever/linko/internal/services"
+	"io"
+	"testing"
+	"time"
+)
+
+// TestOpenAPIDocumentMatchesRoutes fails when a served route is not documented or a documented
+// operation is not served
+func TestOpenAPIDocumentMatchesRoutes(t *testing.T) {
+	cfg, err := config.LoadConfig("", io.Discard)
+	if err != nil {
+		t.Fatalf("loading default configuration: %v", err)
+	}
+
+	allHandlers := handlers.InitializeHandlers(services.InitializeServices(nil, cfg), config.NewReloader("", cfg))
+	app := &Application{cfg: cfg, metrics: &models.ApplicationMetrics{StartTime: time.Now()}}
+	router := app.newRouter(allHandlers)
+
+	problems, err := allHandlers.OpenAPIHandler.Document.CheckRoutes(router)
+	if err != nil {
+		t.Fatalf("checking routes: %v", err)
+	}
+	for _, problem := range problems {
+		t.Error(problem)
+	}
+}
@@ -0,0 +1,381 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"flag"
+	"fmt"
+	"github.com/aarondever/linko/internal/config"
+	"github.com/aarondever/linko/internal/database"
+	"github.com/aarondever/linko/internal/handlers"
+	"github.com/aarondever/linko/internal/models"
+	"github.com/aarondever/linko/internal/openapi"
+	"github.com/aarondever/linko/internal/services"
+	"github.com/aarondever/linko/internal/tracing"
+	"github.com/aarondever/linko/internal/utils"
+	"github.com/go-chi/chi/v5/middleware"
+	"log/slog"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+)
+
+type Application struct {
+	cfg         *config.Config
+	webServer   *http.Server
+	adminServer *http.Server
+	httpServer  *http.Server // Plain HTTP listener next to the TLS web server with ACME
+	metrics     *models.ApplicationMetrics
+	services    *services.Services
+	stopWorkers context.CancelFunc
+}
+
+// runServe starts the web server and blocks until it is shut down. It returns an error when a
+// component fails to start or a listener stops unexpectedly, once everything started so far has
+// been stopped.
+func runServe(args []string) error {
+	flagSet := flag.NewFlagSet("serve", flag.ExitOnError)
+	configFile := config.RegisterFlags(flagSet)
+	flagSet.Parse(args)
+
+	// Load configuration
+	cfg, err := config.LoadConfig(*configFile, os.Stdout)
+	if err != nil {
+		return fmt.Errorf("Configuration loading failed: %w", err)
+	}
+
+	// Export traces of requests, service calls and database commands
+	shutdownTracing, err := tracing.Setup(context.Background(), cfg.Tracing)
+	if err != nil {
+		return fmt.Errorf("Tracing initialization failed: %w", err)
+	}
+	defer func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+
+		if err := shutdownTracing(ctx); err != nil {
+			slog.Error("Tracing shutdown error", "error", err)
+		}
+	}()
+
+	// Initialize database connection pool
+	db, err := database.InitializeDatabase(cfg)
+	if err != nil {
+		return fmt.Errorf("Database initialization failed: %w", err)
+	}
+	defer func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+
+		if err := db.Mongo.Disconnect(ctx); err != nil {
+			slog.Error("Database disconnection error", "error", err)
+		}
+	}()
+
+	// Initialize all services with dependency injection
+	allServices := services.InitializeServices(db, cfg)
+
+	// Initialize all handlers with service dependencies
+	reloader := config.NewReloader(*configFile, cfg)
+	allHandlers := handlers.InitializeHandlers(allServices, reloader)
+
+	// Apply reloadable settings to running components on SIGHUP or configuration file changes
+	reloader.Register(allServices.Reloadables()...)
+	reloader.Register(allHandlers.Reloadables()...)
+
+	app := &Application{
+		cfg:      cfg,
+		metrics:  &models.ApplicationMetrics{StartTime: time.Now()},
+		services: allServices,
+	}
+
+	// Start background jobs, stopped on shutdown
+	var workerCtx context.Context
+	workerCtx, app.stopWorkers = context.WithCancel(context.Background())
+	defer func() {
+		// Already done by a graceful shutdown, but needed when starting a listener fails
+		app.stopWorkers()
+		allServices.WaitWorkers()
+	}()
+	allServices.StartWorkers(workerCtx)
+	go reloader.Run(workerCtx)
+
+	router := app.newRouter(allHandlers)
+
+	// Routes and their documentation are maintained side by side, so report any drift
+	if problems, err := allHandlers.OpenAPIHandler.Document.CheckRoutes(router); err != nil {
+		slog.Error("Failed checking OpenAPI document", "error", err)
+	} else {
+		for _, problem := range problems {
+			slog.Warn("OpenAPI document out of date", "problem", problem)
+		}
+	}
+
+	// Configure server
+	app.webServer = &http.Server{
+		Addr:              fmt.Sprintf("%s:%d", cfg.Server.Host, cfg.Server.Port),
+		Handler:           router,
+		ReadTimeout:       cfg.Server.ReadTimeout,
+		ReadHeaderTimeout: cfg.Server.ReadHeaderTimeout,
+		WriteTimeout:      cfg.Server.WriteTimeout,
+		IdleTimeout:       cfg.Server.IdleTimeout,
+		MaxHeaderBytes:    cfg.Server.MaxHeaderBytes,
+		Protocols:         serverProtocols(cfg.Server),
+	}
+
+	// Terminate TLS with the configured certificate, picking up renewals while running
+	if cfg.Server.TLS.CertFile != "" {
+		certificates, err := utils.NewCertificateReloader(cfg.Server.TLS.CertFile, cfg.Server.TLS.KeyFile)
+		if err != nil {
+			return fmt.Errorf("TLS certificate loading failed: %w", err)
+		}
+		go certificates.Run(workerCtx, cfg.Server.TLS.ReloadInterval)
+
+		app.webServer.TLSConfig = &tls.Config{
+			MinVersion:     tls.VersionTLS12,
+			GetCertificate: certificates.GetCertificate,
+		}
+	}
+
+	// Listeners report failures here, which shuts the application down
+	serverErrs := make(chan error, 3)
+
+	// Obtain certificates via ACME, answering HTTP-01 challenges on a plain HTTP listener that
+	// serves all other requests with the same router
+	if cfg.Server.ACME.Enabled {
+		manager, err := allServices.ACMEService.NewManager()
+		if err != nil {
+			return fmt.Errorf("ACME initialization failed: %w", err)
+		}
+
+		app.webServer.TLSConfig = manager.TLSConfig()
+		app.webServer.TLSConfig.MinVersion = tls.VersionTLS12
+
+		app.httpServer = &http.Server{
+			Addr:              fmt.Sprintf("%s:%d", cfg.Server.Host, cfg.Server.ACME.HTTPPort),
+			Handler:           manager.HTTPHandler(router),
+			ReadTimeout:       cfg.Server.ReadTimeout,
+			ReadHeaderTimeout: cfg.Server.ReadHeaderTimeout,
+			WriteTimeout:      cfg.Server.WriteTimeout,
+			IdleTimeout:       cfg.Server.IdleTimeout,
+			MaxHeaderBytes:    cfg.Server.MaxHeaderBytes,
+		}
+
+		go func() {
+			slog.Info("Starting HTTP server for ACME challenges", "address", app.httpServer.Addr)
+			if err := app.httpServer.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+				serverErrs <- fmt.Errorf("HTTP server failed: %w", err)
+			}
+		}()
+	}
+
+	// Serve health, metrics and pprof apart from public traffic
+	if app.serveAdmin() {
+		app.adminServer = &http.Server{
+			Addr:              fmt.Sprintf("%s:%d", cfg.Server.Admin.Host, cfg.Server.Admin.Port),
+			Handler:           app.newAdminRouter(),
+			ReadHeaderTimeout: cfg.Server.ReadHeaderTimeout,
+		}
+
+		go func() {
+			slog.Info("Starting admin server", "address", app.adminServer.Addr)
+			if err := app.adminServer.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+				serverErrs <- fmt.Errorf("Admin server failed: %w", err)
+			}
+		}()
+	}
+
+	// Setup graceful shutdown handling on a signal or a failed listener, closing shutdownDone
+	// once every component has stopped
+	sigChan := make(chan os.Signal, 1)
+	signal.Notify(sigChan, os.Interrupt, syscall.SIGTERM, syscall.SIGINT)
+	defer signal.Stop(sigChan)
+	shutdownDone := make(chan struct{})
+	var serveErr error
+	go func() {
+		select {
+		case sig := <-sigChan:
+			slog.Info("Received shutdown signal", "signal", sig)
+		case serveErr = <-serverErrs:
+			slog.Error("Listener failed", "error", serveErr)
+		}
+		slog.Info("Initiating graceful shutdown...")
+
+		app.initiateShutdown()
+		close(shutdownDone)
+	}()
+
+	slog.Info("Starting web server", "address", app.webServer.Addr, "tls", app.webServer.TLSConfig != nil)
+
+	// Start server (blocking call)
+	if app.webServer.TLSConfig != nil {
+		err = app.webServer.ListenAndServeTLS("", "")
+	} else {
+		err = app.webServer.ListenAndServe()
+	}
+	if err != nil && !errors.Is(err, http.ErrServerClosed) {
+		serverErrs <- fmt.Errorf("Web server failed: %w", err)
+	}
+
+	// The server returns as soon as shutdown begins; wait for requests to drain and background
+	// jobs to stop before the deferred database disconnect runs
+	<-shutdownDone
+	return serveErr
+}
+
+// newRouter configures the middleware and all routes of the web server
+func (app *Application) newRouter(allHandlers *handlers.Handlers) *chi.Mux {
+	// Configure middleware
+	router := chi.NewRouter()
+	router.Use(middleware.RequestID)                                   // Request ID generation
+	router.Use(handlers.ExposeRequestID)                               // Request ID response header
+	router.Use(handlers.Tracing)                                       // Request tracing
+	router.Use(allHandlers.AccessLogMiddleware.Log)                    // Request logging
+	router.Use(middleware.Recoverer)                                   // Panic recovery
+	router.Use(handlers.LimitRequestBody(app.cfg.Server.MaxBodyBytes)) // Request body size limit
+	if app.cfg.Server.CompressionLevel > 0 {
+		router.Use(middleware.Compress(app.cfg.Server.CompressionLevel)) // Response compression
+	}
+	if app.cfg.Server.RequestTimeout > 0 {
+		router.Use(middleware.Timeout(app.cfg.Server.RequestTimeout)) // Request timeout
+	}
+
+	// Setup routers
+	allHandlers.SetupRouters(router)
+
+	// Setup app routers
+	router.Route("/api", func(router chi.Router) {
+		router.Get("/health", app.getHealth)
+		if !app.serveAdmin() {
+			router.Get("/metrics", app.getMetrics)
+		}
+	})
+	app.describeRoutes(allHandlers.OpenAPIHandler.Document)
+
+	return router
+}
+
+// newAdminRouter configures the routes of the admin listener
+func (app *Application) newAdminRouter() *chi.Mux {
+	router := chi.NewRouter()
+	router.Use(middleware.Recoverer)
+
+	router.Get("/api/health", app.getHealth)
+	router.Get("/api/metrics", app.getMetrics)
+	router.Mount("/debug", middleware.Profiler())
+
+	return router
+}
+
+// serveAdmin reports whether the admin listener is enabled, which then serves metrics instead
+// of the web server
+func (app *Application) serveAdmin() bool {
+	return app.cfg.Server.Admin.Port != 0
+}
+
+// serverProtocols returns the protocols of the web server: HTTP/1 and, over TLS, HTTP/2. HTTP/2
+// without TLS is only accepted with h2c enabled.
+func serverProtocols(server config.ServerConfig) *http.Protocols {
+	protocols := new(http.Protocols)
+	protocols.SetHTTP1(true)
+	protocols.SetHTTP2(true)
+	protocols.SetUnencryptedHTTP2(server.H2C)
+
+	return protocols
+}
+
+// describeRoutes documents the app routes registered by newRouter
+func (app *Application) describeRoutes(document *openapi.Document) {
+	document.AddOperation(http.MethodGet, "/api/health", openapi.Operation{
+		OperationID: "getHealth",
+		Summary:     "Check service health",
+		Tags:        []string{"app"},
+		Security:    openapi.Public(),
+		Responses: map[string]openapi.Response{
+			openapi.Status(http.StatusOK): openapi.JSONResponse("Service is healthy", &openapi.Schema{
+				Type: "object",
+				Properties: map[string]*openapi.Schema{
+					"status":  {Type: "string"},
+					"service": {Type: "string"},
+				},
+			}),
+		},
+	})
+
+	if app.serveAdmin() {
+		return
+	}
+
+	document.AddOperation(http.MethodGet, "/api/metrics", openapi.Operation{
+		OperationID: "getMetrics",
+		Summary:     "Get application metrics",
+		Tags:        []string{"app"},
+		Security:    openapi.Public(),
+		Responses: map[string]openapi.Response{
+			openapi.Status(http.StatusOK): openapi.JSONResponse("Application metrics", document.SchemaRef(models.ApplicationMetrics{})),
+		},
+	})
+}
+
+// initiateShutdown begins the graceful shutdown process for all application components
+func (app *Application) initiateShutdown() {
+	shutdownStart := time.Now()
+
+	// Stop web server with timeout
+	if app.webServer != nil {
+		slog.Info("Stopping web server...")
+
+		ctx, cancel := context.WithTimeout(context.Background(), app.cfg.Server.ShutdownTimeout)
+		defer cancel()
+
+		if err := app.webServer.Shutdown(ctx); err != nil {
+			slog.Error("Web server shutdown error", "error", err)
+		} else {
+			slog.Info("Web server stopped gracefully")
+		}
+	}
+
+	// Stop the other listeners
+	for _, server := range []*http.Server{app.httpServer, app.adminServer} {
+		if server == nil {
+			continue
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), app.cfg.Server.ShutdownTimeout)
+		if err := server.Shutdown(ctx); err != nil {
+			slog.Error("Server shutdown error", "address", server.Addr, "error", err)
+		}
+		cancel()
+	}
+
+	// Stop background jobs
+	if app.stopWorkers != nil {
+		slog.Info("Stopping background jobs...")
+
+		app.stopWorkers()
+		app.services.WaitWorkers()
+	}
+
+	slog.Info("Graceful shutdown completed", "duration", time.Since(shutdownStart))
+	slog.Info("Final application metrics",
+		"start_time", app.metrics.StartTime.Format(time.RFC3339),
+		"total_uptime", app.metrics.TotalUptime)
+}
+
+func (app *Application) getHealth(w http.ResponseWriter, _ *http.Request) {
+	healthResponse := map[string]interface{}{
+		"status":  "healthy",
+		"service": "linko",
+	}
+	utils.RespondWithJSON(w, healthResponse, http.StatusOK)
+}
+
+func (app *Application) getMetrics(w http.ResponseWriter, _ *http.Request) {
+	app.metrics.TotalUptime = time.Since(app.metrics.StartTime)
+	utils.RespondWithJSON(w, app.metrics, http.StatusOK)
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"github.com/aarondever/linko/internal/models"
+	"io"
+	"os"
+)
+
+func runExport(args []string) error {
+	flagSet, configFile := newFlagSet("export", "")
+	format := flagSet.String("format", models.TransferFormatNDJSON, "Export format (ndjson or csv)")
+	includeStats := flagSet.Bool("include-stats", false, "Include click stats")
+	output := flagSet.String("output", "", "File to write to instead of stdout")
+	workspace := flagSet.String("workspace", "", "Workspace whose custom domain links are exported along the default host links")
+	flagSet.Parse(args)
+	if err := requireArgs(flagSet, 0); err != nil {
+		return err
+	}
+
+	env, err := bootstrap(*configFile)
+	if err != nil {
+		return err
+	}
+	defer env.close()
+	ctx, cancel := commandContext()
+	defer cancel()
+
+	var writer io.Writer = os.Stdout
+	if *output != "" {
+		file, err := os.Create(*output)
+		if err != nil {
+			return fmt.Errorf("Failed creating output file: %w", err)
+		}
+		defer file.Close()
+		writer = file
+	}
+
+	buffered := bufio.NewWriter(writer)
+	if err = env.services.TransferService.Export(ctx, buffered, *workspace, *format, *includeStats); err != nil {
+		return fmt.Errorf("Export failed: %w", err)
+	}
+	if err = buffered.Flush(); err != nil {
+		return fmt.Errorf("Export failed: %w", err)
+	}
+
+	return nil
+}
+
+func runImport(args []string) error {
+	flagSet, configFile := newFlagSet("import", "[file]")
+	format := flagSet.String("format", models.TransferFormatNDJSON, "Import format (ndjson or csv)")
+	onConflict := flagSet.String("on-conflict", models.ImportConflictSkip, "Whether to skip or overwrite short codes pointing at a different URL")
+	workspace := flagSet.String("workspace", "", "Workspace whose verified custom domains the links may use")
+	dryRun := flagSet.Bool("dry-run", false, "Report what would change without writing anything")
+	flagSet.Parse(args)
+
+	// Read from stdin unless a file is given
+	var reader io.Reader = os.Stdin
+	switch flagSet.NArg() {
+	case 0:
+	case 1:
+		file, err := os.Open(flagSet.Arg(0))
+		if err != nil {
+			return fmt.Errorf("Failed opening input file: %w", err)
+		}
+		defer file.Close()
+		reader = file
+	default:
+		flagSet.Usage()
+		return errUsage
+	}
+
+	env, err := bootstrap(*configFile)
+	if err != nil {
+		return err
+	}
+	defer env.close()
+	ctx, cancel := commandContext()
+	defer cancel()
+
+	report, err := env.services.TransferService.Import(ctx, bufio.NewReader(reader), *workspace, *format, *onConflict, *dryRun)
+	if err != nil {
+		return fmt.Errorf("Import failed: %w", err)
+	}
+
+	return printJSON(report)
+}
@@ -1,296 +1,661 @@
 package config
 
 import (
+	"errors"
 	"flag"
+	"fmt"
+	"github.com/aarondever/linko/internal/logging"
+	"io"
 	"log/slog"
+	"net/netip"
+	"net/url"
 	"os"
 	"reflect"
+	"regexp"
+	"strings"
 	"time"
 
 	"github.com/joho/godotenv"
 	"gopkg.in/yaml.v3"
 )
 
+// hexColorPattern matches CSS hex colors in the #RGB and #RRGGBB forms
+var hexColorPattern = regexp.MustCompile(`^#([0-9a-fA-F]{3}|[0-9a-fA-F]{6})$`)
+
+// Config is the configuration of linko. Every setting is declared by the tags of its field:
+// yaml is its name in the configuration file, env the environment variable setting it, default
+// its value when neither sets it and desc its description in the reference printed by
+// WriteReference. Settings tagged `secret:"true"` can also be read from the file named by the
+// environment variable with a _FILE suffix and are redacted from the effective configuration.
 type Config struct {
-	AppEnv   string // Environment type (development, production)
-	Timezone *time.Location
-	Server   ServerConfig   `yaml:"server"`
-	Database DatabaseConfig `yaml:"database"`
-	Logging  LoggingConfig  `yaml:"logging"`
+	AppEnv      string            `yaml:"app_env" env:"APP_ENV" default:"development" desc:"Environment type, e.g. development or production"`
+	Timezone    Location          `yaml:"timezone" env:"TZ" default:"UTC" desc:"Time zone of the application"`
+	Server      ServerConfig      `yaml:"server"`
+	Database    DatabaseConfig    `yaml:"database"`
+	Logging     LoggingConfig     `yaml:"logging"`
+	URL         URLConfig         `yaml:"url"`
+	Auth        AuthConfig        `yaml:"auth"`
+	RateLimit   RateLimitConfig   `yaml:"rate_limit"`
+	Screening   ScreeningConfig   `yaml:"screening"`
+	Destination DestinationConfig `yaml:"destination"`
+	HealthCheck HealthCheckConfig `yaml:"health_check"`
+	Webhook     WebhookConfig     `yaml:"webhook"`
+	Metadata    MetadataConfig    `yaml:"metadata"`
+	Landing     LandingConfig     `yaml:"landing"`
+	Domain      DomainConfig      `yaml:"domain"`
+	Reload      ReloadConfig      `yaml:"reload"`
+	Tracing     TracingConfig     `yaml:"tracing"`
 }
 
 type ServerConfig struct {
-	Host string `yaml:"host"`
-	Port int    `yaml:"port"`
+	Host              string        `yaml:"host" env:"HOST" default:"0.0.0.0" desc:"Address the web server listens on"`
+	Port              int           `yaml:"port" env:"PORT" default:"8080" desc:"Port the web server listens on"`
+	BaseURL           string        `yaml:"base_url" env:"BASE_URL" desc:"Public URL of the default host, e.g. https://lnk.example; the request host when empty"`
+	RedirectPrefix    string        `yaml:"redirect_prefix" env:"REDIRECT_PREFIX" default:"/r" desc:"Path prefix of short links; / serves them from the root path"`
+	ReadTimeout       time.Duration `yaml:"read_timeout" env:"SERVER_READ_TIMEOUT" default:"15s" desc:"Maximum duration of reading a request, 0 for no limit"`
+	ReadHeaderTimeout time.Duration `yaml:"read_header_timeout" env:"SERVER_READ_HEADER_TIMEOUT" default:"5s" desc:"Maximum duration of reading request headers, 0 for the read timeout"`
+	WriteTimeout      time.Duration `yaml:"write_timeout" env:"SERVER_WRITE_TIMEOUT" default:"15s" desc:"Maximum duration of writing a response, 0 for no limit"`
+	IdleTimeout       time.Duration `yaml:"idle_timeout" env:"SERVER_IDLE_TIMEOUT" default:"60s" desc:"How long idle keep-alive connections are kept open, 0 for the read timeout"`
+	RequestTimeout    time.Duration `yaml:"request_timeout" env:"SERVER_REQUEST_TIMEOUT" default:"30s" desc:"Deadline of the context of request handlers, 0 for no deadline"`
+	ShutdownTimeout   time.Duration `yaml:"shutdown_timeout" env:"SERVER_SHUTDOWN_TIMEOUT" default:"30s" desc:"How long graceful shutdown waits for requests in progress"`
+	CompressionLevel  int           `yaml:"compression_level" env:"SERVER_COMPRESSION_LEVEL" default:"5" desc:"Gzip level of responses from 1 (fastest) to 9 (smallest), 0 disables compression"`
+
+	MaxHeaderBytes int   `yaml:"max_header_bytes" env:"SERVER_MAX_HEADER_BYTES" default:"1048576" desc:"Maximum size of request headers"`
+	MaxBodyBytes   int64 `yaml:"max_body_bytes" env:"SERVER_MAX_BODY_BYTES" default:"10485760" desc:"Maximum size of request bodies, larger ones are rejected with 413"`
+	H2C            bool  `yaml:"h2c" env:"SERVER_H2C" default:"false" desc:"Accept HTTP/2 without TLS, for proxies speaking cleartext HTTP/2"`
+
+	TLS   ServerTLSConfig   `yaml:"tls"`
+	ACME  ServerACMEConfig  `yaml:"acme"`
+	Admin ServerAdminConfig `yaml:"admin"`
+}
+
+// ServerTLSConfig enables TLS termination by the web server when a certificate is configured.
+// HTTP/2 is negotiated over TLS.
+type ServerTLSConfig struct {
+	CertFile       string        `yaml:"cert_file" env:"SERVER_TLS_CERT_FILE" desc:"PEM certificate chain served over TLS, plain HTTP when empty"`
+	KeyFile        string        `yaml:"key_file" env:"SERVER_TLS_KEY_FILE" desc:"PEM private key of the certificate"`
+	ReloadInterval time.Duration `yaml:"reload_interval" env:"SERVER_TLS_RELOAD_INTERVAL" default:"1m" desc:"How often the certificate files are checked for renewals"`
+}
+
+// ServerACMEConfig enables TLS termination with certificates obtained and renewed via ACME for
+// the host of base_url, the configured hosts and verified custom domains. Certificates are stored
+// in the database and shared by all replicas.
+type ServerACMEConfig struct {
+	Enabled      bool          `yaml:"enabled" env:"SERVER_ACME_ENABLED" default:"false" desc:"Obtain TLS certificates via ACME instead of serving cert_file"`
+	DirectoryURL string        `yaml:"directory_url" env:"SERVER_ACME_DIRECTORY_URL" default:"https://acme-v02.api.letsencrypt.org/directory" desc:"Directory of the ACME server, e.g. https://localhost:14000/dir for Pebble"`
+	Email        string        `yaml:"email" env:"SERVER_ACME_EMAIL" desc:"Contact address of the ACME account, notified about certificate problems"`
+	Hosts        []string      `yaml:"hosts" env:"SERVER_ACME_HOSTS" desc:"Hosts to obtain certificates for besides the host of base_url and verified custom domains"`
+	HTTPPort     int           `yaml:"http_port" env:"SERVER_ACME_HTTP_PORT" default:"80" desc:"Port of the plain HTTP listener answering HTTP-01 challenges and serving all other requests"`
+	CAFile       string        `yaml:"ca_file" env:"SERVER_ACME_CA_FILE" desc:"PEM certificates trusted for the ACME server instead of the system roots, e.g. of Pebble"`
+	RenewBefore  time.Duration `yaml:"renew_before" env:"SERVER_ACME_RENEW_BEFORE" default:"720h" desc:"How long before expiry certificates are renewed"`
+}
+
+// ServerAdminConfig configures the admin listener serving health, metrics and pprof apart from
+// public traffic. While it is enabled, metrics are no longer served by the web server.
+type ServerAdminConfig struct {
+	Host string `yaml:"host" env:"SERVER_ADMIN_HOST" default:"127.0.0.1" desc:"Address the admin listener listens on"`
+	Port int    `yaml:"port" env:"SERVER_ADMIN_PORT" default:"0" desc:"Port of the admin listener, 0 disables it"`
+}
+
+// ShortLinkPrefix returns the path prefix of short links without a trailing slash, which is
+// empty for short links served from the root path
+func (server ServerConfig) ShortLinkPrefix() string {
+	return strings.TrimSuffix(server.RedirectPrefix, "/")
 }
 
 type DatabaseConfig struct {
-	Host     string `yaml:"host"`
-	Port     int    `yaml:"port"`
-	Username string `yaml:"username"`
-	Password string `yaml:"password"`
-	Name     string `yaml:"name"`
+	URI        string `yaml:"uri" env:"DB_URI" secret:"true" desc:"Connection string, replacing host, port, username, password, auth_source and replica_set"`
+	Host       string `yaml:"host" env:"DB_HOST" default:"localhost" desc:"MongoDB host"`
+	Port       int    `yaml:"port" env:"DB_PORT" default:"27017" desc:"MongoDB port"`
+	Username   string `yaml:"username" env:"DB_USER" default:"mongo" secret:"true" desc:"User to authenticate as, no authentication when empty"`
+	Password   string `yaml:"password" env:"DB_PASSWORD" default:"mongo" secret:"true" desc:"Password of the user"`
+	AuthSource string `yaml:"auth_source" env:"DB_AUTH_SOURCE" desc:"Database holding the user, the driver default when empty"`
+	ReplicaSet string `yaml:"replica_set" env:"DB_REPLICA_SET" desc:"Name of the replica set to connect to"`
+	Name       string `yaml:"name" env:"DB_NAME" default:"linko" desc:"Database holding linko's collections"`
+
+	TLS DatabaseTLSConfig `yaml:"tls"`
+
+	// Connection pool and timeouts; zero values keep the driver defaults or those of the URI
+	MaxPoolSize            int           `yaml:"max_pool_size" env:"DB_MAX_POOL_SIZE" default:"0" desc:"Maximum number of connections, 0 for the driver default"`
+	MinPoolSize            int           `yaml:"min_pool_size" env:"DB_MIN_POOL_SIZE" default:"0" desc:"Number of connections kept open when idle"`
+	MaxConnIdleTime        time.Duration `yaml:"max_conn_idle_time" env:"DB_MAX_CONN_IDLE_TIME" default:"0s" desc:"How long a connection stays idle before it is closed, 0 for no limit"`
+	ConnectTimeout         time.Duration `yaml:"connect_timeout" env:"DB_CONNECT_TIMEOUT" default:"10s" desc:"Timeout of opening a connection"`
+	ServerSelectionTimeout time.Duration `yaml:"server_selection_timeout" env:"DB_SERVER_SELECTION_TIMEOUT" default:"10s" desc:"How long an operation waits for a suitable server"`
+	Timeout                time.Duration `yaml:"timeout" env:"DB_TIMEOUT" default:"0s" desc:"Default timeout of every operation, 0 for none"`
+}
+
+type DatabaseTLSConfig struct {
+	Enabled            bool   `yaml:"enabled" env:"DB_TLS_ENABLED" default:"false" desc:"Connect to MongoDB over TLS"`
+	CAFile             string `yaml:"ca_file" env:"DB_TLS_CA_FILE" desc:"PEM certificates of the CAs to trust instead of the system roots"`
+	CertFile           string `yaml:"cert_file" env:"DB_TLS_CERT_FILE" desc:"PEM client certificate, may include the private key"`
+	KeyFile            string `yaml:"key_file" env:"DB_TLS_KEY_FILE" desc:"PEM private key of the client certificate, if not in cert_file"`
+	InsecureSkipVerify bool   `yaml:"insecure_skip_verify" env:"DB_TLS_INSECURE_SKIP_VERIFY" default:"false" desc:"Accept any server certificate, for testing only"`
 }
 
 type LoggingConfig struct {
-	Level  string `yaml:"level"`
-	Format string `yaml:"format"` // e.g. "json", "text"
+	Level  string `yaml:"level" env:"LOG_LEVEL" default:"info" desc:"Minimum level of logged messages: debug, info, warn or error"`
+	Format string `yaml:"format" env:"LOG_FORMAT" default:"text" desc:"Log format: json or text"`
+
+	AccessLog          bool    `yaml:"access_log" env:"LOG_ACCESS" default:"true" desc:"Log every request served by the web server"`
+	RedirectSampleRate float64 `yaml:"redirect_sample_rate" env:"LOG_REDIRECT_SAMPLE_RATE" default:"1" desc:"Share of successful redirects written to the access log, from 0 to 1"`
 }
 
-func LoadConfig() (*Config, error) {
-	// Load config from environment variables
-	config := loadConfigFromEnv()
+type URLConfig struct {
+	BulkMaxItems int `yaml:"bulk_max_items" env:"URL_BULK_MAX_ITEMS" default:"5000" desc:"Maximum number of URLs accepted by a single bulk request"`
+}
 
-	// Parse command line flags
-	var configFile string
-	flag.StringVar(&configFile, "config.file", "config.yaml", "Path to configuration file")
-	flag.Parse()
+type AuthConfig struct {
+	APIKeyRequired bool `yaml:"api_key_required" env:"AUTH_API_KEY_REQUIRED" default:"true" desc:"Reject API requests without a valid API key; create keys with linko keys create. Until the first key exists, shorten and lookup requests are still served without one"`
+}
 
-	// Load config file
-	fileConfig, err := loadConfigFromFile(configFile)
+type RateLimitConfig struct {
+	Enabled        bool                  `yaml:"enabled" env:"RATE_LIMIT_ENABLED" default:"true" desc:"Limit the request rate of clients"`
+	Backend        string                `yaml:"backend" env:"RATE_LIMIT_BACKEND" default:"memory" desc:"memory for a single replica, mongo to share quotas between replicas"`
+	TrustedProxies []string              `yaml:"trusted_proxies" env:"RATE_LIMIT_TRUSTED_PROXIES" desc:"Proxy IPs or CIDRs whose X-Forwarded-For header is honored"`
+	Shorten        RateLimitPolicyConfig `yaml:"shorten" env:"RATE_LIMIT_SHORTEN" default:"requests_per_minute=60,burst=20"`
+	Management     RateLimitPolicyConfig `yaml:"management" env:"RATE_LIMIT_MANAGEMENT" default:"requests_per_minute=300,burst=50"`
+	Redirect       RateLimitPolicyConfig `yaml:"redirect" env:"RATE_LIMIT_REDIRECT" default:"requests_per_minute=1200,burst=200"`
+	// Auth counts failed authentications per client IP instead of requests
+	Auth RateLimitPolicyConfig `yaml:"auth" env:"RATE_LIMIT_AUTH" default:"requests_per_minute=10,burst=20"`
+}
+
+// RateLimitPolicyConfig configures a token bucket refilled at RequestsPerMinute holding up to
+// Burst requests. The env tag of each policy prefixes the variables of its settings and its
+// default tag sets their defaults.
+type RateLimitPolicyConfig struct {
+	RequestsPerMinute int `yaml:"requests_per_minute" env:"RPM" desc:"Requests a client may make per minute"`
+	Burst             int `yaml:"burst" env:"BURST" desc:"Requests a client may make at once"`
+}
+
+type ScreeningConfig struct {
+	Enabled        bool          `yaml:"enabled" env:"SCREENING_ENABLED" default:"true" desc:"Screen destinations against the rules and reject unsafe ones"`
+	RulesFile      string        `yaml:"rules_file" env:"SCREENING_RULES_FILE" desc:"Domain blocklist/allowlist and pattern rules, reloaded when changed"`
+	ReloadInterval time.Duration `yaml:"reload_interval" env:"SCREENING_RELOAD_INTERVAL" default:"30s" desc:"How often the rules file is checked for changes"`
+	RescanInterval time.Duration `yaml:"rescan_interval" env:"SCREENING_RESCAN_INTERVAL" default:"24h" desc:"How often existing links are screened again, 0 disables rescans"`
+}
+
+// DestinationConfig is the policy for the destinations links may point at, protecting linko
+// and its network when it fetches destinations itself
+type DestinationConfig struct {
+	AllowedSchemes       []string      `yaml:"allowed_schemes" env:"DESTINATION_ALLOWED_SCHEMES" default:"http,https" desc:"Subset of http and https"`
+	AllowPrivateNetworks bool          `yaml:"allow_private_networks" env:"DESTINATION_ALLOW_PRIVATE_NETWORKS" default:"false" desc:"Allow hosts resolving to private, loopback or link-local addresses"`
+	AllowCredentials     bool          `yaml:"allow_credentials" env:"DESTINATION_ALLOW_CREDENTIALS" default:"false" desc:"Allow user:password@ in destination URLs"`
+	SelfHosts            []string      `yaml:"self_hosts" env:"DESTINATION_SELF_HOSTS" desc:"Hosts serving linko's short links, rejected to prevent redirect loops"`
+	ResolveTimeout       time.Duration `yaml:"resolve_timeout" env:"DESTINATION_RESOLVE_TIMEOUT" default:"2s" desc:"Timeout of the DNS lookup of destination hosts"`
+}
+
+type HealthCheckConfig struct {
+	Enabled          bool          `yaml:"enabled" env:"HEALTH_CHECK_ENABLED" default:"false" desc:"Periodically check that destinations are reachable"`
+	Interval         time.Duration `yaml:"interval" env:"HEALTH_CHECK_INTERVAL" default:"6h" desc:"How often healthy links are checked"`
+	RetryBackoff     time.Duration `yaml:"retry_backoff" env:"HEALTH_CHECK_RETRY_BACKOFF" default:"5m" desc:"Delay after a first failure, doubled per consecutive failure up to interval"`
+	FailureThreshold int           `yaml:"failure_threshold" env:"HEALTH_CHECK_FAILURE_THRESHOLD" default:"3" desc:"Consecutive failures before a link is reported broken"`
+	Concurrency      int           `yaml:"concurrency" env:"HEALTH_CHECK_CONCURRENCY" default:"8" desc:"Links checked at the same time"`
+	HostDelay        time.Duration `yaml:"host_delay" env:"HEALTH_CHECK_HOST_DELAY" default:"2s" desc:"Minimum delay between requests to the same host"`
+	Timeout          time.Duration `yaml:"timeout" env:"HEALTH_CHECK_TIMEOUT" default:"10s" desc:"Timeout of a single check"`
+	WebhookURL       string        `yaml:"webhook_url" env:"HEALTH_CHECK_WEBHOOK_URL" secret:"true" desc:"Receives unsigned link.broken and link.recovered events without retries; prefer registered webhooks"`
+}
+
+// WebhookConfig configures the delivery of events to the webhooks registered by workspaces.
+// Failed deliveries are retried with exponential backoff and kept as dead letters once
+// max_attempts is reached.
+type WebhookConfig struct {
+	Enabled         bool          `yaml:"enabled" env:"WEBHOOK_ENABLED" default:"true" desc:"Deliver events to registered webhooks"`
+	Concurrency     int           `yaml:"concurrency" env:"WEBHOOK_CONCURRENCY" default:"4" desc:"Deliveries sent at the same time"`
+	Timeout         time.Duration `yaml:"timeout" env:"WEBHOOK_TIMEOUT" default:"10s" desc:"Timeout of a single delivery attempt"`
+	PollInterval    time.Duration `yaml:"poll_interval" env:"WEBHOOK_POLL_INTERVAL" default:"5s" desc:"How often due deliveries and webhook changes are picked up"`
+	MaxAttempts     int           `yaml:"max_attempts" env:"WEBHOOK_MAX_ATTEMPTS" default:"8" desc:"Attempts before a delivery is given up as a dead letter"`
+	RetryBackoff    time.Duration `yaml:"retry_backoff" env:"WEBHOOK_RETRY_BACKOFF" default:"30s" desc:"Delay after a first failed attempt, doubled per attempt up to max_retry_backoff"`
+	MaxRetryBackoff time.Duration `yaml:"max_retry_backoff" env:"WEBHOOK_MAX_RETRY_BACKOFF" default:"6h" desc:"Longest delay between attempts"`
+}
+
+type MetadataConfig struct {
+	Enabled      bool          `yaml:"enabled" env:"METADATA_ENABLED" default:"true" desc:"Fetch the title and preview image of destinations"`
+	Timeout      time.Duration `yaml:"timeout" env:"METADATA_TIMEOUT" default:"5s" desc:"Timeout of fetching a destination page"`
+	MaxBodyBytes int           `yaml:"max_body_bytes" env:"METADATA_MAX_BODY_BYTES" default:"1048576" desc:"Bytes of the page read to find its metadata"`
+	Concurrency  int           `yaml:"concurrency" env:"METADATA_CONCURRENCY" default:"4" desc:"Pages fetched at the same time"`
+	QueueSize    int           `yaml:"queue_size" env:"METADATA_QUEUE_SIZE" default:"1000" desc:"Links waiting to be fetched before new ones are dropped"`
+}
+
+// LandingConfig controls the HTML pages shown to browsers following short links that cannot redirect
+type LandingConfig struct {
+	TemplateDir string         `yaml:"template_dir" env:"LANDING_TEMPLATE_DIR" desc:"Templates overriding the embedded pages of the same file name"`
+	Branding    BrandingConfig `yaml:"branding"` // Branding of workspaces that do not configure their own
+}
+
+type BrandingConfig struct {
+	Name        string `yaml:"name" env:"LANDING_BRAND_NAME" default:"linko" desc:"Name shown on landing pages"`
+	LogoURL     string `yaml:"logo_url" env:"LANDING_LOGO_URL" desc:"URL of the logo shown on landing pages"`
+	AccentColor string `yaml:"accent_color" env:"LANDING_ACCENT_COLOR" desc:"CSS hex color of landing pages, e.g. #2563eb"`
+	SupportURL  string `yaml:"support_url" env:"LANDING_SUPPORT_URL" desc:"Support page linked from landing pages"`
+}
+
+type DomainConfig struct {
+	CacheTTL  time.Duration `yaml:"cache_ttl" env:"DOMAIN_CACHE_TTL" default:"1m" desc:"How long the custom domain served from a host is cached"`
+	CacheSize int           `yaml:"cache_size" env:"DOMAIN_CACHE_SIZE" default:"10000" desc:"Hosts cached at most, bounded since hosts come from request headers"`
+}
+
+// ReloadConfig controls reloading the configuration while running. Only reloadable settings
+// take effect on reload; see Reloader.
+type ReloadConfig struct {
+	WatchInterval time.Duration `yaml:"watch_interval" env:"CONFIG_WATCH_INTERVAL" default:"10s" desc:"How often the configuration file is checked for changes, 0 disables watching"`
+}
+
+// TracingConfig controls exporting OpenTelemetry traces of requests, service calls and database
+// commands. Trace context is propagated in W3C traceparent headers.
+type TracingConfig struct {
+	Enabled      bool    `yaml:"enabled" env:"TRACING_ENABLED" default:"false" desc:"Record and export traces"`
+	Exporter     string  `yaml:"exporter" env:"TRACING_EXPORTER" default:"otlp" desc:"otlp to send traces to an OTLP/HTTP collector, stdout to print them for local runs"`
+	OTLPEndpoint string  `yaml:"otlp_endpoint" env:"TRACING_OTLP_ENDPOINT" desc:"URL of the OTLP/HTTP traces endpoint, e.g. http://localhost:4318/v1/traces; the OTEL_EXPORTER_OTLP_* variables when empty"`
+	SampleRatio  float64 `yaml:"sample_ratio" env:"TRACING_SAMPLE_RATIO" default:"1" desc:"Share of traces started by linko that are sampled, from 0 to 1; sampled callers are always followed"`
+	ServiceName  string  `yaml:"service_name" env:"TRACING_SERVICE_NAME" default:"linko" desc:"Service name of the exported spans"`
+}
+
+// RegisterFlags registers the configuration command line flags on flagSet and returns
+// the configuration file path to pass to LoadConfig once the flags are parsed. The
+// -config.reference flag prints the reference of all settings and exits.
+func RegisterFlags(flagSet *flag.FlagSet) *string {
+	flagSet.BoolFunc("config.reference", "Print the reference of all configuration settings and exit", func(string) error {
+		if err := WriteReference(os.Stdout); err != nil {
+			return err
+		}
+
+		os.Exit(0)
+		return nil
+	})
+
+	return flagSet.String("config.file", "config.yaml", "Path to configuration file")
+}
+
+// LoadConfig loads and validates configuration from environment variables and configFile, and
+// configures the default logger to write to logOutput. An invalid configuration is rejected
+// with an error listing every problem found.
+func LoadConfig(configFile string, logOutput io.Writer) (*Config, error) {
+	config, err := load(configFile)
 	if err != nil {
-		slog.Error("Failed loading configuration file", "config", configFile, "error", err)
 		return nil, err
 	}
 
-	// Override with config file (if exists)
-	if fileConfig != nil {
-		config = mergeConfigs(config, fileConfig)
-		slog.Info("Loaded configuration file", "config", configFile)
-	}
-
-	config.configLogger()
+	config.configLogger(logOutput)
 
 	// Config timezone
-	time.Local = config.Timezone
+	time.Local = config.Timezone.Location
 	slog.Info("Application timezone configured", "timezone", config.Timezone.String())
 
 	return config, nil
 }
 
-func (config *Config) configLogger() {
-	var logHandler slog.Handler
-	var logLevel slog.Level
+// load loads and validates configuration from environment variables and configFile without
+// applying it
+func load(configFile string) (*Config, error) {
+	// Load config from environment variables
+	config, envErr := loadConfigFromEnv()
 
-	switch config.Logging.Level {
-	case "debug":
-		logLevel = slog.LevelDebug
-	case "info":
-		logLevel = slog.LevelInfo
-	case "warn":
-		logLevel = slog.LevelWarn
-	case "error":
-		logLevel = slog.LevelError
-	default:
-		logLevel = slog.LevelInfo
-		slog.Warn("Invalid log level, using 'info' instead", "level", config.Logging.Level)
+	// Load config file
+	fileConfig, fileErr := loadConfigFromFile(configFile, config)
+	if fileErr != nil {
+		fileErr = fmt.Errorf("%s: %w", configFile, fileErr)
+		if fileConfig == nil {
+			return nil, errors.Join(envErr, fileErr)
+		}
 	}
 
-	handlerOptions := &slog.HandlerOptions{Level: logLevel}
+	// The file was decoded over the environment config, so its settings take precedence
+	if fileConfig != nil {
+		config = fileConfig
+		slog.Info("Loaded configuration file", "config", configFile)
+	}
 
-	if config.Logging.Format == "json" {
-		logHandler = slog.NewJSONHandler(os.Stdout, handlerOptions)
-	} else {
-		logHandler = slog.NewTextHandler(os.Stdout, handlerOptions)
+	if err := errors.Join(envErr, fileErr, config.Validate()); err != nil {
+		return nil, err
 	}
 
-	logger := slog.New(logHandler)
-	slog.SetDefault(logger)
+	return config, nil
 }
 
-// loadConfigFromEnv loads configuration from environment variables
-func loadConfigFromEnv() *Config {
-	if err := godotenv.Load(); err != nil {
-		slog.Info("No .env file found")
-	}
+// Validate checks the configuration for invalid values and reports all problems at once
+func (config *Config) Validate() error {
+	var errs []error
 
-	tzName := getStringEnv("TZ", "UTC")
-	timezone, err := time.LoadLocation(tzName)
-	if err != nil {
-		slog.Warn("Invalid timezone, using UTC instead", "timezone", tzName, "error", err)
-		timezone = time.UTC
+	if config.Server.Port < 1 || config.Server.Port > 65535 {
+		errs = append(errs, fmt.Errorf("server.port: %d is not a valid port", config.Server.Port))
+	}
+	if config.Server.BaseURL != "" {
+		baseURL, err := url.Parse(config.Server.BaseURL)
+		if err != nil || (baseURL.Scheme != "http" && baseURL.Scheme != "https") || baseURL.Host == "" ||
+			(baseURL.Path != "" && baseURL.Path != "/") || baseURL.RawQuery != "" || baseURL.Fragment != "" {
+			errs = append(errs, fmt.Errorf("server.base_url: %q must be an http or https URL without path, query or fragment",
+				config.Server.BaseURL))
+		}
+	}
+	prefix := config.Server.ShortLinkPrefix()
+	switch {
+	case config.Server.RedirectPrefix == "" || !strings.HasPrefix(config.Server.RedirectPrefix, "/"):
+		errs = append(errs, fmt.Errorf("server.redirect_prefix: %q must start with /", config.Server.RedirectPrefix))
+	case strings.ContainsAny(prefix, "{}*?#% ") || strings.Contains(prefix, "//"):
+		errs = append(errs, fmt.Errorf("server.redirect_prefix: %q must be a plain path", config.Server.RedirectPrefix))
+	case prefix == "/api" || strings.HasPrefix(prefix, "/api/"):
+		errs = append(errs, fmt.Errorf("server.redirect_prefix: %q must not be under /api", config.Server.RedirectPrefix))
+	}
+	if config.Server.ReadTimeout < 0 || config.Server.WriteTimeout < 0 || config.Server.IdleTimeout < 0 ||
+		config.Server.RequestTimeout < 0 {
+		errs = append(errs, errors.New("server: read_timeout, write_timeout, idle_timeout and request_timeout must not be negative"))
+	}
+	if config.Server.ShutdownTimeout <= 0 {
+		errs = append(errs, fmt.Errorf("server.shutdown_timeout: %s must be positive", config.Server.ShutdownTimeout))
+	}
+	if config.Server.CompressionLevel < 0 || config.Server.CompressionLevel > 9 {
+		errs = append(errs, fmt.Errorf("server.compression_level: %d must be between 0 and 9", config.Server.CompressionLevel))
+	}
+	if config.Server.ReadHeaderTimeout < 0 {
+		errs = append(errs, fmt.Errorf("server.read_header_timeout: %s must not be negative", config.Server.ReadHeaderTimeout))
+	}
+	if config.Server.MaxHeaderBytes < 1 || config.Server.MaxBodyBytes < 1 {
+		errs = append(errs, errors.New("server: max_header_bytes and max_body_bytes must be positive"))
+	}
+	serverTLS := config.Server.TLS
+	if (serverTLS.CertFile == "") != (serverTLS.KeyFile == "") {
+		errs = append(errs, errors.New("server.tls: cert_file and key_file must be set together"))
+	}
+	for _, filename := range []string{serverTLS.CertFile, serverTLS.KeyFile} {
+		if filename == "" {
+			continue
+		}
+		if _, err := os.Stat(filename); err != nil {
+			errs = append(errs, fmt.Errorf("server.tls: %q cannot be read", filename))
+		}
+	}
+	if serverTLS.ReloadInterval <= 0 {
+		errs = append(errs, fmt.Errorf("server.tls.reload_interval: %s must be positive", serverTLS.ReloadInterval))
+	}
+	serverACME := config.Server.ACME
+	if serverACME.Enabled {
+		if serverTLS.CertFile != "" {
+			errs = append(errs, errors.New("server.acme.enabled: must not be set together with server.tls.cert_file"))
+		}
+		if directoryURL, err := url.Parse(serverACME.DirectoryURL); err != nil ||
+			(directoryURL.Scheme != "http" && directoryURL.Scheme != "https") || directoryURL.Host == "" {
+			errs = append(errs, fmt.Errorf("server.acme.directory_url: %q must be an http or https URL", serverACME.DirectoryURL))
+		}
+		if serverACME.HTTPPort < 1 || serverACME.HTTPPort > 65535 {
+			errs = append(errs, fmt.Errorf("server.acme.http_port: %d is not a valid port", serverACME.HTTPPort))
+		} else if serverACME.HTTPPort == config.Server.Port || serverACME.HTTPPort == config.Server.Admin.Port {
+			errs = append(errs, fmt.Errorf("server.acme.http_port: %d must differ from server.port and server.admin.port", serverACME.HTTPPort))
+		}
+		if serverACME.CAFile != "" {
+			if _, err := os.Stat(serverACME.CAFile); err != nil {
+				errs = append(errs, fmt.Errorf("server.acme.ca_file: %q cannot be read", serverACME.CAFile))
+			}
+		}
+		if serverACME.RenewBefore <= 0 {
+			errs = append(errs, fmt.Errorf("server.acme.renew_before: %s must be positive", serverACME.RenewBefore))
+		}
+	}
+	if config.Server.H2C && (serverTLS.CertFile != "" || serverACME.Enabled) {
+		errs = append(errs, errors.New("server.h2c: must not be enabled together with TLS"))
+	}
+	if config.Server.Admin.Port != 0 {
+		if config.Server.Admin.Port < 1 || config.Server.Admin.Port > 65535 {
+			errs = append(errs, fmt.Errorf("server.admin.port: %d is not a valid port", config.Server.Admin.Port))
+		} else if config.Server.Admin.Port == config.Server.Port {
+			errs = append(errs, fmt.Errorf("server.admin.port: %d must differ from server.port", config.Server.Admin.Port))
+		}
 	}
 
-	// Default config from environment variables
-	config := &Config{
-		AppEnv:   getStringEnv("APP_ENV", "development"),
-		Timezone: timezone,
+	if config.Database.URI != "" {
+		if !strings.HasPrefix(config.Database.URI, "mongodb://") && !strings.HasPrefix(config.Database.URI, "mongodb+srv://") {
+			errs = append(errs, errors.New("database.uri: must start with mongodb:// or mongodb+srv://"))
+		}
+	} else {
+		if config.Database.Host == "" {
+			errs = append(errs, errors.New("database.host: must not be empty"))
+		}
+		if config.Database.Port < 1 || config.Database.Port > 65535 {
+			errs = append(errs, fmt.Errorf("database.port: %d is not a valid port", config.Database.Port))
+		}
+	}
+	databaseTLS := config.Database.TLS
+	if !databaseTLS.Enabled && (databaseTLS.CAFile != "" || databaseTLS.CertFile != "" || databaseTLS.KeyFile != "") {
+		errs = append(errs, errors.New("database.tls: ca_file, cert_file and key_file require enabled"))
+	}
+	if databaseTLS.KeyFile != "" && databaseTLS.CertFile == "" {
+		errs = append(errs, errors.New("database.tls.key_file: requires cert_file"))
+	}
+	tlsFiles := []struct {
+		name     string
+		filename string
+	}{
+		{"ca_file", databaseTLS.CAFile},
+		{"cert_file", databaseTLS.CertFile},
+		{"key_file", databaseTLS.KeyFile},
+	}
+	for _, entry := range tlsFiles {
+		if entry.filename == "" {
+			continue
+		}
+		if _, err := os.Stat(entry.filename); err != nil {
+			errs = append(errs, fmt.Errorf("database.tls.%s: %q cannot be read", entry.name, entry.filename))
+		}
+	}
+	if config.Database.MaxPoolSize < 0 || config.Database.MinPoolSize < 0 {
+		errs = append(errs, errors.New("database: max_pool_size and min_pool_size must not be negative"))
+	}
+	if config.Database.MaxPoolSize > 0 && config.Database.MinPoolSize > config.Database.MaxPoolSize {
+		errs = append(errs, fmt.Errorf("database.min_pool_size: %d must not exceed max_pool_size %d",
+			config.Database.MinPoolSize, config.Database.MaxPoolSize))
+	}
+	if config.Database.MaxConnIdleTime < 0 || config.Database.ConnectTimeout < 0 ||
+		config.Database.ServerSelectionTimeout < 0 || config.Database.Timeout < 0 {
+		errs = append(errs, errors.New("database: max_conn_idle_time and timeouts must not be negative"))
+	}
+	if config.Database.Name == "" {
+		errs = append(errs, errors.New("database.name: must not be empty"))
 	}
 
-	// Server config
-	config.Server = ServerConfig{
-		Host: getStringEnv("HOST", "0.0.0.0"),
-		Port: getIntEnv("PORT", 8080),
+	switch config.Logging.Level {
+	case "debug", "info", "warn", "error":
+	default:
+		errs = append(errs, fmt.Errorf("logging.level: %q must be one of debug, info, warn, error", config.Logging.Level))
+	}
+	switch config.Logging.Format {
+	case "json", "text":
+	default:
+		errs = append(errs, fmt.Errorf("logging.format: %q must be one of json, text", config.Logging.Format))
 	}
 
-	// Database config
-	config.Database = DatabaseConfig{
-		Host:     getStringEnv("DB_HOST", "localhost"),
-		Port:     getIntEnv("DB_PORT", 27017),
-		Username: getStringEnv("DB_USER", "mongo"),
-		Password: getStringEnv("DB_PASSWORD", "mongo"),
-		Name:     getStringEnv("DB_NAME", "linko"),
+	if config.Logging.RedirectSampleRate < 0 || config.Logging.RedirectSampleRate > 1 {
+		errs = append(errs, fmt.Errorf("logging.redirect_sample_rate: %g must be between 0 and 1", config.Logging.RedirectSampleRate))
 	}
 
-	// Logging config
-	config.Logging = LoggingConfig{
-		Level:  getStringEnv("LOG_LEVEL", "info"),
-		Format: getStringEnv("LOG_FORMAT", "text"),
+	if config.URL.BulkMaxItems < 1 {
+		errs = append(errs, fmt.Errorf("url.bulk_max_items: %d must be positive", config.URL.BulkMaxItems))
 	}
 
-	return config
-}
+	switch config.RateLimit.Backend {
+	case "memory", "mongo":
+	default:
+		errs = append(errs, fmt.Errorf("rate_limit.backend: %q must be one of memory, mongo", config.RateLimit.Backend))
+	}
+	for _, proxy := range config.RateLimit.TrustedProxies {
+		if _, err := netip.ParsePrefix(proxy); err != nil {
+			if _, err = netip.ParseAddr(proxy); err != nil {
+				errs = append(errs, fmt.Errorf("rate_limit.trusted_proxies: %q is not an IP address or CIDR", proxy))
+			}
+		}
+	}
+	policies := []struct {
+		name   string
+		policy RateLimitPolicyConfig
+	}{
+		{"shorten", config.RateLimit.Shorten},
+		{"management", config.RateLimit.Management},
+		{"redirect", config.RateLimit.Redirect},
+		{"auth", config.RateLimit.Auth},
+	}
+	for _, entry := range policies {
+		if entry.policy.RequestsPerMinute < 1 || entry.policy.Burst < 1 {
+			errs = append(errs, fmt.Errorf("rate_limit.%s: requests_per_minute and burst must be positive", entry.name))
+		}
+	}
 
-// loadConfigFromFile loads configuration from YAML file
-func loadConfigFromFile(filename string) (*Config, error) {
-	if filename == "" {
-		return nil, nil
+	if config.Screening.ReloadInterval <= 0 {
+		errs = append(errs, fmt.Errorf("screening.reload_interval: %s must be positive", config.Screening.ReloadInterval))
+	}
+	if config.Screening.RescanInterval < 0 {
+		errs = append(errs, fmt.Errorf("screening.rescan_interval: %s must not be negative", config.Screening.RescanInterval))
 	}
 
-	file, err := os.Open(filename)
-	if err != nil {
-		if os.IsNotExist(err) {
-			return nil, nil // File doesn't exist
+	if len(config.Destination.AllowedSchemes) == 0 {
+		errs = append(errs, errors.New("destination.allowed_schemes: must not be empty"))
+	}
+	for _, scheme := range config.Destination.AllowedSchemes {
+		if scheme != "http" && scheme != "https" {
+			errs = append(errs, fmt.Errorf("destination.allowed_schemes: %q must be one of http, https", scheme))
 		}
+	}
+	if config.Destination.ResolveTimeout <= 0 {
+		errs = append(errs, fmt.Errorf("destination.resolve_timeout: %s must be positive", config.Destination.ResolveTimeout))
+	}
 
-		slog.Error("Failed to open config file", "filename", filename, "error", err)
-		return nil, err
+	if config.HealthCheck.Interval <= 0 || config.HealthCheck.RetryBackoff <= 0 || config.HealthCheck.Timeout <= 0 {
+		errs = append(errs, errors.New("health_check: interval, retry_backoff and timeout must be positive"))
+	}
+	if config.HealthCheck.HostDelay < 0 {
+		errs = append(errs, fmt.Errorf("health_check.host_delay: %s must not be negative", config.HealthCheck.HostDelay))
+	}
+	if config.HealthCheck.FailureThreshold < 1 {
+		errs = append(errs, fmt.Errorf("health_check.failure_threshold: %d must be positive", config.HealthCheck.FailureThreshold))
+	}
+	if config.HealthCheck.Concurrency < 1 {
+		errs = append(errs, fmt.Errorf("health_check.concurrency: %d must be positive", config.HealthCheck.Concurrency))
 	}
-	defer file.Close()
 
-	var config Config
-	decoder := yaml.NewDecoder(file)
-	if err = decoder.Decode(&config); err != nil {
-		slog.Error("Failed to decode config file", "filename", filename, "error", err)
-		return nil, err
+	if config.Webhook.Timeout <= 0 || config.Webhook.PollInterval <= 0 ||
+		config.Webhook.RetryBackoff <= 0 || config.Webhook.MaxRetryBackoff <= 0 {
+		errs = append(errs, errors.New("webhook: timeout, poll_interval, retry_backoff and max_retry_backoff must be positive"))
+	}
+	if config.Webhook.Concurrency < 1 || config.Webhook.MaxAttempts < 1 {
+		errs = append(errs, errors.New("webhook: concurrency and max_attempts must be positive"))
 	}
 
-	return &config, nil
-}
+	if config.Metadata.Timeout <= 0 {
+		errs = append(errs, fmt.Errorf("metadata.timeout: %s must be positive", config.Metadata.Timeout))
+	}
+	if config.Metadata.MaxBodyBytes < 1 || config.Metadata.Concurrency < 1 || config.Metadata.QueueSize < 1 {
+		errs = append(errs, errors.New("metadata: max_body_bytes, concurrency and queue_size must be positive"))
+	}
 
-// mergeConfigs merges two configs using reflection, with override taking precedence over base
-func mergeConfigs(base, override *Config) *Config {
-	if override == nil {
-		return base
+	if config.Domain.CacheTTL <= 0 || config.Domain.CacheSize < 1 {
+		errs = append(errs, errors.New("domain: cache_ttl and cache_size must be positive"))
+	}
+	switch config.Tracing.Exporter {
+	case "otlp", "stdout":
+	default:
+		errs = append(errs, fmt.Errorf("tracing.exporter: %q must be one of otlp, stdout", config.Tracing.Exporter))
+	}
+	if config.Tracing.OTLPEndpoint != "" {
+		endpoint, err := url.Parse(config.Tracing.OTLPEndpoint)
+		if err != nil || (endpoint.Scheme != "http" && endpoint.Scheme != "https") || endpoint.Host == "" {
+			errs = append(errs, fmt.Errorf("tracing.otlp_endpoint: %q must be an http or https URL", config.Tracing.OTLPEndpoint))
+		}
+	}
+	if config.Tracing.SampleRatio < 0 || config.Tracing.SampleRatio > 1 {
+		errs = append(errs, fmt.Errorf("tracing.sample_ratio: %g must be between 0 and 1", config.Tracing.SampleRatio))
+	}
+
+	if config.Reload.WatchInterval < 0 {
+		errs = append(errs, fmt.Errorf("reload.watch_interval: %s must not be negative", config.Reload.WatchInterval))
+	}
+
+	if config.Landing.TemplateDir != "" {
+		if info, err := os.Stat(config.Landing.TemplateDir); err != nil || !info.IsDir() {
+			errs = append(errs, fmt.Errorf("landing.template_dir: %q is not a directory", config.Landing.TemplateDir))
+		}
+	}
+	if color := config.Landing.Branding.AccentColor; color != "" && !hexColorPattern.MatchString(color) {
+		errs = append(errs, fmt.Errorf("landing.branding.accent_color: %q must be a hex color like #2563eb", color))
 	}
 
-	result := *base // Copy base config
-	mergeStructs(reflect.ValueOf(&result).Elem(), reflect.ValueOf(override).Elem())
-	return &result
+	return errors.Join(errs...)
 }
 
-// mergeStructs recursively merges struct fields using reflection
-func mergeStructs(dst, src reflect.Value) {
-	for i := 0; i < src.NumField(); i++ {
-		srcField := src.Field(i)
-		dstField := dst.Field(i)
+// logLevel is the level of the default logger, changed in place when the configuration is reloaded
+var logLevel slog.LevelVar
 
-		// Skip unexported fields
-		if !srcField.CanInterface() {
-			continue
-		}
+func (config *Config) configLogger(output io.Writer) {
+	var logHandler slog.Handler
 
-		switch srcField.Kind() {
-		case reflect.Struct:
-			// Recursively merge nested structs
-			mergeStructs(dstField, srcField)
-		case reflect.String:
-			// Override if source string is not empty
-			if srcField.String() != "" {
-				dstField.SetString(srcField.String())
-			}
-		case reflect.Int:
-			// Override if source int is not zero
-			if srcField.Int() != 0 {
-				dstField.SetInt(srcField.Int())
-			}
-		case reflect.Float64:
-			// Override if source float is not zero
-			if srcField.Float() != 0 {
-				dstField.SetFloat(srcField.Float())
-			}
-		case reflect.Bool:
-			dstField.SetBool(srcField.Bool())
-		default:
-			// For other types, try direct assignment if possible
-			if dstField.CanSet() && srcField.Type() == dstField.Type() {
-				dstField.Set(srcField)
-			}
-		}
+	config.applyLogLevel()
+	handlerOptions := &slog.HandlerOptions{Level: &logLevel}
+
+	if config.Logging.Format == "json" {
+		logHandler = slog.NewJSONHandler(output, handlerOptions)
+	} else {
+		logHandler = slog.NewTextHandler(output, handlerOptions)
 	}
+
+	logger := slog.New(logging.NewHandler(logHandler))
+	slog.SetDefault(logger)
 }
 
-// getStringEnv retrieves a string environment variable with a default value
-func getStringEnv(key, defaultValue string) string {
-	if value := os.Getenv(key); value != "" {
-		return value
+// applyLogLevel sets the level of the default logger to the configured level
+func (config *Config) applyLogLevel() {
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(config.Logging.Level)); err != nil {
+		level = slog.LevelInfo // Rejected by Validate
 	}
-	return defaultValue
+
+	logLevel.Set(level)
 }
 
-// getIntEnv retrieves an integer environment variable with a default value
-func getIntEnv(key string, defaultValue int) int {
-	if value := os.Getenv(key); value != "" {
-		var result int
-		for _, char := range value {
-			if char >= '0' && char <= '9' {
-				result = result*10 + int(char-'0')
-			} else {
-				slog.Warn("Invalid integer value, using default",
-					"key", key,
-					"value", value,
-					"default", defaultValue)
-				return defaultValue
-			}
-		}
-		return result
+// loadConfigFromEnv loads configuration from environment variables and the defaults of the
+// settings, reporting every variable with an invalid value
+func loadConfigFromEnv() (*Config, error) {
+	if err := godotenv.Load(); err != nil {
+		slog.Info("No .env file found")
 	}
-	return defaultValue
+
+	env := &envReader{}
+	config := &Config{}
+	env.load(reflect.ValueOf(config).Elem())
+	config.Server.BaseURL = strings.TrimSuffix(config.Server.BaseURL, "/")
+
+	return config, env.err()
 }
 
-// getFloatEnv retrieves a float environment variable with a default value
-func getFloatEnv(key string, defaultValue float64) float64 {
-	if value := os.Getenv(key); value != "" {
-		var result float64
-		var decimalFound bool
-		var decimalPlace float64 = 0.1
-		for _, char := range value {
-			if char >= '0' && char <= '9' {
-				if !decimalFound {
-					result = result*10 + float64(char-'0')
-				} else {
-					result += float64(char-'0') * decimalPlace
-					decimalPlace *= 0.1
-				}
-			} else if char == '.' && !decimalFound {
-				decimalFound = true
-			} else {
-				slog.Warn("Invalid float value, using default",
-					"key", key,
-					"value", value,
-					"default", defaultValue)
-				return defaultValue
-			}
-		}
-		return result
+// loadConfigFromFile loads configuration from YAML file. The file is decoded over a copy of base,
+// so settings missing from the file keep their base value while settings present in the file
+// replace it, even when set to a zero value such as 0 or an empty list. Settings of the wrong
+// type or unknown name are reported along with the rest of the file, so that it can still be
+// validated.
+func loadConfigFromFile(filename string, base *Config) (*Config, error) {
+	if filename == "" {
+		return nil, nil
 	}
-	return defaultValue
-}
 
-// getBoolEnv retrieves a boolean environment variable with a default value
-func getBoolEnv(key string, defaultValue bool) bool {
-	if value := os.Getenv(key); value != "" {
-		if value == "true" || value == "1" || value == "yes" {
-			return true
-		} else if value == "false" || value == "0" || value == "no" {
-			return false
-		} else {
-			slog.Warn("Invalid boolean value, using default",
-				"key", key,
-				"value", value,
-				"default", defaultValue)
-			return defaultValue
+	file, err := os.Open(filename)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil // File doesn't exist
 		}
+
+		slog.Error("Failed to open config file", "filename", filename, "error", err)
+		return nil, err
+	}
+	defer file.Close()
+
+	config := *base
+	decoder := yaml.NewDecoder(file)
+	decoder.KnownFields(true) // Report misspelled settings instead of ignoring them
+	err = decoder.Decode(&config)
+	if errors.Is(err, io.EOF) {
+		return &config, nil // Empty file
+	}
+
+	var typeErr *yaml.TypeError
+	if err != nil && !errors.As(err, &typeErr) {
+		slog.Error("Failed to decode config file", "filename", filename, "error", err)
+		return nil, err
 	}
-	return defaultValue
+
+	return &config, err
 }
@@ -0,0 +1,88 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestLoadFileOverridesEnvironment(t *testing.T) {
+	tests := []struct {
+		name string
+		env  map[string]string
+		file string
+		got  func(config *Config) any
+		want any
+	}{
+		{
+			name: "zero compression level overrides the default",
+			file: "server:\n  compression_level: 0\n",
+			got:  func(config *Config) any { return config.Server.CompressionLevel },
+			want: 0,
+		},
+		{
+			name: "zero compression level overrides the environment",
+			env:  map[string]string{"SERVER_COMPRESSION_LEVEL": "7"},
+			file: "server:\n  compression_level: 0\n",
+			got:  func(config *Config) any { return config.Server.CompressionLevel },
+			want: 0,
+		},
+		{
+			name: "missing setting keeps the environment value",
+			env:  map[string]string{"SERVER_COMPRESSION_LEVEL": "7"},
+			file: "server:\n  port: 8081\n",
+			got:  func(config *Config) any { return config.Server.CompressionLevel },
+			want: 7,
+		},
+		{
+			name: "zero admin port disables the admin listener",
+			env:  map[string]string{"SERVER_ADMIN_PORT": "9090"},
+			file: "server:\n  admin:\n    port: 0\n",
+			got:  func(config *Config) any { return config.Server.Admin.Port },
+			want: 0,
+		},
+		{
+			name: "empty list clears the environment list",
+			env:  map[string]string{"DESTINATION_SELF_HOSTS": "short.example"},
+			file: "destination:\n  self_hosts: []\n",
+			got:  func(config *Config) any { return len(config.Destination.SelfHosts) },
+			want: 0,
+		},
+		{
+			name: "list replaces the environment list",
+			env:  map[string]string{"DESTINATION_SELF_HOSTS": "short.example,go.example"},
+			file: "destination:\n  self_hosts: [link.example]\n",
+			got:  func(config *Config) any { return config.Destination.SelfHosts },
+			want: []string{"link.example"},
+		},
+		{
+			name: "false overrides a true default",
+			file: "auth:\n  api_key_required: false\n",
+			got:  func(config *Config) any { return config.Auth.APIKeyRequired },
+			want: false,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			for name, value := range test.env {
+				t.Setenv(name, value)
+			}
+
+			filename := filepath.Join(t.TempDir(), "config.yaml")
+			if err := os.WriteFile(filename, []byte(test.file), 0o600); err != nil {
+				t.Fatal(err)
+			}
+
+			config, err := load(filename)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if got := test.got(config); !reflect.DeepEqual(got, test.want) {
+				t.Errorf("got %v, want %v", got, test.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,99 @@
+package config
+
+import (
+	"reflect"
+	"strings"
+	"time"
+)
+
+// redacted replaces the value of secret settings in Redacted
+const redacted = "REDACTED"
+
+var (
+	durationType = reflect.TypeFor[time.Duration]()
+	locationType = reflect.TypeFor[Location]()
+)
+
+// Redacted returns the configuration as nested maps keyed by the YAML setting names, with the
+// values of settings tagged `secret:"true"` replaced when they are set
+func (config *Config) Redacted() map[string]any {
+	return settingsMap(reflect.ValueOf(config).Elem())
+}
+
+// settingsMap converts a configuration struct into a map keyed by setting name
+func settingsMap(value reflect.Value) map[string]any {
+	settings := make(map[string]any, value.NumField())
+	for i := range value.NumField() {
+		field := value.Type().Field(i)
+		name, ok := settingName(field)
+		if !ok {
+			continue
+		}
+
+		fieldValue := value.Field(i)
+		switch {
+		case field.Tag.Get("secret") == "true" && !fieldValue.IsZero():
+			settings[name] = redacted
+		case field.Type == durationType:
+			settings[name] = fieldValue.Interface().(time.Duration).String()
+		case field.Type == locationType:
+			settings[name] = fieldValue.Interface().(Location).String()
+		case field.Type.Kind() == reflect.Struct:
+			settings[name] = settingsMap(fieldValue)
+		default:
+			settings[name] = fieldValue.Interface()
+		}
+	}
+
+	return settings
+}
+
+// changedSettings returns the dotted names of the settings that differ between two configurations
+func changedSettings(before, after *Config) []string {
+	return diffSettings("", reflect.ValueOf(before).Elem(), reflect.ValueOf(after).Elem())
+}
+
+func diffSettings(prefix string, before, after reflect.Value) []string {
+	var changed []string
+	for i := range before.NumField() {
+		field := before.Type().Field(i)
+		name, ok := settingName(field)
+		if !ok {
+			continue
+		}
+
+		beforeField, afterField := before.Field(i), after.Field(i)
+		switch {
+		case field.Type == locationType:
+			if beforeField.Interface().(Location).String() != afterField.Interface().(Location).String() {
+				changed = append(changed, prefix+name)
+			}
+		case field.Type.Kind() == reflect.Struct:
+			changed = append(changed, diffSettings(prefix+name+".", beforeField, afterField)...)
+		default:
+			if !reflect.DeepEqual(beforeField.Interface(), afterField.Interface()) {
+				changed = append(changed, prefix+name)
+			}
+		}
+	}
+
+	return changed
+}
+
+// settingName returns the YAML name of a configuration field, which defaults to the lower case
+// field name like the YAML decoder does
+func settingName(field reflect.StructField) (string, bool) {
+	if !field.IsExported() {
+		return "", false
+	}
+
+	name, _, _ := strings.Cut(field.Tag.Get("yaml"), ",")
+	switch name {
+	case "-":
+		return "", false
+	case "":
+		return strings.ToLower(field.Name), true
+	default:
+		return name, true
+	}
+}
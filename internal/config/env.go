@@ -0,0 +1,62 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"reflect"
+	"strings"
+)
+
+// envReader reads the settings of the configuration from environment variables, collecting an
+// error for every variable whose value cannot be parsed instead of silently falling back to the
+// default
+type envReader struct {
+	errs []error
+}
+
+// err returns the errors of all invalid variables read so far
+func (env *envReader) err() error {
+	return errors.Join(env.errs...)
+}
+
+// load sets every declared setting of config, the value of a Config, from its environment
+// variable or else its default
+func (env *envReader) load(config reflect.Value) {
+	for _, entry := range declaredSettings {
+		field := config.FieldByIndex(entry.index)
+
+		if value, ok := env.lookup(entry); ok {
+			if parseSetting(field, value) == nil {
+				continue
+			}
+			env.errs = append(env.errs, fmt.Errorf("%s: %q is not a valid %s", entry.env, value, typeName(entry.valueType)))
+		}
+
+		parseSetting(field, entry.defaultValue) // Checked by collectSettings
+	}
+}
+
+// lookup returns the value of the environment variable of a setting and whether it is set. A
+// secret can also be read from the file named by the variable with a _FILE suffix, as mounted
+// by Docker and Kubernetes secrets; a trailing line break of the file is ignored.
+func (env *envReader) lookup(entry setting) (string, bool) {
+	value := os.Getenv(entry.env)
+	filename := os.Getenv(entry.env + "_FILE")
+	if !entry.secret || filename == "" {
+		return value, value != ""
+	}
+
+	if value != "" {
+		env.errs = append(env.errs, fmt.Errorf("%s: must not be set together with %s_FILE", entry.env, entry.env))
+		return "", false
+	}
+
+	content, err := os.ReadFile(filename)
+	if err != nil {
+		env.errs = append(env.errs, fmt.Errorf("%s_FILE: %w", entry.env, err))
+		return "", false
+	}
+
+	return strings.TrimRight(string(content), "\r\n"), true
+}
@@ -0,0 +1,29 @@
+package config
+
+import (
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Location is a time zone setting, written as an IANA name like Europe/Berlin in the
+// configuration file and environment
+type Location struct {
+	*time.Location
+}
+
+// UnmarshalYAML loads the time zone named by a YAML string
+func (location *Location) UnmarshalYAML(node *yaml.Node) error {
+	var name string
+	if err := node.Decode(&name); err != nil {
+		return err
+	}
+
+	loaded, err := time.LoadLocation(name)
+	if err != nil {
+		return err
+	}
+
+	location.Location = loaded
+	return nil
+}
@@ -0,0 +1,173 @@
+package config
+
+import (
+	"context"
+	"log/slog"
+	"os"
+	"os/signal"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+)
+
+// Reloadable is implemented by components that apply reloadable settings while running. Reload
+// receives the complete effective configuration and must switch to it atomically, so that a
+// reload never leaves a component with a partially applied configuration.
+type Reloadable interface {
+	Reload(cfg *Config)
+}
+
+// Reloader reloads the configuration on SIGHUP or when the configuration file changes. A new
+// configuration is validated as a whole and rejected when invalid; otherwise its reloadable
+// settings are handed to every registered component. Other changes are logged and take effect
+// on the next restart.
+//
+// Reloadable settings are the log level and access log, rate limits and trusted proxies, whether screening is
+// enabled and its rules file, the destination policy and the custom domain cache.
+type Reloader struct {
+	configFile string
+	current    atomic.Pointer[Config]
+
+	mu         sync.Mutex // Serializes reloads
+	components []Reloadable
+	modTime    time.Time
+}
+
+func NewReloader(configFile string, cfg *Config) *Reloader {
+	reloader := &Reloader{configFile: configFile}
+	reloader.current.Store(cfg)
+	reloader.modTime = reloader.fileModTime()
+
+	return reloader
+}
+
+// Register adds components to notify of reloads. It must be called before Run.
+func (reloader *Reloader) Register(components ...Reloadable) {
+	reloader.mu.Lock()
+	defer reloader.mu.Unlock()
+
+	reloader.components = append(reloader.components, components...)
+}
+
+// Current returns the effective configuration
+func (reloader *Reloader) Current() *Config {
+	return reloader.current.Load()
+}
+
+// Reload loads the configuration again and applies its reloadable settings. An invalid
+// configuration is rejected with an error listing every problem and changes nothing.
+func (reloader *Reloader) Reload() error {
+	reloader.mu.Lock()
+	defer reloader.mu.Unlock()
+
+	reloader.modTime = reloader.fileModTime()
+	next, err := load(reloader.configFile)
+	if err != nil {
+		return err
+	}
+
+	current := reloader.current.Load()
+	effective := withReloadable(current, next)
+	if pending := changedSettings(effective, next); len(pending) > 0 {
+		slog.Warn("Configuration changes take effect after a restart", "settings", pending)
+	}
+
+	changed := changedSettings(current, effective)
+	if len(changed) == 0 {
+		slog.Info("Configuration reloaded without changes")
+		return nil
+	}
+
+	effective.applyLogLevel()
+	for _, component := range reloader.components {
+		component.Reload(effective)
+	}
+	reloader.current.Store(effective)
+
+	slog.Info("Configuration reloaded", "changed", changed)
+	return nil
+}
+
+// Run reloads the configuration on SIGHUP and, unless disabled, whenever the configuration file
+// changes, until ctx is cancelled
+func (reloader *Reloader) Run(ctx context.Context) {
+	hangup := make(chan os.Signal, 1)
+	signal.Notify(hangup, syscall.SIGHUP)
+	defer signal.Stop(hangup)
+
+	var watch <-chan time.Time
+	if interval := reloader.Current().Reload.WatchInterval; interval > 0 && reloader.configFile != "" {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		watch = ticker.C
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-hangup:
+			slog.Info("Received SIGHUP, reloading configuration")
+			reloader.reload()
+		case <-watch:
+			if modTime := reloader.fileModTime(); !modTime.Equal(reloader.lastModTime()) {
+				slog.Info("Configuration file changed, reloading configuration", "config", reloader.configFile)
+				reloader.reload()
+			}
+		}
+	}
+}
+
+// reload reloads the configuration, logging why it was rejected
+func (reloader *Reloader) reload() {
+	if err := reloader.Reload(); err != nil {
+		slog.Error("Configuration reload rejected, keeping the current configuration", "error", err)
+	}
+}
+
+func (reloader *Reloader) lastModTime() time.Time {
+	reloader.mu.Lock()
+	defer reloader.mu.Unlock()
+
+	return reloader.modTime
+}
+
+// fileModTime returns the modification time of the configuration file, or the zero time when
+// it does not exist
+func (reloader *Reloader) fileModTime() time.Time {
+	if reloader.configFile == "" {
+		return time.Time{}
+	}
+
+	info, err := os.Stat(reloader.configFile)
+	if err != nil {
+		return time.Time{}
+	}
+
+	return info.ModTime()
+}
+
+// withReloadable returns a copy of current with the reloadable settings of next
+func withReloadable(current, next *Config) *Config {
+	effective := *current
+
+	effective.Logging.Level = next.Logging.Level
+	effective.Logging.AccessLog = next.Logging.AccessLog
+	effective.Logging.RedirectSampleRate = next.Logging.RedirectSampleRate
+
+	effective.RateLimit.Enabled = next.RateLimit.Enabled
+	effective.RateLimit.TrustedProxies = next.RateLimit.TrustedProxies
+	effective.RateLimit.Shorten = next.RateLimit.Shorten
+	effective.RateLimit.Management = next.RateLimit.Management
+	effective.RateLimit.Redirect = next.RateLimit.Redirect
+	effective.RateLimit.Auth = next.RateLimit.Auth
+
+	effective.Screening.Enabled = next.Screening.Enabled
+	effective.Screening.RulesFile = next.Screening.RulesFile
+
+	effective.Destination = next.Destination
+	effective.Domain = next.Domain
+
+	return &effective
+}
@@ -0,0 +1,173 @@
+package config
+
+import (
+	"cmp"
+	"fmt"
+	"io"
+	"reflect"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// setting is a configuration setting as declared by the tags of its Config field
+type setting struct {
+	name         string // Dotted YAML name, e.g. database.tls.enabled
+	env          string
+	defaultValue string
+	description  string
+	secret       bool
+	index        []int // Field index for reflect.Value.FieldByIndex
+	valueType    reflect.Type
+}
+
+// declaredSettings lists every setting of Config in declaration order
+var declaredSettings = collectSettings(reflect.TypeFor[Config](), nil, "", "", nil)
+
+// collectSettings lists the settings declared by the fields of a configuration struct. Nested
+// structs are sections: their env tag prefixes the variables of their settings and their default
+// tag, a comma separated list of name=value pairs, overrides the defaults of their settings.
+// Missing tags and invalid defaults are programming errors and panic.
+func collectSettings(structType reflect.Type, index []int, namePrefix, envPrefix string, defaults map[string]string) []setting {
+	var settings []setting
+	for i := range structType.NumField() {
+		field := structType.Field(i)
+		name, ok := settingName(field)
+		if !ok {
+			continue
+		}
+
+		fieldIndex := append(append([]int(nil), index...), i)
+		if field.Type.Kind() == reflect.Struct && field.Type != locationType {
+			sectionEnvPrefix := envPrefix
+			if env := field.Tag.Get("env"); env != "" {
+				sectionEnvPrefix += env + "_"
+			}
+			sectionDefaults := make(map[string]string)
+			if tag := field.Tag.Get("default"); tag != "" {
+				for _, pair := range strings.Split(tag, ",") {
+					key, value, _ := strings.Cut(pair, "=")
+					sectionDefaults[key] = value
+				}
+			}
+
+			settings = append(settings, collectSettings(field.Type, fieldIndex, namePrefix+name+".", sectionEnvPrefix, sectionDefaults)...)
+			continue
+		}
+
+		entry := setting{
+			name:         namePrefix + name,
+			env:          envPrefix + field.Tag.Get("env"),
+			defaultValue: field.Tag.Get("default"),
+			description:  field.Tag.Get("desc"),
+			secret:       field.Tag.Get("secret") == "true",
+			index:        fieldIndex,
+			valueType:    field.Type,
+		}
+		if value, ok := defaults[name]; ok {
+			entry.defaultValue = value
+		}
+
+		if field.Tag.Get("env") == "" || entry.description == "" {
+			panic(fmt.Sprintf("config: setting %s must have env and desc tags", entry.name))
+		}
+		if err := parseSetting(reflect.New(field.Type).Elem(), entry.defaultValue); err != nil {
+			panic(fmt.Sprintf("config: default of setting %s: %v", entry.name, err))
+		}
+
+		settings = append(settings, entry)
+	}
+
+	return settings
+}
+
+// parseSetting parses text into value according to the type of the setting. Lists are comma
+// separated and durations are written like "1m30s".
+func parseSetting(value reflect.Value, text string) error {
+	switch {
+	case value.Type() == durationType:
+		duration, err := time.ParseDuration(cmp.Or(text, "0s"))
+		if err != nil {
+			return err
+		}
+		value.SetInt(int64(duration))
+	case value.Type() == locationType:
+		location, err := time.LoadLocation(text)
+		if err != nil {
+			return err
+		}
+		value.Set(reflect.ValueOf(Location{location}))
+	case value.Kind() == reflect.String:
+		value.SetString(text)
+	case value.Kind() == reflect.Int || value.Kind() == reflect.Int64:
+		number, err := strconv.ParseInt(cmp.Or(text, "0"), 10, value.Type().Bits())
+		if err != nil {
+			return err
+		}
+		value.SetInt(number)
+	case value.Kind() == reflect.Float64:
+		number, err := strconv.ParseFloat(cmp.Or(text, "0"), 64)
+		if err != nil {
+			return err
+		}
+		value.SetFloat(number)
+	case value.Kind() == reflect.Bool:
+		switch text {
+		case "true", "1", "yes":
+			value.SetBool(true)
+		case "", "false", "0", "no":
+			value.SetBool(false)
+		default:
+			return fmt.Errorf("invalid boolean %q", text)
+		}
+	case value.Kind() == reflect.Slice && value.Type().Elem().Kind() == reflect.String:
+		var items []string
+		for _, item := range strings.Split(text, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				items = append(items, item)
+			}
+		}
+		value.Set(reflect.ValueOf(items))
+	default:
+		return fmt.Errorf("unsupported setting type %s", value.Type())
+	}
+
+	return nil
+}
+
+// typeName describes the type of a setting
+func typeName(valueType reflect.Type) string {
+	switch {
+	case valueType == durationType:
+		return "duration"
+	case valueType == locationType:
+		return "time zone"
+	case valueType.Kind() == reflect.Bool:
+		return "boolean"
+	case valueType.Kind() == reflect.Int || valueType.Kind() == reflect.Int64:
+		return "integer"
+	case valueType.Kind() == reflect.Float64:
+		return "number"
+	case valueType.Kind() == reflect.Slice:
+		return "list"
+	default:
+		return "string"
+	}
+}
+
+// WriteReference writes a table of every setting with its name in the configuration file,
+// environment variable, type, default and description
+func WriteReference(w io.Writer) error {
+	table := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(table, "SETTING\tENVIRONMENT VARIABLE\tTYPE\tDEFAULT\tDESCRIPTION")
+	for _, entry := range declaredSettings {
+		env := entry.env
+		if entry.secret {
+			env += ", " + entry.env + "_FILE"
+		}
+		fmt.Fprintf(table, "%s\t%s\t%s\t%s\t%s\n", entry.name, env, typeName(entry.valueType), entry.defaultValue, entry.description)
+	}
+
+	return table.Flush()
+}
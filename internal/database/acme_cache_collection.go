@@ -0,0 +1,79 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+	"log/slog"
+	"time"
+)
+
+const acmeCacheCollectionName = "acme_cache"
+
+type acmeCacheEntry struct {
+	Data []byte `bson:"data"`
+}
+
+// GetACMECacheEntry returns the data stored under key by the ACME client, or nil if there is none
+func (database *Database) GetACMECacheEntry(ctx context.Context, key string) ([]byte, error) {
+	var entry acmeCacheEntry
+	if err := database.acmeCacheCollection.FindOne(ctx, bson.M{"_id": key}).Decode(&entry); err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, nil
+		}
+
+		slog.ErrorContext(ctx, "Failed find ACME cache entry", "error", err)
+		return nil, err
+	}
+
+	return entry.Data, nil
+}
+
+// PutACMECacheEntry stores data under key, replacing any previous data
+func (database *Database) PutACMECacheEntry(ctx context.Context, key string, data []byte) error {
+	update := bson.M{"$set": bson.M{"data": data, "updated_at": time.Now()}}
+	opts := options.UpdateOne().SetUpsert(true)
+	if _, err := database.acmeCacheCollection.UpdateOne(ctx, bson.M{"_id": key}, update, opts); err != nil {
+		slog.ErrorContext(ctx, "Failed update ACME cache entry", "error", err)
+		return err
+	}
+
+	return nil
+}
+
+// DeleteACMECacheEntry deletes the data stored under key, if any
+func (database *Database) DeleteACMECacheEntry(ctx context.Context, key string) error {
+	if _, err := database.acmeCacheCollection.DeleteOne(ctx, bson.M{"_id": key}); err != nil {
+		slog.ErrorContext(ctx, "Failed delete ACME cache entry", "error", err)
+		return err
+	}
+
+	return nil
+}
+
+func (database *Database) initACMECacheCollection(ctx context.Context) *mongo.Collection {
+	database.createCollection(ctx, acmeCacheCollectionName, bson.M{
+		"$jsonSchema": bson.M{
+			"bsonType": "object",
+			"required": []string{"data", "updated_at"},
+			"properties": bson.M{
+				"_id": bson.M{
+					"bsonType":    "string",
+					"description": "cache key of the ACME client: a host name, or the account key",
+				},
+				"data": bson.M{
+					"bsonType":    "binData",
+					"description": "PEM encoded private key and certificate chain, or account key",
+				},
+				"updated_at": bson.M{
+					"bsonType":    "date",
+					"description": "timestamp when the entry was last stored",
+				},
+			},
+		},
+	})
+
+	return database.db.Collection(acmeCacheCollectionName)
+}
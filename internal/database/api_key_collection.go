@@ -0,0 +1,161 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"github.com/aarondever/linko/internal/models"
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+	"log/slog"
+	"time"
+)
+
+const apiKeyCollectionName = "api_keys"
+
+func (database *Database) CreateAPIKey(ctx context.Context, params models.APIKey) (*models.APIKey, error) {
+	params.CreatedAt = time.Now()
+
+	result, err := database.apiKeyCollection.InsertOne(ctx, params)
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed insert API key", "error", err)
+		return nil, err
+	}
+
+	params.ID = result.InsertedID.(bson.ObjectID)
+	return &params, nil
+}
+
+func (database *Database) GetAPIKeyByHash(ctx context.Context, keyHash string) (*models.APIKey, error) {
+	var apiKey models.APIKey
+	if err := database.apiKeyCollection.FindOne(ctx, bson.M{"key_hash": keyHash}).Decode(&apiKey); err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, nil
+		}
+
+		slog.ErrorContext(ctx, "Failed find API key", "error", err)
+		return nil, err
+	}
+
+	return &apiKey, nil
+}
+
+func (database *Database) ListAPIKeys(ctx context.Context) ([]models.APIKey, error) {
+	opts := options.Find().SetSort(bson.D{{Key: "created_at", Value: -1}})
+	cursor, err := database.apiKeyCollection.Find(ctx, bson.M{}, opts)
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed find API keys", "error", err)
+		return nil, err
+	}
+
+	apiKeys := []models.APIKey{}
+	if err = cursor.All(ctx, &apiKeys); err != nil {
+		slog.ErrorContext(ctx, "Failed decode API keys", "error", err)
+		return nil, err
+	}
+
+	return apiKeys, nil
+}
+
+// HasActiveAPIKeys reports whether any API key has been created and not revoked
+func (database *Database) HasActiveAPIKeys(ctx context.Context) (bool, error) {
+	filter := bson.M{"revoked_at": bson.M{"$exists": false}}
+	count, err := database.apiKeyCollection.CountDocuments(ctx, filter, options.Count().SetLimit(1))
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed count API keys", "error", err)
+		return false, err
+	}
+
+	return count > 0, nil
+}
+
+// RevokeAPIKey marks an API key as revoked and returns it, or nil if no active key has the given ID
+func (database *Database) RevokeAPIKey(ctx context.Context, id string) (*models.APIKey, error) {
+	apiKeyID, err := bson.ObjectIDFromHex(id)
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed parse API key ID", "error", err)
+		return nil, err
+	}
+
+	filter := bson.M{"_id": apiKeyID, "revoked_at": bson.M{"$exists": false}}
+	update := bson.M{"$set": bson.M{"revoked_at": time.Now()}}
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+
+	var apiKey models.APIKey
+	if err = database.apiKeyCollection.FindOneAndUpdate(ctx, filter, update, opts).Decode(&apiKey); err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, nil
+		}
+
+		slog.ErrorContext(ctx, "Failed revoke API key", "error", err)
+		return nil, err
+	}
+
+	return &apiKey, nil
+}
+
+func (database *Database) TouchAPIKey(ctx context.Context, id bson.ObjectID) error {
+	_, err := database.apiKeyCollection.UpdateByID(ctx, id, bson.M{"$set": bson.M{"last_used_at": time.Now()}})
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed update API key last use", "error", err)
+		return err
+	}
+
+	return nil
+}
+
+func (database *Database) initAPIKeyCollection(ctx context.Context) *mongo.Collection {
+	database.createCollection(ctx, apiKeyCollectionName, bson.M{
+		"$jsonSchema": bson.M{
+			"bsonType": "object",
+			"required": []string{"name", "prefix", "key_hash", "created_at"},
+			"properties": bson.M{
+				"name": bson.M{
+					"bsonType":    "string",
+					"description": "human readable name of the key",
+				},
+				"workspace": bson.M{
+					"bsonType":    "string",
+					"description": "workspace whose custom domains the key can use",
+				},
+				"admin": bson.M{
+					"bsonType":    "bool",
+					"description": "whether the key can use the administrative routes",
+				},
+				"prefix": bson.M{
+					"bsonType":    "string",
+					"description": "first characters of the key used to identify it",
+				},
+				"key_hash": bson.M{
+					"bsonType":    "string",
+					"pattern":     "^[a-f0-9]{64}$",
+					"description": "hex encoded SHA-256 hash of the key",
+				},
+				"created_at": bson.M{
+					"bsonType":    "date",
+					"description": "timestamp when the key was created",
+				},
+				"last_used_at": bson.M{
+					"bsonType":    "date",
+					"description": "timestamp when the key was last used",
+				},
+				"revoked_at": bson.M{
+					"bsonType":    "date",
+					"description": "timestamp when the key was revoked",
+				},
+			},
+		},
+	})
+
+	collection := database.db.Collection(apiKeyCollectionName)
+
+	database.createIndexes(ctx, collection, []mongo.IndexModel{
+		// Index on key_hash for authenticating keys
+		{
+			Keys:    bson.D{{Key: "key_hash", Value: 1}},
+			Options: options.Index().SetUnique(true).SetName("key_hash_unique"),
+		},
+	})
+
+	return collection
+}
@@ -0,0 +1,186 @@
+package database
+
+import (
+	"context"
+	"github.com/aarondever/linko/internal/models"
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+	"log/slog"
+)
+
+const auditCollectionName = "audit_log"
+
+// InsertAuditEntries appends entries to the audit log. The audit log is append-only: there are
+// no methods to update or delete its entries.
+func (database *Database) InsertAuditEntries(ctx context.Context, entries []models.AuditEntry) error {
+	if _, err := database.auditCollection.InsertMany(ctx, entries); err != nil {
+		slog.ErrorContext(ctx, "Failed insert audit entries", "error", err)
+		return err
+	}
+
+	return nil
+}
+
+// ListAuditEntries returns entries matching filter from newest to oldest, skipping offset and
+// returning at most limit
+func (database *Database) ListAuditEntries(
+	ctx context.Context,
+	filter models.AuditFilter,
+	offset, limit int64,
+) ([]models.AuditEntry, error) {
+	opts := options.Find().
+		SetSort(bson.D{{Key: "time", Value: -1}, {Key: "_id", Value: -1}}).
+		SetSkip(offset).
+		SetLimit(limit)
+
+	cursor, err := database.auditCollection.Find(ctx, auditQuery(filter), opts)
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed find audit entries", "error", err)
+		return nil, err
+	}
+
+	entries := []models.AuditEntry{}
+	if err = cursor.All(ctx, &entries); err != nil {
+		slog.ErrorContext(ctx, "Failed decode audit entries", "error", err)
+		return nil, err
+	}
+
+	return entries, nil
+}
+
+// ForEachAuditEntry streams the entries matching filter from oldest to newest to fn, stopping
+// at the first error
+func (database *Database) ForEachAuditEntry(
+	ctx context.Context,
+	filter models.AuditFilter,
+	fn func(entry models.AuditEntry) error,
+) error {
+	opts := options.Find().SetSort(bson.D{{Key: "time", Value: 1}, {Key: "_id", Value: 1}})
+	cursor, err := database.auditCollection.Find(ctx, auditQuery(filter), opts)
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed find audit entries", "error", err)
+		return err
+	}
+	defer cursor.Close(ctx)
+
+	for cursor.Next(ctx) {
+		var entry models.AuditEntry
+		if err = cursor.Decode(&entry); err != nil {
+			slog.ErrorContext(ctx, "Failed decode audit entry", "error", err)
+			return err
+		}
+
+		if err = fn(entry); err != nil {
+			return err
+		}
+	}
+
+	if err = cursor.Err(); err != nil {
+		slog.ErrorContext(ctx, "Failed iterate audit entries", "error", err)
+		return err
+	}
+
+	return nil
+}
+
+// auditQuery builds the query of an audit filter. Entries are always limited to the workspace
+// of the filter.
+func auditQuery(filter models.AuditFilter) bson.M {
+	query := bson.M{"workspace": absentIfEmpty(filter.Workspace)}
+
+	fields := map[string]string{
+		"actor.type":  filter.ActorType,
+		"actor.id":    filter.ActorID,
+		"action":      filter.Action,
+		"target.type": filter.TargetType,
+		"target.id":   filter.TargetID,
+	}
+	for field, value := range fields {
+		if value != "" {
+			query[field] = value
+		}
+	}
+
+	timeRange := bson.M{}
+	if filter.Since != nil {
+		timeRange["$gte"] = *filter.Since
+	}
+	if filter.Until != nil {
+		timeRange["$lt"] = *filter.Until
+	}
+	if len(timeRange) > 0 {
+		query["time"] = timeRange
+	}
+
+	return query
+}
+
+func (database *Database) initAuditCollection(ctx context.Context) *mongo.Collection {
+	database.createCollection(ctx, auditCollectionName, bson.M{
+		"$jsonSchema": bson.M{
+			"bsonType": "object",
+			"required": []string{"time", "actor", "action", "target", "changes"},
+			"properties": bson.M{
+				"time": bson.M{
+					"bsonType":    "date",
+					"description": "timestamp when the action was performed",
+				},
+				"workspace": bson.M{
+					"bsonType":    "string",
+					"description": "workspace the entry is visible to",
+				},
+				"actor": bson.M{
+					"bsonType":    "object",
+					"required":    []string{"type"},
+					"description": "who performed the action",
+				},
+				"action": bson.M{
+					"bsonType":    "string",
+					"description": "performed action, e.g. link.update",
+				},
+				"target": bson.M{
+					"bsonType":    "object",
+					"required":    []string{"type"},
+					"description": "what the action changed",
+				},
+				"changes": bson.M{
+					"bsonType":    "array",
+					"description": "changed fields with their values before and after the action",
+				},
+				"ip": bson.M{
+					"bsonType":    "string",
+					"description": "client IP of the request performing the action",
+				},
+				"request_id": bson.M{
+					"bsonType":    "string",
+					"description": "ID of the request performing the action",
+				},
+			},
+		},
+	})
+
+	// Change values are arbitrary documents; decode them as maps so they render as JSON objects
+	collection := database.db.Collection(auditCollectionName,
+		options.Collection().SetBSONOptions(&options.BSONOptions{DefaultDocumentM: true}))
+
+	database.createIndexes(ctx, collection, []mongo.IndexModel{
+		// Index on workspace and time for listing the entries of a workspace
+		{
+			Keys:    bson.D{{Key: "workspace", Value: 1}, {Key: "time", Value: -1}},
+			Options: options.Index().SetName("workspace_asc_time_desc"),
+		},
+		// Index on target for the history of a link, key or domain
+		{
+			Keys:    bson.D{{Key: "target.type", Value: 1}, {Key: "target.id", Value: 1}, {Key: "time", Value: -1}},
+			Options: options.Index().SetName("target_asc_time_desc"),
+		},
+		// Index on actor for the actions of an API key or user
+		{
+			Keys:    bson.D{{Key: "actor.id", Value: 1}, {Key: "time", Value: -1}},
+			Options: options.Index().SetName("actor_id_asc_time_desc"),
+		},
+	})
+
+	return collection
+}
@@ -0,0 +1,107 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"github.com/aarondever/linko/internal/models"
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+	"log/slog"
+	"time"
+)
+
+const brandingCollectionName = "branding"
+
+// GetBranding returns the branding of a workspace, or nil if it has none
+func (database *Database) GetBranding(ctx context.Context, workspace string) (*models.Branding, error) {
+	var branding models.Branding
+	filter := bson.M{"workspace": absentIfEmpty(workspace)}
+	if err := database.brandingCollection.FindOne(ctx, filter).Decode(&branding); err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, nil
+		}
+
+		slog.ErrorContext(ctx, "Failed find branding", "error", err)
+		return nil, err
+	}
+
+	return &branding, nil
+}
+
+// ReplaceBranding stores the branding of a workspace, replacing any previous branding
+func (database *Database) ReplaceBranding(ctx context.Context, branding models.Branding) (*models.Branding, error) {
+	branding.UpdatedAt = time.Now()
+
+	filter := bson.M{"workspace": absentIfEmpty(branding.Workspace)}
+	opts := options.Replace().SetUpsert(true)
+	if _, err := database.brandingCollection.ReplaceOne(ctx, filter, branding, opts); err != nil {
+		slog.ErrorContext(ctx, "Failed replace branding", "error", err)
+		return nil, err
+	}
+
+	return &branding, nil
+}
+
+// DeleteBranding deletes the branding of a workspace and returns it, or nil if it had none
+func (database *Database) DeleteBranding(ctx context.Context, workspace string) (*models.Branding, error) {
+	var branding models.Branding
+	filter := bson.M{"workspace": absentIfEmpty(workspace)}
+	if err := database.brandingCollection.FindOneAndDelete(ctx, filter).Decode(&branding); err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, nil
+		}
+
+		slog.ErrorContext(ctx, "Failed delete branding", "error", err)
+		return nil, err
+	}
+
+	return &branding, nil
+}
+
+func (database *Database) initBrandingCollection(ctx context.Context) *mongo.Collection {
+	database.createCollection(ctx, brandingCollectionName, bson.M{
+		"$jsonSchema": bson.M{
+			"bsonType": "object",
+			"required": []string{"updated_at"},
+			"properties": bson.M{
+				"workspace": bson.M{
+					"bsonType":    "string",
+					"description": "workspace the branding applies to, absent for keys without a workspace",
+				},
+				"name": bson.M{
+					"bsonType":    "string",
+					"description": "brand name shown on landing pages",
+				},
+				"logo_url": bson.M{
+					"bsonType":    "string",
+					"description": "URL of the logo shown on landing pages",
+				},
+				"accent_color": bson.M{
+					"bsonType":    "string",
+					"description": "CSS hex color of landing page accents",
+				},
+				"support_url": bson.M{
+					"bsonType":    "string",
+					"description": "URL visitors are pointed to for help",
+				},
+				"updated_at": bson.M{
+					"bsonType":    "date",
+					"description": "timestamp when the branding was last changed",
+				},
+			},
+		},
+	})
+
+	collection := database.db.Collection(brandingCollectionName)
+
+	database.createIndexes(ctx, collection, []mongo.IndexModel{
+		// Index on workspace, which has at most one branding
+		{
+			Keys:    bson.D{{Key: "workspace", Value: 1}},
+			Options: options.Index().SetUnique(true).SetName("workspace_unique"),
+		},
+	})
+
+	return collection
+}
@@ -1,35 +1,58 @@
 package database
 
 import (
+	"cmp"
 	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
 	"fmt"
 	"github.com/aarondever/linko/internal/config"
 	"go.mongodb.org/mongo-driver/v2/bson"
 	"go.mongodb.org/mongo-driver/v2/mongo"
 	"go.mongodb.org/mongo-driver/v2/mongo/options"
 	"log/slog"
+	"net"
 	"os"
+	"strconv"
 	"time"
 )
 
+// indexNotFoundErrorCode is the MongoDB server error code for dropping an index that does not exist
+const indexNotFoundErrorCode = 27
+
 type Database struct {
-	Mongo         *mongo.Client
-	db            *mongo.Database
-	urlCollection *mongo.Collection
+	Mongo                     *mongo.Client
+	db                        *mongo.Database
+	validators                map[string]bson.M   // Validation schema of each collection, applied by Migrate
+	obsoleteIndexes           map[string][]string // Indexes of each collection dropped by Migrate
+	urlCollection             *mongo.Collection
+	apiKeyCollection          *mongo.Collection
+	rateLimitCollection       *mongo.Collection
+	domainCollection          *mongo.Collection
+	brandingCollection        *mongo.Collection
+	acmeCacheCollection       *mongo.Collection
+	auditCollection           *mongo.Collection
+	webhookCollection         *mongo.Collection
+	webhookDeliveryCollection *mongo.Collection
 }
 
 func InitializeDatabase(config *config.Config) (*Database, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
 	defer cancel()
 
-	databaseURL := fmt.Sprintf("mongodb://%s:%s@%s:%d",
-		config.Database.Username,
-		config.Database.Password,
-		config.Database.Host,
-		config.Database.Port)
+	clientOptions, err := newClientOptions(config.Database)
+	if err != nil {
+		slog.Error("Invalid MongoDB connection settings", "error", err)
+		return nil, err
+	}
+
+	if config.Tracing.Enabled {
+		clientOptions.SetMonitor(newTracingMonitor())
+	}
 
 	// Connect to MongoDB
-	client, err := mongo.Connect(options.Client().ApplyURI(databaseURL))
+	client, err := mongo.Connect(clientOptions)
 	if err != nil {
 		slog.Error("Failed to connect to MongoDB", "error", err)
 		return nil, err
@@ -44,17 +67,146 @@ func InitializeDatabase(config *config.Config) (*Database, error) {
 	slog.Info("Connected to MongoDB")
 
 	database := &Database{
-		Mongo: client,
-		db:    client.Database(config.Database.Name),
+		Mongo:           client,
+		db:              client.Database(config.Database.Name),
+		validators:      make(map[string]bson.M),
+		obsoleteIndexes: make(map[string][]string),
 	}
 
 	// Initialize collections
 	database.urlCollection = database.initURLCollection(ctx)
+	database.apiKeyCollection = database.initAPIKeyCollection(ctx)
+	database.rateLimitCollection = database.initRateLimitCollection(ctx)
+	database.domainCollection = database.initDomainCollection(ctx)
+	database.brandingCollection = database.initBrandingCollection(ctx)
+	database.acmeCacheCollection = database.initACMECacheCollection(ctx)
+	database.auditCollection = database.initAuditCollection(ctx)
+	database.webhookCollection = database.initWebhookCollection(ctx)
+	database.webhookDeliveryCollection = database.initWebhookDeliveryCollection(ctx)
 
 	return database, nil
 }
 
+// newClientOptions builds the MongoDB client options of the database configuration. Credentials
+// are passed as such rather than formatted into a connection string, so they need no escaping.
+func newClientOptions(databaseConfig config.DatabaseConfig) (*options.ClientOptions, error) {
+	clientOptions := options.Client()
+	if databaseConfig.URI != "" {
+		clientOptions.ApplyURI(databaseConfig.URI)
+	} else {
+		clientOptions.SetHosts([]string{net.JoinHostPort(databaseConfig.Host, strconv.Itoa(databaseConfig.Port))})
+		if databaseConfig.Username != "" {
+			clientOptions.SetAuth(options.Credential{
+				Username:   databaseConfig.Username,
+				Password:   databaseConfig.Password,
+				AuthSource: databaseConfig.AuthSource,
+			})
+		}
+		if databaseConfig.ReplicaSet != "" {
+			clientOptions.SetReplicaSet(databaseConfig.ReplicaSet)
+		}
+	}
+
+	if databaseConfig.TLS.Enabled {
+		tlsConfig, err := newTLSConfig(databaseConfig.TLS)
+		if err != nil {
+			return nil, err
+		}
+		clientOptions.SetTLSConfig(tlsConfig)
+	}
+
+	if databaseConfig.MaxPoolSize > 0 {
+		clientOptions.SetMaxPoolSize(uint64(databaseConfig.MaxPoolSize))
+	}
+	if databaseConfig.MinPoolSize > 0 {
+		clientOptions.SetMinPoolSize(uint64(databaseConfig.MinPoolSize))
+	}
+	if databaseConfig.MaxConnIdleTime > 0 {
+		clientOptions.SetMaxConnIdleTime(databaseConfig.MaxConnIdleTime)
+	}
+	if databaseConfig.ConnectTimeout > 0 {
+		clientOptions.SetConnectTimeout(databaseConfig.ConnectTimeout)
+	}
+	if databaseConfig.ServerSelectionTimeout > 0 {
+		clientOptions.SetServerSelectionTimeout(databaseConfig.ServerSelectionTimeout)
+	}
+	if databaseConfig.Timeout > 0 {
+		clientOptions.SetTimeout(databaseConfig.Timeout)
+	}
+
+	return clientOptions, clientOptions.Validate()
+}
+
+// newTLSConfig builds the TLS configuration of database connections
+func newTLSConfig(tlsSettings config.DatabaseTLSConfig) (*tls.Config, error) {
+	tlsConfig := &tls.Config{
+		MinVersion:         tls.VersionTLS12,
+		InsecureSkipVerify: tlsSettings.InsecureSkipVerify,
+	}
+
+	if tlsSettings.CAFile != "" {
+		pem, err := os.ReadFile(tlsSettings.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("reading CA file: %w", err)
+		}
+
+		tlsConfig.RootCAs = x509.NewCertPool()
+		if !tlsConfig.RootCAs.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in CA file %s", tlsSettings.CAFile)
+		}
+	}
+
+	if tlsSettings.CertFile != "" {
+		keyFile := cmp.Or(tlsSettings.KeyFile, tlsSettings.CertFile)
+		certificate, err := tls.LoadX509KeyPair(tlsSettings.CertFile, keyFile)
+		if err != nil {
+			return nil, fmt.Errorf("loading client certificate: %w", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{certificate}
+	}
+
+	return tlsConfig, nil
+}
+
+// Migrate brings existing collections up to date with the current validation schemas.
+// Missing collections and indexes are already created by InitializeDatabase.
+func (database *Database) Migrate(ctx context.Context) error {
+	for collectionName, validator := range database.validators {
+		command := bson.D{
+			{Key: "collMod", Value: collectionName},
+			{Key: "validator", Value: validator},
+		}
+		if err := database.db.RunCommand(ctx, command).Err(); err != nil {
+			slog.Error("Failed to update collection validator", "collection", collectionName, "error", err)
+			return err
+		}
+
+		slog.Info("Collection validator updated", "collection", collectionName)
+	}
+
+	for collectionName, indexNames := range database.obsoleteIndexes {
+		for _, indexName := range indexNames {
+			err := database.db.Collection(collectionName).Indexes().DropOne(ctx, indexName)
+			if err != nil {
+				var serverErr mongo.ServerError
+				if errors.As(err, &serverErr) && serverErr.HasErrorCode(indexNotFoundErrorCode) {
+					continue
+				}
+
+				slog.Error("Failed to drop obsolete index", "collection", collectionName, "index", indexName, "error", err)
+				return err
+			}
+
+			slog.Info("Obsolete index dropped", "collection", collectionName, "index", indexName)
+		}
+	}
+
+	return nil
+}
+
 func (database *Database) createCollection(ctx context.Context, collectionName string, validator bson.M) {
+	database.validators[collectionName] = validator
+
 	// If collection exists, skip creation
 	collections, _ := database.db.ListCollectionNames(ctx, bson.M{"name": collectionName})
 	if len(collections) > 0 {
@@ -0,0 +1,190 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"github.com/aarondever/linko/internal/models"
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+	"log/slog"
+	"time"
+)
+
+const domainCollectionName = "domains"
+
+// CreateDomain inserts a domain. Several workspaces may register a hostname until one of them
+// verifies it, but registering a hostname twice in a workspace fails with a duplicate key error.
+func (database *Database) CreateDomain(ctx context.Context, params models.Domain) (*models.Domain, error) {
+	params.CreatedAt = time.Now()
+
+	result, err := database.domainCollection.InsertOne(ctx, params)
+	if err != nil {
+		// Taken hostnames are reported to the caller, not logged
+		if !mongo.IsDuplicateKeyError(err) {
+			slog.ErrorContext(ctx, "Failed insert domain", "error", err)
+		}
+		return nil, err
+	}
+
+	params.ID = result.InsertedID.(bson.ObjectID)
+	return &params, nil
+}
+
+// GetDomain returns the domain of a workspace with the given hostname, or nil if the workspace
+// has not registered it
+func (database *Database) GetDomain(ctx context.Context, workspace, hostname string) (*models.Domain, error) {
+	return database.findDomain(ctx, workspaceDomainFilter(workspace, hostname))
+}
+
+// GetVerifiedDomain returns the verified domain with the given hostname, whichever workspace it
+// belongs to, or nil if no workspace has verified it
+func (database *Database) GetVerifiedDomain(ctx context.Context, hostname string) (*models.Domain, error) {
+	return database.findDomain(ctx, bson.M{"hostname": hostname, "verified_at": bson.M{"$exists": true}})
+}
+
+func (database *Database) findDomain(ctx context.Context, filter bson.M) (*models.Domain, error) {
+	var domain models.Domain
+	if err := database.domainCollection.FindOne(ctx, filter).Decode(&domain); err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, nil
+		}
+
+		slog.ErrorContext(ctx, "Failed find domain", "error", err)
+		return nil, err
+	}
+
+	return &domain, nil
+}
+
+// ListDomains returns the domains of a workspace in alphabetical order
+func (database *Database) ListDomains(ctx context.Context, workspace string) ([]models.Domain, error) {
+	opts := options.Find().SetSort(bson.D{{Key: "hostname", Value: 1}})
+	cursor, err := database.domainCollection.Find(ctx, bson.M{"workspace": absentIfEmpty(workspace)}, opts)
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed find domains", "error", err)
+		return nil, err
+	}
+
+	domains := []models.Domain{}
+	if err = cursor.All(ctx, &domains); err != nil {
+		slog.ErrorContext(ctx, "Failed decode domains", "error", err)
+		return nil, err
+	}
+
+	return domains, nil
+}
+
+// MarkDomainVerified records when a domain of a workspace was verified and returns it, or nil if
+// it does not exist. Only one workspace can verify a hostname, so verifying a hostname verified
+// by another workspace fails with a duplicate key error.
+func (database *Database) MarkDomainVerified(ctx context.Context, workspace, hostname string) (*models.Domain, error) {
+	update := bson.M{"$set": bson.M{"verified_at": time.Now()}}
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+
+	var domain models.Domain
+	err := database.domainCollection.FindOneAndUpdate(ctx, workspaceDomainFilter(workspace, hostname), update, opts).Decode(&domain)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, nil
+		}
+
+		// Hostnames verified by another workspace are reported to the caller, not logged
+		if !mongo.IsDuplicateKeyError(err) {
+			slog.ErrorContext(ctx, "Failed verify domain", "error", err)
+		}
+		return nil, err
+	}
+
+	return &domain, nil
+}
+
+// DeleteUnverifiedDomains deletes the unverified registrations of a hostname, once a workspace
+// has verified it, and returns how many were deleted
+func (database *Database) DeleteUnverifiedDomains(ctx context.Context, hostname string) (int64, error) {
+	filter := bson.M{"hostname": hostname, "verified_at": bson.M{"$exists": false}}
+	result, err := database.domainCollection.DeleteMany(ctx, filter)
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed delete unverified domains", "error", err)
+		return 0, err
+	}
+
+	return result.DeletedCount, nil
+}
+
+// DeleteDomain deletes a domain of a workspace and reports whether it existed
+func (database *Database) DeleteDomain(ctx context.Context, workspace, hostname string) (bool, error) {
+	result, err := database.domainCollection.DeleteOne(ctx, workspaceDomainFilter(workspace, hostname))
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed delete domain", "error", err)
+		return false, err
+	}
+
+	return result.DeletedCount > 0, nil
+}
+
+// workspaceDomainFilter matches the registration of a hostname by a workspace
+func workspaceDomainFilter(workspace, hostname string) bson.M {
+	return bson.M{"hostname": hostname, "workspace": absentIfEmpty(workspace)}
+}
+
+func (database *Database) initDomainCollection(ctx context.Context) *mongo.Collection {
+	database.createCollection(ctx, domainCollectionName, bson.M{
+		"$jsonSchema": bson.M{
+			"bsonType": "object",
+			"required": []string{"hostname", "verification_token", "created_at"},
+			"properties": bson.M{
+				"hostname": bson.M{
+					"bsonType":    "string",
+					"description": "lower case fully qualified host name of the domain",
+				},
+				"workspace": bson.M{
+					"bsonType":    "string",
+					"description": "workspace owning the domain",
+				},
+				"verification_token": bson.M{
+					"bsonType":    "string",
+					"description": "token expected in the verification TXT record",
+				},
+				"created_at": bson.M{
+					"bsonType":    "date",
+					"description": "timestamp when the domain was registered",
+				},
+				"verified_at": bson.M{
+					"bsonType":    "date",
+					"description": "timestamp when domain ownership was verified",
+				},
+			},
+		},
+	})
+
+	collection := database.db.Collection(domainCollectionName)
+
+	database.createIndexes(ctx, collection, []mongo.IndexModel{
+		// Index on hostname and workspace for finding the domains of a workspace; a workspace
+		// registers a hostname at most once
+		{
+			Keys:    bson.D{{Key: "hostname", Value: 1}, {Key: "workspace", Value: 1}},
+			Options: options.Index().SetUnique(true).SetName("hostname_workspace_unique"),
+		},
+		// Partial index on hostname for routing requests by host; only one workspace can verify
+		// a hostname
+		{
+			Keys: bson.D{{Key: "hostname", Value: 1}},
+			Options: options.Index().
+				SetUnique(true).
+				SetPartialFilterExpression(bson.M{"verified_at": bson.M{"$exists": true}}).
+				SetName("hostname_verified_unique"),
+		},
+		// Index on workspace for listing the domains of a workspace
+		{
+			Keys:    bson.D{{Key: "workspace", Value: 1}},
+			Options: options.Index().SetName("workspace_asc"),
+		},
+	})
+
+	// Hostnames used to be unique across workspaces, verified or not
+	database.obsoleteIndexes[domainCollectionName] = []string{"hostname_unique"}
+
+	return collection
+}
@@ -0,0 +1,76 @@
+package database
+
+import (
+	"context"
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+	"log/slog"
+	"time"
+)
+
+const rateLimitCollectionName = "rate_limits"
+
+type rateLimitBucket struct {
+	Tokens  float64 `bson:"tokens"`
+	Allowed bool    `bson:"allowed"`
+}
+
+// TakeRateLimitToken refills the token bucket of key at rate tokens per second up to burst and takes
+// cost tokens if at least one is available, so a cost of 0 only checks the bucket. The update runs
+// as a single atomic pipeline using the server clock, so replicas with skewed clocks share one
+// consistent bucket. It returns the tokens left and whether a token was available.
+func (database *Database) TakeRateLimitToken(
+	ctx context.Context,
+	key string,
+	rate float64,
+	burst int,
+	cost float64,
+	ttl time.Duration,
+) (float64, bool, error) {
+	refilled := bson.M{"$min": bson.A{
+		float64(burst),
+		bson.M{"$add": bson.A{
+			bson.M{"$ifNull": bson.A{"$tokens", float64(burst)}},
+			bson.M{"$multiply": bson.A{
+				bson.M{"$subtract": bson.A{"$$NOW", bson.M{"$ifNull": bson.A{"$updated_at", "$$NOW"}}}},
+				rate / 1000,
+			}},
+		}},
+	}}
+
+	hasToken := bson.M{"$gte": bson.A{"$tokens", 1}}
+
+	update := mongo.Pipeline{
+		{{Key: "$set", Value: bson.M{"tokens": refilled, "updated_at": "$$NOW"}}},
+		{{Key: "$set", Value: bson.M{
+			"allowed":    hasToken,
+			"tokens":     bson.M{"$cond": bson.A{hasToken, bson.M{"$subtract": bson.A{"$tokens", cost}}, "$tokens"}},
+			"expires_at": bson.M{"$add": bson.A{"$$NOW", ttl.Milliseconds()}},
+		}}},
+	}
+	opts := options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After)
+
+	var bucket rateLimitBucket
+	err := database.rateLimitCollection.FindOneAndUpdate(ctx, bson.M{"_id": key}, update, opts).Decode(&bucket)
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed update rate limit bucket", "error", err)
+		return 0, false, err
+	}
+
+	return bucket.Tokens, bucket.Allowed, nil
+}
+
+func (database *Database) initRateLimitCollection(ctx context.Context) *mongo.Collection {
+	collection := database.db.Collection(rateLimitCollectionName)
+
+	database.createIndexes(ctx, collection, []mongo.IndexModel{
+		// Expire buckets that have not been used long enough to be full again
+		{
+			Keys:    bson.D{{Key: "expires_at", Value: 1}},
+			Options: options.Index().SetExpireAfterSeconds(0).SetName("expires_at_ttl"),
+		},
+	})
+
+	return collection
+}
@@ -0,0 +1,57 @@
+package database
+
+import (
+	"context"
+	"go.mongodb.org/mongo-driver/v2/event"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+	"sync"
+)
+
+var tracer = otel.Tracer("github.com/aarondever/linko/internal/database")
+
+// newTracingMonitor returns a command monitor tracing every MongoDB command as a client span
+// named after the command and collection, e.g. "find urls"
+func newTracingMonitor() *event.CommandMonitor {
+	var spans sync.Map // Request ID of each command in progress to its span
+
+	end := func(requestID int64, err error) {
+		value, ok := spans.LoadAndDelete(requestID)
+		if !ok {
+			return
+		}
+
+		span := value.(trace.Span)
+		if err != nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+		}
+		span.End()
+	}
+
+	return &event.CommandMonitor{
+		Started: func(ctx context.Context, started *event.CommandStartedEvent) {
+			name := started.CommandName
+			attributes := []attribute.KeyValue{
+				attribute.String("db.system.name", "mongodb"),
+				attribute.String("db.namespace", started.DatabaseName),
+				attribute.String("db.operation.name", started.CommandName),
+			}
+			if collection, ok := started.Command.Lookup(started.CommandName).StringValueOK(); ok {
+				name += " " + collection
+				attributes = append(attributes, attribute.String("db.collection.name", collection))
+			}
+
+			_, span := tracer.Start(ctx, name, trace.WithSpanKind(trace.SpanKindClient), trace.WithAttributes(attributes...))
+			spans.Store(started.RequestID, span)
+		},
+		Succeeded: func(_ context.Context, succeeded *event.CommandSucceededEvent) {
+			end(succeeded.RequestID, nil)
+		},
+		Failed: func(_ context.Context, failed *event.CommandFailedEvent) {
+			end(failed.RequestID, failed.Failure)
+		},
+	}
+}
@@ -13,14 +13,30 @@ import (
 
 const urlCollectionName = "urls"
 
-func (database *Database) IsURLShortCodeExists(ctx context.Context, shortCode string) (bool, error) {
+// shortCodeFilter matches the link with a short code on a custom domain, or on the default host
+// when domain is empty. Null also matches links stored without a domain field.
+func shortCodeFilter(domain, shortCode string) bson.M {
+	return bson.M{"domain": absentIfEmpty(domain), "short_code": shortCode}
+}
+
+// absentIfEmpty returns the filter value matching an optional string field, which is omitted
+// from documents when empty
+func absentIfEmpty(value string) any {
+	if value == "" {
+		return nil
+	}
+
+	return value
+}
+
+func (database *Database) IsURLShortCodeExists(ctx context.Context, domain, shortCode string) (bool, error) {
 	var existing models.URLMapping
-	if err := database.urlCollection.FindOne(ctx, bson.M{"short_code": shortCode}).Decode(&existing); err != nil {
+	if err := database.urlCollection.FindOne(ctx, shortCodeFilter(domain, shortCode)).Decode(&existing); err != nil {
 		if errors.Is(err, mongo.ErrNoDocuments) {
 			return false, nil
 		}
 
-		slog.Error("Failed checking existing short code", "error", err)
+		slog.ErrorContext(ctx, "Failed checking existing short code", "error", err)
 		return false, err
 	}
 
@@ -30,7 +46,7 @@ func (database *Database) IsURLShortCodeExists(ctx context.Context, shortCode st
 func (database *Database) GetURLMappingByID(ctx context.Context, id string) (*models.URLMapping, error) {
 	mappingID, err := bson.ObjectIDFromHex(id)
 	if err != nil {
-		slog.Error("Failed parse mapping ID", "error", err)
+		slog.ErrorContext(ctx, "Failed parse mapping ID", "error", err)
 		return nil, err
 	}
 
@@ -40,7 +56,7 @@ func (database *Database) GetURLMappingByID(ctx context.Context, id string) (*mo
 			return nil, nil
 		}
 
-		slog.Error("Failed find URL mapping", "error", err)
+		slog.ErrorContext(ctx, "Failed find URL mapping", "error", err)
 		return nil, err
 	}
 
@@ -55,27 +71,365 @@ func (database *Database) CreateURLShortCode(
 
 	result, err := database.urlCollection.InsertOne(ctx, params)
 	if err != nil {
-		slog.Error("Failed insert URL short code", "error", err)
+		slog.ErrorContext(ctx, "Failed insert URL short code", "error", err)
 		return nil, err
 	}
 
 	return database.GetURLMappingByID(ctx, result.InsertedID.(bson.ObjectID).Hex())
 }
 
-func (database *Database) GetURL(ctx context.Context, shortCode string) (string, error) {
+// CreateURLMappings inserts mappings as a single unordered batch, assigning their IDs and
+// creation time, so a failing document does not prevent the rest from being stored. Per-document failures are
+// returned keyed by their index in mappings; a non-nil error means the batch failed.
+func (database *Database) CreateURLMappings(
+	ctx context.Context,
+	mappings []models.URLMapping,
+) (map[int]mongo.WriteError, error) {
+	now := time.Now()
+	for i := range mappings {
+		mappings[i].ID = bson.NewObjectID()
+		mappings[i].CreatedAt = now
+	}
+
+	opts := options.InsertMany().SetOrdered(false)
+	_, err := database.urlCollection.InsertMany(ctx, mappings, opts)
+	if err == nil {
+		return nil, nil
+	}
+
+	var bulkErr mongo.BulkWriteException
+	if !errors.As(err, &bulkErr) || bulkErr.WriteConcernError != nil {
+		slog.ErrorContext(ctx, "Failed insert URL mappings", "error", err)
+		return nil, err
+	}
+
+	writeErrors := make(map[int]mongo.WriteError, len(bulkErr.WriteErrors))
+	for _, writeErr := range bulkErr.WriteErrors {
+		writeErrors[writeErr.Index] = writeErr.WriteError
+	}
+
+	return writeErrors, nil
+}
+
+func (database *Database) GetURL(ctx context.Context, domain, shortCode string) (string, error) {
 	var mapping models.URLMapping
-	if err := database.urlCollection.FindOne(ctx, bson.M{"short_code": shortCode}).Decode(&mapping); err != nil {
+	if err := database.urlCollection.FindOne(ctx, shortCodeFilter(domain, shortCode)).Decode(&mapping); err != nil {
 		if errors.Is(err, mongo.ErrNoDocuments) {
 			return "", nil
 		}
 
-		slog.Error("Failed find URL mapping", "error", err)
+		slog.ErrorContext(ctx, "Failed find URL mapping", "error", err)
 		return "", err
 	}
 
 	return mapping.URL, nil
 }
 
+// ListURLMappings returns mappings matching filter from newest to oldest, skipping offset and
+// returning at most limit
+func (database *Database) ListURLMappings(
+	ctx context.Context,
+	filter models.URLFilter,
+	offset, limit int64,
+) ([]models.URLMapping, error) {
+	query := bson.M{"domain": absentIfEmpty(filter.Domain)}
+	if filter.Broken {
+		query["health.broken_since"] = bson.M{"$exists": true}
+	}
+
+	opts := options.Find().
+		SetSort(bson.D{{Key: "created_at", Value: -1}}).
+		SetSkip(offset).
+		SetLimit(limit)
+
+	cursor, err := database.urlCollection.Find(ctx, query, opts)
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed find URL mappings", "error", err)
+		return nil, err
+	}
+
+	mappings := []models.URLMapping{}
+	if err = cursor.All(ctx, &mappings); err != nil {
+		slog.ErrorContext(ctx, "Failed decode URL mappings", "error", err)
+		return nil, err
+	}
+
+	return mappings, nil
+}
+
+func (database *Database) GetURLMappingByShortCode(ctx context.Context, domain, shortCode string) (*models.URLMapping, error) {
+	var mapping models.URLMapping
+	if err := database.urlCollection.FindOne(ctx, shortCodeFilter(domain, shortCode)).Decode(&mapping); err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, nil
+		}
+
+		slog.ErrorContext(ctx, "Failed find URL mapping", "error", err)
+		return nil, err
+	}
+
+	return &mapping, nil
+}
+
+// UpdateURLMapping applies update to the mapping of a short code and returns the updated
+// mapping, or nil if the short code does not exist
+func (database *Database) UpdateURLMapping(
+	ctx context.Context,
+	domain, shortCode string,
+	update bson.M,
+) (*models.URLMapping, error) {
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+
+	var mapping models.URLMapping
+	err := database.urlCollection.FindOneAndUpdate(ctx, shortCodeFilter(domain, shortCode), update, opts).Decode(&mapping)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, nil
+		}
+
+		slog.ErrorContext(ctx, "Failed update URL mapping", "error", err)
+		return nil, err
+	}
+
+	return &mapping, nil
+}
+
+// DeleteURLMapping deletes the mapping of a short code and returns it, or nil if the short code
+// does not exist
+func (database *Database) DeleteURLMapping(ctx context.Context, domain, shortCode string) (*models.URLMapping, error) {
+	var mapping models.URLMapping
+	if err := database.urlCollection.FindOneAndDelete(ctx, shortCodeFilter(domain, shortCode)).Decode(&mapping); err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, nil
+		}
+
+		slog.ErrorContext(ctx, "Failed delete URL mapping", "error", err)
+		return nil, err
+	}
+
+	return &mapping, nil
+}
+
+// RecordURLClick atomically increments the click stats of a short code and returns the
+// updated mapping, or nil if the short code does not exist or is disabled
+func (database *Database) RecordURLClick(ctx context.Context, domain, shortCode string) (*models.URLMapping, error) {
+	filter := shortCodeFilter(domain, shortCode)
+	filter["disabled_at"] = bson.M{"$exists": false}
+	update := bson.M{
+		"$inc": bson.M{"click_count": int64(1)},
+		"$set": bson.M{"last_clicked_at": time.Now()},
+	}
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+
+	var mapping models.URLMapping
+	err := database.urlCollection.FindOneAndUpdate(ctx, filter, update, opts).Decode(&mapping)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, nil
+		}
+
+		slog.ErrorContext(ctx, "Failed record URL click", "error", err)
+		return nil, err
+	}
+
+	return &mapping, nil
+}
+
+// DisableURLMapping disables an enabled short code, recording why. It reports whether the
+// short code was enabled before.
+func (database *Database) DisableURLMapping(ctx context.Context, domain, shortCode, reason string) (bool, error) {
+	filter := shortCodeFilter(domain, shortCode)
+	filter["disabled_at"] = bson.M{"$exists": false}
+	update := bson.M{"$set": bson.M{"disabled_at": time.Now(), "disabled_reason": reason}}
+
+	result, err := database.urlCollection.UpdateOne(ctx, filter, update)
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed disable URL mapping", "error", err)
+		return false, err
+	}
+
+	return result.ModifiedCount > 0, nil
+}
+
+// UpdateURLMetadata stores the metadata fetched for a short code, unless its destination
+// changed since the fetch started
+func (database *Database) UpdateURLMetadata(
+	ctx context.Context,
+	domain, shortCode, url string,
+	metadata models.LinkMetadata,
+) error {
+	filter := shortCodeFilter(domain, shortCode)
+	filter["url"] = url
+	if _, err := database.urlCollection.UpdateOne(ctx, filter, bson.M{"$set": bson.M{"metadata": metadata}}); err != nil {
+		slog.ErrorContext(ctx, "Failed update URL metadata", "error", err)
+		return err
+	}
+
+	return nil
+}
+
+// ClaimURLMappingForHealthCheck returns the enabled mapping most overdue for a health check and
+// postpones its next check by lease, so other replicas do not check it concurrently. It returns
+// nil when no check is due.
+func (database *Database) ClaimURLMappingForHealthCheck(ctx context.Context, lease time.Duration) (*models.URLMapping, error) {
+	now := time.Now()
+	filter := bson.M{
+		"disabled_at": bson.M{"$exists": false},
+		"$or": bson.A{
+			bson.M{"next_health_check_at": bson.M{"$exists": false}},
+			bson.M{"next_health_check_at": bson.M{"$lte": now}},
+		},
+	}
+	update := bson.M{"$set": bson.M{"next_health_check_at": now.Add(lease)}}
+	opts := options.FindOneAndUpdate().SetSort(bson.D{{Key: "next_health_check_at", Value: 1}})
+
+	var mapping models.URLMapping
+	if err := database.urlCollection.FindOneAndUpdate(ctx, filter, update, opts).Decode(&mapping); err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, nil
+		}
+
+		slog.ErrorContext(ctx, "Failed claim URL mapping for health check", "error", err)
+		return nil, err
+	}
+
+	return &mapping, nil
+}
+
+// UpdateURLHealth records the health of a short code's destination and when to check it next
+func (database *Database) UpdateURLHealth(
+	ctx context.Context,
+	domain, shortCode string,
+	health models.LinkHealth,
+	nextCheckAt time.Time,
+) error {
+	update := bson.M{"$set": bson.M{"health": health, "next_health_check_at": nextCheckAt}}
+	if _, err := database.urlCollection.UpdateOne(ctx, shortCodeFilter(domain, shortCode), update); err != nil {
+		slog.ErrorContext(ctx, "Failed update URL health", "error", err)
+		return err
+	}
+
+	return nil
+}
+
+// HasURLMappingsOnDomain reports whether any link is served from a custom domain
+func (database *Database) HasURLMappingsOnDomain(ctx context.Context, hostname string) (bool, error) {
+	count, err := database.urlCollection.CountDocuments(ctx, bson.M{"domain": hostname}, options.Count().SetLimit(1))
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed count URL mappings on domain", "error", err)
+		return false, err
+	}
+
+	return count > 0, nil
+}
+
+// ForEachURLMapping streams URL mappings in creation order to fn, stopping at the first error.
+// Unless domains is nil, only the mappings on the given domains are streamed, with the empty
+// string selecting the default host.
+func (database *Database) ForEachURLMapping(
+	ctx context.Context,
+	domains []string,
+	fn func(mapping models.URLMapping) error,
+) error {
+	query := bson.M{}
+	if domains != nil {
+		values := make([]any, len(domains))
+		for i, domain := range domains {
+			values[i] = absentIfEmpty(domain)
+		}
+		query["domain"] = bson.M{"$in": values}
+	}
+
+	opts := options.Find().SetSort(bson.D{{Key: "created_at", Value: 1}})
+	cursor, err := database.urlCollection.Find(ctx, query, opts)
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed find URL mappings", "error", err)
+		return err
+	}
+	defer cursor.Close(ctx)
+
+	for cursor.Next(ctx) {
+		var mapping models.URLMapping
+		if err = cursor.Decode(&mapping); err != nil {
+			slog.ErrorContext(ctx, "Failed decode URL mapping", "error", err)
+			return err
+		}
+
+		if err = fn(mapping); err != nil {
+			return err
+		}
+	}
+
+	if err = cursor.Err(); err != nil {
+		slog.ErrorContext(ctx, "Failed iterate URL mappings", "error", err)
+		return err
+	}
+
+	return nil
+}
+
+// GetURLMappingsByShortCodes returns the existing mappings on a domain for the given short codes
+// keyed by short code
+func (database *Database) GetURLMappingsByShortCodes(
+	ctx context.Context,
+	domain string,
+	shortCodes []string,
+) (map[string]models.URLMapping, error) {
+	filter := bson.M{"domain": absentIfEmpty(domain), "short_code": bson.M{"$in": shortCodes}}
+	cursor, err := database.urlCollection.Find(ctx, filter)
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed find URL mappings", "error", err)
+		return nil, err
+	}
+
+	var mappings []models.URLMapping
+	if err = cursor.All(ctx, &mappings); err != nil {
+		slog.ErrorContext(ctx, "Failed decode URL mappings", "error", err)
+		return nil, err
+	}
+
+	result := make(map[string]models.URLMapping, len(mappings))
+	for _, mapping := range mappings {
+		result[mapping.ShortCode] = mapping
+	}
+
+	return result, nil
+}
+
+// UpsertURLMappings creates or overwrites mappings by domain and short code, keeping their given
+// created_at and click stats. Created mappings get their given ID, if any. Writes are applied in
+// order so repeated short codes resolve to the last mapping.
+func (database *Database) UpsertURLMappings(ctx context.Context, mappings []models.URLMapping) error {
+	writes := make([]mongo.WriteModel, len(mappings))
+	for i, mapping := range mappings {
+		set := bson.M{
+			"url":         mapping.URL,
+			"created_at":  mapping.CreatedAt,
+			"click_count": mapping.ClickCount,
+		}
+		if mapping.LastClickedAt != nil {
+			set["last_clicked_at"] = *mapping.LastClickedAt
+		}
+
+		update := bson.M{"$set": set}
+		if !mapping.ID.IsZero() {
+			update["$setOnInsert"] = bson.M{"_id": mapping.ID}
+		}
+
+		writes[i] = mongo.NewUpdateOneModel().
+			SetFilter(shortCodeFilter(mapping.Domain, mapping.ShortCode)).
+			SetUpdate(update).
+			SetUpsert(true)
+	}
+
+	if _, err := database.urlCollection.BulkWrite(ctx, writes); err != nil {
+		slog.ErrorContext(ctx, "Failed upsert URL mappings", "error", err)
+		return err
+	}
+
+	return nil
+}
+
 func (database *Database) initURLCollection(ctx context.Context) *mongo.Collection {
 	database.createCollection(ctx, urlCollectionName, bson.M{
 		"$jsonSchema": bson.M{
@@ -84,8 +438,12 @@ func (database *Database) initURLCollection(ctx context.Context) *mongo.Collecti
 			"properties": bson.M{
 				"short_code": bson.M{
 					"bsonType":    "string",
-					"pattern":     "^[a-zA-Z0-9]{8}$",
-					"description": "must be a string of exactly 8 alphanumeric characters",
+					"pattern":     models.ShortCodePattern,
+					"description": "must be a string of 1 to 64 letters, digits, hyphens or underscores",
+				},
+				"domain": bson.M{
+					"bsonType":    "string",
+					"description": "custom domain the link is served from",
 				},
 				"url": bson.M{
 					"bsonType":    "string",
@@ -96,6 +454,38 @@ func (database *Database) initURLCollection(ctx context.Context) *mongo.Collecti
 					"bsonType":    "date",
 					"description": "timestamp when the URL was shortened",
 				},
+				"click_count": bson.M{
+					"bsonType":    []string{"int", "long"},
+					"description": "number of times the short link was followed",
+				},
+				"last_clicked_at": bson.M{
+					"bsonType":    "date",
+					"description": "timestamp of the most recent click",
+				},
+				"disabled_at": bson.M{
+					"bsonType":    "date",
+					"description": "timestamp when the link was disabled",
+				},
+				"disabled_reason": bson.M{
+					"bsonType":    "string",
+					"description": "why the link was disabled",
+				},
+				"social_preview": bson.M{
+					"bsonType":    "object",
+					"description": "Open Graph override served to crawlers",
+				},
+				"metadata": bson.M{
+					"bsonType":    "object",
+					"description": "title, description and Open Graph details of the destination page",
+				},
+				"health": bson.M{
+					"bsonType":    "object",
+					"description": "outcome of the latest destination health check",
+				},
+				"next_health_check_at": bson.M{
+					"bsonType":    "date",
+					"description": "timestamp when the destination is checked next",
+				},
 			},
 		},
 	})
@@ -108,12 +498,25 @@ func (database *Database) initURLCollection(ctx context.Context) *mongo.Collecti
 			Keys:    bson.D{{Key: "created_at", Value: -1}},
 			Options: options.Index().SetName("created_at_desc"),
 		},
-		// Index on short_code for finding url by code
+		// Index on domain and short_code for finding url by code; codes are unique per domain
 		{
-			Keys:    bson.D{{Key: "short_code", Value: 1}},
-			Options: options.Index().SetUnique(true).SetName("short_code_unique"),
+			Keys:    bson.D{{Key: "domain", Value: 1}, {Key: "short_code", Value: 1}},
+			Options: options.Index().SetUnique(true).SetName("domain_short_code_unique"),
+		},
+		// Index on next_health_check_at for finding links due for a health check
+		{
+			Keys:    bson.D{{Key: "next_health_check_at", Value: 1}},
+			Options: options.Index().SetName("next_health_check_at_asc"),
+		},
+		// Sparse index on health.broken_since for listing broken links
+		{
+			Keys:    bson.D{{Key: "health.broken_since", Value: 1}},
+			Options: options.Index().SetSparse(true).SetName("health_broken_since_sparse"),
 		},
 	})
 
+	// Short codes used to be unique across all domains
+	database.obsoleteIndexes[urlCollectionName] = []string{"short_code_unique"}
+
 	return collection
 }
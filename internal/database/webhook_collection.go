@@ -0,0 +1,142 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"github.com/aarondever/linko/internal/models"
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+	"log/slog"
+	"time"
+)
+
+const webhookCollectionName = "webhooks"
+
+func (database *Database) CreateWebhook(ctx context.Context, params models.Webhook) (*models.Webhook, error) {
+	params.CreatedAt = time.Now()
+
+	result, err := database.webhookCollection.InsertOne(ctx, params)
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed insert webhook", "error", err)
+		return nil, err
+	}
+
+	params.ID = result.InsertedID.(bson.ObjectID)
+	return &params, nil
+}
+
+// GetWebhook returns the webhook of a workspace with the given ID, or nil if it does not exist
+func (database *Database) GetWebhook(ctx context.Context, workspace string, id bson.ObjectID) (*models.Webhook, error) {
+	var webhook models.Webhook
+	filter := bson.M{"_id": id, "workspace": absentIfEmpty(workspace)}
+	if err := database.webhookCollection.FindOne(ctx, filter).Decode(&webhook); err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, nil
+		}
+
+		slog.ErrorContext(ctx, "Failed find webhook", "error", err)
+		return nil, err
+	}
+
+	return &webhook, nil
+}
+
+// ListWebhooks returns the webhooks of a workspace from newest to oldest
+func (database *Database) ListWebhooks(ctx context.Context, workspace string) ([]models.Webhook, error) {
+	return database.findWebhooks(ctx, bson.M{"workspace": absentIfEmpty(workspace)})
+}
+
+// ListAllWebhooks returns the webhooks of every workspace
+func (database *Database) ListAllWebhooks(ctx context.Context) ([]models.Webhook, error) {
+	return database.findWebhooks(ctx, bson.M{})
+}
+
+func (database *Database) findWebhooks(ctx context.Context, filter bson.M) ([]models.Webhook, error) {
+	opts := options.Find().SetSort(bson.D{{Key: "created_at", Value: -1}})
+	cursor, err := database.webhookCollection.Find(ctx, filter, opts)
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed find webhooks", "error", err)
+		return nil, err
+	}
+
+	webhooks := []models.Webhook{}
+	if err = cursor.All(ctx, &webhooks); err != nil {
+		slog.ErrorContext(ctx, "Failed decode webhooks", "error", err)
+		return nil, err
+	}
+
+	return webhooks, nil
+}
+
+// DeleteWebhook deletes a webhook of a workspace together with its deliveries and returns it,
+// or nil if it does not exist
+func (database *Database) DeleteWebhook(ctx context.Context, workspace string, id bson.ObjectID) (*models.Webhook, error) {
+	var webhook models.Webhook
+	filter := bson.M{"_id": id, "workspace": absentIfEmpty(workspace)}
+	if err := database.webhookCollection.FindOneAndDelete(ctx, filter).Decode(&webhook); err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, nil
+		}
+
+		slog.ErrorContext(ctx, "Failed delete webhook", "error", err)
+		return nil, err
+	}
+
+	if _, err := database.webhookDeliveryCollection.DeleteMany(ctx, bson.M{"webhook_id": id}); err != nil {
+		slog.ErrorContext(ctx, "Failed delete webhook deliveries", "error", err)
+		return nil, err
+	}
+
+	return &webhook, nil
+}
+
+func (database *Database) initWebhookCollection(ctx context.Context) *mongo.Collection {
+	database.createCollection(ctx, webhookCollectionName, bson.M{
+		"$jsonSchema": bson.M{
+			"bsonType": "object",
+			"required": []string{"url", "events", "secret", "created_at"},
+			"properties": bson.M{
+				"workspace": bson.M{
+					"bsonType":    "string",
+					"description": "workspace owning the webhook",
+				},
+				"url": bson.M{
+					"bsonType":    "string",
+					"pattern":     "^https?://",
+					"description": "endpoint receiving the events",
+				},
+				"events": bson.M{
+					"bsonType":    "array",
+					"minItems":    1,
+					"items":       bson.M{"bsonType": "string"},
+					"description": "names of the subscribed link events",
+				},
+				"description": bson.M{
+					"bsonType":    "string",
+					"description": "human readable description of the webhook",
+				},
+				"secret": bson.M{
+					"bsonType":    "string",
+					"description": "secret signing the deliveries",
+				},
+				"created_at": bson.M{
+					"bsonType":    "date",
+					"description": "timestamp when the webhook was registered",
+				},
+			},
+		},
+	})
+
+	collection := database.db.Collection(webhookCollectionName)
+
+	database.createIndexes(ctx, collection, []mongo.IndexModel{
+		// Index on workspace for listing the webhooks of a workspace
+		{
+			Keys:    bson.D{{Key: "workspace", Value: 1}, {Key: "created_at", Value: -1}},
+			Options: options.Index().SetName("workspace_asc_created_at_desc"),
+		},
+	})
+
+	return collection
+}
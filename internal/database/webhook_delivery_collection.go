@@ -0,0 +1,201 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"github.com/aarondever/linko/internal/models"
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+	"log/slog"
+	"time"
+)
+
+const webhookDeliveryCollectionName = "webhook_deliveries"
+
+// InsertWebhookDeliveries stores new deliveries, assigning their IDs
+func (database *Database) InsertWebhookDeliveries(ctx context.Context, deliveries []models.WebhookDelivery) error {
+	for i := range deliveries {
+		deliveries[i].ID = bson.NewObjectID()
+	}
+
+	if _, err := database.webhookDeliveryCollection.InsertMany(ctx, deliveries); err != nil {
+		slog.ErrorContext(ctx, "Failed insert webhook deliveries", "error", err)
+		return err
+	}
+
+	return nil
+}
+
+// ClaimWebhookDelivery returns the pending delivery most overdue for an attempt and postpones
+// its next attempt by lease, so other replicas do not send it concurrently. It returns nil when
+// no delivery is due.
+func (database *Database) ClaimWebhookDelivery(ctx context.Context, lease time.Duration) (*models.WebhookDelivery, error) {
+	now := time.Now()
+	filter := bson.M{
+		"status":          models.WebhookDeliveryPending,
+		"next_attempt_at": bson.M{"$lte": now},
+	}
+	update := bson.M{"$set": bson.M{"next_attempt_at": now.Add(lease)}}
+	opts := options.FindOneAndUpdate().SetSort(bson.D{{Key: "next_attempt_at", Value: 1}})
+
+	var delivery models.WebhookDelivery
+	if err := database.webhookDeliveryCollection.FindOneAndUpdate(ctx, filter, update, opts).Decode(&delivery); err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, nil
+		}
+
+		slog.ErrorContext(ctx, "Failed claim webhook delivery", "error", err)
+		return nil, err
+	}
+
+	return &delivery, nil
+}
+
+// RecordWebhookAttempt stores the outcome of an attempt and the resulting state of a delivery.
+// The next attempt is scheduled at nextAttemptAt for pending deliveries and unset otherwise.
+func (database *Database) RecordWebhookAttempt(
+	ctx context.Context,
+	id bson.ObjectID,
+	attempt models.WebhookAttempt,
+	status string,
+	nextAttemptAt time.Time,
+) error {
+	set := bson.M{"status": status, "last_attempt": attempt}
+	update := bson.M{"$set": set, "$inc": bson.M{"attempts": 1}}
+	if status == models.WebhookDeliveryPending {
+		set["next_attempt_at"] = nextAttemptAt
+	} else {
+		set["completed_at"] = attempt.At
+		update["$unset"] = bson.M{"next_attempt_at": ""}
+	}
+
+	if _, err := database.webhookDeliveryCollection.UpdateByID(ctx, id, update); err != nil {
+		slog.ErrorContext(ctx, "Failed update webhook delivery", "error", err)
+		return err
+	}
+
+	return nil
+}
+
+// GetWebhookDelivery returns a delivery of a webhook, or nil if it does not exist
+func (database *Database) GetWebhookDelivery(ctx context.Context, webhookID, id bson.ObjectID) (*models.WebhookDelivery, error) {
+	var delivery models.WebhookDelivery
+	filter := bson.M{"_id": id, "webhook_id": webhookID}
+	if err := database.webhookDeliveryCollection.FindOne(ctx, filter).Decode(&delivery); err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, nil
+		}
+
+		slog.ErrorContext(ctx, "Failed find webhook delivery", "error", err)
+		return nil, err
+	}
+
+	return &delivery, nil
+}
+
+// ListWebhookDeliveries returns the deliveries of a webhook from newest to oldest, only those in
+// the given status unless it is empty, skipping offset and returning at most limit
+func (database *Database) ListWebhookDeliveries(
+	ctx context.Context,
+	webhookID bson.ObjectID,
+	status string,
+	offset, limit int64,
+) ([]models.WebhookDelivery, error) {
+	filter := bson.M{"webhook_id": webhookID}
+	if status != "" {
+		filter["status"] = status
+	}
+
+	opts := options.Find().
+		SetSort(bson.D{{Key: "created_at", Value: -1}, {Key: "_id", Value: -1}}).
+		SetSkip(offset).
+		SetLimit(limit)
+
+	cursor, err := database.webhookDeliveryCollection.Find(ctx, filter, opts)
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed find webhook deliveries", "error", err)
+		return nil, err
+	}
+
+	deliveries := []models.WebhookDelivery{}
+	if err = cursor.All(ctx, &deliveries); err != nil {
+		slog.ErrorContext(ctx, "Failed decode webhook deliveries", "error", err)
+		return nil, err
+	}
+
+	return deliveries, nil
+}
+
+func (database *Database) initWebhookDeliveryCollection(ctx context.Context) *mongo.Collection {
+	database.createCollection(ctx, webhookDeliveryCollectionName, bson.M{
+		"$jsonSchema": bson.M{
+			"bsonType": "object",
+			"required": []string{"webhook_id", "event_id", "event", "payload", "status", "attempts", "created_at"},
+			"properties": bson.M{
+				"webhook_id": bson.M{
+					"bsonType":    "objectId",
+					"description": "webhook receiving the delivery",
+				},
+				"event_id": bson.M{
+					"bsonType":    "string",
+					"description": "ID of the delivered event, shared by its redeliveries",
+				},
+				"event": bson.M{
+					"bsonType":    "string",
+					"description": "name of the delivered event",
+				},
+				"payload": bson.M{
+					"bsonType":    "string",
+					"description": "JSON request body",
+				},
+				"status": bson.M{
+					"enum":        []string{models.WebhookDeliveryPending, models.WebhookDeliverySucceeded, models.WebhookDeliveryDead},
+					"description": "pending until delivered, or dead once every attempt failed",
+				},
+				"attempts": bson.M{
+					"bsonType":    []string{"int", "long"},
+					"minimum":     0,
+					"description": "number of attempts made",
+				},
+				"next_attempt_at": bson.M{
+					"bsonType":    "date",
+					"description": "timestamp of the next attempt of a pending delivery",
+				},
+				"last_attempt": bson.M{
+					"bsonType":    "object",
+					"description": "outcome of the latest attempt",
+				},
+				"redelivery_of": bson.M{
+					"bsonType":    "objectId",
+					"description": "delivery this delivery sends again",
+				},
+				"created_at": bson.M{
+					"bsonType":    "date",
+					"description": "timestamp when the delivery was created",
+				},
+				"completed_at": bson.M{
+					"bsonType":    "date",
+					"description": "timestamp when the delivery succeeded or was given up",
+				},
+			},
+		},
+	})
+
+	collection := database.db.Collection(webhookDeliveryCollectionName)
+
+	database.createIndexes(ctx, collection, []mongo.IndexModel{
+		// Index on status and next attempt for claiming due deliveries
+		{
+			Keys:    bson.D{{Key: "status", Value: 1}, {Key: "next_attempt_at", Value: 1}},
+			Options: options.Index().SetName("status_asc_next_attempt_at_asc"),
+		},
+		// Index on webhook for the delivery log of a webhook
+		{
+			Keys:    bson.D{{Key: "webhook_id", Value: 1}, {Key: "created_at", Value: -1}},
+			Options: options.Index().SetName("webhook_id_asc_created_at_desc"),
+		},
+	})
+
+	return collection
+}
@@ -0,0 +1,115 @@
+package handlers
+
+import (
+	"context"
+	"github.com/aarondever/linko/internal/config"
+	"github.com/aarondever/linko/internal/utils"
+	"github.com/go-chi/chi/v5"
+	chimiddleware "github.com/go-chi/chi/v5/middleware"
+	"log/slog"
+	"math/rand/v2"
+	"net/http"
+	"net/netip"
+	"sync/atomic"
+	"time"
+)
+
+type accessLogContextKey struct{}
+
+// accessLogEntry collects what handlers further down the chain learn about a request, like the
+// API key that authenticated it, for the access log written once the response is sent
+type accessLogEntry struct {
+	apiKeyID string
+}
+
+// AccessLogMiddleware writes one structured log record per request with the method, route
+// pattern, status, response size, latency, client IP and API key of the request. The request ID
+// and trace of the request are added by the log handler.
+type AccessLogMiddleware struct {
+	redirectRoute string
+	settings      atomic.Pointer[accessLogSettings]
+}
+
+// accessLogSettings is the access log configuration of the middleware, replaced as a whole on reload
+type accessLogSettings struct {
+	config         config.LoggingConfig
+	trustedProxies []netip.Prefix
+}
+
+// NewAccessLogMiddleware returns the access log middleware sampling the successful redirects
+// served by the route with the pattern redirectRoute
+func NewAccessLogMiddleware(cfg *config.Config, redirectRoute string) *AccessLogMiddleware {
+	middleware := &AccessLogMiddleware{redirectRoute: redirectRoute}
+	middleware.Reload(cfg)
+
+	return middleware
+}
+
+// Reload switches to the access log settings and trusted proxies of cfg
+func (middleware *AccessLogMiddleware) Reload(cfg *config.Config) {
+	middleware.settings.Store(&accessLogSettings{
+		config:         cfg.Logging,
+		trustedProxies: utils.ParseTrustedProxies(cfg.RateLimit.TrustedProxies),
+	})
+}
+
+// Log logs every request once it has been served. Successful short link redirects, the bulk of
+// the traffic, are sampled at the configured rate; errors are always logged.
+func (middleware *AccessLogMiddleware) Log(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(responseWriter http.ResponseWriter, request *http.Request) {
+		settings := middleware.settings.Load()
+		if !settings.config.AccessLog {
+			next.ServeHTTP(responseWriter, request)
+			return
+		}
+
+		start := time.Now()
+		entry := &accessLogEntry{}
+		ctx := context.WithValue(request.Context(), accessLogContextKey{}, entry)
+		wrapped := chimiddleware.NewWrapResponseWriter(responseWriter, request.ProtoMajor)
+		defer func() {
+			status := wrapped.Status()
+			if status == 0 {
+				status = http.StatusOK
+			}
+
+			var route string
+			if routeContext := chi.RouteContext(ctx); routeContext != nil {
+				route = routeContext.RoutePattern()
+			}
+
+			if route == middleware.redirectRoute && isRedirectStatus(status) &&
+				rand.Float64() >= settings.config.RedirectSampleRate {
+				return
+			}
+
+			level := slog.LevelInfo
+			if status >= http.StatusInternalServerError {
+				level = slog.LevelError
+			}
+
+			slog.LogAttrs(ctx, level, "HTTP request",
+				slog.String("method", request.Method),
+				slog.String("route", route),
+				slog.String("path", request.URL.Path),
+				slog.Int("status", status),
+				slog.Int("bytes", wrapped.BytesWritten()),
+				slog.Duration("latency", time.Since(start)),
+				slog.String("client_ip", utils.ClientIP(request, settings.trustedProxies)),
+				slog.String("api_key_id", entry.apiKeyID))
+		}()
+
+		next.ServeHTTP(wrapped, request.WithContext(ctx))
+	})
+}
+
+// recordAPIKey notes the API key that authenticated the request in its access log entry
+func recordAPIKey(ctx context.Context, apiKeyID string) {
+	if entry, ok := ctx.Value(accessLogContextKey{}).(*accessLogEntry); ok {
+		entry.apiKeyID = apiKeyID
+	}
+}
+
+func isRedirectStatus(status int) bool {
+	return status >= http.StatusMultipleChoices && status < http.StatusBadRequest
+}
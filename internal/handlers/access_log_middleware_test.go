@@ -0,0 +1,150 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"github.com/aarondever/linko/internal/config"
+	"github.com/aarondever/linko/internal/logging"
+	"github.com/go-chi/chi/v5"
+	chimiddleware "github.com/go-chi/chi/v5/middleware"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestAccessLogMiddleware(t *testing.T) {
+	tests := []struct {
+		name               string
+		path               string
+		forwardedFor       string
+		accessLog          bool
+		redirectSampleRate float64
+		fields             map[string]any // Expected fields, nil when nothing is logged
+	}{
+		{
+			name:               "authenticated API request",
+			path:               "/api/v1/urls/abc123",
+			accessLog:          true,
+			redirectSampleRate: 1,
+			fields: map[string]any{
+				"level":      "INFO",
+				"msg":        "HTTP request",
+				"method":     "GET",
+				"route":      "/api/v1/urls/{shortCode}",
+				"path":       "/api/v1/urls/abc123",
+				"status":     float64(http.StatusOK),
+				"bytes":      float64(len(`{"ok":true}`)),
+				"client_ip":  "192.0.2.1",
+				"api_key_id": "key-1",
+			},
+		},
+		{
+			name:               "client IP forwarded by a trusted proxy",
+			path:               "/api/v1/urls/abc123",
+			forwardedFor:       "203.0.113.7",
+			accessLog:          true,
+			redirectSampleRate: 1,
+			fields:             map[string]any{"client_ip": "203.0.113.7"},
+		},
+		{
+			name:               "server error",
+			path:               "/fail",
+			accessLog:          true,
+			redirectSampleRate: 0,
+			fields:             map[string]any{"level": "ERROR", "status": float64(http.StatusInternalServerError), "api_key_id": ""},
+		},
+		{
+			name:               "sampled redirect",
+			path:               "/r/abc123",
+			accessLog:          true,
+			redirectSampleRate: 1,
+			fields:             map[string]any{"route": "/r/{shortCode}", "status": float64(http.StatusFound)},
+		},
+		{
+			name:               "redirect dropped by sampling",
+			path:               "/r/abc123",
+			accessLog:          true,
+			redirectSampleRate: 0,
+		},
+		{
+			name:               "missing short link is logged despite sampling",
+			path:               "/r/missing",
+			accessLog:          true,
+			redirectSampleRate: 0,
+			fields:             map[string]any{"route": "/r/{shortCode}", "status": float64(http.StatusNotFound)},
+		},
+		{
+			name:               "access log disabled",
+			path:               "/api/v1/urls/abc123",
+			redirectSampleRate: 1,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var output bytes.Buffer
+			defaultLogger := slog.Default()
+			slog.SetDefault(slog.New(logging.NewHandler(slog.NewJSONHandler(&output, nil))))
+			t.Cleanup(func() { slog.SetDefault(defaultLogger) })
+
+			cfg := &config.Config{}
+			cfg.Logging.AccessLog = test.accessLog
+			cfg.Logging.RedirectSampleRate = test.redirectSampleRate
+			cfg.RateLimit.TrustedProxies = []string{"192.0.2.0/24"}
+
+			router := chi.NewRouter()
+			router.Use(chimiddleware.RequestID, NewAccessLogMiddleware(cfg, "/r/{shortCode}").Log)
+			router.Get("/r/{shortCode}", func(responseWriter http.ResponseWriter, request *http.Request) {
+				if chi.URLParam(request, "shortCode") == "missing" {
+					http.NotFound(responseWriter, request)
+					return
+				}
+				http.Redirect(responseWriter, request, "https://example.com", http.StatusFound)
+			})
+			router.Get("/api/v1/urls/{shortCode}", func(responseWriter http.ResponseWriter, request *http.Request) {
+				recordAPIKey(request.Context(), "key-1")
+				responseWriter.Write([]byte(`{"ok":true}`))
+			})
+			router.Get("/fail", func(responseWriter http.ResponseWriter, request *http.Request) {
+				responseWriter.WriteHeader(http.StatusInternalServerError)
+			})
+
+			request := httptest.NewRequest(http.MethodGet, test.path, nil)
+			request.RemoteAddr = "192.0.2.1:43210"
+			if test.forwardedFor != "" {
+				request.Header.Set("X-Forwarded-For", test.forwardedFor)
+			}
+			router.ServeHTTP(httptest.NewRecorder(), request)
+
+			if test.fields == nil {
+				if output.Len() != 0 {
+					t.Fatalf("logged %s, want nothing", output.String())
+				}
+				return
+			}
+			lines := strings.Split(strings.TrimSpace(output.String()), "\n")
+			if len(lines) != 1 {
+				t.Fatalf("logged %d records, want one:\n%s", len(lines), output.String())
+			}
+
+			var record map[string]any
+			if err := json.Unmarshal([]byte(lines[0]), &record); err != nil {
+				t.Fatalf("decoding record: %v", err)
+			}
+
+			for name, want := range test.fields {
+				if got := record[name]; got != want {
+					t.Errorf("%s = %v, want %v", name, got, want)
+				}
+			}
+			if requestID, _ := record["request_id"].(string); requestID == "" {
+				t.Error("request_id is missing")
+			}
+			if _, ok := record["latency"]; !ok {
+				t.Error("latency is missing")
+			}
+		})
+	}
+}
@@ -0,0 +1,182 @@
+package handlers
+
+import (
+	"fmt"
+	"github.com/aarondever/linko/internal/models"
+	"github.com/aarondever/linko/internal/openapi"
+	"github.com/aarondever/linko/internal/services"
+	"github.com/aarondever/linko/internal/utils"
+	"github.com/go-chi/chi/v5"
+	"log/slog"
+	"net/http"
+	"time"
+)
+
+// AuditHandler exposes the audit log of the workspace of the calling API key
+type AuditHandler struct {
+	auditService *services.AuditService
+	rateLimit    *RateLimitMiddleware
+}
+
+func NewAuditHandler(auditService *services.AuditService, rateLimit *RateLimitMiddleware) *AuditHandler {
+	return &AuditHandler{
+		auditService: auditService,
+		rateLimit:    rateLimit,
+	}
+}
+
+func (handler *AuditHandler) RegisterRoutes(router chi.Router) {
+	router.Route("/api/v1/audit", func(router chi.Router) {
+		manage := router.With(handler.rateLimit.Management)
+
+		manage.Get("/", handler.ListEntries)
+		manage.Get("/export", handler.ExportEntries)
+	})
+}
+
+// ListEntries lists audit entries from newest to oldest, filtered by the query parameters and
+// paginated by the "offset" and "limit" query parameters
+func (handler *AuditHandler) ListEntries(responseWriter http.ResponseWriter, request *http.Request) {
+	filter, err := parseAuditFilter(request)
+	if err != nil {
+		respondWithError(responseWriter, request, err)
+		return
+	}
+
+	offset, err := parseIntQuery(request, "offset", 0)
+	if err != nil || offset < 0 {
+		respondWithError(responseWriter, request, invalidQueryError("offset", "must be a non-negative integer"))
+		return
+	}
+
+	limit, err := parseIntQuery(request, "limit", defaultListLimit)
+	if err != nil || limit < 1 || limit > maxListLimit {
+		respondWithError(responseWriter, request,
+			invalidQueryError("limit", fmt.Sprintf("must be an integer between 1 and %d", maxListLimit)))
+		return
+	}
+
+	entries, err := handler.auditService.ListEntries(request.Context(), filter, offset, limit)
+	if err != nil {
+		respondWithError(responseWriter, request, err)
+		return
+	}
+
+	utils.RespondWithJSON(responseWriter, models.ListAuditEntriesResponse{
+		Entries: entries,
+		Offset:  offset,
+		Limit:   limit,
+	}, http.StatusOK)
+}
+
+// ExportEntries streams every audit entry matching the query parameters as NDJSON, from
+// oldest to newest
+func (handler *AuditHandler) ExportEntries(responseWriter http.ResponseWriter, request *http.Request) {
+	filter, err := parseAuditFilter(request)
+	if err != nil {
+		respondWithError(responseWriter, request, err)
+		return
+	}
+
+	filename := fmt.Sprintf("linko-audit-%s.ndjson", time.Now().Format("20060102-150405"))
+	responseWriter.Header().Set("Content-Type", "application/x-ndjson")
+	responseWriter.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+	responseWriter.WriteHeader(http.StatusOK)
+
+	// Headers are already sent, so failures can only be logged and surface as a truncated body
+	if err = handler.auditService.Export(request.Context(), responseWriter, filter); err != nil {
+		slog.ErrorContext(request.Context(), "Failed streaming audit export", "error", err)
+	}
+}
+
+// parseAuditFilter reads the filter query parameters of the audit routes. Entries are always
+// limited to the workspace of the calling API key.
+func parseAuditFilter(request *http.Request) (models.AuditFilter, error) {
+	query := request.URL.Query()
+	filter := models.AuditFilter{
+		Workspace:  WorkspaceFromContext(request.Context()),
+		ActorType:  query.Get("actor_type"),
+		ActorID:    query.Get("actor_id"),
+		Action:     query.Get("action"),
+		TargetType: query.Get("target_type"),
+		TargetID:   query.Get("target_id"),
+	}
+
+	var err error
+	if filter.Since, err = parseTimeQuery(request, "since"); err != nil {
+		return filter, invalidQueryError("since", "must be an RFC 3339 timestamp")
+	}
+	if filter.Until, err = parseTimeQuery(request, "until"); err != nil {
+		return filter, invalidQueryError("until", "must be an RFC 3339 timestamp")
+	}
+
+	return filter, nil
+}
+
+// parseTimeQuery parses an optional RFC 3339 timestamp query parameter, returning nil when absent
+func parseTimeQuery(request *http.Request, name string) (*time.Time, error) {
+	value := request.URL.Query().Get(name)
+	if value == "" {
+		return nil, nil
+	}
+
+	parsed, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return nil, err
+	}
+
+	return &parsed, nil
+}
+
+// DescribeRoutes documents the routes registered by RegisterRoutes
+func (handler *AuditHandler) DescribeRoutes(document *openapi.Document) {
+	filterParams := []openapi.Parameter{
+		openapi.QueryParam("actor_type", "string", "Only entries of actors of this type: api_key, anonymous, cli or system"),
+		openapi.QueryParam("actor_id", "string", "Only entries of this API key ID or OS user"),
+		openapi.QueryParam("action", "string", "Only entries of this action, e.g. link.update"),
+		openapi.QueryParam("target_type", "string", "Only entries changing targets of this type: link, api_key, domain, branding, webhook or config"),
+		openapi.QueryParam("target_id", "string", "Only entries changing this target, e.g. a short code prefixed with its custom domain"),
+		{Name: "since", In: "query", Description: "Only entries at or after this time", Schema: &openapi.Schema{Type: "string", Format: "date-time"}},
+		{Name: "until", In: "query", Description: "Only entries before this time", Schema: &openapi.Schema{Type: "string", Format: "date-time"}},
+	}
+	invalidParams := errorResponse(document, "Invalid parameters")
+	rateLimited := errorResponse(document, "Rate limit exceeded")
+	adminKeyRequired := errorResponse(document, "Admin API key required")
+
+	document.AddOperation(http.MethodGet, "/api/v1/audit", openapi.Operation{
+		OperationID: "listAuditEntries",
+		Summary:     "List audit log entries",
+		Description: "Lists the changes made to links, API keys, domains, branding, webhooks and the configuration " +
+			"in the workspace of the API key, newest first.",
+		Tags: []string{"audit"},
+		Parameters: append(filterParams,
+			openapi.QueryParam("offset", "integer", "Number of entries to skip"),
+			openapi.QueryParam("limit", "integer", fmt.Sprintf("Maximum number of entries to return (default %d, at most %d)", defaultListLimit, maxListLimit)),
+		),
+		Responses: map[string]openapi.Response{
+			openapi.Status(http.StatusOK):              openapi.JSONResponse("Audit entries", document.SchemaRef(models.ListAuditEntriesResponse{})),
+			openapi.Status(http.StatusBadRequest):      invalidParams,
+			openapi.Status(http.StatusTooManyRequests): rateLimited,
+			openapi.Status(http.StatusForbidden):       adminKeyRequired,
+		},
+	})
+
+	document.AddOperation(http.MethodGet, "/api/v1/audit/export", openapi.Operation{
+		OperationID: "exportAuditEntries",
+		Summary:     "Export audit log entries",
+		Description: "Streams every matching entry in the workspace of the API key, oldest first.",
+		Tags:        []string{"audit"},
+		Parameters:  filterParams,
+		Responses: map[string]openapi.Response{
+			openapi.Status(http.StatusOK): {
+				Description: "Stream of audit entries, one per line",
+				Content: map[string]openapi.MediaType{
+					"application/x-ndjson": {Schema: document.SchemaRef(models.AuditEntry{})},
+				},
+			},
+			openapi.Status(http.StatusBadRequest):      invalidParams,
+			openapi.Status(http.StatusTooManyRequests): rateLimited,
+			openapi.Status(http.StatusForbidden):       adminKeyRequired,
+		},
+	})
+}
@@ -0,0 +1,165 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"github.com/aarondever/linko/internal/config"
+	"github.com/aarondever/linko/internal/models"
+	"github.com/aarondever/linko/internal/services"
+	"github.com/aarondever/linko/internal/utils"
+	chimiddleware "github.com/go-chi/chi/v5/middleware"
+	"net/http"
+	"net/netip"
+	"strings"
+	"sync/atomic"
+)
+
+type apiKeyContextKey struct{}
+
+var (
+	errAPIKeyRequired   = services.NewError(services.ErrorKindUnauthorized, "api_key_required", "API key required")
+	errAdminKeyRequired = services.NewError(services.ErrorKindForbidden, "admin_key_required", "Admin API key required")
+)
+
+// AuthMiddleware authenticates API requests by the key sent in the Authorization header
+// as a bearer token or in the X-API-Key header, and attributes the audited actions of the
+// request to its key. Clients failing to authenticate too often are rejected by their IP before
+// their key is looked up.
+type AuthMiddleware struct {
+	apiKeyService  *services.APIKeyService
+	rateLimit      *RateLimitMiddleware
+	cfg            *config.Config
+	trustedProxies atomic.Pointer[[]netip.Prefix]
+}
+
+func NewAuthMiddleware(apiKeyService *services.APIKeyService, rateLimit *RateLimitMiddleware, cfg *config.Config) *AuthMiddleware {
+	middleware := &AuthMiddleware{
+		apiKeyService: apiKeyService,
+		rateLimit:     rateLimit,
+		cfg:           cfg,
+	}
+	middleware.Reload(cfg)
+
+	return middleware
+}
+
+// Reload switches to the trusted proxies of cfg, used to record the client IP of audited actions
+func (middleware *AuthMiddleware) Reload(cfg *config.Config) {
+	trustedProxies := utils.ParseTrustedProxies(cfg.RateLimit.TrustedProxies)
+	middleware.trustedProxies.Store(&trustedProxies)
+}
+
+// Authenticate rejects requests with an unknown or revoked key. Requests without a key are
+// only let through when API keys are not required by the configuration.
+func (middleware *AuthMiddleware) Authenticate(next http.Handler) http.Handler {
+	return middleware.authenticate(next, false)
+}
+
+// AuthenticateLegacy is Authenticate for the shorten and lookup routes that predate API keys.
+// Until the first API key is created, it also lets requests without a key through when API keys
+// are required, so anonymous clients keep working after an upgrade.
+func (middleware *AuthMiddleware) AuthenticateLegacy(next http.Handler) http.Handler {
+	return middleware.authenticate(next, true)
+}
+
+func (middleware *AuthMiddleware) authenticate(next http.Handler, legacy bool) http.Handler {
+	return http.HandlerFunc(func(responseWriter http.ResponseWriter, request *http.Request) {
+		key := apiKeyFromRequest(request)
+		if key == "" && (!middleware.cfg.Auth.APIKeyRequired || legacy && !middleware.hasActiveAPIKeys(request)) {
+			actor := models.AuditActor{Type: models.AuditActorAnonymous}
+			next.ServeHTTP(responseWriter, request.WithContext(middleware.withAuditActor(request, actor)))
+			return
+		}
+
+		// Stop clients guessing keys before looking up any more of them
+		if !middleware.rateLimit.checkAuthentication(responseWriter, request) {
+			return
+		}
+
+		if key == "" {
+			middleware.rateLimit.countFailedAuthentication(request)
+			respondWithError(responseWriter, request, errAPIKeyRequired)
+			return
+		}
+
+		apiKey, err := middleware.apiKeyService.AuthenticateAPIKey(request.Context(), key)
+		if err != nil {
+			if errors.Is(err, services.ErrInvalidAPIKey) {
+				middleware.rateLimit.countFailedAuthentication(request)
+			}
+
+			respondWithError(responseWriter, request, err)
+			return
+		}
+
+		recordAPIKey(request.Context(), apiKey.ID.Hex())
+		ctx := middleware.withAuditActor(request, models.AuditActor{
+			Type:      models.AuditActorAPIKey,
+			ID:        apiKey.ID.Hex(),
+			Name:      apiKey.Name,
+			Workspace: apiKey.Workspace,
+		})
+		ctx = context.WithValue(ctx, apiKeyContextKey{}, apiKey)
+		next.ServeHTTP(responseWriter, request.WithContext(ctx))
+	})
+}
+
+// hasActiveAPIKeys reports whether any API key exists, assuming one does when the lookup fails
+func (middleware *AuthMiddleware) hasActiveAPIKeys(request *http.Request) bool {
+	hasActiveKeys, err := middleware.apiKeyService.HasActiveAPIKeys(request.Context())
+	return hasActiveKeys || err != nil
+}
+
+// RequireAdmin only lets requests authenticated by an admin API key through. It must run after
+// Authenticate, and rejects anonymous requests even when API keys are not required.
+func (middleware *AuthMiddleware) RequireAdmin(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(responseWriter http.ResponseWriter, request *http.Request) {
+		apiKey := APIKeyFromContext(request.Context())
+		if apiKey == nil {
+			respondWithError(responseWriter, request, errAPIKeyRequired)
+			return
+		}
+
+		if !apiKey.Admin {
+			respondWithError(responseWriter, request, errAdminKeyRequired)
+			return
+		}
+
+		next.ServeHTTP(responseWriter, request)
+	})
+}
+
+// withAuditActor returns the context of request with actor performing its audited actions
+func (middleware *AuthMiddleware) withAuditActor(request *http.Request, actor models.AuditActor) context.Context {
+	ip := utils.ClientIP(request, *middleware.trustedProxies.Load())
+	return services.WithAuditActor(request.Context(), actor, ip, chimiddleware.GetReqID(request.Context()))
+}
+
+// APIKeyFromContext returns the API key that authenticated the request, or nil for anonymous requests
+func APIKeyFromContext(ctx context.Context) *models.APIKey {
+	apiKey, _ := ctx.Value(apiKeyContextKey{}).(*models.APIKey)
+	return apiKey
+}
+
+// WorkspaceFromContext returns the workspace of the API key that authenticated the request, or an
+// empty string for the default workspace
+func WorkspaceFromContext(ctx context.Context) string {
+	if apiKey := APIKeyFromContext(ctx); apiKey != nil {
+		return apiKey.Workspace
+	}
+
+	return ""
+}
+
+func apiKeyFromRequest(request *http.Request) string {
+	if key := request.Header.Get("X-API-Key"); key != "" {
+		return key
+	}
+
+	scheme, token, found := strings.Cut(request.Header.Get("Authorization"), " ")
+	if found && strings.EqualFold(scheme, "Bearer") {
+		return strings.TrimSpace(token)
+	}
+
+	return ""
+}
@@ -0,0 +1,113 @@
+package handlers
+
+import (
+	"github.com/aarondever/linko/internal/models"
+	"github.com/aarondever/linko/internal/openapi"
+	"github.com/aarondever/linko/internal/services"
+	"github.com/aarondever/linko/internal/utils"
+	"github.com/go-chi/chi/v5"
+	"net/http"
+)
+
+// BrandingHandler manages the landing page branding of the workspace of the calling API key
+type BrandingHandler struct {
+	brandingService *services.BrandingService
+	rateLimit       *RateLimitMiddleware
+}
+
+func NewBrandingHandler(brandingService *services.BrandingService, rateLimit *RateLimitMiddleware) *BrandingHandler {
+	return &BrandingHandler{
+		brandingService: brandingService,
+		rateLimit:       rateLimit,
+	}
+}
+
+func (handler *BrandingHandler) RegisterRoutes(router chi.Router) {
+	router.Route("/api/v1/branding", func(router chi.Router) {
+		manage := router.With(handler.rateLimit.Management)
+
+		manage.Get("/", handler.GetBranding)
+		manage.Put("/", handler.SetBranding)
+		manage.Delete("/", handler.DeleteBranding)
+	})
+}
+
+// GetBranding returns the branding of the workspace, including the fields it inherits from the
+// configured branding
+func (handler *BrandingHandler) GetBranding(responseWriter http.ResponseWriter, request *http.Request) {
+	branding, err := handler.brandingService.GetBranding(request.Context(), WorkspaceFromContext(request.Context()))
+	if err != nil {
+		respondWithError(responseWriter, request, err)
+		return
+	}
+
+	utils.RespondWithJSON(responseWriter, branding, http.StatusOK)
+}
+
+// SetBranding replaces the branding of the workspace. Omitted fields inherit the configured branding.
+func (handler *BrandingHandler) SetBranding(responseWriter http.ResponseWriter, request *http.Request) {
+	var params models.Branding
+	if !decodeRequestBody(responseWriter, request, &params) {
+		return
+	}
+
+	workspace := WorkspaceFromContext(request.Context())
+	branding, err := handler.brandingService.SetBranding(request.Context(), workspace, params)
+	if err != nil {
+		respondWithError(responseWriter, request, err)
+		return
+	}
+
+	utils.RespondWithJSON(responseWriter, branding, http.StatusOK)
+}
+
+func (handler *BrandingHandler) DeleteBranding(responseWriter http.ResponseWriter, request *http.Request) {
+	if err := handler.brandingService.DeleteBranding(request.Context(), WorkspaceFromContext(request.Context())); err != nil {
+		respondWithError(responseWriter, request, err)
+		return
+	}
+
+	responseWriter.WriteHeader(http.StatusNoContent)
+}
+
+// DescribeRoutes documents the routes registered by RegisterRoutes
+func (handler *BrandingHandler) DescribeRoutes(document *openapi.Document) {
+	branding := document.SchemaRef(models.Branding{})
+	rateLimited := errorResponse(document, "Rate limit exceeded")
+
+	document.AddOperation(http.MethodGet, "/api/v1/branding", openapi.Operation{
+		OperationID: "getBranding",
+		Summary:     "Get the landing page branding of the workspace",
+		Description: "Fields the workspace does not set are inherited from the configured branding.",
+		Tags:        []string{"branding"},
+		Responses: map[string]openapi.Response{
+			openapi.Status(http.StatusOK):              openapi.JSONResponse("Branding", branding),
+			openapi.Status(http.StatusTooManyRequests): rateLimited,
+		},
+	})
+
+	document.AddOperation(http.MethodPut, "/api/v1/branding", openapi.Operation{
+		OperationID: "setBranding",
+		Summary:     "Set the landing page branding of the workspace",
+		Description: "Landing pages are shown to browsers following links on the custom domains of the " +
+			"workspace that cannot redirect. Omitted fields are inherited from the configured branding.",
+		Tags:        []string{"branding"},
+		RequestBody: openapi.JSONBody(branding),
+		Responses: map[string]openapi.Response{
+			openapi.Status(http.StatusOK):              openapi.JSONResponse("Branding", branding),
+			openapi.Status(http.StatusBadRequest):      errorResponse(document, "Invalid request body"),
+			openapi.Status(http.StatusTooManyRequests): rateLimited,
+		},
+	})
+
+	document.AddOperation(http.MethodDelete, "/api/v1/branding", openapi.Operation{
+		OperationID: "deleteBranding",
+		Summary:     "Reset the landing page branding of the workspace to the configured branding",
+		Tags:        []string{"branding"},
+		Responses: map[string]openapi.Response{
+			openapi.Status(http.StatusNoContent):       {Description: "Branding deleted"},
+			openapi.Status(http.StatusNotFound):        errorResponse(document, "Workspace has no branding"),
+			openapi.Status(http.StatusTooManyRequests): rateLimited,
+		},
+	})
+}
@@ -0,0 +1,50 @@
+package handlers
+
+import (
+	"github.com/aarondever/linko/internal/config"
+	"github.com/aarondever/linko/internal/openapi"
+	"github.com/aarondever/linko/internal/utils"
+	"github.com/go-chi/chi/v5"
+	"net/http"
+)
+
+// ConfigHandler exposes the effective configuration of the running server
+type ConfigHandler struct {
+	reloader  *config.Reloader
+	rateLimit *RateLimitMiddleware
+}
+
+func NewConfigHandler(reloader *config.Reloader, rateLimit *RateLimitMiddleware) *ConfigHandler {
+	return &ConfigHandler{
+		reloader:  reloader,
+		rateLimit: rateLimit,
+	}
+}
+
+func (handler *ConfigHandler) RegisterRoutes(router chi.Router) {
+	router.With(handler.rateLimit.Management).Get("/api/v1/config", handler.GetConfig)
+}
+
+// GetConfig returns the effective configuration, including reloaded settings, with secrets redacted
+func (handler *ConfigHandler) GetConfig(responseWriter http.ResponseWriter, _ *http.Request) {
+	utils.RespondWithJSON(responseWriter, handler.reloader.Current().Redacted(), http.StatusOK)
+}
+
+// DescribeRoutes documents the routes registered by RegisterRoutes
+func (handler *ConfigHandler) DescribeRoutes(document *openapi.Document) {
+	document.AddOperation(http.MethodGet, "/api/v1/config", openapi.Operation{
+		OperationID: "getConfig",
+		Summary:     "Get the effective configuration",
+		Description: "Settings are keyed by their names in the configuration file. Reloaded settings are " +
+			"included and the values of secrets such as the database password are redacted.",
+		Tags: []string{"config"},
+		Responses: map[string]openapi.Response{
+			openapi.Status(http.StatusOK): openapi.JSONResponse("Effective configuration", &openapi.Schema{
+				Type:                 "object",
+				AdditionalProperties: &openapi.Schema{},
+			}),
+			openapi.Status(http.StatusTooManyRequests): errorResponse(document, "Rate limit exceeded"),
+			openapi.Status(http.StatusForbidden):       errorResponse(document, "Admin API key required"),
+		},
+	})
+}
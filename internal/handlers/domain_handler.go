@@ -0,0 +1,172 @@
+package handlers
+
+import (
+	"github.com/aarondever/linko/internal/models"
+	"github.com/aarondever/linko/internal/openapi"
+	"github.com/aarondever/linko/internal/services"
+	"github.com/aarondever/linko/internal/utils"
+	"github.com/go-chi/chi/v5"
+	"net/http"
+)
+
+// DomainHandler manages the custom domains of the workspace of the calling API key
+type DomainHandler struct {
+	domainService *services.DomainService
+	rateLimit     *RateLimitMiddleware
+}
+
+func NewDomainHandler(domainService *services.DomainService, rateLimit *RateLimitMiddleware) *DomainHandler {
+	return &DomainHandler{
+		domainService: domainService,
+		rateLimit:     rateLimit,
+	}
+}
+
+func (handler *DomainHandler) RegisterRoutes(router chi.Router) {
+	router.Route("/api/v1/domains", func(router chi.Router) {
+		manage := router.With(handler.rateLimit.Management)
+
+		manage.Get("/", handler.ListDomains)
+		manage.Post("/", handler.CreateDomain)
+		manage.Get("/{hostname}", handler.GetDomain)
+		manage.Delete("/{hostname}", handler.DeleteDomain)
+		manage.Post("/{hostname}/verify", handler.VerifyDomain)
+	})
+}
+
+// CreateDomain registers a custom domain. The response carries the TXT record to publish
+// before verifying the domain.
+func (handler *DomainHandler) CreateDomain(responseWriter http.ResponseWriter, request *http.Request) {
+	var params models.CreateDomainRequest
+	if !decodeRequestBody(responseWriter, request, &params) {
+		return
+	}
+
+	workspace := WorkspaceFromContext(request.Context())
+	domain, err := handler.domainService.CreateDomain(request.Context(), workspace, params.Hostname)
+	if err != nil {
+		respondWithError(responseWriter, request, err)
+		return
+	}
+
+	utils.RespondWithJSON(responseWriter, domain, http.StatusCreated)
+}
+
+func (handler *DomainHandler) ListDomains(responseWriter http.ResponseWriter, request *http.Request) {
+	domains, err := handler.domainService.ListDomains(request.Context(), WorkspaceFromContext(request.Context()))
+	if err != nil {
+		respondWithError(responseWriter, request, err)
+		return
+	}
+
+	utils.RespondWithJSON(responseWriter, models.ListDomainsResponse{Domains: domains}, http.StatusOK)
+}
+
+func (handler *DomainHandler) GetDomain(responseWriter http.ResponseWriter, request *http.Request) {
+	workspace := WorkspaceFromContext(request.Context())
+	domain, err := handler.domainService.GetDomain(request.Context(), workspace, request.PathValue("hostname"))
+	if err != nil {
+		respondWithError(responseWriter, request, err)
+		return
+	}
+
+	utils.RespondWithJSON(responseWriter, domain, http.StatusOK)
+}
+
+// VerifyDomain looks up the verification TXT record of a domain and marks the domain as
+// verified when the record is published
+func (handler *DomainHandler) VerifyDomain(responseWriter http.ResponseWriter, request *http.Request) {
+	workspace := WorkspaceFromContext(request.Context())
+	domain, err := handler.domainService.VerifyDomain(request.Context(), workspace, request.PathValue("hostname"))
+	if err != nil {
+		respondWithError(responseWriter, request, err)
+		return
+	}
+
+	utils.RespondWithJSON(responseWriter, domain, http.StatusOK)
+}
+
+func (handler *DomainHandler) DeleteDomain(responseWriter http.ResponseWriter, request *http.Request) {
+	workspace := WorkspaceFromContext(request.Context())
+	if err := handler.domainService.DeleteDomain(request.Context(), workspace, request.PathValue("hostname")); err != nil {
+		respondWithError(responseWriter, request, err)
+		return
+	}
+
+	responseWriter.WriteHeader(http.StatusNoContent)
+}
+
+// DescribeRoutes documents the routes registered by RegisterRoutes
+func (handler *DomainHandler) DescribeRoutes(document *openapi.Document) {
+	hostnameParam := openapi.PathParam("hostname", "Host name of the domain")
+	domain := document.SchemaRef(models.Domain{})
+	notFound := errorResponse(document, "Domain not found")
+	rateLimited := errorResponse(document, "Rate limit exceeded")
+
+	document.AddOperation(http.MethodGet, "/api/v1/domains", openapi.Operation{
+		OperationID: "listDomains",
+		Summary:     "List the custom domains of the workspace",
+		Tags:        []string{"domains"},
+		Responses: map[string]openapi.Response{
+			openapi.Status(http.StatusOK):              openapi.JSONResponse("Custom domains", document.SchemaRef(models.ListDomainsResponse{})),
+			openapi.Status(http.StatusTooManyRequests): rateLimited,
+		},
+	})
+
+	document.AddOperation(http.MethodPost, "/api/v1/domains", openapi.Operation{
+		OperationID: "createDomain",
+		Summary:     "Register a custom domain",
+		Description: "Registers a domain for the workspace of the API key. Publish the returned TXT record, " +
+			"then verify the domain before creating links on it. Other workspaces may register the same domain " +
+			"until one of them verifies it.",
+		Tags:        []string{"domains"},
+		RequestBody: openapi.JSONBody(document.SchemaRef(models.CreateDomainRequest{})),
+		Responses: map[string]openapi.Response{
+			openapi.Status(http.StatusCreated):         openapi.JSONResponse("Domain registered", domain),
+			openapi.Status(http.StatusBadRequest):      errorResponse(document, "Invalid request body"),
+			openapi.Status(http.StatusConflict):        errorResponse(document, "Domain is already registered or verified by another workspace"),
+			openapi.Status(http.StatusTooManyRequests): rateLimited,
+		},
+	})
+
+	document.AddOperation(http.MethodGet, "/api/v1/domains/{hostname}", openapi.Operation{
+		OperationID: "getDomain",
+		Summary:     "Get a custom domain",
+		Tags:        []string{"domains"},
+		Parameters:  []openapi.Parameter{hostnameParam},
+		Responses: map[string]openapi.Response{
+			openapi.Status(http.StatusOK):              openapi.JSONResponse("Domain", domain),
+			openapi.Status(http.StatusNotFound):        notFound,
+			openapi.Status(http.StatusTooManyRequests): rateLimited,
+		},
+	})
+
+	document.AddOperation(http.MethodDelete, "/api/v1/domains/{hostname}", openapi.Operation{
+		OperationID: "deleteDomain",
+		Summary:     "Delete a custom domain without links",
+		Tags:        []string{"domains"},
+		Parameters:  []openapi.Parameter{hostnameParam},
+		Responses: map[string]openapi.Response{
+			openapi.Status(http.StatusNoContent):       {Description: "Domain deleted"},
+			openapi.Status(http.StatusNotFound):        notFound,
+			openapi.Status(http.StatusConflict):        errorResponse(document, "Domain still has links"),
+			openapi.Status(http.StatusTooManyRequests): rateLimited,
+		},
+	})
+
+	document.AddOperation(http.MethodPost, "/api/v1/domains/{hostname}/verify", openapi.Operation{
+		OperationID: "verifyDomain",
+		Summary:     "Verify ownership of a custom domain",
+		Description: "Looks up the TXT record given in the verification field of the domain. Registrations of the " +
+			"domain by other workspaces are dropped once it is verified.",
+		Tags:       []string{"domains"},
+		Parameters: []openapi.Parameter{hostnameParam},
+		Responses: map[string]openapi.Response{
+			openapi.Status(http.StatusOK):              openapi.JSONResponse("Verified domain", domain),
+			openapi.Status(http.StatusBadRequest):      errorResponse(document, "Verification record not found"),
+			openapi.Status(http.StatusNotFound):        notFound,
+			openapi.Status(http.StatusConflict):        errorResponse(document, "Domain verified by another workspace"),
+			openapi.Status(http.StatusTooManyRequests): rateLimited,
+		},
+	})
+}
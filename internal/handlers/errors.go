@@ -0,0 +1,163 @@
+package handlers
+
+import (
+	"errors"
+	"github.com/aarondever/linko/internal/models"
+	"github.com/aarondever/linko/internal/services"
+	"github.com/aarondever/linko/internal/utils"
+	"github.com/go-chi/chi/v5/middleware"
+	"log/slog"
+	"net/http"
+	"strconv"
+)
+
+// problemTypePrefix prefixes error codes to form the problem type URI
+const problemTypePrefix = "urn:linko:problem:"
+
+// errInternal replaces errors that are not domain errors so internals never reach clients
+var errInternal = services.NewError(services.ErrorKindInternal, "internal_error", "An internal error occurred")
+
+// Request errors detected by handlers
+var (
+	errInvalidBody   = services.NewValidationError("invalid_body", "Request body is not valid JSON")
+	errNoURLs        = services.NewValidationError("no_urls", "No URLs provided")
+	errInvalidQuery  = services.NewValidationError("invalid_query", "Invalid query parameter")
+	errRouteNotFound = services.NewError(services.ErrorKindNotFound, "route_not_found", "No route matches the request")
+	errBodyTooLarge  = services.NewError(services.ErrorKindTooLarge, "body_too_large", "Request body is too large")
+)
+
+// respondWithError maps err to a problem details response. Domain errors from the service layer
+// keep their code and message and bodies over the size limit are reported as too large; any
+// other error is logged and reported as an internal error.
+func respondWithError(responseWriter http.ResponseWriter, request *http.Request, err error) {
+	requestID := middleware.GetReqID(request.Context())
+
+	var domainErr *services.Error
+	var maxBytesErr *http.MaxBytesError
+	switch {
+	case errors.As(err, &domainErr):
+	case errors.As(err, &maxBytesErr):
+		domainErr = errBodyTooLarge
+	default:
+		slog.ErrorContext(request.Context(), "Internal error handling request",
+			"error", err,
+			"method", request.Method,
+			"path", request.URL.Path,
+			"request_id", requestID)
+		domainErr = errInternal
+	}
+
+	status := errorStatus(domainErr.Kind)
+	if domainErr.RetryAfter > 0 {
+		responseWriter.Header().Set("Retry-After", strconv.Itoa(ceilSeconds(domainErr.RetryAfter)))
+	}
+	if status == http.StatusUnauthorized {
+		responseWriter.Header().Set("WWW-Authenticate", "Bearer")
+	}
+
+	utils.RespondWithProblem(responseWriter, models.Problem{
+		Type:      problemTypePrefix + domainErr.Code,
+		Title:     http.StatusText(status),
+		Status:    status,
+		Detail:    domainErr.Message,
+		Instance:  request.URL.Path,
+		Code:      domainErr.Code,
+		RequestID: requestID,
+		Errors:    domainErr.Fields,
+	})
+}
+
+// decodeRequestBody decodes and validates a JSON request body. On failure it responds with a
+// problem listing the invalid fields and returns false.
+func decodeRequestBody(responseWriter http.ResponseWriter, request *http.Request, params any) bool {
+	err := utils.DecodeRequestBody(request, params)
+	if err == nil {
+		return true
+	}
+
+	if fields := utils.FieldErrors(err); fields != nil {
+		respondWithError(responseWriter, request,
+			services.NewValidationError("validation_failed", "Request validation failed", fields...))
+		return false
+	}
+
+	var maxBytesErr *http.MaxBytesError
+	if errors.As(err, &maxBytesErr) {
+		respondWithError(responseWriter, request, errBodyTooLarge)
+		return false
+	}
+
+	respondWithError(responseWriter, request, errInvalidBody)
+	return false
+}
+
+// invalidQueryError reports an invalid query parameter
+func invalidQueryError(name, message string) error {
+	return services.NewValidationError(errInvalidQuery.Code, errInvalidQuery.Message, models.FieldError{
+		Field:   name,
+		Rule:    "query",
+		Message: message,
+	})
+}
+
+func errorStatus(kind services.ErrorKind) int {
+	switch kind {
+	case services.ErrorKindValidation:
+		return http.StatusBadRequest
+	case services.ErrorKindNotFound:
+		return http.StatusNotFound
+	case services.ErrorKindConflict:
+		return http.StatusConflict
+	case services.ErrorKindGone:
+		return http.StatusGone
+	case services.ErrorKindUnauthorized:
+		return http.StatusUnauthorized
+	case services.ErrorKindForbidden:
+		return http.StatusForbidden
+	case services.ErrorKindTooLarge:
+		return http.StatusRequestEntityTooLarge
+	case services.ErrorKindRateLimited:
+		return http.StatusTooManyRequests
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+// NotFound responds to requests for unknown routes
+func NotFound(responseWriter http.ResponseWriter, request *http.Request) {
+	respondWithError(responseWriter, request, errRouteNotFound)
+}
+
+// MethodNotAllowed responds to requests using a method a route does not support
+func MethodNotAllowed(responseWriter http.ResponseWriter, request *http.Request) {
+	utils.RespondWithProblem(responseWriter, models.Problem{
+		Type:      problemTypePrefix + "method_not_allowed",
+		Title:     http.StatusText(http.StatusMethodNotAllowed),
+		Status:    http.StatusMethodNotAllowed,
+		Instance:  request.URL.Path,
+		Code:      "method_not_allowed",
+		RequestID: middleware.GetReqID(request.Context()),
+	})
+}
+
+// ExposeRequestID returns the request ID assigned by middleware.RequestID in the X-Request-Id
+// response header so clients can quote it when reporting problems
+func ExposeRequestID(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(responseWriter http.ResponseWriter, request *http.Request) {
+		if requestID := middleware.GetReqID(request.Context()); requestID != "" {
+			responseWriter.Header().Set(middleware.RequestIDHeader, requestID)
+		}
+
+		next.ServeHTTP(responseWriter, request)
+	})
+}
+
+// LimitRequestBody rejects request bodies larger than maxBytes once handlers read past the limit
+func LimitRequestBody(maxBytes int64) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(responseWriter http.ResponseWriter, request *http.Request) {
+			request.Body = http.MaxBytesReader(responseWriter, request.Body, maxBytes)
+			next.ServeHTTP(responseWriter, request)
+		})
+	}
+}
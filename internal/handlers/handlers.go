@@ -1,22 +1,98 @@
 package handlers
 
 import (
+	"github.com/aarondever/linko/internal/config"
 	"github.com/aarondever/linko/internal/services"
 	"github.com/go-chi/chi/v5"
 )
 
 type Handlers struct {
-	URLHandler *URLHandler
+	AccessLogMiddleware *AccessLogMiddleware
+	AuthMiddleware      *AuthMiddleware
+	RateLimitMiddleware *RateLimitMiddleware
+	URLHandler          *URLHandler
+	TransferHandler     *TransferHandler
+	DomainHandler       *DomainHandler
+	BrandingHandler     *BrandingHandler
+	ConfigHandler       *ConfigHandler
+	AuditHandler        *AuditHandler
+	WebhookHandler      *WebhookHandler
+	OpenAPIHandler      *OpenAPIHandler
 }
 
-func InitializeHandlers(services *services.Services) *Handlers {
+func InitializeHandlers(services *services.Services, reloader *config.Reloader) *Handlers {
+	cfg := reloader.Current()
+
 	// Initialize each handler - add new handlers here
-	return &Handlers{
-		URLHandler: NewURLHandler(services.URLService),
+	rateLimitMiddleware := NewRateLimitMiddleware(services.RateLimiter, cfg)
+	urlHandler := NewURLHandler(services.URLService, services.QRService, services.DomainService, services.BrandingService, rateLimitMiddleware, cfg)
+	handlers := &Handlers{
+		AccessLogMiddleware: NewAccessLogMiddleware(cfg, urlHandler.redirectPath()),
+		AuthMiddleware:      NewAuthMiddleware(services.APIKeyService, rateLimitMiddleware, cfg),
+		RateLimitMiddleware: rateLimitMiddleware,
+		URLHandler:          urlHandler,
+		TransferHandler:     NewTransferHandler(services.TransferService, rateLimitMiddleware),
+		DomainHandler:       NewDomainHandler(services.DomainService, rateLimitMiddleware),
+		BrandingHandler:     NewBrandingHandler(services.BrandingService, rateLimitMiddleware),
+		ConfigHandler:       NewConfigHandler(reloader, rateLimitMiddleware),
+		AuditHandler:        NewAuditHandler(services.AuditService, rateLimitMiddleware),
+		WebhookHandler:      NewWebhookHandler(services.WebhookService, rateLimitMiddleware),
+	}
+
+	// Document the routes of every handler
+	handlers.OpenAPIHandler = NewOpenAPIHandler(
+		handlers.URLHandler,
+		handlers.TransferHandler,
+		handlers.DomainHandler,
+		handlers.BrandingHandler,
+		handlers.ConfigHandler,
+		handlers.AuditHandler,
+		handlers.WebhookHandler,
+	)
+
+	return handlers
+}
+
+// Reloadables returns the handlers and middleware applying reloaded configuration while running
+func (handlers *Handlers) Reloadables() []config.Reloadable {
+	return []config.Reloadable{
+		handlers.AccessLogMiddleware,
+		handlers.AuthMiddleware,
+		handlers.RateLimitMiddleware,
 	}
 }
 
 func (handlers *Handlers) SetupRouters(router *chi.Mux) {
+	router.NotFound(NotFound)
+	router.MethodNotAllowed(MethodNotAllowed)
+
+	// Setup the shorten and lookup routes, which stay anonymous until the first API key exists
+	router.Group(func(router chi.Router) {
+		router.Use(handlers.AuthMiddleware.AuthenticateLegacy)
+
+		handlers.URLHandler.RegisterLegacyRoutes(router)
+	})
+
 	// Setup API routes
-	handlers.URLHandler.RegisterRoutes(router)
+	router.Group(func(router chi.Router) {
+		router.Use(handlers.AuthMiddleware.Authenticate)
+
+		handlers.URLHandler.RegisterRoutes(router)
+		handlers.DomainHandler.RegisterRoutes(router)
+		handlers.BrandingHandler.RegisterRoutes(router)
+
+		// Setup administrative routes, which require an admin API key
+		router.Group(func(router chi.Router) {
+			router.Use(handlers.AuthMiddleware.RequireAdmin)
+
+			handlers.TransferHandler.RegisterRoutes(router)
+			handlers.ConfigHandler.RegisterRoutes(router)
+			handlers.AuditHandler.RegisterRoutes(router)
+			handlers.WebhookHandler.RegisterRoutes(router)
+		})
+	})
+
+	// Setup public routes
+	handlers.URLHandler.RegisterPublicRoutes(router)
+	handlers.OpenAPIHandler.RegisterRoutes(router)
 }
@@ -0,0 +1,117 @@
+package handlers
+
+import (
+	"bytes"
+	"errors"
+	"github.com/aarondever/linko/internal/config"
+	"github.com/aarondever/linko/internal/landing"
+	"github.com/aarondever/linko/internal/services"
+	"github.com/go-chi/chi/v5/middleware"
+	"log/slog"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// landingPages maps the error codes of the redirect path to the page shown to browsers
+var landingPages = map[string]string{
+	services.ErrURLNotFound.Code: landing.PageNotFound,
+	services.ErrURLDisabled.Code: landing.PageDisabled,
+	services.ErrURLFlagged.Code:  landing.PageUnsafe,
+}
+
+// loadLandingPages loads the landing page templates of the configuration, falling back to the
+// embedded templates when the template directory cannot be parsed
+func loadLandingPages(cfg *config.Config) *landing.Pages {
+	pages, err := landing.Load(cfg.Landing.TemplateDir)
+	if err == nil {
+		return pages
+	}
+
+	slog.Error("Failed loading landing page templates, using the embedded templates",
+		"dir", cfg.Landing.TemplateDir, "error", err)
+
+	pages, err = landing.Load("")
+	if err != nil {
+		panic(err) // The embedded templates are broken
+	}
+
+	return pages
+}
+
+// respondWithLandingPage responds to a short link that cannot redirect. Browsers get the
+// landing page of the error, branded for the workspace of the domain; other clients and
+// errors without a page get a problem details response.
+func (handler *URLHandler) respondWithLandingPage(
+	responseWriter http.ResponseWriter,
+	request *http.Request,
+	domain, shortCode string,
+	err error,
+) {
+	var domainErr *services.Error
+	if !errors.As(err, &domainErr) || !prefersHTML(request) {
+		respondWithError(responseWriter, request, err)
+		return
+	}
+
+	page, ok := landingPages[domainErr.Code]
+	if !ok {
+		respondWithError(responseWriter, request, err)
+		return
+	}
+
+	branding, err := handler.brandingService.DomainBranding(request.Context(), domain)
+	if err != nil {
+		respondWithError(responseWriter, request, err)
+		return
+	}
+
+	status := errorStatus(domainErr.Kind)
+	data := landing.Data{
+		Status:    status,
+		ShortURL:  handler.shortURL(request, domain, shortCode),
+		RequestID: middleware.GetReqID(request.Context()),
+		Branding:  *branding,
+	}
+
+	var body bytes.Buffer
+	if err = handler.pages.Render(&body, page, data); err != nil {
+		slog.ErrorContext(request.Context(), "Failed rendering landing page", "page", page, "error", err)
+		respondWithError(responseWriter, request, domainErr)
+		return
+	}
+
+	responseWriter.Header().Set("Content-Type", "text/html; charset=utf-8")
+	responseWriter.Header().Set("Cache-Control", "no-store")
+	responseWriter.WriteHeader(status)
+	responseWriter.Write(body.Bytes())
+}
+
+// prefersHTML reports whether the Accept header of a request ranks HTML above JSON. Requests
+// accepting anything, like those of curl and most HTTP libraries, get JSON.
+func prefersHTML(request *http.Request) bool {
+	var htmlQuality, jsonQuality float64
+	for _, mediaRange := range strings.Split(request.Header.Get("Accept"), ",") {
+		mediaType, params, err := mime.ParseMediaType(mediaRange)
+		if err != nil {
+			continue
+		}
+
+		quality := 1.0
+		if value, ok := params["q"]; ok {
+			if quality, err = strconv.ParseFloat(value, 64); err != nil {
+				continue
+			}
+		}
+
+		switch mediaType {
+		case "text/html", "application/xhtml+xml":
+			htmlQuality = max(htmlQuality, quality)
+		case "application/json", "application/problem+json":
+			jsonQuality = max(jsonQuality, quality)
+		}
+	}
+
+	return htmlQuality > 0 && htmlQuality >= jsonQuality
+}
@@ -0,0 +1,121 @@
+package handlers
+
+import (
+	"encoding/json"
+	"github.com/aarondever/linko/internal/models"
+	"github.com/aarondever/linko/internal/openapi"
+	"github.com/go-chi/chi/v5"
+	"net/http"
+	"sync"
+)
+
+// docsPage renders the OpenAPI document with Swagger UI
+const docsPage = `<!DOCTYPE html>
+<html lang="en">
+<head>
+  <meta charset="utf-8">
+  <title>linko API</title>
+  <link rel="stylesheet" href="https://unpkg.com/swagger-ui-dist@5/swagger-ui.css">
+</head>
+<body>
+  <div id="swagger-ui"></div>
+  <script src="https://unpkg.com/swagger-ui-dist@5/swagger-ui-bundle.js" crossorigin></script>
+  <script>
+    window.onload = () => {
+      window.ui = SwaggerUIBundle({ url: "/api/openapi.json", dom_id: "#swagger-ui" });
+    };
+  </script>
+</body>
+</html>
+`
+
+// RouteDescriber is implemented by handlers that document their routes in the OpenAPI document
+type RouteDescriber interface {
+	DescribeRoutes(document *openapi.Document)
+}
+
+type OpenAPIHandler struct {
+	Document *openapi.Document
+
+	once sync.Once
+	spec []byte
+	err  error
+}
+
+// NewOpenAPIHandler creates the OpenAPI document from the routes of the given handlers.
+// Routes registered outside the handlers package are added to Document before serving.
+func NewOpenAPIHandler(describers ...RouteDescriber) *OpenAPIHandler {
+	handler := &OpenAPIHandler{
+		Document: openapi.NewDocument("linko", "1.0.0", "URL shortener API"),
+	}
+
+	for _, describer := range describers {
+		describer.DescribeRoutes(handler.Document)
+	}
+	handler.DescribeRoutes(handler.Document)
+
+	return handler
+}
+
+func (handler *OpenAPIHandler) RegisterRoutes(router chi.Router) {
+	router.Get("/api/openapi.json", handler.GetSpec)
+	router.Get("/api/docs", handler.GetDocs)
+}
+
+func (handler *OpenAPIHandler) GetSpec(responseWriter http.ResponseWriter, request *http.Request) {
+	// The document is complete once the server is serving, so it is only encoded once
+	handler.once.Do(func() {
+		handler.spec, handler.err = json.Marshal(handler.Document)
+	})
+
+	if handler.err != nil {
+		respondWithError(responseWriter, request, handler.err)
+		return
+	}
+
+	responseWriter.Header().Set("Content-Type", "application/json")
+	responseWriter.WriteHeader(http.StatusOK)
+	responseWriter.Write(handler.spec)
+}
+
+func (handler *OpenAPIHandler) GetDocs(responseWriter http.ResponseWriter, _ *http.Request) {
+	responseWriter.Header().Set("Content-Type", "text/html; charset=utf-8")
+	responseWriter.WriteHeader(http.StatusOK)
+	responseWriter.Write([]byte(docsPage))
+}
+
+// DescribeRoutes documents the routes registered by RegisterRoutes
+func (handler *OpenAPIHandler) DescribeRoutes(document *openapi.Document) {
+	document.AddOperation(http.MethodGet, "/api/openapi.json", openapi.Operation{
+		OperationID: "getOpenAPISpec",
+		Summary:     "Get this OpenAPI document",
+		Tags:        []string{"docs"},
+		Security:    openapi.Public(),
+		Responses: map[string]openapi.Response{
+			openapi.Status(http.StatusOK): openapi.JSONResponse("OpenAPI document", &openapi.Schema{Type: "object"}),
+		},
+	})
+
+	document.AddOperation(http.MethodGet, "/api/docs", openapi.Operation{
+		OperationID: "getAPIDocs",
+		Summary:     "Browse the API documentation",
+		Tags:        []string{"docs"},
+		Security:    openapi.Public(),
+		Responses: map[string]openapi.Response{
+			openapi.Status(http.StatusOK): {
+				Description: "Swagger UI page",
+				Content:     map[string]openapi.MediaType{"text/html": {Schema: &openapi.Schema{Type: "string"}}},
+			},
+		},
+	})
+}
+
+// errorResponse documents an error response with a problem details body
+func errorResponse(document *openapi.Document, description string) openapi.Response {
+	return openapi.Response{
+		Description: description,
+		Content: map[string]openapi.MediaType{
+			"application/problem+json": {Schema: document.SchemaRef(models.Problem{})},
+		},
+	}
+}
@@ -0,0 +1,145 @@
+package handlers
+
+import (
+	"fmt"
+	"github.com/aarondever/linko/internal/qrcode"
+	"github.com/aarondever/linko/internal/services"
+	"image/color"
+	"net/http"
+	"strings"
+)
+
+// Bounds and defaults of the QR code query parameters
+const (
+	defaultQRCodeSize   = 256
+	minQRCodeSize       = 64
+	maxQRCodeSize       = 2048
+	defaultQRCodeMargin = 4
+	maxQRCodeMargin     = 16
+)
+
+// qrCodeCacheControl lets clients reuse a code for an hour and revalidate it with its ETag after that
+const qrCodeCacheControl = "private, max-age=3600"
+
+// GetQRCode renders a QR code of the short URL of a link as PNG or SVG. Responses carry an ETag
+// derived from the link and the query parameters, so revalidation skips rendering.
+func (handler *URLHandler) GetQRCode(responseWriter http.ResponseWriter, request *http.Request) {
+	params, err := parseQRCodeParams(request)
+	if err != nil {
+		respondWithError(responseWriter, request, err)
+		return
+	}
+
+	domain, err := handler.linkDomain(request, false)
+	if err != nil {
+		respondWithError(responseWriter, request, err)
+		return
+	}
+
+	mapping, err := handler.urlService.GetURLMapping(request.Context(), domain, request.PathValue("shortCode"))
+	if err != nil {
+		respondWithError(responseWriter, request, err)
+		return
+	}
+	params.Text = handler.shortURL(request, mapping.Domain, mapping.ShortCode)
+
+	etag := params.ETag()
+	responseWriter.Header().Set("ETag", etag)
+	responseWriter.Header().Set("Cache-Control", qrCodeCacheControl)
+	if etagMatches(request.Header.Get("If-None-Match"), etag) {
+		responseWriter.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	qrCode, err := handler.qrService.GenerateQRCode(request.Context(), params)
+	if err != nil {
+		responseWriter.Header().Del("ETag")
+		responseWriter.Header().Del("Cache-Control")
+		respondWithError(responseWriter, request, err)
+		return
+	}
+
+	responseWriter.Header().Set("Content-Type", qrCode.ContentType)
+	responseWriter.WriteHeader(http.StatusOK)
+	responseWriter.Write(qrCode.Data)
+}
+
+// parseQRCodeParams reads the image options of a QR code request from its query parameters
+func parseQRCodeParams(request *http.Request) (services.QRCodeParams, error) {
+	query := request.URL.Query()
+	params := services.QRCodeParams{
+		Format:  query.Get("format"),
+		LogoURL: query.Get("logo"),
+	}
+
+	switch params.Format {
+	case "":
+		params.Format = services.QRCodeFormatPNG
+	case services.QRCodeFormatPNG, services.QRCodeFormatSVG:
+	default:
+		return params, invalidQueryError("format", "must be png or svg")
+	}
+
+	size, err := parseIntQuery(request, "size", defaultQRCodeSize)
+	if err != nil || size < minQRCodeSize || size > maxQRCodeSize {
+		return params, invalidQueryError("size", fmt.Sprintf("must be an integer between %d and %d", minQRCodeSize, maxQRCodeSize))
+	}
+	params.Options.Size = int(size)
+
+	margin, err := parseIntQuery(request, "margin", defaultQRCodeMargin)
+	if err != nil || margin < 0 || margin > maxQRCodeMargin {
+		return params, invalidQueryError("margin", fmt.Sprintf("must be an integer between 0 and %d", maxQRCodeMargin))
+	}
+	params.Options.Margin = int(margin)
+
+	// A logo hides modules in the center, which the highest error correction level recovers
+	params.Options.Level = strings.ToUpper(query.Get("ec"))
+	if params.Options.Level == "" {
+		params.Options.Level = "M"
+		if params.LogoURL != "" {
+			params.Options.Level = "H"
+		}
+	}
+	if _, ok := qrcode.Levels[params.Options.Level]; !ok {
+		return params, invalidQueryError("ec", "must be one of L, M, Q or H")
+	}
+
+	params.Options.Foreground, err = parseColorQuery(request, "fg", color.RGBA{A: 0xff})
+	if err != nil {
+		return params, err
+	}
+
+	params.Options.Background, err = parseColorQuery(request, "bg", color.RGBA{R: 0xff, G: 0xff, B: 0xff, A: 0xff})
+	if err != nil {
+		return params, err
+	}
+
+	return params, nil
+}
+
+// parseColorQuery parses an optional hex color query parameter, returning defaultValue when absent
+func parseColorQuery(request *http.Request, name string, defaultValue color.RGBA) (color.RGBA, error) {
+	value := request.URL.Query().Get(name)
+	if value == "" {
+		return defaultValue, nil
+	}
+
+	parsed, err := qrcode.ParseColor(value)
+	if err != nil {
+		return parsed, invalidQueryError(name, "must be a hex color of the form RRGGBB or RRGGBBAA")
+	}
+
+	return parsed, nil
+}
+
+// etagMatches reports whether an If-None-Match header matches etag, comparing weakly
+func etagMatches(header, etag string) bool {
+	for candidate := range strings.SplitSeq(header, ",") {
+		candidate = strings.TrimPrefix(strings.TrimSpace(candidate), "W/")
+		if candidate == "*" || candidate == etag {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,112 @@
+package handlers
+
+import (
+	"errors"
+	"github.com/aarondever/linko/internal/services"
+	"image/color"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestParseQRCodeParams(t *testing.T) {
+	tests := []struct {
+		name       string
+		query      string
+		format     string
+		size       int
+		margin     int
+		level      string
+		foreground color.RGBA
+		background color.RGBA
+		field      string // Query parameter reported as invalid
+	}{
+		{
+			name:       "defaults",
+			format:     services.QRCodeFormatPNG,
+			size:       defaultQRCodeSize,
+			margin:     defaultQRCodeMargin,
+			level:      "M",
+			foreground: color.RGBA{A: 0xff},
+			background: color.RGBA{R: 0xff, G: 0xff, B: 0xff, A: 0xff},
+		},
+		{
+			name:       "all parameters",
+			query:      "format=svg&size=512&margin=0&ec=q&fg=000080&bg=ffffff00",
+			format:     services.QRCodeFormatSVG,
+			size:       512,
+			margin:     0,
+			level:      "Q",
+			foreground: color.RGBA{B: 0x80, A: 0xff},
+			background: color.RGBA{},
+		},
+		{
+			name:       "logo raises the default error correction",
+			query:      "logo=https://cdn.example/logo.png",
+			format:     services.QRCodeFormatPNG,
+			size:       defaultQRCodeSize,
+			margin:     defaultQRCodeMargin,
+			level:      "H",
+			foreground: color.RGBA{A: 0xff},
+			background: color.RGBA{R: 0xff, G: 0xff, B: 0xff, A: 0xff},
+		},
+		{name: "unknown format", query: "format=gif", field: "format"},
+		{name: "size too small", query: "size=63", field: "size"},
+		{name: "size too large", query: "size=2049", field: "size"},
+		{name: "size not a number", query: "size=big", field: "size"},
+		{name: "negative margin", query: "margin=-1", field: "margin"},
+		{name: "margin too large", query: "margin=17", field: "margin"},
+		{name: "unknown error correction", query: "ec=X", field: "ec"},
+		{name: "invalid foreground", query: "fg=blue", field: "fg"},
+		{name: "invalid background", query: "bg=fff", field: "bg"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			request := httptest.NewRequest(http.MethodGet, "/api/v1/urls/abc123/qr?"+test.query, nil)
+
+			params, err := parseQRCodeParams(request)
+			if test.field != "" {
+				var serviceErr *services.Error
+				if !errors.As(err, &serviceErr) || len(serviceErr.Fields) != 1 || serviceErr.Fields[0].Field != test.field {
+					t.Fatalf("error = %v, want one for the %s parameter", err, test.field)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			options := params.Options
+			if params.Format != test.format || options.Size != test.size || options.Margin != test.margin || options.Level != test.level {
+				t.Errorf("format, size, margin, level = %s, %d, %d, %s, want %s, %d, %d, %s", params.Format,
+					options.Size, options.Margin, options.Level, test.format, test.size, test.margin, test.level)
+			}
+			if options.Foreground != test.foreground || options.Background != test.background {
+				t.Errorf("colors = %v, %v, want %v, %v", options.Foreground, options.Background, test.foreground, test.background)
+			}
+		})
+	}
+}
+
+func TestETagMatches(t *testing.T) {
+	const etag = `"abc"`
+
+	tests := []struct {
+		header string
+		match  bool
+	}{
+		{`"abc"`, true},
+		{`W/"abc"`, true},
+		{`"xyz", "abc"`, true},
+		{"*", true},
+		{`"xyz"`, false},
+		{"", false},
+	}
+
+	for _, test := range tests {
+		if got := etagMatches(test.header, etag); got != test.match {
+			t.Errorf("etagMatches(%q) = %t, want %t", test.header, got, test.match)
+		}
+	}
+}
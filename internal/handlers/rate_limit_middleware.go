@@ -0,0 +1,170 @@
+package handlers
+
+import (
+	"fmt"
+	"github.com/aarondever/linko/internal/config"
+	"github.com/aarondever/linko/internal/services"
+	"github.com/aarondever/linko/internal/utils"
+	"log/slog"
+	"math"
+	"net/http"
+	"net/netip"
+	"strconv"
+	"sync/atomic"
+	"time"
+)
+
+// Rate limit policy names, also used to keep the buckets of each policy apart
+const (
+	rateLimitPolicyShorten    = "shorten"
+	rateLimitPolicyManagement = "management"
+	rateLimitPolicyRedirect   = "redirect"
+	rateLimitPolicyAuth       = "auth"
+)
+
+// RateLimitMiddleware limits requests per API key, or per client IP for anonymous requests,
+// with a separate quota for shortening, link management and redirects. Failed authentications
+// are limited per client IP.
+type RateLimitMiddleware struct {
+	rateLimiter services.RateLimiter
+	settings    atomic.Pointer[rateLimitSettings]
+}
+
+// rateLimitSettings is the rate limit configuration of the middleware, replaced as a whole on reload
+type rateLimitSettings struct {
+	config         config.RateLimitConfig
+	trustedProxies []netip.Prefix
+}
+
+func NewRateLimitMiddleware(rateLimiter services.RateLimiter, cfg *config.Config) *RateLimitMiddleware {
+	middleware := &RateLimitMiddleware{rateLimiter: rateLimiter}
+	middleware.Reload(cfg)
+
+	return middleware
+}
+
+// Reload switches to the quotas and trusted proxies of cfg. Buckets keep their tokens.
+func (middleware *RateLimitMiddleware) Reload(cfg *config.Config) {
+	middleware.settings.Store(&rateLimitSettings{
+		config:         cfg.RateLimit,
+		trustedProxies: utils.ParseTrustedProxies(cfg.RateLimit.TrustedProxies),
+	})
+}
+
+// Shorten applies the quota of routes creating short links
+func (middleware *RateLimitMiddleware) Shorten(next http.Handler) http.Handler {
+	return middleware.limit(rateLimitPolicyShorten, next)
+}
+
+// Management applies the quota of routes reading and managing links
+func (middleware *RateLimitMiddleware) Management(next http.Handler) http.Handler {
+	return middleware.limit(rateLimitPolicyManagement, next)
+}
+
+// Redirect applies the quota of short link redirects
+func (middleware *RateLimitMiddleware) Redirect(next http.Handler) http.Handler {
+	return middleware.limit(rateLimitPolicyRedirect, next)
+}
+
+func (middleware *RateLimitMiddleware) limit(policyName string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(responseWriter http.ResponseWriter, request *http.Request) {
+		settings := middleware.settings.Load()
+		if !settings.config.Enabled {
+			next.ServeHTTP(responseWriter, request)
+			return
+		}
+
+		policy := settings.policy(policyName)
+		result, err := middleware.rateLimiter.Allow(request.Context(), settings.clientKey(request), policy)
+		if err != nil {
+			respondWithError(responseWriter, request, err)
+			return
+		}
+
+		// Advertise the quota following the IETF RateLimit header fields draft
+		header := responseWriter.Header()
+		window := int(math.Ceil(float64(policy.Burst) / policy.Rate))
+		header.Set("RateLimit-Policy", fmt.Sprintf("%d;w=%d", policy.Burst, window))
+		header.Set("RateLimit-Limit", strconv.Itoa(result.Limit))
+		header.Set("RateLimit-Remaining", strconv.Itoa(result.Remaining))
+		header.Set("RateLimit-Reset", strconv.Itoa(ceilSeconds(result.ResetAfter)))
+
+		if !result.Allowed {
+			respondWithError(responseWriter, request, services.NewRateLimitedError(
+				fmt.Sprintf("Rate limit of the %s quota exceeded", policyName), result.RetryAfter))
+			return
+		}
+
+		next.ServeHTTP(responseWriter, request)
+	})
+}
+
+// checkAuthentication rejects requests from client IPs that used up their quota of failed
+// authentications, before their key is looked up. It reports whether the request may proceed.
+func (middleware *RateLimitMiddleware) checkAuthentication(responseWriter http.ResponseWriter, request *http.Request) bool {
+	settings := middleware.settings.Load()
+	if !settings.config.Enabled {
+		return true
+	}
+
+	policy := settings.policy(rateLimitPolicyAuth)
+	result, err := middleware.rateLimiter.Check(request.Context(), settings.ipKey(request), policy)
+	if err != nil {
+		respondWithError(responseWriter, request, err)
+		return false
+	}
+
+	if !result.Allowed {
+		respondWithError(responseWriter, request, services.NewRateLimitedError(
+			"Too many failed authentications", result.RetryAfter))
+		return false
+	}
+
+	return true
+}
+
+// countFailedAuthentication counts a failed authentication against the quota of the client IP
+func (middleware *RateLimitMiddleware) countFailedAuthentication(request *http.Request) {
+	settings := middleware.settings.Load()
+	if !settings.config.Enabled {
+		return
+	}
+
+	policy := settings.policy(rateLimitPolicyAuth)
+	if _, err := middleware.rateLimiter.Allow(request.Context(), settings.ipKey(request), policy); err != nil {
+		slog.WarnContext(request.Context(), "Failed record authentication failure", "error", err)
+	}
+}
+
+// policy returns the configured token bucket of the named policy
+func (settings *rateLimitSettings) policy(name string) services.RateLimitPolicy {
+	switch name {
+	case rateLimitPolicyShorten:
+		return services.NewRateLimitPolicy(name, settings.config.Shorten)
+	case rateLimitPolicyRedirect:
+		return services.NewRateLimitPolicy(name, settings.config.Redirect)
+	case rateLimitPolicyAuth:
+		return services.NewRateLimitPolicy(name, settings.config.Auth)
+	default:
+		return services.NewRateLimitPolicy(name, settings.config.Management)
+	}
+}
+
+// clientKey identifies the caller by its API key, or else by its IP address
+func (settings *rateLimitSettings) clientKey(request *http.Request) string {
+	if apiKey := APIKeyFromContext(request.Context()); apiKey != nil {
+		return "key:" + apiKey.ID.Hex()
+	}
+
+	return settings.ipKey(request)
+}
+
+// ipKey identifies the caller by its IP address
+func (settings *rateLimitSettings) ipKey(request *http.Request) string {
+	return "ip:" + utils.ClientIP(request, settings.trustedProxies)
+}
+
+// ceilSeconds rounds a duration up to whole seconds
+func ceilSeconds(duration time.Duration) int {
+	return int(math.Ceil(duration.Seconds()))
+}
@@ -0,0 +1,99 @@
+package handlers
+
+import (
+	"github.com/aarondever/linko/internal/models"
+	"html/template"
+	"log/slog"
+	"net/http"
+	"strings"
+)
+
+// crawlerUserAgents are lowercase User-Agent fragments of the link unfurling crawlers of chat
+// apps and social networks
+var crawlerUserAgents = []string{
+	"slackbot",
+	"slack-imgproxy",
+	"twitterbot",
+	"facebookexternalhit",
+	"facebot",
+	"linkedinbot",
+	"discordbot",
+	"telegrambot",
+	"whatsapp",
+}
+
+var socialPreviewTemplate = template.Must(template.New("social_preview").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>{{.Title}}</title>
+<meta property="og:type" content="website">
+<meta property="og:url" content="{{.ShortURL}}">
+{{- with .Title}}
+<meta property="og:title" content="{{.}}">
+<meta name="twitter:title" content="{{.}}">
+{{- end}}
+{{- with .Description}}
+<meta property="og:description" content="{{.}}">
+<meta name="description" content="{{.}}">
+<meta name="twitter:description" content="{{.}}">
+{{- end}}
+{{- with .Image}}
+<meta property="og:image" content="{{.}}">
+<meta name="twitter:image" content="{{.}}">
+<meta name="twitter:card" content="summary_large_image">
+{{- else}}
+<meta name="twitter:card" content="summary">
+{{- end}}
+<meta http-equiv="refresh" content="0; url={{.Destination}}">
+</head>
+<body>
+<p>Redirecting to <a href="{{.Destination}}">{{.Destination}}</a></p>
+</body>
+</html>
+`))
+
+type socialPreviewPage struct {
+	models.SocialPreview
+	ShortURL    string
+	Destination string
+}
+
+// isCrawler reports whether the request comes from a link unfurling crawler
+func isCrawler(request *http.Request) bool {
+	userAgent := strings.ToLower(request.UserAgent())
+	for _, crawler := range crawlerUserAgents {
+		if strings.Contains(userAgent, crawler) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// renderSocialPreview writes a page carrying the Open Graph tags of the link's social preview
+// that also sends any browser that ends up on it on to the destination
+func renderSocialPreview(
+	responseWriter http.ResponseWriter,
+	request *http.Request,
+	mapping *models.URLMapping,
+	shortURL string,
+) {
+	page := socialPreviewPage{
+		SocialPreview: *mapping.SocialPreview,
+		ShortURL:      shortURL,
+		Destination:   mapping.URL,
+	}
+
+	var body strings.Builder
+	if err := socialPreviewTemplate.Execute(&body, page); err != nil {
+		slog.ErrorContext(request.Context(), "Failed rendering social preview", "short_code", mapping.ShortCode, "error", err)
+		http.Redirect(responseWriter, request, mapping.URL, http.StatusFound)
+		return
+	}
+
+	responseWriter.Header().Set("Content-Type", "text/html; charset=utf-8")
+	responseWriter.Header().Set("Vary", "User-Agent")
+	responseWriter.WriteHeader(http.StatusOK)
+	responseWriter.Write([]byte(body.String()))
+}
@@ -0,0 +1,112 @@
+package handlers
+
+import (
+	"github.com/aarondever/linko/internal/models"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestIsCrawler(t *testing.T) {
+	tests := []struct {
+		userAgent string
+		crawler   bool
+	}{
+		{"Slackbot-LinkExpanding 1.0 (+https://api.slack.com/robots)", true},
+		{"Slackbot 1.0 (+https://api.slack.com/robots)", true},
+		{"Slack-ImgProxy (+https://api.slack.com/robots)", true},
+		{"Twitterbot/1.0", true},
+		{"facebookexternalhit/1.1 (+http://www.facebook.com/externalhit_uatext.php)", true},
+		{"Facebot", true},
+		{"LinkedInBot/1.0 (compatible; Mozilla/5.0; Apache-HttpClient +http://www.linkedin.com)", true},
+		{"Mozilla/5.0 (compatible; Discordbot/2.0; +https://discordapp.com)", true},
+		{"TelegramBot (like TwitterBot)", true},
+		{"WhatsApp/2.23.20.0", true},
+		{"Mozilla/5.0 (Macintosh; Intel Mac OS X 10_15_7) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/17.0 Safari/605.1.15", false},
+		{"Mozilla/5.0 (Windows NT 10.0; Win64; x64; rv:128.0) Gecko/20100101 Firefox/128.0", false},
+		{"curl/8.4.0", false},
+		{"Googlebot/2.1 (+http://www.google.com/bot.html)", false},
+		{"", false},
+	}
+
+	for _, test := range tests {
+		request := httptest.NewRequest(http.MethodGet, "/r/abc123", nil)
+		request.Header.Set("User-Agent", test.userAgent)
+
+		if got := isCrawler(request); got != test.crawler {
+			t.Errorf("isCrawler(%q) = %t, want %t", test.userAgent, got, test.crawler)
+		}
+	}
+}
+
+func TestRenderSocialPreview(t *testing.T) {
+	tests := []struct {
+		name     string
+		preview  models.SocialPreview
+		contains []string
+		excludes []string
+	}{
+		{
+			name: "preview with image",
+			preview: models.SocialPreview{
+				Title:       "Spring sale",
+				Description: "Everything 20% off",
+				Image:       "https://cdn.example/sale.png",
+			},
+			contains: []string{
+				`<meta property="og:title" content="Spring sale">`,
+				`<meta property="og:description" content="Everything 20% off">`,
+				`<meta property="og:image" content="https://cdn.example/sale.png">`,
+				`<meta name="twitter:card" content="summary_large_image">`,
+				`<meta property="og:url" content="https://lnk.example/abc123">`,
+				`<meta http-equiv="refresh" content="0; url=https://shop.example/sale?a=1&amp;b=2">`,
+			},
+		},
+		{
+			name:     "preview without image",
+			preview:  models.SocialPreview{Title: "Spring sale"},
+			contains: []string{`<meta name="twitter:card" content="summary">`},
+			excludes: []string{"og:image", "og:description"},
+		},
+		{
+			name:     "text is escaped",
+			preview:  models.SocialPreview{Title: `"><script>alert(1)</script>`},
+			contains: []string{`content="&#34;&gt;&lt;script&gt;alert(1)&lt;/script&gt;"`},
+			excludes: []string{"<script>"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			mapping := &models.URLMapping{
+				ShortCode:     "abc123",
+				URL:           "https://shop.example/sale?a=1&b=2",
+				SocialPreview: &test.preview,
+			}
+			recorder := httptest.NewRecorder()
+			request := httptest.NewRequest(http.MethodGet, "/r/abc123", nil)
+
+			renderSocialPreview(recorder, request, mapping, "https://lnk.example/abc123")
+
+			if recorder.Code != http.StatusOK {
+				t.Errorf("status = %d, want %d", recorder.Code, http.StatusOK)
+			}
+			if vary := recorder.Header().Get("Vary"); vary != "User-Agent" {
+				t.Errorf("Vary = %q, want User-Agent", vary)
+			}
+
+			body := recorder.Body.String()
+			for _, want := range test.contains {
+				if !strings.Contains(body, want) {
+					t.Errorf("body does not contain %s:\n%s", want, body)
+				}
+			}
+			for _, unwanted := range test.excludes {
+				if strings.Contains(body, unwanted) {
+					t.Errorf("body contains %s:\n%s", unwanted, body)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,52 @@
+package handlers
+
+import (
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+	"net/http"
+)
+
+var tracer = otel.Tracer("github.com/aarondever/linko/internal/handlers")
+
+// Tracing starts a server span for every request, continuing the trace of the caller given in
+// W3C traceparent headers. The span is named after the route pattern once the request is routed,
+// so that all requests to a route share one span name.
+func Tracing(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(responseWriter http.ResponseWriter, request *http.Request) {
+		ctx := otel.GetTextMapPropagator().Extract(request.Context(), propagation.HeaderCarrier(request.Header))
+		ctx, span := tracer.Start(ctx, request.Method,
+			trace.WithSpanKind(trace.SpanKindServer),
+			trace.WithAttributes(
+				attribute.String("http.request.method", request.Method),
+				attribute.String("url.path", request.URL.Path),
+				attribute.String("server.address", request.Host),
+			))
+		defer span.End()
+
+		if requestID := middleware.GetReqID(ctx); requestID != "" {
+			span.SetAttributes(attribute.String("linko.request_id", requestID))
+		}
+
+		wrapped := middleware.NewWrapResponseWriter(responseWriter, request.ProtoMajor)
+		next.ServeHTTP(wrapped, request.WithContext(ctx))
+
+		if routeContext := chi.RouteContext(ctx); routeContext != nil && routeContext.RoutePattern() != "" {
+			span.SetName(request.Method + " " + routeContext.RoutePattern())
+			span.SetAttributes(attribute.String("http.route", routeContext.RoutePattern()))
+		}
+
+		status := wrapped.Status()
+		if status == 0 {
+			status = http.StatusOK
+		}
+		span.SetAttributes(attribute.Int("http.response.status_code", status))
+		if status >= http.StatusInternalServerError {
+			span.SetStatus(codes.Error, http.StatusText(status))
+		}
+	})
+}
@@ -0,0 +1,182 @@
+package handlers
+
+import (
+	"fmt"
+	"github.com/aarondever/linko/internal/models"
+	"github.com/aarondever/linko/internal/openapi"
+	"github.com/aarondever/linko/internal/services"
+	"github.com/aarondever/linko/internal/utils"
+	"github.com/go-chi/chi/v5"
+	"log/slog"
+	"mime"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+type TransferHandler struct {
+	transferService *services.TransferService
+	rateLimit       *RateLimitMiddleware
+}
+
+func NewTransferHandler(transferService *services.TransferService, rateLimit *RateLimitMiddleware) *TransferHandler {
+	return &TransferHandler{
+		transferService: transferService,
+		rateLimit:       rateLimit,
+	}
+}
+
+func (handler *TransferHandler) RegisterRoutes(router chi.Router) {
+	router.With(handler.rateLimit.Management).Get("/api/v1/export", handler.Export)
+	router.With(handler.rateLimit.Management).Post("/api/v1/import", handler.Import)
+}
+
+// Export streams the links of the caller's workspace as NDJSON (default) or CSV, selected by the
+// "format" query parameter. Click stats are included when "include_stats" is true.
+func (handler *TransferHandler) Export(responseWriter http.ResponseWriter, request *http.Request) {
+	format := request.URL.Query().Get("format")
+	if format == "" {
+		format = models.TransferFormatNDJSON
+	}
+
+	includeStats, err := parseBoolQuery(request, "include_stats")
+	if err != nil {
+		respondWithError(responseWriter, request, invalidQueryError("include_stats", "must be a boolean"))
+		return
+	}
+
+	var contentType string
+	switch format {
+	case models.TransferFormatNDJSON:
+		contentType = "application/x-ndjson"
+	case models.TransferFormatCSV:
+		contentType = "text/csv"
+	default:
+		respondWithError(responseWriter, request, services.ErrUnsupportedTransferFormat)
+		return
+	}
+
+	filename := fmt.Sprintf("linko-export-%s.%s", time.Now().Format("20060102-150405"), format)
+	responseWriter.Header().Set("Content-Type", contentType)
+	responseWriter.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+	responseWriter.WriteHeader(http.StatusOK)
+
+	// Headers are already sent, so failures can only be logged and surface as a truncated body
+	if err = handler.transferService.Export(request.Context(), responseWriter,
+		WorkspaceFromContext(request.Context()), format, includeStats); err != nil {
+		slog.ErrorContext(request.Context(), "Failed streaming export", "format", format, "error", err)
+	}
+}
+
+// Import upserts links from an NDJSON or CSV body into the caller's workspace. The format is taken from the "format" query
+// parameter or else the Content-Type header. Short codes already pointing at a different URL are
+// kept unless "on_conflict" is set to overwrite. With "dry_run" set to true nothing is written and
+// the response reports what the import would change, including conflicting short codes.
+func (handler *TransferHandler) Import(responseWriter http.ResponseWriter, request *http.Request) {
+	dryRun, err := parseBoolQuery(request, "dry_run")
+	if err != nil {
+		respondWithError(responseWriter, request, invalidQueryError("dry_run", "must be a boolean"))
+		return
+	}
+
+	format := request.URL.Query().Get("format")
+	if format == "" {
+		mediaType, _, _ := mime.ParseMediaType(request.Header.Get("Content-Type"))
+		switch mediaType {
+		case "text/csv":
+			format = models.TransferFormatCSV
+		default:
+			format = models.TransferFormatNDJSON
+		}
+	}
+
+	onConflict := request.URL.Query().Get("on_conflict")
+	if onConflict == "" {
+		onConflict = models.ImportConflictSkip
+	}
+
+	report, err := handler.transferService.Import(request.Context(), request.Body,
+		WorkspaceFromContext(request.Context()), format, onConflict, dryRun)
+	if err != nil {
+		respondWithError(responseWriter, request, err)
+		return
+	}
+
+	utils.RespondWithJSON(responseWriter, report, http.StatusOK)
+}
+
+// parseBoolQuery parses an optional boolean query parameter, defaulting to false
+func parseBoolQuery(request *http.Request, name string) (bool, error) {
+	value := request.URL.Query().Get(name)
+	if value == "" {
+		return false, nil
+	}
+
+	return strconv.ParseBool(value)
+}
+
+// DescribeRoutes documents the routes registered by RegisterRoutes
+func (handler *TransferHandler) DescribeRoutes(document *openapi.Document) {
+	rateLimited := errorResponse(document, "Rate limit exceeded")
+	adminKeyRequired := errorResponse(document, "Admin API key required")
+
+	document.AddOperation(http.MethodGet, "/api/v1/export", openapi.Operation{
+		OperationID: "exportLinks",
+		Summary:     "Export links",
+		Description: "Streams the links on the default host and on the custom domains of the caller's workspace.",
+		Tags:        []string{"transfer"},
+		Parameters: []openapi.Parameter{
+			{Name: "format", In: "query", Description: "Export format (default ndjson)", Schema: &openapi.Schema{
+				Type: "string",
+				Enum: []string{models.TransferFormatNDJSON, models.TransferFormatCSV},
+			}},
+			openapi.QueryParam("include_stats", "boolean", "Include click stats"),
+		},
+		Responses: map[string]openapi.Response{
+			openapi.Status(http.StatusOK): {
+				Description: "Stream of links, one record per line or row",
+				Content: map[string]openapi.MediaType{
+					"application/x-ndjson": {Schema: document.SchemaRef(models.TransferRecord{})},
+					"text/csv":             {Schema: &openapi.Schema{Type: "string"}},
+				},
+			},
+			openapi.Status(http.StatusBadRequest):      errorResponse(document, "Invalid parameters"),
+			openapi.Status(http.StatusTooManyRequests): rateLimited,
+			openapi.Status(http.StatusForbidden):       adminKeyRequired,
+		},
+	})
+
+	document.AddOperation(http.MethodPost, "/api/v1/import", openapi.Operation{
+		OperationID: "importLinks",
+		Summary:     "Import links",
+		Description: "Upserts links with their original short codes and creation times. Links on custom domains " +
+			"are rejected unless the domain is a verified domain of the caller's workspace, and so are destinations " +
+			"failing the checks applied to new short links. Existing short codes " +
+			"pointing at a different URL are reported as conflicts and kept, or replaced when on_conflict is overwrite.",
+		Tags: []string{"transfer"},
+		Parameters: []openapi.Parameter{
+			{Name: "format", In: "query", Description: "Import format, defaults to the Content-Type", Schema: &openapi.Schema{
+				Type: "string",
+				Enum: []string{models.TransferFormatNDJSON, models.TransferFormatCSV},
+			}},
+			{Name: "on_conflict", In: "query", Description: "Whether to skip (default) or overwrite short codes pointing at a different URL", Schema: &openapi.Schema{
+				Type: "string",
+				Enum: []string{models.ImportConflictSkip, models.ImportConflictOverwrite},
+			}},
+			openapi.QueryParam("dry_run", "boolean", "Report what would change without writing anything"),
+		},
+		RequestBody: &openapi.RequestBody{
+			Required: true,
+			Content: map[string]openapi.MediaType{
+				"application/x-ndjson": {Schema: document.SchemaRef(models.TransferRecord{})},
+				"text/csv":             {Schema: &openapi.Schema{Type: "string"}},
+			},
+		},
+		Responses: map[string]openapi.Response{
+			openapi.Status(http.StatusOK):              openapi.JSONResponse("Import report", document.SchemaRef(models.ImportReport{})),
+			openapi.Status(http.StatusBadRequest):      errorResponse(document, "Invalid import data"),
+			openapi.Status(http.StatusTooManyRequests): rateLimited,
+			openapi.Status(http.StatusForbidden):       adminKeyRequired,
+		},
+	})
+}
@@ -1,58 +1,221 @@
 package handlers
 
 import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/aarondever/linko/internal/config"
+	"github.com/aarondever/linko/internal/landing"
 	"github.com/aarondever/linko/internal/models"
+	"github.com/aarondever/linko/internal/openapi"
 	"github.com/aarondever/linko/internal/services"
 	"github.com/aarondever/linko/internal/utils"
 	"github.com/go-chi/chi/v5"
+	"io"
+	"mime"
 	"net/http"
+	"strconv"
+	"strings"
 )
 
+// Page size bounds for listing short links
+const (
+	defaultListLimit = 50
+	maxListLimit     = 1000
+)
+
+// errTooManyItems is returned when a bulk request exceeds the configured item limit
+var errTooManyItems = errors.New("too many items")
+
 type URLHandler struct {
-	urlService *services.URLService
+	urlService      *services.URLService
+	qrService       *services.QRService
+	domainService   *services.DomainService
+	brandingService *services.BrandingService
+	pages           *landing.Pages
+	rateLimit       *RateLimitMiddleware
+	cfg             *config.Config
 }
 
-func NewURLHandler(urlService *services.URLService) *URLHandler {
-	return &URLHandler{urlService: urlService}
+func NewURLHandler(
+	urlService *services.URLService,
+	qrService *services.QRService,
+	domainService *services.DomainService,
+	brandingService *services.BrandingService,
+	rateLimit *RateLimitMiddleware,
+	cfg *config.Config,
+) *URLHandler {
+	return &URLHandler{
+		urlService:      urlService,
+		qrService:       qrService,
+		domainService:   domainService,
+		brandingService: brandingService,
+		pages:           loadLandingPages(cfg),
+		rateLimit:       rateLimit,
+		cfg:             cfg,
+	}
 }
 
-func (handler *URLHandler) RegisterRoutes(router *chi.Mux) {
+// RegisterLegacyRoutes registers the shorten and lookup routes that predate API keys
+func (handler *URLHandler) RegisterLegacyRoutes(router chi.Router) {
 	router.Route("/api/v1/url", func(router chi.Router) {
-		router.Post("/shorten", handler.ShortenURL)
-		router.Get("/shorten/{shortCode}", handler.GetURL)
+		router.With(handler.rateLimit.Shorten).Post("/shorten", handler.ShortenURL)
+		router.With(handler.rateLimit.Management).Get("/shorten/{shortCode}", handler.GetURL)
+	})
+}
+
+func (handler *URLHandler) RegisterRoutes(router chi.Router) {
+	router.Route("/api/v1/urls", func(router chi.Router) {
+		shorten := router.With(handler.rateLimit.Shorten)
+		manage := router.With(handler.rateLimit.Management)
+
+		manage.Get("/", handler.ListURLs)
+		shorten.Post("/bulk", handler.BulkShortenURL)
+		manage.Get("/{shortCode}", handler.GetURLMapping)
+		manage.Patch("/{shortCode}", handler.UpdateURL)
+		manage.Delete("/{shortCode}", handler.DeleteURL)
+		manage.Get("/{shortCode}/stats", handler.GetURLStats)
+		manage.Get("/{shortCode}/qr", handler.GetQRCode)
 	})
+}
 
-	router.Get("/r/{shortCode}", handler.RedirectShortURL)
+// redirectPath is the route pattern of short links under the configured prefix. Static routes
+// such as /api/... take precedence over it, even when short links are served from the root path.
+func (handler *URLHandler) redirectPath() string {
+	return handler.cfg.Server.ShortLinkPrefix() + "/{shortCode}"
+}
+
+// RegisterPublicRoutes registers the routes that are served without authentication
+func (handler *URLHandler) RegisterPublicRoutes(router chi.Router) {
+	router.With(handler.rateLimit.Redirect).Get(handler.redirectPath(), handler.RedirectShortURL)
 }
 
 func (handler *URLHandler) ShortenURL(responseWriter http.ResponseWriter, request *http.Request) {
 	var params models.ShortenURLRequest
-	if err := utils.DecodeRequestBody(request, &params); err != nil {
-		utils.RespondWithError(responseWriter, "Invalid request body", http.StatusBadRequest)
+	if !decodeRequestBody(responseWriter, request, &params) {
 		return
 	}
 
-	shortCode, err := handler.urlService.ShortenURL(request.Context(), params.URL)
+	domain, err := handler.authorizeDomain(request, params.Domain, true)
 	if err != nil {
-		utils.RespondWithError(responseWriter, err.Error(), http.StatusInternalServerError)
+		respondWithError(responseWriter, request, err)
+		return
+	}
+
+	shortCode, err := handler.urlService.ShortenURL(request.Context(), domain, params.URL)
+	if err != nil {
+		respondWithError(responseWriter, request, err)
 		return
 	}
 
 	utils.RespondWithJSON(responseWriter, models.ShortenURLResponse{
 		ShortCode: shortCode,
+		Domain:    domain,
+		ShortURL:  handler.shortURL(request, domain, shortCode),
 	}, http.StatusCreated)
 }
 
+// BulkShortenURL shortens a batch of URLs sent as a JSON array of shorten requests,
+// a text/csv body, or a CSV file uploaded as the "file" field of a multipart form.
+// CSV input takes the URL from the first column and may start with a "url" header row.
+// All links are created on the custom domain given by the "domain" query parameter, if any.
+// Failures are reported per item; when no item succeeds the response status is 422.
+func (handler *URLHandler) BulkShortenURL(responseWriter http.ResponseWriter, request *http.Request) {
+	maxItems := handler.cfg.URL.BulkMaxItems
+
+	domain, err := handler.linkDomain(request, true)
+	if err != nil {
+		respondWithError(responseWriter, request, err)
+		return
+	}
+
+	urls, err := decodeBulkShortenRequest(request, maxItems)
+	if err != nil {
+		if errors.Is(err, errTooManyItems) {
+			respondWithError(responseWriter, request, services.Errorf(services.ErrorKindTooLarge,
+				"too_many_urls", "Too many URLs, at most %d are allowed per request", maxItems))
+			return
+		}
+
+		respondWithError(responseWriter, request, errInvalidBody)
+		return
+	}
+
+	if len(urls) == 0 {
+		respondWithError(responseWriter, request, errNoURLs)
+		return
+	}
+
+	// Validate each URL up front and only shorten the valid ones
+	results := make([]models.BulkShortenResult, len(urls))
+	var validURLs []string
+	var validIndexes []int
+	for i, url := range urls {
+		results[i] = models.BulkShortenResult{Index: i, URL: url}
+
+		if err = utils.ValidateStruct(models.ShortenURLRequest{URL: url}); err != nil {
+			results[i].Error = "Invalid URL"
+			continue
+		}
+
+		validURLs = append(validURLs, url)
+		validIndexes = append(validIndexes, i)
+	}
+
+	if len(validURLs) > 0 {
+		shortened, err := handler.urlService.ShortenURLs(request.Context(), domain, validURLs)
+		if err != nil {
+			respondWithError(responseWriter, request, err)
+			return
+		}
+
+		for i, result := range shortened {
+			result.Index = validIndexes[i]
+			if result.ShortCode != "" {
+				result.ShortURL = handler.shortURL(request, domain, result.ShortCode)
+			}
+			results[validIndexes[i]] = result
+		}
+	}
+
+	response, status := newBulkShortenResponse(results)
+	utils.RespondWithJSON(responseWriter, response, status)
+}
+
+// newBulkShortenResponse counts the outcomes of a bulk request and picks its status, where a
+// batch in which nothing could be shortened is reported as failed as a whole
+func newBulkShortenResponse(results []models.BulkShortenResult) (models.BulkShortenResponse, int) {
+	response := models.BulkShortenResponse{
+		Total:   len(results),
+		Results: results,
+	}
+	for _, result := range results {
+		if result.Error == "" {
+			response.Succeeded++
+		} else {
+			response.Failed++
+		}
+	}
+
+	if response.Succeeded == 0 {
+		return response, http.StatusUnprocessableEntity
+	}
+
+	return response, http.StatusOK
+}
+
 func (handler *URLHandler) GetURL(responseWriter http.ResponseWriter, request *http.Request) {
-	shortCode := request.PathValue("shortCode")
-	originalURL, err := handler.urlService.GetURL(request.Context(), shortCode)
+	domain, err := handler.linkDomain(request, false)
 	if err != nil {
-		utils.RespondWithError(responseWriter, err.Error(), http.StatusInternalServerError)
+		respondWithError(responseWriter, request, err)
 		return
 	}
 
-	if originalURL == "" {
-		utils.RespondWithError(responseWriter, "URL not found", http.StatusNotFound)
+	shortCode := request.PathValue("shortCode")
+	originalURL, err := handler.urlService.GetURL(request.Context(), domain, shortCode)
+	if err != nil {
+		respondWithError(responseWriter, request, err)
 		return
 	}
 
@@ -61,18 +224,501 @@ func (handler *URLHandler) GetURL(responseWriter http.ResponseWriter, request *h
 	}, http.StatusOK)
 }
 
+// ListURLs lists short links from newest to oldest, paginated by the "offset" and "limit" query parameters.
+// With "broken" set to true only links whose destination is reported broken are listed. Links on
+// a custom domain are listed when it is given by the "domain" query parameter.
+func (handler *URLHandler) ListURLs(responseWriter http.ResponseWriter, request *http.Request) {
+	domain, err := handler.linkDomain(request, false)
+	if err != nil {
+		respondWithError(responseWriter, request, err)
+		return
+	}
+
+	offset, err := parseIntQuery(request, "offset", 0)
+	if err != nil || offset < 0 {
+		respondWithError(responseWriter, request, invalidQueryError("offset", "must be a non-negative integer"))
+		return
+	}
+
+	limit, err := parseIntQuery(request, "limit", defaultListLimit)
+	if err != nil || limit < 1 || limit > maxListLimit {
+		respondWithError(responseWriter, request,
+			invalidQueryError("limit", fmt.Sprintf("must be an integer between 1 and %d", maxListLimit)))
+		return
+	}
+
+	broken, err := parseBoolQuery(request, "broken")
+	if err != nil {
+		respondWithError(responseWriter, request, invalidQueryError("broken", "must be a boolean"))
+		return
+	}
+
+	filter := models.URLFilter{Domain: domain, Broken: broken}
+	mappings, err := handler.urlService.ListURLMappings(request.Context(), filter, offset, limit)
+	if err != nil {
+		respondWithError(responseWriter, request, err)
+		return
+	}
+
+	for i := range mappings {
+		mappings[i].ShortURL = handler.shortURL(request, mappings[i].Domain, mappings[i].ShortCode)
+	}
+
+	utils.RespondWithJSON(responseWriter, models.ListURLsResponse{
+		URLs:   mappings,
+		Offset: offset,
+		Limit:  limit,
+	}, http.StatusOK)
+}
+
+func (handler *URLHandler) GetURLMapping(responseWriter http.ResponseWriter, request *http.Request) {
+	domain, err := handler.linkDomain(request, false)
+	if err != nil {
+		respondWithError(responseWriter, request, err)
+		return
+	}
+
+	mapping, err := handler.urlService.GetURLMapping(request.Context(), domain, request.PathValue("shortCode"))
+	if err != nil {
+		respondWithError(responseWriter, request, err)
+		return
+	}
+	mapping.ShortURL = handler.shortURL(request, mapping.Domain, mapping.ShortCode)
+
+	utils.RespondWithJSON(responseWriter, mapping, http.StatusOK)
+}
+
+func (handler *URLHandler) UpdateURL(responseWriter http.ResponseWriter, request *http.Request) {
+	var params models.UpdateURLRequest
+	if !decodeRequestBody(responseWriter, request, &params) {
+		return
+	}
+
+	domain, err := handler.linkDomain(request, false)
+	if err != nil {
+		respondWithError(responseWriter, request, err)
+		return
+	}
+
+	mapping, err := handler.urlService.UpdateURL(request.Context(), domain, request.PathValue("shortCode"), params)
+	if err != nil {
+		respondWithError(responseWriter, request, err)
+		return
+	}
+	mapping.ShortURL = handler.shortURL(request, mapping.Domain, mapping.ShortCode)
+
+	utils.RespondWithJSON(responseWriter, mapping, http.StatusOK)
+}
+
+func (handler *URLHandler) DeleteURL(responseWriter http.ResponseWriter, request *http.Request) {
+	domain, err := handler.linkDomain(request, false)
+	if err != nil {
+		respondWithError(responseWriter, request, err)
+		return
+	}
+
+	if err = handler.urlService.DeleteURL(request.Context(), domain, request.PathValue("shortCode")); err != nil {
+		respondWithError(responseWriter, request, err)
+		return
+	}
+
+	responseWriter.WriteHeader(http.StatusNoContent)
+}
+
+func (handler *URLHandler) GetURLStats(responseWriter http.ResponseWriter, request *http.Request) {
+	domain, err := handler.linkDomain(request, false)
+	if err != nil {
+		respondWithError(responseWriter, request, err)
+		return
+	}
+
+	mapping, err := handler.urlService.GetURLMapping(request.Context(), domain, request.PathValue("shortCode"))
+	if err != nil {
+		respondWithError(responseWriter, request, err)
+		return
+	}
+
+	utils.RespondWithJSON(responseWriter, models.URLStatsResponse{
+		ShortCode:     mapping.ShortCode,
+		ClickCount:    mapping.ClickCount,
+		LastClickedAt: mapping.LastClickedAt,
+		CreatedAt:     mapping.CreatedAt,
+	}, http.StatusOK)
+}
+
+// RedirectShortURL redirects to the destination of a short link. Redirects are temporary so that
+// browsers come back for every click and see updated or disabled links. Link unfurling crawlers
+// get the social preview page of links that configure one, and their requests are not counted as
+// clicks. Requests sent to a verified custom domain resolve the short codes of that domain.
 func (handler *URLHandler) RedirectShortURL(responseWriter http.ResponseWriter, request *http.Request) {
 	shortCode := request.PathValue("shortCode")
-	originalURL, err := handler.urlService.GetURL(request.Context(), shortCode)
+
+	// Responses differ between crawlers and browsers, and errors between browsers and API clients
+	responseWriter.Header().Set("Vary", "User-Agent, Accept")
+
+	domain, err := handler.domainService.ResolveHost(request.Context(), request.Host)
 	if err != nil {
-		utils.RespondWithError(responseWriter, err.Error(), http.StatusInternalServerError)
+		respondWithError(responseWriter, request, err)
 		return
 	}
 
-	if originalURL == "" {
-		utils.RespondWithError(responseWriter, "URL not found", http.StatusNotFound)
+	if isCrawler(request) {
+		mapping, err := handler.urlService.GetPreviewMapping(request.Context(), domain, shortCode)
+		if err != nil {
+			handler.respondWithLandingPage(responseWriter, request, domain, shortCode, err)
+			return
+		}
+
+		if mapping.SocialPreview != nil {
+			renderSocialPreview(responseWriter, request, mapping, handler.shortURL(request, domain, shortCode))
+			return
+		}
+
+		http.Redirect(responseWriter, request, mapping.URL, http.StatusFound)
+		return
+	}
+
+	originalURL, err := handler.urlService.ResolveShortCode(request.Context(), domain, shortCode)
+	if err != nil {
+		handler.respondWithLandingPage(responseWriter, request, domain, shortCode, err)
 		return
 	}
 
-	http.Redirect(responseWriter, request, originalURL, http.StatusMovedPermanently)
+	http.Redirect(responseWriter, request, originalURL, http.StatusFound)
+}
+
+// shortURL builds the public short URL of a link, falling back to the scheme and host of the
+// request when no base URL is configured
+func (handler *URLHandler) shortURL(request *http.Request, domain, shortCode string) string {
+	scheme := "http"
+	if request.TLS != nil {
+		scheme = "https"
+	}
+
+	return handler.urlService.ShortURL(scheme+"://"+request.Host, domain, shortCode)
+}
+
+// authorizeDomain checks that a custom domain belongs to the workspace of the caller and returns
+// its normalized hostname. An empty hostname selects the default host.
+func (handler *URLHandler) authorizeDomain(request *http.Request, hostname string, requireVerified bool) (string, error) {
+	if hostname == "" {
+		return "", nil
+	}
+
+	workspace := WorkspaceFromContext(request.Context())
+	return handler.domainService.AuthorizeDomain(request.Context(), workspace, hostname, requireVerified)
+}
+
+// linkDomain returns the custom domain selected by the "domain" query parameter, see authorizeDomain
+func (handler *URLHandler) linkDomain(request *http.Request, requireVerified bool) (string, error) {
+	return handler.authorizeDomain(request, request.URL.Query().Get("domain"), requireVerified)
+}
+
+// decodeBulkShortenRequest extracts the URLs of a bulk shorten request based on its content type
+func decodeBulkShortenRequest(request *http.Request, maxItems int) ([]string, error) {
+	mediaType, _, err := mime.ParseMediaType(request.Header.Get("Content-Type"))
+	if err != nil {
+		mediaType = "application/json"
+	}
+
+	switch mediaType {
+	case "text/csv":
+		return readCSVURLs(request.Body, maxItems)
+	case "multipart/form-data":
+		file, _, err := request.FormFile("file")
+		if err != nil {
+			return nil, err
+		}
+		defer file.Close()
+
+		return readCSVURLs(file, maxItems)
+	default:
+		return readJSONURLs(request.Body, maxItems)
+	}
+}
+
+// readJSONURLs reads the URLs of a JSON array of shorten requests item by item, so that no more
+// than maxItems items are ever decoded
+func readJSONURLs(reader io.Reader, maxItems int) ([]string, error) {
+	decoder := json.NewDecoder(reader)
+	if token, err := decoder.Token(); err != nil {
+		return nil, err
+	} else if token != json.Delim('[') {
+		return nil, errors.New("expected a JSON array")
+	}
+
+	var urls []string
+	for decoder.More() {
+		if len(urls) == maxItems {
+			return nil, errTooManyItems
+		}
+
+		var param models.ShortenURLRequest
+		if err := decoder.Decode(&param); err != nil {
+			return nil, err
+		}
+
+		urls = append(urls, param.URL)
+	}
+
+	if _, err := decoder.Token(); err != nil {
+		return nil, err
+	}
+
+	return urls, nil
+}
+
+// readCSVURLs reads URLs from the first column of a CSV stream, skipping an optional header row
+func readCSVURLs(reader io.Reader, maxItems int) ([]string, error) {
+	csvReader := csv.NewReader(reader)
+	csvReader.FieldsPerRecord = -1
+	csvReader.TrimLeadingSpace = true
+
+	var urls []string
+	for row := 0; ; row++ {
+		record, err := csvReader.Read()
+		if errors.Is(err, io.EOF) {
+			return urls, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		url := strings.TrimSpace(record[0])
+		if row == 0 && strings.EqualFold(url, "url") {
+			continue
+		}
+
+		if url == "" {
+			continue
+		}
+
+		if len(urls) == maxItems {
+			return nil, errTooManyItems
+		}
+
+		urls = append(urls, url)
+	}
+}
+
+// parseIntQuery parses an optional integer query parameter, returning defaultValue when absent
+func parseIntQuery(request *http.Request, name string, defaultValue int64) (int64, error) {
+	value := request.URL.Query().Get(name)
+	if value == "" {
+		return defaultValue, nil
+	}
+
+	return strconv.ParseInt(value, 10, 64)
+}
+
+// DescribeRoutes documents the routes registered by RegisterRoutes and RegisterPublicRoutes
+func (handler *URLHandler) DescribeRoutes(document *openapi.Document) {
+	shortCodeParam := openapi.PathParam("shortCode", "Short code of the link")
+	domainParam := openapi.QueryParam("domain", "string", "Custom domain of the link, the default host when omitted")
+	urlMapping := document.SchemaRef(models.URLMapping{})
+	notFound := errorResponse(document, "Short code not found")
+	rateLimited := errorResponse(document, "Rate limit exceeded")
+	unsafeURL := errorResponse(document, "URL was flagged as unsafe")
+
+	document.AddOperation(http.MethodPost, "/api/v1/url/shorten", openapi.Operation{
+		OperationID: "shortenURL",
+		Summary:     "Shorten a URL",
+		Description: "Served without an API key until the first key is created, even when keys are required.",
+		Tags:        []string{"urls"},
+		RequestBody: openapi.JSONBody(document.SchemaRef(models.ShortenURLRequest{})),
+		Responses: map[string]openapi.Response{
+			openapi.Status(http.StatusCreated):         openapi.JSONResponse("Short link created", document.SchemaRef(models.ShortenURLResponse{})),
+			openapi.Status(http.StatusBadRequest):      errorResponse(document, "Invalid request body"),
+			openapi.Status(http.StatusForbidden):       unsafeURL,
+			openapi.Status(http.StatusTooManyRequests): rateLimited,
+		},
+	})
+
+	document.AddOperation(http.MethodGet, "/api/v1/url/shorten/{shortCode}", openapi.Operation{
+		OperationID: "getOriginalURL",
+		Summary:     "Get the original URL of a short code",
+		Description: "Served without an API key until the first key is created, even when keys are required.",
+		Tags:        []string{"urls"},
+		Parameters:  []openapi.Parameter{shortCodeParam, domainParam},
+		Responses: map[string]openapi.Response{
+			openapi.Status(http.StatusOK):              openapi.JSONResponse("Original URL", document.SchemaRef(models.GetURLResponse{})),
+			openapi.Status(http.StatusNotFound):        notFound,
+			openapi.Status(http.StatusTooManyRequests): rateLimited,
+		},
+	})
+
+	document.AddOperation(http.MethodGet, "/api/v1/urls", openapi.Operation{
+		OperationID: "listURLs",
+		Summary:     "List short links, newest first",
+		Tags:        []string{"urls"},
+		Parameters: []openapi.Parameter{
+			openapi.QueryParam("offset", "integer", "Number of links to skip"),
+			openapi.QueryParam("limit", "integer", fmt.Sprintf("Page size, 1 to %d (default %d)", maxListLimit, defaultListLimit)),
+			openapi.QueryParam("broken", "boolean", "Only list links whose destination is reported broken"),
+			openapi.QueryParam("domain", "string", "Only list links on this custom domain, else links on the default host"),
+		},
+		Responses: map[string]openapi.Response{
+			openapi.Status(http.StatusOK):              openapi.JSONResponse("Page of short links", document.SchemaRef(models.ListURLsResponse{})),
+			openapi.Status(http.StatusBadRequest):      errorResponse(document, "Invalid query parameters"),
+			openapi.Status(http.StatusTooManyRequests): rateLimited,
+		},
+	})
+
+	document.AddOperation(http.MethodPost, "/api/v1/urls/bulk", openapi.Operation{
+		OperationID: "bulkShortenURLs",
+		Summary:     "Shorten a batch of URLs",
+		Description: "Accepts a JSON array of shorten requests, a text/csv body, or a CSV file uploaded as the " +
+			"\"file\" field of a multipart form. CSV input takes the URL from the first column and may start " +
+			"with a \"url\" header row. Failures are reported per item; when no item succeeds the response " +
+			"status is 422.",
+		Tags: []string{"urls"},
+		Parameters: []openapi.Parameter{
+			openapi.QueryParam("domain", "string",
+				"Verified custom domain to create every link on; the domain field of JSON items is not used"),
+		},
+		RequestBody: &openapi.RequestBody{
+			Required: true,
+			Content: map[string]openapi.MediaType{
+				"application/json": {Schema: openapi.ArrayOf(document.SchemaRef(models.ShortenURLRequest{}))},
+				"text/csv":         {Schema: &openapi.Schema{Type: "string"}},
+				"multipart/form-data": {Schema: &openapi.Schema{
+					Type:       "object",
+					Properties: map[string]*openapi.Schema{"file": {Type: "string", Format: "binary"}},
+					Required:   []string{"file"},
+				}},
+			},
+		},
+		Responses: map[string]openapi.Response{
+			openapi.Status(http.StatusTooManyRequests):       rateLimited,
+			openapi.Status(http.StatusOK):                    openapi.JSONResponse("Per-item results", document.SchemaRef(models.BulkShortenResponse{})),
+			openapi.Status(http.StatusUnprocessableEntity):   openapi.JSONResponse("Per-item results, none succeeded", document.SchemaRef(models.BulkShortenResponse{})),
+			openapi.Status(http.StatusBadRequest):            errorResponse(document, "Invalid request body"),
+			openapi.Status(http.StatusRequestEntityTooLarge): errorResponse(document, "Too many URLs"),
+		},
+	})
+
+	document.AddOperation(http.MethodGet, "/api/v1/urls/{shortCode}", openapi.Operation{
+		OperationID: "getURL",
+		Summary:     "Get a short link",
+		Tags:        []string{"urls"},
+		Parameters:  []openapi.Parameter{shortCodeParam, domainParam},
+		Responses: map[string]openapi.Response{
+			openapi.Status(http.StatusOK):              openapi.JSONResponse("Short link", urlMapping),
+			openapi.Status(http.StatusNotFound):        notFound,
+			openapi.Status(http.StatusTooManyRequests): rateLimited,
+		},
+	})
+
+	document.AddOperation(http.MethodPatch, "/api/v1/urls/{shortCode}", openapi.Operation{
+		OperationID: "updateURL",
+		Summary:     "Update a short link",
+		Tags:        []string{"urls"},
+		Parameters:  []openapi.Parameter{shortCodeParam, domainParam},
+		RequestBody: openapi.JSONBody(document.SchemaRef(models.UpdateURLRequest{})),
+		Responses: map[string]openapi.Response{
+			openapi.Status(http.StatusOK):              openapi.JSONResponse("Updated short link", urlMapping),
+			openapi.Status(http.StatusBadRequest):      errorResponse(document, "Invalid request body"),
+			openapi.Status(http.StatusForbidden):       unsafeURL,
+			openapi.Status(http.StatusNotFound):        notFound,
+			openapi.Status(http.StatusTooManyRequests): rateLimited,
+		},
+	})
+
+	document.AddOperation(http.MethodDelete, "/api/v1/urls/{shortCode}", openapi.Operation{
+		OperationID: "deleteURL",
+		Summary:     "Delete a short link",
+		Tags:        []string{"urls"},
+		Parameters:  []openapi.Parameter{shortCodeParam, domainParam},
+		Responses: map[string]openapi.Response{
+			openapi.Status(http.StatusNoContent):       openapi.JSONResponse("Short link deleted", nil),
+			openapi.Status(http.StatusNotFound):        notFound,
+			openapi.Status(http.StatusTooManyRequests): rateLimited,
+		},
+	})
+
+	document.AddOperation(http.MethodGet, "/api/v1/urls/{shortCode}/stats", openapi.Operation{
+		OperationID: "getURLStats",
+		Summary:     "Get the click stats of a short link",
+		Tags:        []string{"urls"},
+		Parameters:  []openapi.Parameter{shortCodeParam, domainParam},
+		Responses: map[string]openapi.Response{
+			openapi.Status(http.StatusOK):              openapi.JSONResponse("Click stats", document.SchemaRef(models.URLStatsResponse{})),
+			openapi.Status(http.StatusNotFound):        notFound,
+			openapi.Status(http.StatusTooManyRequests): rateLimited,
+		},
+	})
+
+	document.AddOperation(http.MethodGet, "/api/v1/urls/{shortCode}/qr", openapi.Operation{
+		OperationID: "getQRCode",
+		Summary:     "Get a QR code of a short link",
+		Description: "Renders a QR code encoding the short URL. Responses carry an ETag, so clients can " +
+			"revalidate cached codes with If-None-Match. Logos are fetched from the given URL and must be " +
+			"PNG, JPEG or GIF images.",
+		Tags: []string{"urls"},
+		Parameters: []openapi.Parameter{
+			shortCodeParam,
+			domainParam,
+			{Name: "format", In: "query", Description: "Image format (default png)", Schema: &openapi.Schema{
+				Type: "string",
+				Enum: []string{services.QRCodeFormatPNG, services.QRCodeFormatSVG},
+			}},
+			openapi.QueryParam("size", "integer",
+				fmt.Sprintf("Width and height in pixels, %d to %d (default %d)", minQRCodeSize, maxQRCodeSize, defaultQRCodeSize)),
+			{Name: "ec", In: "query", Description: "Error correction level (default M, or H with a logo)", Schema: &openapi.Schema{
+				Type: "string",
+				Enum: []string{"L", "M", "Q", "H"},
+			}},
+			openapi.QueryParam("margin", "integer",
+				fmt.Sprintf("Quiet zone in modules, 0 to %d (default %d)", maxQRCodeMargin, defaultQRCodeMargin)),
+			openapi.QueryParam("fg", "string", "Foreground color as hex RRGGBB or RRGGBBAA (default 000000)"),
+			openapi.QueryParam("bg", "string", "Background color as hex RRGGBB or RRGGBBAA (default ffffff)"),
+			openapi.QueryParam("logo", "string", "URL of an image drawn in the center of the code"),
+		},
+		Responses: map[string]openapi.Response{
+			openapi.Status(http.StatusOK): {
+				Description: "QR code image",
+				Headers:     map[string]openapi.Header{"ETag": {Schema: &openapi.Schema{Type: "string"}}},
+				Content: map[string]openapi.MediaType{
+					"image/png":     {Schema: &openapi.Schema{Type: "string", Format: "binary"}},
+					"image/svg+xml": {Schema: &openapi.Schema{Type: "string"}},
+				},
+			},
+			openapi.Status(http.StatusNotModified):     {Description: "Cached QR code is still current"},
+			openapi.Status(http.StatusBadRequest):      errorResponse(document, "Invalid query parameters or logo"),
+			openapi.Status(http.StatusNotFound):        notFound,
+			openapi.Status(http.StatusTooManyRequests): rateLimited,
+		},
+	})
+
+	document.AddOperation(http.MethodGet, handler.redirectPath(), openapi.Operation{
+		OperationID: "redirect",
+		Summary:     "Redirect to the original URL",
+		Description: "Crawlers of chat apps and social networks get the social preview page of links that " +
+			"configure one instead of a redirect. Their requests are not counted as clicks. Requests sent " +
+			"to a verified custom domain resolve the short codes of that domain. Errors are sent as an HTML " +
+			"landing page, branded for the workspace of the domain, to clients preferring text/html over JSON.",
+		Tags:       []string{"redirect"},
+		Parameters: []openapi.Parameter{shortCodeParam},
+		Security:   openapi.Public(),
+		Responses: map[string]openapi.Response{
+			openapi.Status(http.StatusOK): {
+				Description: "Social preview page with Open Graph tags, served to link unfurling crawlers",
+				Content:     map[string]openapi.MediaType{"text/html": {Schema: &openapi.Schema{Type: "string"}}},
+			},
+			openapi.Status(http.StatusFound): {
+				Description: "Temporary redirect to the original URL, so that every click reaches the server",
+				Headers:     map[string]openapi.Header{"Location": {Schema: &openapi.Schema{Type: "string", Format: "uri"}}},
+			},
+			openapi.Status(http.StatusNotFound):        landingResponse(document, "Short code not found"),
+			openapi.Status(http.StatusGone):            landingResponse(document, "Short link disabled or flagged as unsafe"),
+			openapi.Status(http.StatusTooManyRequests): rateLimited,
+		},
+	})
+}
+
+// landingResponse describes an error response that browsers get as a landing page
+func landingResponse(document *openapi.Document, description string) openapi.Response {
+	response := errorResponse(document, description)
+	response.Content["text/html"] = openapi.MediaType{Schema: &openapi.Schema{Type: "string"}}
+	return response
 }
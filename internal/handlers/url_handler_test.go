@@ -0,0 +1,174 @@
+package handlers
+
+import (
+	"bytes"
+	"errors"
+	"github.com/aarondever/linko/internal/models"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestDecodeBulkShortenRequest(t *testing.T) {
+	tests := []struct {
+		name        string
+		contentType string
+		body        string
+		maxItems    int
+		urls        []string
+		err         error
+	}{
+		{
+			name:        "JSON array",
+			contentType: "application/json",
+			body:        `[{"url": "https://a.example"}, {"url": "https://b.example"}]`,
+			maxItems:    2,
+			urls:        []string{"https://a.example", "https://b.example"},
+		},
+		{
+			name:     "missing content type is JSON",
+			body:     `[{"url": "https://a.example"}]`,
+			maxItems: 1,
+			urls:     []string{"https://a.example"},
+		},
+		{
+			name:        "JSON array over the limit",
+			contentType: "application/json",
+			body:        `[{"url": "https://a.example"}, {"url": "https://b.example"}, {"url": "https://c.example"}]`,
+			maxItems:    2,
+			err:         errTooManyItems,
+		},
+		{
+			name:        "JSON object instead of an array",
+			contentType: "application/json",
+			body:        `{"url": "https://a.example"}`,
+			maxItems:    2,
+			err:         errors.New("expected a JSON array"),
+		},
+		{
+			name:        "CSV with header row and blank URLs",
+			contentType: "text/csv",
+			body:        "url,campaign\nhttps://a.example,spring\n,\n https://b.example,fall\n",
+			maxItems:    2,
+			urls:        []string{"https://a.example", "https://b.example"},
+		},
+		{
+			name:        "CSV header does not count towards the limit",
+			contentType: "text/csv; charset=utf-8",
+			body:        "URL\nhttps://a.example\n",
+			maxItems:    1,
+			urls:        []string{"https://a.example"},
+		},
+		{
+			name:        "CSV over the limit",
+			contentType: "text/csv",
+			body:        "https://a.example\nhttps://b.example\n",
+			maxItems:    1,
+			err:         errTooManyItems,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			request := httptest.NewRequest(http.MethodPost, "/api/v1/urls/bulk", strings.NewReader(test.body))
+			if test.contentType != "" {
+				request.Header.Set("Content-Type", test.contentType)
+			}
+
+			urls, err := decodeBulkShortenRequest(request, test.maxItems)
+			if test.err != nil {
+				if err == nil || (err != test.err && err.Error() != test.err.Error()) {
+					t.Fatalf("error = %v, want %v", err, test.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if !slices.Equal(urls, test.urls) {
+				t.Errorf("urls = %v, want %v", urls, test.urls)
+			}
+		})
+	}
+}
+
+func TestDecodeBulkShortenRequestUpload(t *testing.T) {
+	var body bytes.Buffer
+	writer := multipart.NewWriter(&body)
+	part, err := writer.CreateFormFile("file", "links.csv")
+	if err != nil {
+		t.Fatal(err)
+	}
+	part.Write([]byte("url\nhttps://a.example\nhttps://b.example\n"))
+	writer.Close()
+
+	for _, maxItems := range []int{2, 1} {
+		request := httptest.NewRequest(http.MethodPost, "/api/v1/urls/bulk", bytes.NewReader(body.Bytes()))
+		request.Header.Set("Content-Type", writer.FormDataContentType())
+
+		urls, err := decodeBulkShortenRequest(request, maxItems)
+		if maxItems == 1 {
+			if !errors.Is(err, errTooManyItems) {
+				t.Errorf("error with limit 1 = %v, want %v", err, errTooManyItems)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		if want := []string{"https://a.example", "https://b.example"}; !slices.Equal(urls, want) {
+			t.Errorf("urls = %v, want %v", urls, want)
+		}
+	}
+}
+
+func TestNewBulkShortenResponse(t *testing.T) {
+	succeeded := models.BulkShortenResult{URL: "https://a.example", ShortCode: "abc123"}
+	failed := models.BulkShortenResult{URL: "ftp://b.example", Error: "Invalid URL"}
+
+	tests := []struct {
+		name      string
+		results   []models.BulkShortenResult
+		succeeded int
+		failed    int
+		status    int
+	}{
+		{
+			name:      "all succeeded",
+			results:   []models.BulkShortenResult{succeeded, succeeded},
+			succeeded: 2,
+			status:    http.StatusOK,
+		},
+		{
+			name:      "partial failure",
+			results:   []models.BulkShortenResult{succeeded, failed, failed},
+			succeeded: 1,
+			failed:    2,
+			status:    http.StatusOK,
+		},
+		{
+			name:    "all failed",
+			results: []models.BulkShortenResult{failed, failed},
+			failed:  2,
+			status:  http.StatusUnprocessableEntity,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			response, status := newBulkShortenResponse(test.results)
+			if response.Total != len(test.results) || response.Succeeded != test.succeeded || response.Failed != test.failed {
+				t.Errorf("total, succeeded, failed = %d, %d, %d, want %d, %d, %d", response.Total,
+					response.Succeeded, response.Failed, len(test.results), test.succeeded, test.failed)
+			}
+			if status != test.status {
+				t.Errorf("status = %d, want %d", status, test.status)
+			}
+		})
+	}
+}
@@ -0,0 +1,238 @@
+package handlers
+
+import (
+	"fmt"
+	"github.com/aarondever/linko/internal/models"
+	"github.com/aarondever/linko/internal/openapi"
+	"github.com/aarondever/linko/internal/services"
+	"github.com/aarondever/linko/internal/utils"
+	"github.com/go-chi/chi/v5"
+	"net/http"
+)
+
+// WebhookHandler manages the webhooks of the workspace of the calling API key and their
+// delivery logs
+type WebhookHandler struct {
+	webhookService *services.WebhookService
+	rateLimit      *RateLimitMiddleware
+}
+
+func NewWebhookHandler(webhookService *services.WebhookService, rateLimit *RateLimitMiddleware) *WebhookHandler {
+	return &WebhookHandler{
+		webhookService: webhookService,
+		rateLimit:      rateLimit,
+	}
+}
+
+func (handler *WebhookHandler) RegisterRoutes(router chi.Router) {
+	router.Route("/api/v1/webhooks", func(router chi.Router) {
+		manage := router.With(handler.rateLimit.Management)
+
+		manage.Get("/", handler.ListWebhooks)
+		manage.Post("/", handler.CreateWebhook)
+		manage.Get("/{id}", handler.GetWebhook)
+		manage.Delete("/{id}", handler.DeleteWebhook)
+		manage.Get("/{id}/deliveries", handler.ListDeliveries)
+		manage.Post("/{id}/deliveries/{deliveryID}/redeliver", handler.Redeliver)
+	})
+}
+
+// CreateWebhook registers a webhook. The response carries the signing secret, which is not
+// returned again.
+func (handler *WebhookHandler) CreateWebhook(responseWriter http.ResponseWriter, request *http.Request) {
+	var params models.CreateWebhookRequest
+	if !decodeRequestBody(responseWriter, request, &params) {
+		return
+	}
+
+	workspace := WorkspaceFromContext(request.Context())
+	webhook, err := handler.webhookService.CreateWebhook(request.Context(), workspace, params)
+	if err != nil {
+		respondWithError(responseWriter, request, err)
+		return
+	}
+
+	utils.RespondWithJSON(responseWriter, webhook, http.StatusCreated)
+}
+
+func (handler *WebhookHandler) ListWebhooks(responseWriter http.ResponseWriter, request *http.Request) {
+	webhooks, err := handler.webhookService.ListWebhooks(request.Context(), WorkspaceFromContext(request.Context()))
+	if err != nil {
+		respondWithError(responseWriter, request, err)
+		return
+	}
+
+	utils.RespondWithJSON(responseWriter, models.ListWebhooksResponse{Webhooks: webhooks}, http.StatusOK)
+}
+
+func (handler *WebhookHandler) GetWebhook(responseWriter http.ResponseWriter, request *http.Request) {
+	workspace := WorkspaceFromContext(request.Context())
+	webhook, err := handler.webhookService.GetWebhook(request.Context(), workspace, request.PathValue("id"))
+	if err != nil {
+		respondWithError(responseWriter, request, err)
+		return
+	}
+
+	utils.RespondWithJSON(responseWriter, webhook, http.StatusOK)
+}
+
+func (handler *WebhookHandler) DeleteWebhook(responseWriter http.ResponseWriter, request *http.Request) {
+	workspace := WorkspaceFromContext(request.Context())
+	if err := handler.webhookService.DeleteWebhook(request.Context(), workspace, request.PathValue("id")); err != nil {
+		respondWithError(responseWriter, request, err)
+		return
+	}
+
+	responseWriter.WriteHeader(http.StatusNoContent)
+}
+
+// ListDeliveries lists the deliveries of a webhook from newest to oldest, optionally only those
+// in the status given by the "status" query parameter, paginated by the "offset" and "limit"
+// query parameters
+func (handler *WebhookHandler) ListDeliveries(responseWriter http.ResponseWriter, request *http.Request) {
+	status := request.URL.Query().Get("status")
+	switch status {
+	case "", models.WebhookDeliveryPending, models.WebhookDeliverySucceeded, models.WebhookDeliveryDead:
+	default:
+		respondWithError(responseWriter, request, invalidQueryError("status", "must be pending, succeeded or dead"))
+		return
+	}
+
+	offset, err := parseIntQuery(request, "offset", 0)
+	if err != nil || offset < 0 {
+		respondWithError(responseWriter, request, invalidQueryError("offset", "must be a non-negative integer"))
+		return
+	}
+
+	limit, err := parseIntQuery(request, "limit", defaultListLimit)
+	if err != nil || limit < 1 || limit > maxListLimit {
+		respondWithError(responseWriter, request,
+			invalidQueryError("limit", fmt.Sprintf("must be an integer between 1 and %d", maxListLimit)))
+		return
+	}
+
+	workspace := WorkspaceFromContext(request.Context())
+	deliveries, err := handler.webhookService.ListDeliveries(request.Context(), workspace, request.PathValue("id"), status, offset, limit)
+	if err != nil {
+		respondWithError(responseWriter, request, err)
+		return
+	}
+
+	utils.RespondWithJSON(responseWriter, models.ListWebhookDeliveriesResponse{
+		Deliveries: deliveries,
+		Offset:     offset,
+		Limit:      limit,
+	}, http.StatusOK)
+}
+
+// Redeliver schedules sending a delivery again, e.g. a dead letter once the endpoint is fixed
+func (handler *WebhookHandler) Redeliver(responseWriter http.ResponseWriter, request *http.Request) {
+	workspace := WorkspaceFromContext(request.Context())
+	delivery, err := handler.webhookService.Redeliver(request.Context(), workspace, request.PathValue("id"), request.PathValue("deliveryID"))
+	if err != nil {
+		respondWithError(responseWriter, request, err)
+		return
+	}
+
+	utils.RespondWithJSON(responseWriter, delivery, http.StatusAccepted)
+}
+
+// DescribeRoutes documents the routes registered by RegisterRoutes
+func (handler *WebhookHandler) DescribeRoutes(document *openapi.Document) {
+	idParam := openapi.PathParam("id", "ID of the webhook")
+	webhook := document.SchemaRef(models.Webhook{})
+	notFound := errorResponse(document, "Webhook not found")
+	rateLimited := errorResponse(document, "Rate limit exceeded")
+	adminKeyRequired := errorResponse(document, "Admin API key required")
+
+	document.AddOperation(http.MethodGet, "/api/v1/webhooks", openapi.Operation{
+		OperationID: "listWebhooks",
+		Summary:     "List the webhooks of the workspace",
+		Tags:        []string{"webhooks"},
+		Responses: map[string]openapi.Response{
+			openapi.Status(http.StatusOK):              openapi.JSONResponse("Webhooks", document.SchemaRef(models.ListWebhooksResponse{})),
+			openapi.Status(http.StatusTooManyRequests): rateLimited,
+			openapi.Status(http.StatusForbidden):       adminKeyRequired,
+		},
+	})
+
+	document.AddOperation(http.MethodPost, "/api/v1/webhooks", openapi.Operation{
+		OperationID: "createWebhook",
+		Summary:     "Register a webhook",
+		Description: "Registers an endpoint receiving the subscribed events about links of the workspace as JSON POST " +
+			"requests. Each request carries the event name in the X-Linko-Event header, the delivery ID in the " +
+			"X-Linko-Delivery header and the signature \"t=<unix seconds>,v1=<hex HMAC-SHA256>\" in the X-Linko-Signature " +
+			"header, an HMAC of \"<unix seconds>.<body>\" keyed by the secret returned here. Responses other than 2xx " +
+			"are retried with exponential backoff.",
+		Tags:        []string{"webhooks"},
+		RequestBody: openapi.JSONBody(document.SchemaRef(models.CreateWebhookRequest{})),
+		Responses: map[string]openapi.Response{
+			openapi.Status(http.StatusCreated):         openapi.JSONResponse("Webhook registered, with its signing secret", document.SchemaRef(models.CreateWebhookResponse{})),
+			openapi.Status(http.StatusBadRequest):      errorResponse(document, "Invalid request body or endpoint"),
+			openapi.Status(http.StatusTooManyRequests): rateLimited,
+			openapi.Status(http.StatusForbidden):       adminKeyRequired,
+		},
+	})
+
+	document.AddOperation(http.MethodGet, "/api/v1/webhooks/{id}", openapi.Operation{
+		OperationID: "getWebhook",
+		Summary:     "Get a webhook",
+		Tags:        []string{"webhooks"},
+		Parameters:  []openapi.Parameter{idParam},
+		Responses: map[string]openapi.Response{
+			openapi.Status(http.StatusOK):              openapi.JSONResponse("Webhook", webhook),
+			openapi.Status(http.StatusNotFound):        notFound,
+			openapi.Status(http.StatusTooManyRequests): rateLimited,
+			openapi.Status(http.StatusForbidden):       adminKeyRequired,
+		},
+	})
+
+	document.AddOperation(http.MethodDelete, "/api/v1/webhooks/{id}", openapi.Operation{
+		OperationID: "deleteWebhook",
+		Summary:     "Delete a webhook and its delivery log",
+		Tags:        []string{"webhooks"},
+		Parameters:  []openapi.Parameter{idParam},
+		Responses: map[string]openapi.Response{
+			openapi.Status(http.StatusNoContent):       {Description: "Webhook deleted"},
+			openapi.Status(http.StatusNotFound):        notFound,
+			openapi.Status(http.StatusTooManyRequests): rateLimited,
+			openapi.Status(http.StatusForbidden):       adminKeyRequired,
+		},
+	})
+
+	document.AddOperation(http.MethodGet, "/api/v1/webhooks/{id}/deliveries", openapi.Operation{
+		OperationID: "listWebhookDeliveries",
+		Summary:     "List the deliveries of a webhook",
+		Description: "Lists the events sent or to be sent to the webhook with the outcome of their latest attempt, " +
+			"newest first. Dead deliveries failed every attempt and are only sent again when redelivered.",
+		Tags: []string{"webhooks"},
+		Parameters: []openapi.Parameter{
+			idParam,
+			openapi.QueryParam("status", "string", "Only deliveries in this status: pending, succeeded or dead"),
+			openapi.QueryParam("offset", "integer", "Number of deliveries to skip"),
+			openapi.QueryParam("limit", "integer", fmt.Sprintf("Maximum number of deliveries to return (default %d, at most %d)", defaultListLimit, maxListLimit)),
+		},
+		Responses: map[string]openapi.Response{
+			openapi.Status(http.StatusOK):              openapi.JSONResponse("Deliveries", document.SchemaRef(models.ListWebhookDeliveriesResponse{})),
+			openapi.Status(http.StatusBadRequest):      errorResponse(document, "Invalid parameters"),
+			openapi.Status(http.StatusNotFound):        notFound,
+			openapi.Status(http.StatusTooManyRequests): rateLimited,
+			openapi.Status(http.StatusForbidden):       adminKeyRequired,
+		},
+	})
+
+	document.AddOperation(http.MethodPost, "/api/v1/webhooks/{id}/deliveries/{deliveryID}/redeliver", openapi.Operation{
+		OperationID: "redeliverWebhookDelivery",
+		Summary:     "Send a delivery again",
+		Description: "Schedules a new delivery with the same event ID and payload, e.g. for a dead delivery once " +
+			"the endpoint works again.",
+		Tags:       []string{"webhooks"},
+		Parameters: []openapi.Parameter{idParam, openapi.PathParam("deliveryID", "ID of the delivery to send again")},
+		Responses: map[string]openapi.Response{
+			openapi.Status(http.StatusAccepted):        openapi.JSONResponse("Scheduled delivery", document.SchemaRef(models.WebhookDelivery{})),
+			openapi.Status(http.StatusNotFound):        errorResponse(document, "Webhook or delivery not found"),
+			openapi.Status(http.StatusTooManyRequests): rateLimited,
+			openapi.Status(http.StatusForbidden):       adminKeyRequired,
+		},
+	})
+}
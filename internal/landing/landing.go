@@ -0,0 +1,102 @@
+// Package landing renders the HTML pages shown to browsers following short links that cannot
+// redirect. Every page is rendered by layout.html, which includes the "title" and "message"
+// templates defined by the page's own file. Any of these files can be replaced by a file of
+// the same name in a template directory.
+package landing
+
+import (
+	"embed"
+	"errors"
+	"fmt"
+	"github.com/aarondever/linko/internal/models"
+	"html/template"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// Pages, named after the file of their template without the .html extension
+const (
+	PageNotFound = "not_found"
+	PageDisabled = "disabled"
+	PageUnsafe   = "unsafe"
+)
+
+const layoutFile = "layout.html"
+
+var pageNames = []string{PageNotFound, PageDisabled, PageUnsafe}
+
+//go:embed templates/*.html
+var embedded embed.FS
+
+// defaultTemplates holds the embedded templates, used for files missing from the template directory
+var defaultTemplates, _ = fs.Sub(embedded, "templates")
+
+// Data is passed to the templates of every page
+type Data struct {
+	Status    int    // HTTP status code of the response
+	ShortURL  string // Short link the visitor followed
+	RequestID string
+	Branding  models.Branding
+}
+
+// Pages holds the parsed template of every page
+type Pages struct {
+	templates map[string]*template.Template
+}
+
+// Load parses the templates of every page, preferring files in dir over the embedded defaults.
+// An empty dir uses the embedded templates only.
+func Load(dir string) (*Pages, error) {
+	layout, err := parseFile(template.New("landing"), dir, layoutFile)
+	if err != nil {
+		return nil, err
+	}
+
+	pages := &Pages{templates: make(map[string]*template.Template, len(pageNames))}
+	for _, name := range pageNames {
+		page, err := layout.Clone()
+		if err != nil {
+			return nil, err
+		}
+
+		if pages.templates[name], err = parseFile(page, dir, name+".html"); err != nil {
+			return nil, err
+		}
+	}
+
+	return pages, nil
+}
+
+// Render writes a page to w
+func (pages *Pages) Render(w io.Writer, name string, data Data) error {
+	page, ok := pages.templates[name]
+	if !ok {
+		return fmt.Errorf("unknown landing page %q", name)
+	}
+
+	return page.ExecuteTemplate(w, layoutFile, data)
+}
+
+// parseFile parses a template file from dir, or the embedded default when dir does not have it,
+// as a template named after the file associated with tmpl
+func parseFile(tmpl *template.Template, dir, filename string) (*template.Template, error) {
+	var content []byte
+	var err error
+	if dir != "" {
+		content, err = os.ReadFile(filepath.Join(dir, filename))
+	}
+	if dir == "" || errors.Is(err, fs.ErrNotExist) {
+		content, err = fs.ReadFile(defaultTemplates, filename)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err = tmpl.New(filename).Parse(string(content)); err != nil {
+		return nil, fmt.Errorf("parsing %s: %w", filename, err)
+	}
+
+	return tmpl, nil
+}
@@ -0,0 +1,43 @@
+// Package logging enriches log records with the request and trace the logging code runs for.
+// Log calls made with a context, such as slog.InfoContext, carry the request ID assigned by
+// middleware.RequestID and the trace and span IDs of the current span.
+package logging
+
+import (
+	"context"
+	"github.com/go-chi/chi/v5/middleware"
+	"go.opentelemetry.io/otel/trace"
+	"log/slog"
+)
+
+// contextHandler adds the request ID and the trace and span IDs found in the context of a log
+// call to its record
+type contextHandler struct {
+	slog.Handler
+}
+
+// NewHandler wraps handler to add the request and trace of the context of every log call
+func NewHandler(handler slog.Handler) slog.Handler {
+	return contextHandler{handler}
+}
+
+func (handler contextHandler) Handle(ctx context.Context, record slog.Record) error {
+	if requestID := middleware.GetReqID(ctx); requestID != "" {
+		record.AddAttrs(slog.String("request_id", requestID))
+	}
+	if spanContext := trace.SpanContextFromContext(ctx); spanContext.IsValid() {
+		record.AddAttrs(
+			slog.String("trace_id", spanContext.TraceID().String()),
+			slog.String("span_id", spanContext.SpanID().String()))
+	}
+
+	return handler.Handler.Handle(ctx, record)
+}
+
+func (handler contextHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return contextHandler{handler.Handler.WithAttrs(attrs)}
+}
+
+func (handler contextHandler) WithGroup(name string) slog.Handler {
+	return contextHandler{handler.Handler.WithGroup(name)}
+}
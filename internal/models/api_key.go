@@ -0,0 +1,24 @@
+package models
+
+import (
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"time"
+)
+
+// APIKey represents the API key document in MongoDB. Only a hash of the key is stored.
+type APIKey struct {
+	ID         bson.ObjectID `json:"id" bson:"_id,omitempty"`
+	Name       string        `bson:"name" json:"name"`
+	Workspace  string        `bson:"workspace,omitempty" json:"workspace,omitempty"` // Scopes the custom domains the key can use
+	Admin      bool          `bson:"admin,omitempty" json:"admin"`                   // Grants access to the administrative routes
+	Prefix     string        `bson:"prefix" json:"prefix"`                           // First characters of the key, to help identify it
+	KeyHash    string        `bson:"key_hash" json:"-"`
+	CreatedAt  time.Time     `bson:"created_at" json:"created_at"`
+	LastUsedAt *time.Time    `bson:"last_used_at,omitempty" json:"last_used_at,omitempty"`
+	RevokedAt  *time.Time    `bson:"revoked_at,omitempty" json:"revoked_at,omitempty"`
+}
+
+type CreateAPIKeyResponse struct {
+	APIKey
+	Key string `json:"key"` // Plaintext key, only available at creation time
+}
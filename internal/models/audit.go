@@ -0,0 +1,95 @@
+package models
+
+import (
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"time"
+)
+
+// Audit actor types
+const (
+	AuditActorAPIKey    = "api_key"   // A request authenticated by an API key
+	AuditActorAnonymous = "anonymous" // A request without an API key, when keys are not required
+	AuditActorCLI       = "cli"       // An administrative command, identified by the OS user
+	AuditActorSystem    = "system"    // The server itself, e.g. screening or a configuration reload
+)
+
+// Audited actions
+const (
+	AuditActionLinkCreate     = "link.create"
+	AuditActionLinkUpdate     = "link.update"
+	AuditActionLinkDelete     = "link.delete"
+	AuditActionLinkDisable    = "link.disable"
+	AuditActionAPIKeyCreate   = "api_key.create"
+	AuditActionAPIKeyRevoke   = "api_key.revoke"
+	AuditActionDomainCreate   = "domain.create"
+	AuditActionDomainVerify   = "domain.verify"
+	AuditActionDomainDelete   = "domain.delete"
+	AuditActionBrandingUpdate = "branding.update"
+	AuditActionBrandingDelete = "branding.delete"
+	AuditActionWebhookCreate  = "webhook.create"
+	AuditActionWebhookDelete  = "webhook.delete"
+	AuditActionConfigReload   = "config.reload"
+)
+
+// Audit target types
+const (
+	AuditTargetLink     = "link"
+	AuditTargetAPIKey   = "api_key"
+	AuditTargetDomain   = "domain"
+	AuditTargetBranding = "branding"
+	AuditTargetWebhook  = "webhook"
+	AuditTargetConfig   = "config"
+)
+
+// AuditEntry represents an audit log document in MongoDB. Entries are only ever inserted.
+type AuditEntry struct {
+	ID        bson.ObjectID `json:"id" bson:"_id,omitempty"`
+	Time      time.Time     `bson:"time" json:"time"`
+	Workspace string        `bson:"workspace,omitempty" json:"workspace,omitempty"` // Workspace the entry is visible to
+	Actor     AuditActor    `bson:"actor" json:"actor"`
+	Action    string        `bson:"action" json:"action"`
+	Target    AuditTarget   `bson:"target" json:"target"`
+	Changes   []AuditChange `bson:"changes" json:"changes"`
+	IP        string        `bson:"ip,omitempty" json:"ip,omitempty"`
+	RequestID string        `bson:"request_id,omitempty" json:"request_id,omitempty"`
+}
+
+// AuditActor identifies who performed an audited action
+type AuditActor struct {
+	Type      string `bson:"type" json:"type"`
+	ID        string `bson:"id,omitempty" json:"id,omitempty"`     // API key ID or OS user name
+	Name      string `bson:"name,omitempty" json:"name,omitempty"` // API key name
+	Workspace string `bson:"workspace,omitempty" json:"workspace,omitempty"`
+}
+
+// AuditTarget identifies what an audited action changed
+type AuditTarget struct {
+	Type string `bson:"type" json:"type"`
+	ID   string `bson:"id,omitempty" json:"id,omitempty"` // Short code prefixed with its custom domain, key ID, host name or workspace
+}
+
+// AuditChange is a field that an audited action changed, named by its dotted JSON path.
+// Before is omitted for created fields and After for removed ones.
+type AuditChange struct {
+	Field  string `bson:"field" json:"field"`
+	Before any    `bson:"before" json:"before,omitempty"`
+	After  any    `bson:"after" json:"after,omitempty"`
+}
+
+// AuditFilter selects audit entries of a workspace; other empty fields match any value
+type AuditFilter struct {
+	Workspace  string // Always applies, empty for the default workspace
+	ActorType  string
+	ActorID    string
+	Action     string
+	TargetType string
+	TargetID   string
+	Since      *time.Time
+	Until      *time.Time
+}
+
+type ListAuditEntriesResponse struct {
+	Entries []AuditEntry `json:"entries"`
+	Offset  int64        `json:"offset"`
+	Limit   int64        `json:"limit"`
+}
@@ -0,0 +1,14 @@
+package models
+
+import "time"
+
+// Branding customizes the landing pages shown to visitors of the links of a workspace that
+// cannot redirect, e.g. because they were not found or have been disabled
+type Branding struct {
+	Workspace   string    `bson:"workspace,omitempty" json:"-"`
+	Name        string    `bson:"name,omitempty" json:"name,omitempty" validate:"max=100"`
+	LogoURL     string    `bson:"logo_url,omitempty" json:"logo_url,omitempty" validate:"omitempty,http_url"`
+	AccentColor string    `bson:"accent_color,omitempty" json:"accent_color,omitempty" validate:"omitempty,hexcolor"`
+	SupportURL  string    `bson:"support_url,omitempty" json:"support_url,omitempty" validate:"omitempty,url"`
+	UpdatedAt   time.Time `bson:"updated_at" json:"updated_at,omitzero"` // Zero until the workspace sets its branding
+}
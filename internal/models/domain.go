@@ -0,0 +1,33 @@
+package models
+
+import (
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"time"
+)
+
+// Domain represents a custom domain document in MongoDB. Links on a domain are served from its
+// host once ownership has been proven by publishing the verification record in DNS.
+type Domain struct {
+	ID                bson.ObjectID      `json:"id" bson:"_id,omitempty"`
+	Hostname          string             `bson:"hostname" json:"hostname"`
+	Workspace         string             `bson:"workspace,omitempty" json:"workspace,omitempty"`
+	VerificationToken string             `bson:"verification_token" json:"-"`
+	Verification      DomainVerification `bson:"-" json:"verification"` // DNS record proving ownership
+	CreatedAt         time.Time          `bson:"created_at" json:"created_at"`
+	VerifiedAt        *time.Time         `bson:"verified_at,omitempty" json:"verified_at,omitempty"`
+}
+
+// DomainVerification is the DNS record to publish before verifying a domain
+type DomainVerification struct {
+	Type  string `json:"type"`
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+type CreateDomainRequest struct {
+	Hostname string `json:"hostname" validate:"required,fqdn"`
+}
+
+type ListDomainsResponse struct {
+	Domains []Domain `json:"domains"`
+}
@@ -0,0 +1,20 @@
+package models
+
+// Problem is the RFC 7807 problem details body returned for every API error
+type Problem struct {
+	Type      string       `json:"type"`
+	Title     string       `json:"title"`
+	Status    int          `json:"status"`
+	Detail    string       `json:"detail,omitempty"`
+	Instance  string       `json:"instance,omitempty"`
+	Code      string       `json:"code"`                 // Stable machine readable error code
+	RequestID string       `json:"request_id,omitempty"` // ID of the request, also sent as X-Request-Id
+	Errors    []FieldError `json:"errors,omitempty"`     // Field level validation failures
+}
+
+// FieldError describes why a single request field failed validation
+type FieldError struct {
+	Field   string `json:"field"`
+	Rule    string `json:"rule"`
+	Message string `json:"message"`
+}
@@ -0,0 +1,14 @@
+package models
+
+import "time"
+
+// LinkHealth is the outcome of the latest health check of a link's destination
+type LinkHealth struct {
+	Healthy             bool       `bson:"healthy" json:"healthy"`
+	StatusCode          int        `bson:"status_code,omitempty" json:"status_code,omitempty"`
+	LatencyMS           int64      `bson:"latency_ms" json:"latency_ms"`
+	Error               string     `bson:"error,omitempty" json:"error,omitempty"`
+	CheckedAt           time.Time  `bson:"checked_at" json:"checked_at"`
+	ConsecutiveFailures int        `bson:"consecutive_failures" json:"consecutive_failures"`
+	BrokenSince         *time.Time `bson:"broken_since,omitempty" json:"broken_since,omitempty"` // Set once failures reach the threshold
+}
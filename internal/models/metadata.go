@@ -0,0 +1,24 @@
+package models
+
+import "time"
+
+// LinkMetadata describes the destination page of a link, taken from its HTML head
+type LinkMetadata struct {
+	Title        string     `bson:"title,omitempty" json:"title,omitempty"`
+	Description  string     `bson:"description,omitempty" json:"description,omitempty"`
+	CanonicalURL string     `bson:"canonical_url,omitempty" json:"canonical_url,omitempty"`
+	FaviconURL   string     `bson:"favicon_url,omitempty" json:"favicon_url,omitempty"`
+	OpenGraph    *OpenGraph `bson:"open_graph,omitempty" json:"open_graph,omitempty"`
+	FetchedAt    time.Time  `bson:"fetched_at" json:"fetched_at"`
+	Error        string     `bson:"error,omitempty" json:"error,omitempty"` // Why the page could not be fetched or parsed
+}
+
+// OpenGraph holds the og:* properties of a page
+type OpenGraph struct {
+	Title       string `bson:"title,omitempty" json:"title,omitempty"`
+	Description string `bson:"description,omitempty" json:"description,omitempty"`
+	Image       string `bson:"image,omitempty" json:"image,omitempty"`
+	Type        string `bson:"type,omitempty" json:"type,omitempty"`
+	SiteName    string `bson:"site_name,omitempty" json:"site_name,omitempty"`
+	URL         string `bson:"url,omitempty" json:"url,omitempty"`
+}
@@ -0,0 +1,55 @@
+package models
+
+import "time"
+
+// Supported formats for exporting and importing link data
+const (
+	TransferFormatNDJSON = "ndjson"
+	TransferFormatCSV    = "csv"
+)
+
+// How an import handles short codes that already point at a different URL
+const (
+	ImportConflictSkip      = "skip"      // Keep the stored link
+	ImportConflictOverwrite = "overwrite" // Replace the stored link with the imported one
+)
+
+// TransferRecord is the backend-neutral representation of a link used by export and import
+type TransferRecord struct {
+	ShortCode     string     `json:"short_code"`
+	Domain        string     `json:"domain,omitempty"` // Custom domain, empty for the default host
+	URL           string     `json:"url"`
+	CreatedAt     time.Time  `json:"created_at"`
+	ClickCount    *int64     `json:"click_count,omitempty"`
+	LastClickedAt *time.Time `json:"last_clicked_at,omitempty"`
+}
+
+// ImportConflict describes an imported short code that already points at a different URL
+type ImportConflict struct {
+	Line        int    `json:"line"`
+	ShortCode   string `json:"short_code"`
+	Domain      string `json:"domain,omitempty"`
+	ExistingURL string `json:"existing_url"`
+	ImportedURL string `json:"imported_url"`
+	Overwritten bool   `json:"overwritten"` // Whether the imported URL replaced the existing one
+}
+
+// ImportError describes an import record that could not be processed
+type ImportError struct {
+	Line      int    `json:"line"`
+	ShortCode string `json:"short_code,omitempty"`
+	Error     string `json:"error"`
+}
+
+// ImportReport summarizes the outcome of an import, or what it would be in dry-run mode
+type ImportReport struct {
+	DryRun     bool             `json:"dry_run"`
+	OnConflict string           `json:"on_conflict"`
+	Total      int              `json:"total"`
+	Created    int              `json:"created"`
+	Updated    int              `json:"updated"`
+	Unchanged  int              `json:"unchanged"`
+	Skipped    int              `json:"skipped"` // Conflicting records left out
+	Conflicts  []ImportConflict `json:"conflicts"`
+	Errors     []ImportError    `json:"errors"`
+}
@@ -6,21 +6,101 @@ import (
 )
 
 type ShortenURLRequest struct {
-	URL string `json:"url" validate:"required,url"`
+	URL    string `json:"url" validate:"required,url"`
+	Domain string `json:"domain,omitempty" validate:"omitempty,fqdn"` // Verified custom domain to serve the link from
 }
 
 type ShortenURLResponse struct {
 	ShortCode string `json:"short_code"`
+	Domain    string `json:"domain,omitempty"`
+	ShortURL  string `json:"short_url,omitempty"`
+}
+
+// BulkShortenResult reports the outcome of a single item in a bulk shorten request
+type BulkShortenResult struct {
+	Index     int    `json:"index"`
+	URL       string `json:"url"`
+	ShortCode string `json:"short_code,omitempty"`
+	ShortURL  string `json:"short_url,omitempty"`
+	Error     string `json:"error,omitempty"`
+}
+
+type BulkShortenResponse struct {
+	Total     int                 `json:"total"`
+	Succeeded int                 `json:"succeeded"`
+	Failed    int                 `json:"failed"`
+	Results   []BulkShortenResult `json:"results"`
 }
 
 type GetURLResponse struct {
 	OriginalURL string `json:"original_url"`
 }
 
+// UpdateURLRequest changes an existing short link; omitted fields are left unchanged
+type UpdateURLRequest struct {
+	URL           *string        `json:"url,omitempty" validate:"omitempty,url"`
+	Disabled      *bool          `json:"disabled,omitempty"`       // Disable the link, or re-enable a disabled or flagged link
+	SocialPreview *SocialPreview `json:"social_preview,omitempty"` // An empty preview removes the override
+}
+
+// SocialPreview overrides how a link unfurls in chat apps and social networks. Crawlers
+// requesting the short link get a page with these Open Graph tags instead of a redirect.
+type SocialPreview struct {
+	Title       string `bson:"title,omitempty" json:"title,omitempty" validate:"max=300"`
+	Description string `bson:"description,omitempty" json:"description,omitempty" validate:"max=1000"`
+	Image       string `bson:"image,omitempty" json:"image,omitempty" validate:"omitempty,http_url"`
+}
+
+type ListURLsResponse struct {
+	URLs   []URLMapping `json:"urls"`
+	Offset int64        `json:"offset"`
+	Limit  int64        `json:"limit"`
+}
+
+type URLStatsResponse struct {
+	ShortCode     string     `json:"short_code"`
+	ClickCount    int64      `json:"click_count"`
+	LastClickedAt *time.Time `json:"last_clicked_at,omitempty"`
+	CreatedAt     time.Time  `json:"created_at"`
+}
+
+// ShortCodePattern is the format of stored short codes. Generated codes are 8 alphanumeric
+// characters; imported codes keep the form they had in the shortener they come from.
+const ShortCodePattern = `^[a-zA-Z0-9_-]{1,64}$`
+
 // URLMapping represents the URL document in MongoDB
 type URLMapping struct {
 	ID        bson.ObjectID `json:"id" bson:"_id,omitempty"`
 	ShortCode string        `bson:"short_code" json:"short_code"`
 	URL       string        `bson:"url" json:"url"`
 	CreatedAt time.Time     `bson:"created_at" json:"created_at"`
+
+	// Custom domain the link is served from; short codes are unique per domain and links
+	// without a domain are served from the default host
+	Domain   string `bson:"domain,omitempty" json:"domain,omitempty"`
+	ShortURL string `bson:"-" json:"short_url,omitempty"`
+
+	// Click stats
+	ClickCount    int64      `bson:"click_count" json:"click_count"`
+	LastClickedAt *time.Time `bson:"last_clicked_at,omitempty" json:"last_clicked_at,omitempty"`
+
+	// Disabled links no longer redirect, e.g. after their destination was flagged as unsafe
+	DisabledAt     *time.Time `bson:"disabled_at,omitempty" json:"disabled_at,omitempty"`
+	DisabledReason string     `bson:"disabled_reason,omitempty" json:"disabled_reason,omitempty"`
+
+	// Open Graph override served to crawlers
+	SocialPreview *SocialPreview `bson:"social_preview,omitempty" json:"social_preview,omitempty"`
+
+	// Destination page details, fetched in the background after the link is created
+	Metadata *LinkMetadata `bson:"metadata,omitempty" json:"metadata,omitempty"`
+
+	// Destination health, checked in the background
+	Health            *LinkHealth `bson:"health,omitempty" json:"health,omitempty"`
+	NextHealthCheckAt *time.Time  `bson:"next_health_check_at,omitempty" json:"-"`
+}
+
+// URLFilter narrows down listed links; zero values match every link
+type URLFilter struct {
+	Domain string // Only links on this custom domain, or on the default host when empty
+	Broken bool   // Only links whose destination is reported broken
 }
@@ -0,0 +1,90 @@
+package models
+
+import (
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"time"
+)
+
+// Link event names delivered to webhooks
+const (
+	LinkEventCreated   = "link.created"
+	LinkEventUpdated   = "link.updated"
+	LinkEventDeleted   = "link.deleted"
+	LinkEventClicked   = "link.clicked"
+	LinkEventBroken    = "link.broken"
+	LinkEventRecovered = "link.recovered"
+)
+
+// LinkEvent is the webhook payload sent when a link changes state
+type LinkEvent struct {
+	ID         string     `json:"id"` // Same for every delivery of the event, to deduplicate redeliveries
+	Event      string     `json:"event"`
+	OccurredAt time.Time  `json:"occurred_at"`
+	Link       URLMapping `json:"link"`
+}
+
+// Webhook represents a webhook document in MongoDB: an endpoint of a workspace receiving the
+// link events it subscribed to
+type Webhook struct {
+	ID          bson.ObjectID `json:"id" bson:"_id,omitempty"`
+	Workspace   string        `bson:"workspace,omitempty" json:"workspace,omitempty"`
+	URL         string        `bson:"url" json:"url"`
+	Events      []string      `bson:"events" json:"events"`
+	Description string        `bson:"description,omitempty" json:"description,omitempty"`
+	Secret      string        `bson:"secret" json:"-"` // Signs the deliveries
+	CreatedAt   time.Time     `bson:"created_at" json:"created_at"`
+}
+
+type CreateWebhookRequest struct {
+	URL         string   `json:"url" validate:"required,http_url"`
+	Events      []string `json:"events" validate:"required,min=1,dive,oneof=link.created link.updated link.deleted link.clicked link.broken link.recovered"`
+	Description string   `json:"description,omitempty" validate:"max=300"`
+}
+
+type CreateWebhookResponse struct {
+	Webhook
+	Secret string `json:"secret"` // Signing secret, only available at creation time
+}
+
+type ListWebhooksResponse struct {
+	Webhooks []Webhook `json:"webhooks"`
+}
+
+// Webhook delivery states. Deliveries that fail max_attempts times are dead letters, kept for
+// inspection and redelivery.
+const (
+	WebhookDeliveryPending   = "pending"
+	WebhookDeliverySucceeded = "succeeded"
+	WebhookDeliveryDead      = "dead"
+)
+
+// WebhookDelivery represents a webhook delivery document in MongoDB: one event sent to one
+// webhook, with the outcome of its latest attempt
+type WebhookDelivery struct {
+	ID            bson.ObjectID   `json:"id" bson:"_id,omitempty"`
+	WebhookID     bson.ObjectID   `bson:"webhook_id" json:"webhook_id"`
+	EventID       string          `bson:"event_id" json:"event_id"`
+	Event         string          `bson:"event" json:"event"`
+	Payload       string          `bson:"payload" json:"payload"` // JSON request body
+	Status        string          `bson:"status" json:"status"`
+	Attempts      int             `bson:"attempts" json:"attempts"`
+	NextAttemptAt *time.Time      `bson:"next_attempt_at,omitempty" json:"next_attempt_at,omitempty"` // Unset once the delivery succeeded or is dead
+	LastAttempt   *WebhookAttempt `bson:"last_attempt,omitempty" json:"last_attempt,omitempty"`
+	RedeliveryOf  *bson.ObjectID  `bson:"redelivery_of,omitempty" json:"redelivery_of,omitempty"`
+	CreatedAt     time.Time       `bson:"created_at" json:"created_at"`
+	CompletedAt   *time.Time      `bson:"completed_at,omitempty" json:"completed_at,omitempty"`
+}
+
+// WebhookAttempt is the outcome of sending a delivery once
+type WebhookAttempt struct {
+	At         time.Time `bson:"at" json:"at"`
+	StatusCode int       `bson:"status_code,omitempty" json:"status_code,omitempty"`
+	Error      string    `bson:"error,omitempty" json:"error,omitempty"`
+	DurationMS int64     `bson:"duration_ms" json:"duration_ms"`
+}
+
+type ListWebhookDeliveriesResponse struct {
+	Deliveries []WebhookDelivery `json:"deliveries"`
+	Offset     int64             `json:"offset"`
+	Limit      int64             `json:"limit"`
+}
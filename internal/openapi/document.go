@@ -0,0 +1,167 @@
+// Package openapi builds the OpenAPI 3 document describing the linko HTTP API.
+package openapi
+
+import (
+	"strconv"
+	"strings"
+)
+
+const Version = "3.0.3"
+
+// Document is the root object of an OpenAPI document
+type Document struct {
+	OpenAPI    string                `json:"openapi"`
+	Info       Info                  `json:"info"`
+	Paths      map[string]PathItem   `json:"paths"`
+	Components Components            `json:"components"`
+	Security   []map[string][]string `json:"security,omitempty"`
+}
+
+type Info struct {
+	Title       string `json:"title"`
+	Version     string `json:"version"`
+	Description string `json:"description,omitempty"`
+}
+
+// PathItem maps lower case HTTP methods to the operations of a path
+type PathItem map[string]*Operation
+
+type Components struct {
+	Schemas         map[string]*Schema         `json:"schemas,omitempty"`
+	SecuritySchemes map[string]*SecurityScheme `json:"securitySchemes,omitempty"`
+}
+
+type SecurityScheme struct {
+	Type   string `json:"type"`
+	Scheme string `json:"scheme,omitempty"`
+	In     string `json:"in,omitempty"`
+	Name   string `json:"name,omitempty"`
+}
+
+type Operation struct {
+	OperationID string                 `json:"operationId"`
+	Summary     string                 `json:"summary"`
+	Description string                 `json:"description,omitempty"`
+	Tags        []string               `json:"tags,omitempty"`
+	Parameters  []Parameter            `json:"parameters,omitempty"`
+	RequestBody *RequestBody           `json:"requestBody,omitempty"`
+	Responses   map[string]Response    `json:"responses"`
+	Security    *[]map[string][]string `json:"security,omitempty"`
+}
+
+type Parameter struct {
+	Name        string  `json:"name"`
+	In          string  `json:"in"`
+	Description string  `json:"description,omitempty"`
+	Required    bool    `json:"required,omitempty"`
+	Schema      *Schema `json:"schema"`
+}
+
+type RequestBody struct {
+	Required bool                 `json:"required,omitempty"`
+	Content  map[string]MediaType `json:"content"`
+}
+
+type Response struct {
+	Description string               `json:"description"`
+	Headers     map[string]Header    `json:"headers,omitempty"`
+	Content     map[string]MediaType `json:"content,omitempty"`
+}
+
+type Header struct {
+	Description string  `json:"description,omitempty"`
+	Schema      *Schema `json:"schema"`
+}
+
+type MediaType struct {
+	Schema *Schema `json:"schema"`
+}
+
+// NewDocument creates an empty document whose operations may authenticate with an API key
+func NewDocument(title, version, description string) *Document {
+	return &Document{
+		OpenAPI: Version,
+		Info: Info{
+			Title:       title,
+			Version:     version,
+			Description: description,
+		},
+		Paths: make(map[string]PathItem),
+		Components: Components{
+			Schemas: make(map[string]*Schema),
+			SecuritySchemes: map[string]*SecurityScheme{
+				"bearerAuth": {Type: "http", Scheme: "bearer"},
+				"apiKeyAuth": {Type: "apiKey", In: "header", Name: "X-API-Key"},
+			},
+		},
+		Security: []map[string][]string{
+			{"bearerAuth": {}},
+			{"apiKeyAuth": {}},
+		},
+	}
+}
+
+// AddOperation documents the operation served for method on path. Path parameters use the
+// {name} syntax shared by chi and OpenAPI.
+func (document *Document) AddOperation(method, path string, operation Operation) {
+	item, ok := document.Paths[path]
+	if !ok {
+		item = make(PathItem)
+		document.Paths[path] = item
+	}
+
+	if operation.Responses == nil {
+		operation.Responses = make(map[string]Response)
+	}
+
+	item[strings.ToLower(method)] = &operation
+}
+
+// Public marks an operation as not requiring authentication
+func Public() *[]map[string][]string {
+	return &[]map[string][]string{}
+}
+
+// PathParam describes a required string path parameter
+func PathParam(name, description string) Parameter {
+	return Parameter{
+		Name:        name,
+		In:          "path",
+		Description: description,
+		Required:    true,
+		Schema:      &Schema{Type: "string"},
+	}
+}
+
+// QueryParam describes an optional query parameter of the given schema type
+func QueryParam(name, schemaType, description string) Parameter {
+	return Parameter{
+		Name:        name,
+		In:          "query",
+		Description: description,
+		Schema:      &Schema{Type: schemaType},
+	}
+}
+
+// JSONBody describes a required JSON request body
+func JSONBody(schema *Schema) *RequestBody {
+	return &RequestBody{
+		Required: true,
+		Content:  map[string]MediaType{"application/json": {Schema: schema}},
+	}
+}
+
+// JSONResponse describes a JSON response, or a response without body if schema is nil
+func JSONResponse(description string, schema *Schema) Response {
+	response := Response{Description: description}
+	if schema != nil {
+		response.Content = map[string]MediaType{"application/json": {Schema: schema}}
+	}
+
+	return response
+}
+
+// Status formats a status code as a responses map key
+func Status(statusCode int) string {
+	return strconv.Itoa(statusCode)
+}
@@ -0,0 +1,56 @@
+package openapi
+
+import (
+	"fmt"
+	"github.com/go-chi/chi/v5"
+	"net/http"
+	"sort"
+	"strings"
+)
+
+// CheckRoutes compares the routes served by router with the documented operations and
+// returns one message per route missing from the document or operation without a route
+func (document *Document) CheckRoutes(router chi.Routes) ([]string, error) {
+	served := make(map[string]bool)
+	err := chi.Walk(router, func(method, route string, _ http.Handler, _ ...func(http.Handler) http.Handler) error {
+		served[routeKey(method, normalizePath(route))] = true
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	documented := make(map[string]bool)
+	for path, item := range document.Paths {
+		for method := range item {
+			documented[routeKey(method, normalizePath(path))] = true
+		}
+	}
+
+	var problems []string
+	for key := range served {
+		if !documented[key] {
+			problems = append(problems, fmt.Sprintf("route %s is served but not documented", key))
+		}
+	}
+	for key := range documented {
+		if !served[key] {
+			problems = append(problems, fmt.Sprintf("operation %s is documented but not served", key))
+		}
+	}
+
+	sort.Strings(problems)
+	return problems, nil
+}
+
+func routeKey(method, path string) string {
+	return strings.ToUpper(method) + " " + path
+}
+
+// normalizePath strips the trailing slash chi adds to the index route of a sub-router
+func normalizePath(path string) string {
+	if len(path) > 1 {
+		return strings.TrimSuffix(path, "/")
+	}
+	return path
+}
@@ -0,0 +1,152 @@
+package openapi
+
+import (
+	"encoding/json"
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"reflect"
+	"strings"
+	"time"
+)
+
+// Schema is the subset of the OpenAPI schema object generated from Go types
+type Schema struct {
+	Ref                  string             `json:"$ref,omitempty"`
+	Type                 string             `json:"type,omitempty"`
+	Format               string             `json:"format,omitempty"`
+	Description          string             `json:"description,omitempty"`
+	Nullable             bool               `json:"nullable,omitempty"`
+	Properties           map[string]*Schema `json:"properties,omitempty"`
+	Required             []string           `json:"required,omitempty"`
+	Items                *Schema            `json:"items,omitempty"`
+	AdditionalProperties *Schema            `json:"additionalProperties,omitempty"`
+	Enum                 []string           `json:"enum,omitempty"`
+}
+
+var (
+	timeType     = reflect.TypeFor[time.Time]()
+	durationType = reflect.TypeFor[time.Duration]()
+	objectIDType = reflect.TypeFor[bson.ObjectID]()
+	rawJSONType  = reflect.TypeFor[json.RawMessage]()
+)
+
+// SchemaRef returns a reference to the component schema of value's type, generating the component
+// and those of nested named structs on first use. Schemas follow the encoding/json representation:
+// json tags name properties and mark them optional with omitempty or omitzero, and
+// `validate:"required"` fields are always required. A `validate:"url"` rule documents the
+// property as a URI.
+func (document *Document) SchemaRef(value any) *Schema {
+	return document.schemaFor(reflect.TypeOf(value))
+}
+
+// ArrayOf returns a schema for an array whose items use schema
+func ArrayOf(schema *Schema) *Schema {
+	return &Schema{Type: "array", Items: schema}
+}
+
+func (document *Document) schemaFor(valueType reflect.Type) *Schema {
+	switch valueType {
+	case timeType:
+		return &Schema{Type: "string", Format: "date-time"}
+	case durationType:
+		return &Schema{Type: "integer", Format: "int64", Description: "duration in nanoseconds"}
+	case objectIDType:
+		return &Schema{Type: "string", Description: "hex encoded object ID"}
+	case rawJSONType:
+		return &Schema{}
+	}
+
+	switch valueType.Kind() {
+	case reflect.Pointer:
+		schema := document.schemaFor(valueType.Elem())
+		if schema.Ref != "" {
+			return schema
+		}
+		schema.Nullable = true
+		return schema
+	case reflect.String:
+		return &Schema{Type: "string"}
+	case reflect.Bool:
+		return &Schema{Type: "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Uint8, reflect.Uint16, reflect.Uint32:
+		return &Schema{Type: "integer", Format: "int32"}
+	case reflect.Int64, reflect.Uint, reflect.Uint64:
+		return &Schema{Type: "integer", Format: "int64"}
+	case reflect.Float32, reflect.Float64:
+		return &Schema{Type: "number"}
+	case reflect.Slice, reflect.Array:
+		if valueType.Elem().Kind() == reflect.Uint8 {
+			return &Schema{Type: "string", Format: "byte"}
+		}
+		return ArrayOf(document.schemaFor(valueType.Elem()))
+	case reflect.Map:
+		return &Schema{Type: "object", AdditionalProperties: document.schemaFor(valueType.Elem())}
+	case reflect.Struct:
+		if valueType.Name() == "" {
+			return document.structSchema(valueType)
+		}
+
+		name := valueType.Name()
+		if _, exists := document.Components.Schemas[name]; !exists {
+			// Register before generating to terminate recursive types
+			document.Components.Schemas[name] = &Schema{}
+			*document.Components.Schemas[name] = *document.structSchema(valueType)
+		}
+		return &Schema{Ref: "#/components/schemas/" + name}
+	default:
+		return &Schema{}
+	}
+}
+
+func (document *Document) structSchema(structType reflect.Type) *Schema {
+	schema := &Schema{Type: "object", Properties: make(map[string]*Schema)}
+	document.addStructFields(schema, structType)
+	return schema
+}
+
+func (document *Document) addStructFields(schema *Schema, structType reflect.Type) {
+	for i := 0; i < structType.NumField(); i++ {
+		field := structType.Field(i)
+
+		name, options, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if name == "-" && options == "" {
+			continue
+		}
+
+		// Embedded structs without a json name are flattened like encoding/json does
+		if field.Anonymous && name == "" && field.Type.Kind() == reflect.Struct {
+			document.addStructFields(schema, field.Type)
+			continue
+		}
+
+		if !field.IsExported() {
+			continue
+		}
+
+		if name == "" {
+			name = field.Name
+		}
+
+		validateRules := strings.Split(field.Tag.Get("validate"), ",")
+
+		property := document.schemaFor(field.Type)
+		if containsString(validateRules, "url") && property.Type == "string" {
+			property.Format = "uri"
+		}
+		schema.Properties[name] = property
+
+		jsonOptions := strings.Split(options, ",")
+		omitEmpty := containsString(jsonOptions, "omitempty") || containsString(jsonOptions, "omitzero")
+		if containsString(validateRules, "required") || !omitEmpty && field.Type.Kind() != reflect.Pointer {
+			schema.Required = append(schema.Required, name)
+		}
+	}
+}
+
+func containsString(values []string, value string) bool {
+	for _, candidate := range values {
+		if candidate == value {
+			return true
+		}
+	}
+	return false
+}
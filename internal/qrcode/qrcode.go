@@ -0,0 +1,251 @@
+// Package qrcode renders QR codes as PNG or SVG images with custom colors, margin and a
+// centered logo
+package qrcode
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"strings"
+
+	"rsc.io/qr"
+)
+
+// logoScale is the share of the code width covered by a logo. High error correction
+// restores up to 30% of damaged modules, which leaves room for the logo and its padding.
+const logoScale = 0.2
+
+// Error correction levels, from recovering 7% to 30% of the code
+var Levels = map[string]qr.Level{
+	"L": qr.L,
+	"M": qr.M,
+	"Q": qr.Q,
+	"H": qr.H,
+}
+
+// Logo is an image drawn in the center of the code
+type Logo struct {
+	Image     image.Image // Decoded image, drawn into PNG codes
+	Data      []byte      // Encoded image, embedded into SVG codes
+	MediaType string      // Media type of Data, e.g. "image/png"
+}
+
+// Options control how a code is rendered
+type Options struct {
+	Size       int    // Width and height of the image in pixels
+	Level      string // Error correction level, one of the keys of Levels
+	Margin     int    // Quiet zone around the code in modules
+	Foreground color.RGBA
+	Background color.RGBA
+	Logo       *Logo
+}
+
+// Code is an encoded QR code ready to be rendered
+type Code struct {
+	code    *qr.Code
+	options Options
+	modules int // Modules per side including the margin
+}
+
+// Encode encodes text as a QR code
+func Encode(text string, options Options) (*Code, error) {
+	level, ok := Levels[options.Level]
+	if !ok {
+		return nil, fmt.Errorf("unknown error correction level %q", options.Level)
+	}
+
+	code, err := qr.Encode(text, level)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Code{
+		code:    code,
+		options: options,
+		modules: code.Size + 2*options.Margin,
+	}, nil
+}
+
+// dark reports whether the module at x, y counted from the outer edge of the margin is dark
+func (code *Code) dark(x, y int) bool {
+	return code.code.Black(x-code.options.Margin, y-code.options.Margin)
+}
+
+// PNG renders the code as a PNG image. Modules are drawn with a whole number of pixels each,
+// so the pixels left over when Size is not a multiple of the module count widen the margin.
+func (code *Code) PNG() ([]byte, error) {
+	size := max(code.options.Size, code.modules)
+	scale := size / code.modules
+	offset := (size - code.modules*scale) / 2
+
+	palette := color.Palette{code.options.Background, code.options.Foreground}
+	img := image.NewPaletted(image.Rect(0, 0, size, size), palette)
+	for y := range code.modules {
+		for x := range code.modules {
+			if !code.dark(x, y) {
+				continue
+			}
+			for py := offset + y*scale; py < offset+(y+1)*scale; py++ {
+				for px := offset + x*scale; px < offset+(x+1)*scale; px++ {
+					img.SetColorIndex(px, py, 1)
+				}
+			}
+		}
+	}
+
+	var output image.Image = img
+	if code.options.Logo != nil && code.options.Logo.Image != nil {
+		rgba := image.NewRGBA(img.Bounds())
+		draw.Draw(rgba, rgba.Bounds(), img, image.Point{}, draw.Src)
+
+		logoSize := int(float64(code.code.Size*scale) * logoScale)
+		logoOffset := (size - logoSize) / 2
+		bounds := image.Rect(logoOffset, logoOffset, logoOffset+logoSize, logoOffset+logoSize)
+		drawLogo(rgba, bounds, code.options.Background, code.options.Logo.Image)
+		output = rgba
+	}
+
+	var buffer bytes.Buffer
+	encoder := png.Encoder{CompressionLevel: png.BestCompression}
+	if err := encoder.Encode(&buffer, output); err != nil {
+		return nil, err
+	}
+
+	return buffer.Bytes(), nil
+}
+
+// SVG renders the code as an SVG image drawing all dark modules with a single path. The view
+// box is measured in modules, so the code scales to Size without rounding.
+func (code *Code) SVG() []byte {
+	var path strings.Builder
+	for y := range code.modules {
+		for x := 0; x < code.modules; x++ {
+			if !code.dark(x, y) {
+				continue
+			}
+
+			// Merge horizontal runs of dark modules into one rectangle
+			run := 1
+			for x+run < code.modules && code.dark(x+run, y) {
+				run++
+			}
+			fmt.Fprintf(&path, "M%d %dh%dv1h-%dz", x, y, run, run)
+			x += run - 1
+		}
+	}
+
+	var svg strings.Builder
+	fmt.Fprintf(&svg, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" viewBox="0 0 %d %d" shape-rendering="crispEdges">`,
+		code.options.Size, code.options.Size, code.modules, code.modules)
+	fmt.Fprintf(&svg, `<rect width="100%%" height="100%%" fill="%s"%s/>`,
+		hexColor(code.options.Background), fillOpacity(code.options.Background))
+	fmt.Fprintf(&svg, `<path d="%s" fill="%s"%s/>`,
+		path.String(), hexColor(code.options.Foreground), fillOpacity(code.options.Foreground))
+
+	if logo := code.options.Logo; logo != nil && len(logo.Data) > 0 {
+		logoSize := float64(code.code.Size) * logoScale
+		offset := (float64(code.modules) - logoSize) / 2
+		padding := logoSize / 10
+		fmt.Fprintf(&svg, `<rect x="%.2f" y="%.2f" width="%.2f" height="%.2f" fill="%s"%s/>`,
+			offset, offset, logoSize, logoSize, hexColor(code.options.Background), fillOpacity(code.options.Background))
+		fmt.Fprintf(&svg, `<image x="%.2f" y="%.2f" width="%.2f" height="%.2f" href="data:%s;base64,%s"/>`,
+			offset+padding, offset+padding, logoSize-2*padding, logoSize-2*padding,
+			logo.MediaType, base64.StdEncoding.EncodeToString(logo.Data))
+	}
+
+	svg.WriteString("</svg>")
+	return []byte(svg.String())
+}
+
+// drawLogo fills bounds with the background and draws logo scaled into it, leaving padding
+func drawLogo(img *image.RGBA, bounds image.Rectangle, background color.RGBA, logo image.Image) {
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			img.SetRGBA(x, y, background)
+		}
+	}
+
+	padding := bounds.Dx() / 10
+	target := bounds.Inset(padding)
+	if target.Empty() {
+		return
+	}
+
+	// Box filter: average the source pixels covered by each target pixel
+	source := logo.Bounds()
+	for ty := target.Min.Y; ty < target.Max.Y; ty++ {
+		sy0 := source.Min.Y + (ty-target.Min.Y)*source.Dy()/target.Dy()
+		sy1 := max(sy0+1, source.Min.Y+(ty-target.Min.Y+1)*source.Dy()/target.Dy())
+		for tx := target.Min.X; tx < target.Max.X; tx++ {
+			sx0 := source.Min.X + (tx-target.Min.X)*source.Dx()/target.Dx()
+			sx1 := max(sx0+1, source.Min.X+(tx-target.Min.X+1)*source.Dx()/target.Dx())
+
+			var r, g, b, a, count uint32
+			for sy := sy0; sy < sy1; sy++ {
+				for sx := sx0; sx < sx1; sx++ {
+					pr, pg, pb, pa := logo.At(sx, sy).RGBA()
+					r, g, b, a, count = r+pr, g+pg, b+pb, a+pa, count+1
+				}
+			}
+			r, g, b, a = r/count, g/count, b/count, a/count
+
+			// Blend the premultiplied logo pixel over the background
+			bg := img.RGBAAt(tx, ty)
+			inverse := 0xffff - a
+			img.SetRGBA(tx, ty, color.RGBA{
+				R: uint8((r + uint32(bg.R)*0x101*inverse/0xffff) >> 8),
+				G: uint8((g + uint32(bg.G)*0x101*inverse/0xffff) >> 8),
+				B: uint8((b + uint32(bg.B)*0x101*inverse/0xffff) >> 8),
+				A: uint8((a + uint32(bg.A)*0x101*inverse/0xffff) >> 8),
+			})
+		}
+	}
+}
+
+// ParseColor parses a hex color of the form RRGGBB or RRGGBBAA, with an optional leading #
+func ParseColor(value string) (color.RGBA, error) {
+	value = strings.TrimPrefix(value, "#")
+
+	var c color.RGBA
+	switch len(value) {
+	case 6:
+		c.A = 0xff
+		if _, err := fmt.Sscanf(value, "%02x%02x%02x", &c.R, &c.G, &c.B); err != nil {
+			return c, fmt.Errorf("invalid color %q", value)
+		}
+	case 8:
+		if _, err := fmt.Sscanf(value, "%02x%02x%02x%02x", &c.R, &c.G, &c.B, &c.A); err != nil {
+			return c, fmt.Errorf("invalid color %q", value)
+		}
+	default:
+		return c, fmt.Errorf("invalid color %q", value)
+	}
+
+	// color.RGBA is alpha premultiplied
+	c.R = uint8(uint32(c.R) * uint32(c.A) / 0xff)
+	c.G = uint8(uint32(c.G) * uint32(c.A) / 0xff)
+	c.B = uint8(uint32(c.B) * uint32(c.A) / 0xff)
+	return c, nil
+}
+
+func hexColor(c color.RGBA) string {
+	if c.A == 0 {
+		return "#000000"
+	}
+
+	// Undo the alpha premultiplication
+	return fmt.Sprintf("#%02x%02x%02x",
+		uint32(c.R)*0xff/uint32(c.A), uint32(c.G)*0xff/uint32(c.A), uint32(c.B)*0xff/uint32(c.A))
+}
+
+func fillOpacity(c color.RGBA) string {
+	if c.A == 0xff {
+		return ""
+	}
+
+	return fmt.Sprintf(` fill-opacity="%.3f"`, float64(c.A)/0xff)
+}
@@ -0,0 +1,190 @@
+package qrcode
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"strings"
+	"testing"
+)
+
+var (
+	black = color.RGBA{A: 0xff}
+	white = color.RGBA{R: 0xff, G: 0xff, B: 0xff, A: 0xff}
+	navy  = color.RGBA{B: 0x80, A: 0xff}
+)
+
+func TestPNG(t *testing.T) {
+	tests := []struct {
+		name    string
+		options Options
+		size    int // Expected image size, Size unless the modules do not fit
+	}{
+		{
+			name:    "default colors",
+			options: Options{Size: 256, Level: "M", Margin: 4, Foreground: black, Background: white},
+			size:    256,
+		},
+		{
+			name:    "custom colors without margin",
+			options: Options{Size: 300, Level: "H", Margin: 0, Foreground: navy, Background: white},
+			size:    300,
+		},
+		{
+			name:    "size smaller than the modules",
+			options: Options{Size: 10, Level: "L", Margin: 2, Foreground: black, Background: white},
+			size:    29, // 25 modules of a version 2 code plus the margin
+		},
+		{
+			name: "logo",
+			options: Options{Size: 256, Level: "H", Margin: 4, Foreground: black, Background: white,
+				Logo: &Logo{Image: uniformImage(navy, 16)}},
+			size: 256,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			code, err := Encode("https://lnk.example/abc123", test.options)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			data, err := code.PNG()
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			img, err := png.Decode(bytes.NewReader(data))
+			if err != nil {
+				t.Fatalf("decoding PNG: %v", err)
+			}
+
+			if bounds := img.Bounds(); bounds.Dx() != test.size || bounds.Dy() != test.size {
+				t.Fatalf("size = %dx%d, want %dx%d", bounds.Dx(), bounds.Dy(), test.size, test.size)
+			}
+
+			// The top left module of the code is the corner of a finder pattern, which is dark
+			scale := test.size / code.modules
+			offset := (test.size - code.modules*scale) / 2
+			corner := offset + test.options.Margin*scale
+			if got := color.RGBAModel.Convert(img.At(corner, corner)); got != test.options.Foreground {
+				t.Errorf("top left module = %v, want %v", got, test.options.Foreground)
+			}
+			if test.options.Margin > 0 {
+				if got := color.RGBAModel.Convert(img.At(0, 0)); got != test.options.Background {
+					t.Errorf("margin = %v, want %v", got, test.options.Background)
+				}
+			}
+
+			center := test.size / 2
+			centerColor := color.RGBAModel.Convert(img.At(center, center))
+			if test.options.Logo != nil && centerColor != test.options.Logo.Image.At(0, 0) {
+				t.Errorf("center = %v, want the logo color %v", centerColor, test.options.Logo.Image.At(0, 0))
+			}
+		})
+	}
+}
+
+// uniformImage returns a square image of size pixels filled with c
+func uniformImage(c color.RGBA, size int) image.Image {
+	img := image.NewRGBA(image.Rect(0, 0, size, size))
+	draw.Draw(img, img.Bounds(), image.NewUniform(c), image.Point{}, draw.Src)
+	return img
+}
+
+func TestSVG(t *testing.T) {
+	tests := []struct {
+		name     string
+		options  Options
+		contains []string
+		excludes []string
+	}{
+		{
+			name:    "opaque colors",
+			options: Options{Size: 256, Level: "M", Margin: 4, Foreground: navy, Background: white},
+			contains: []string{
+				`width="256" height="256" viewBox="0 0 33 33"`,
+				`<rect width="100%" height="100%" fill="#ffffff"/>`,
+				`fill="#000080"/>`,
+				`<path d="M4 4h7v1h-7z`,
+			},
+			excludes: []string{"fill-opacity", "<image"},
+		},
+		{
+			name:    "translucent background without margin",
+			options: Options{Size: 512, Level: "M", Margin: 0, Foreground: black, Background: color.RGBA{A: 0}},
+			contains: []string{
+				`viewBox="0 0 25 25"`,
+				`fill="#000000" fill-opacity="0.000"/>`,
+				`<path d="M0 0h7v1h-7z`,
+			},
+		},
+		{
+			name: "logo",
+			options: Options{Size: 256, Level: "H", Margin: 4, Foreground: black, Background: white,
+				Logo: &Logo{Data: []byte("logo"), MediaType: "image/png"}},
+			contains: []string{`href="data:image/png;base64,bG9nbw=="`},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			code, err := Encode("https://lnk.example/abc123", test.options)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			svg := string(code.SVG())
+			if !strings.HasPrefix(svg, "<svg ") || !strings.HasSuffix(svg, "</svg>") {
+				t.Errorf("not an SVG document: %s", svg)
+			}
+			for _, want := range test.contains {
+				if !strings.Contains(svg, want) {
+					t.Errorf("SVG does not contain %s:\n%s", want, svg)
+				}
+			}
+			for _, unwanted := range test.excludes {
+				if strings.Contains(svg, unwanted) {
+					t.Errorf("SVG contains %s:\n%s", unwanted, svg)
+				}
+			}
+		})
+	}
+}
+
+func TestEncodeRejectsUnknownLevel(t *testing.T) {
+	if _, err := Encode("https://lnk.example/abc123", Options{Size: 256, Level: "X"}); err == nil {
+		t.Error("expected an error for level X")
+	}
+}
+
+func TestParseColor(t *testing.T) {
+	tests := []struct {
+		value string
+		color color.RGBA
+		err   bool
+	}{
+		{value: "000080", color: navy},
+		{value: "#FFFFFF", color: white},
+		{value: "ff000080", color: color.RGBA{R: 0x80, A: 0x80}},
+		{value: "#00000000", color: color.RGBA{}},
+		{value: "fff", err: true},
+		{value: "gg0000", err: true},
+		{value: "", err: true},
+	}
+
+	for _, test := range tests {
+		got, err := ParseColor(test.value)
+		if test.err {
+			if err == nil {
+				t.Errorf("ParseColor(%q) = %v, want an error", test.value, got)
+			}
+			continue
+		}
+		if err != nil || got != test.color {
+			t.Errorf("ParseColor(%q) = %v, %v, want %v", test.value, got, err, test.color)
+		}
+	}
+}
@@ -0,0 +1,111 @@
+package services
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"github.com/aarondever/linko/internal/config"
+	"github.com/aarondever/linko/internal/database"
+	"golang.org/x/crypto/acme"
+	"golang.org/x/crypto/acme/autocert"
+	"net/http"
+	"net/url"
+	"os"
+	"slices"
+)
+
+// ACMEService obtains and renews TLS certificates via ACME for the host of the base URL, the
+// configured hosts and verified custom domains. Certificates and the ACME account key are kept
+// in the database, so that replicas share them instead of each requesting its own.
+type ACMEService struct {
+	db            *database.Database
+	cfg           *config.Config
+	domainService *DomainService
+}
+
+func NewACMEService(db *database.Database, cfg *config.Config, domainService *DomainService) *ACMEService {
+	return &ACMEService{
+		db:            db,
+		cfg:           cfg,
+		domainService: domainService,
+	}
+}
+
+// NewManager creates the certificate manager. Its TLSConfig serves the certificates and answers
+// TLS-ALPN-01 challenges; its HTTPHandler answers HTTP-01 challenges in front of the router.
+func (service *ACMEService) NewManager() (*autocert.Manager, error) {
+	acmeConfig := service.cfg.Server.ACME
+
+	client := &acme.Client{DirectoryURL: acmeConfig.DirectoryURL}
+	if acmeConfig.CAFile != "" {
+		pem, err := os.ReadFile(acmeConfig.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("reading ACME CA file: %w", err)
+		}
+
+		rootCAs := x509.NewCertPool()
+		if !rootCAs.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in ACME CA file %s", acmeConfig.CAFile)
+		}
+
+		transport := http.DefaultTransport.(*http.Transport).Clone()
+		transport.TLSClientConfig = &tls.Config{RootCAs: rootCAs}
+		client.HTTPClient = &http.Client{Transport: transport}
+	}
+
+	return &autocert.Manager{
+		Prompt:      autocert.AcceptTOS,
+		Cache:       &acmeCache{db: service.db},
+		HostPolicy:  service.hostPolicy,
+		RenewBefore: acmeConfig.RenewBefore,
+		Client:      client,
+		Email:       acmeConfig.Email,
+	}, nil
+}
+
+// hostPolicy only allows certificates for hosts linko serves, so that requests with arbitrary
+// SNI names cannot make it request certificates
+func (service *ACMEService) hostPolicy(ctx context.Context, host string) error {
+	if slices.Contains(service.cfg.Server.ACME.Hosts, host) {
+		return nil
+	}
+	if baseURL, err := url.Parse(service.cfg.Server.BaseURL); err == nil && baseURL.Hostname() == host {
+		return nil
+	}
+
+	domain, err := service.domainService.ResolveHost(ctx, host)
+	if err != nil {
+		return err
+	}
+	if domain == "" {
+		return fmt.Errorf("host %q is not a verified custom domain", host)
+	}
+
+	return nil
+}
+
+// acmeCache stores the data of the certificate manager in the database
+type acmeCache struct {
+	db *database.Database
+}
+
+func (cache *acmeCache) Get(ctx context.Context, key string) ([]byte, error) {
+	data, err := cache.db.GetACMECacheEntry(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	if data == nil {
+		return nil, autocert.ErrCacheMiss
+	}
+
+	return data, nil
+}
+
+func (cache *acmeCache) Put(ctx context.Context, key string, data []byte) error {
+	return cache.db.PutACMECacheEntry(ctx, key, data)
+}
+
+func (cache *acmeCache) Delete(ctx context.Context, key string) error {
+	return cache.db.DeleteACMECacheEntry(ctx, key)
+}
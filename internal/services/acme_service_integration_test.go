@@ -0,0 +1,130 @@
+//go:build integration
+
+// The ACME integration test also needs Pebble, started with PEBBLE_VA_ALWAYS_VALID=1 so that
+// no challenge has to be answered:
+//
+//	SERVER_ACME_DIRECTORY_URL=https://localhost:14000/dir SERVER_ACME_CA_FILE=pebble.minica.pem \
+//		go test -tags integration ./internal/services
+
+package services
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	"github.com/aarondever/linko/internal/database"
+	"golang.org/x/crypto/acme/autocert"
+	"os"
+	"slices"
+	"testing"
+	"time"
+)
+
+const acmeTestHost = "lnk.example"
+
+func TestACMEManagerIssuesCachesAndRenews(t *testing.T) {
+	if _, ok := os.LookupEnv("SERVER_ACME_DIRECTORY_URL"); !ok {
+		t.Skip("SERVER_ACME_DIRECTORY_URL is not set to a Pebble directory")
+	}
+
+	cfg := newTestConfig(t)
+	cfg.Server.BaseURL = "https://" + acmeTestHost
+
+	// The disconnect at the end of the test makes the renewal loop of the last manager fail and
+	// back off, so that it stops requesting certificates
+	db := newTestDatabase(t, cfg)
+	service := NewACMEService(db, cfg, NewDomainService(db, cfg, nil))
+	hello := &tls.ClientHelloInfo{
+		ServerName:   acmeTestHost,
+		CipherSuites: []uint16{tls.TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256},
+	}
+	ctx := context.Background()
+
+	// Issuance
+	issued := acmeTestCertificate(t, service, hello)
+	if !slices.Contains(issued.DNSNames, acmeTestHost) {
+		t.Fatalf("DNS names = %v, want %s", issued.DNSNames, acmeTestHost)
+	}
+
+	// The certificate and the account key are stored in the database
+	if cached := acmeTestCachedCertificate(t, db, acmeTestHost); cached.SerialNumber.Cmp(issued.SerialNumber) != 0 {
+		t.Fatalf("cached serial = %v, want %v", cached.SerialNumber, issued.SerialNumber)
+	}
+	if key, err := db.GetACMECacheEntry(ctx, "acme_account+key"); err != nil || key == nil {
+		t.Fatalf("account key = %v, %v, want a stored key", key, err)
+	}
+
+	// Another replica serves the stored certificate instead of requesting its own
+	if shared := acmeTestCertificate(t, service, hello); shared.SerialNumber.Cmp(issued.SerialNumber) != 0 {
+		t.Fatalf("serial of another manager = %v, want the cached %v", shared.SerialNumber, issued.SerialNumber)
+	}
+
+	// Renewal starts right away when the certificate expires within RenewBefore
+	cfg.Server.ACME.RenewBefore = 100 * 365 * 24 * time.Hour
+	acmeTestCertificate(t, service, hello)
+
+	deadline := time.Now().Add(2 * time.Minute)
+	for {
+		renewed := acmeTestCachedCertificate(t, db, acmeTestHost)
+		if renewed.SerialNumber.Cmp(issued.SerialNumber) != 0 {
+			if !slices.Contains(renewed.DNSNames, acmeTestHost) {
+				t.Fatalf("DNS names of the renewed certificate = %v, want %s", renewed.DNSNames, acmeTestHost)
+			}
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("certificate was not renewed")
+		}
+		time.Sleep(time.Second)
+	}
+}
+
+// acmeTestCertificate returns the leaf served by a new manager of service
+func acmeTestCertificate(t *testing.T, service *ACMEService, hello *tls.ClientHelloInfo) *x509.Certificate {
+	t.Helper()
+
+	manager, err := service.NewManager()
+	if err != nil {
+		t.Fatalf("creating manager: %v", err)
+	}
+
+	cert, err := manager.GetCertificate(hello)
+	if err != nil {
+		t.Fatalf("getting certificate: %v", err)
+	}
+
+	leaf, err := x509.ParseCertificate(cert.Certificate[0])
+	if err != nil {
+		t.Fatalf("parsing certificate: %v", err)
+	}
+
+	return leaf
+}
+
+// acmeTestCachedCertificate returns the leaf stored for host, which follows the private key in
+// the PEM data written by the manager
+func acmeTestCachedCertificate(t *testing.T, db *database.Database, host string) *x509.Certificate {
+	t.Helper()
+
+	data, err := db.GetACMECacheEntry(context.Background(), host)
+	if err != nil {
+		t.Fatalf("reading cache entry: %v", err)
+	}
+	if data == nil {
+		t.Fatalf("no cache entry for %s: %v", host, autocert.ErrCacheMiss)
+	}
+
+	_, rest := pem.Decode(data)
+	block, _ := pem.Decode(rest)
+	if block == nil || block.Type != "CERTIFICATE" {
+		t.Fatalf("cache entry for %s holds no certificate", host)
+	}
+
+	leaf, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		t.Fatalf("parsing cached certificate: %v", err)
+	}
+
+	return leaf
+}
@@ -0,0 +1,45 @@
+package services
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestACMEHostPolicy(t *testing.T) {
+	cfg := newTestConfig(t)
+	cfg.Server.BaseURL = "https://lnk.example"
+	cfg.Server.ACME.Hosts = []string{"www.lnk.example"}
+
+	// Seed the host cache so that hosts resolve without a database: a verified custom domain, an
+	// unverified one and an unknown host
+	domainService := NewDomainService(nil, cfg, nil)
+	expiresAt := time.Now().Add(time.Hour)
+	for _, entry := range []domainCacheEntry{
+		{host: "go.example.com", domain: "go.example.com", expiresAt: expiresAt},
+		{host: "pending.example.com", expiresAt: expiresAt},
+		{host: "other.lnk.example", expiresAt: expiresAt},
+	} {
+		domainService.cacheHost(&entry, cfg.Domain.CacheSize)
+	}
+
+	service := NewACMEService(nil, cfg, domainService)
+
+	tests := []struct {
+		host    string
+		allowed bool
+	}{
+		{"lnk.example", true},
+		{"www.lnk.example", true},
+		{"go.example.com", true},
+		{"pending.example.com", false},
+		{"other.lnk.example", false},
+	}
+
+	for _, test := range tests {
+		err := service.hostPolicy(context.Background(), test.host)
+		if allowed := err == nil; allowed != test.allowed {
+			t.Errorf("hostPolicy(%q) = %v, want allowed %t", test.host, err, test.allowed)
+		}
+	}
+}
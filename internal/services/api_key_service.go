@@ -0,0 +1,168 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"github.com/aarondever/linko/internal/config"
+	"github.com/aarondever/linko/internal/database"
+	"github.com/aarondever/linko/internal/models"
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"sync"
+	"time"
+)
+
+// apiKeyPrefix marks linko API keys so they are easy to recognize, e.g. in secret scanners
+const apiKeyPrefix = "lk_"
+
+// apiKeyDisplayLength is the number of leading key characters stored in plaintext for identification
+const apiKeyDisplayLength = 11
+
+// apiKeyUsageInterval is how often the last use of an API key is recorded
+const apiKeyUsageInterval = time.Minute
+
+// apiKeyPresenceInterval is how long HasActiveAPIKeys reuses its answer. Keys are usually created
+// with the CLI, so the server only notices them once the answer expires.
+const apiKeyPresenceInterval = 10 * time.Second
+
+type APIKeyService struct {
+	db           *database.Database
+	cfg          *config.Config
+	auditService *AuditService
+
+	mu                sync.Mutex
+	hasActiveKeys     bool
+	hasActiveKeysTime time.Time
+}
+
+func NewAPIKeyService(db *database.Database, cfg *config.Config, auditService *AuditService) *APIKeyService {
+	return &APIKeyService{
+		db:           db,
+		cfg:          cfg,
+		auditService: auditService,
+	}
+}
+
+// CreateAPIKey generates a new API key for a workspace, or for the default workspace when it is
+// empty. Admin keys can also use the administrative routes, such as the configuration, audit log,
+// export and import, and webhooks. The plaintext key is only returned here and never stored.
+func (service *APIKeyService) CreateAPIKey(
+	ctx context.Context,
+	name, workspace string,
+	admin bool,
+) (*models.CreateAPIKeyResponse, error) {
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return nil, err
+	}
+	key := apiKeyPrefix + hex.EncodeToString(secret)
+
+	apiKey, err := service.db.CreateAPIKey(ctx, models.APIKey{
+		Name:      name,
+		Workspace: workspace,
+		Admin:     admin,
+		Prefix:    key[:apiKeyDisplayLength],
+		KeyHash:   hashAPIKey(key),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	service.auditService.record(ctx, auditRecord{
+		action:    models.AuditActionAPIKeyCreate,
+		target:    apiKeyTarget(apiKey),
+		workspace: apiKey.Workspace,
+		after:     apiKey,
+	})
+
+	return &models.CreateAPIKeyResponse{
+		APIKey: *apiKey,
+		Key:    key,
+	}, nil
+}
+
+func (service *APIKeyService) ListAPIKeys(ctx context.Context) ([]models.APIKey, error) {
+	return service.db.ListAPIKeys(ctx)
+}
+
+// RevokeAPIKey revokes an API key, or returns ErrAPIKeyNotFound if no active key has the given ID
+func (service *APIKeyService) RevokeAPIKey(ctx context.Context, id string) (*models.APIKey, error) {
+	if _, err := bson.ObjectIDFromHex(id); err != nil {
+		return nil, ErrAPIKeyNotFound
+	}
+
+	apiKey, err := service.db.RevokeAPIKey(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if apiKey == nil {
+		return nil, ErrAPIKeyNotFound
+	}
+
+	active := *apiKey
+	active.RevokedAt = nil
+	service.auditService.record(ctx, auditRecord{
+		action:    models.AuditActionAPIKeyRevoke,
+		target:    apiKeyTarget(apiKey),
+		workspace: apiKey.Workspace,
+		before:    &active,
+		after:     apiKey,
+	})
+
+	return apiKey, nil
+}
+
+// HasActiveAPIKeys reports whether any API key can currently authenticate requests. The answer
+// is cached for apiKeyPresenceInterval.
+func (service *APIKeyService) HasActiveAPIKeys(ctx context.Context) (bool, error) {
+	service.mu.Lock()
+	defer service.mu.Unlock()
+
+	if !service.hasActiveKeysTime.IsZero() && time.Since(service.hasActiveKeysTime) < apiKeyPresenceInterval {
+		return service.hasActiveKeys, nil
+	}
+
+	hasActiveKeys, err := service.db.HasActiveAPIKeys(ctx)
+	if err != nil {
+		return false, err
+	}
+
+	service.hasActiveKeys = hasActiveKeys
+	service.hasActiveKeysTime = time.Now()
+	return hasActiveKeys, nil
+}
+
+// AuthenticateAPIKey returns the active API key matching key, or ErrInvalidAPIKey if the key
+// is unknown or revoked
+func (service *APIKeyService) AuthenticateAPIKey(ctx context.Context, key string) (*models.APIKey, error) {
+	apiKey, err := service.db.GetAPIKeyByHash(ctx, hashAPIKey(key))
+	if err != nil {
+		return nil, err
+	}
+
+	if apiKey == nil || apiKey.RevokedAt != nil {
+		return nil, ErrInvalidAPIKey
+	}
+
+	// Only record usage periodically to avoid a write on every request
+	if apiKey.LastUsedAt == nil || time.Since(*apiKey.LastUsedAt) > apiKeyUsageInterval {
+		if err = service.db.TouchAPIKey(ctx, apiKey.ID); err != nil {
+			return nil, err
+		}
+	}
+
+	return apiKey, nil
+}
+
+// apiKeyTarget identifies an API key in the audit log by its ID
+func apiKeyTarget(apiKey *models.APIKey) models.AuditTarget {
+	return models.AuditTarget{Type: models.AuditTargetAPIKey, ID: apiKey.ID.Hex()}
+}
+
+// hashAPIKey returns the hex encoded SHA-256 hash under which a key is stored
+func hashAPIKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
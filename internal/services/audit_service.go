@@ -0,0 +1,216 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/aarondever/linko/internal/config"
+	"github.com/aarondever/linko/internal/database"
+	"github.com/aarondever/linko/internal/models"
+	"io"
+	"log/slog"
+	"maps"
+	"reflect"
+	"slices"
+	"sync/atomic"
+	"time"
+)
+
+type auditSourceContextKey struct{}
+
+// auditSource describes who performs the actions of a context and from where
+type auditSource struct {
+	actor     models.AuditActor
+	ip        string
+	requestID string
+}
+
+// WithAuditActor returns a context whose audited actions are attributed to actor, performed
+// from the given client IP in the request with the given ID. Actions of contexts without an
+// actor are attributed to the system.
+func WithAuditActor(ctx context.Context, actor models.AuditActor, ip, requestID string) context.Context {
+	return context.WithValue(ctx, auditSourceContextKey{}, auditSource{actor: actor, ip: ip, requestID: requestID})
+}
+
+func auditSourceFromContext(ctx context.Context) auditSource {
+	if source, ok := ctx.Value(auditSourceContextKey{}).(auditSource); ok {
+		return source
+	}
+
+	return auditSource{actor: models.AuditActor{Type: models.AuditActorSystem}}
+}
+
+// auditRecord is an action to record before the audit entry is completed from its context
+type auditRecord struct {
+	action    string
+	target    models.AuditTarget
+	workspace string // Workspace of the target, defaulting to the workspace of the actor
+	before    any
+	after     any
+}
+
+// AuditService keeps an append-only audit log of the changes made to links, API keys, domains,
+// branding, webhooks and the configuration. Entries are recorded after the change they
+// describe; an entry that cannot be stored is logged and does not undo the change.
+type AuditService struct {
+	db  *database.Database
+	cfg atomic.Pointer[config.Config]
+}
+
+func NewAuditService(db *database.Database, cfg *config.Config) *AuditService {
+	service := &AuditService{db: db}
+	service.cfg.Store(cfg)
+
+	return service
+}
+
+// Reload records the configuration settings changed by a reload. Secrets are compared redacted,
+// so their values never end up in the audit log.
+func (service *AuditService) Reload(cfg *config.Config) {
+	previous := service.cfg.Swap(cfg)
+
+	service.record(context.Background(), auditRecord{
+		action: models.AuditActionConfigReload,
+		target: models.AuditTarget{Type: models.AuditTargetConfig},
+		before: previous.Redacted(),
+		after:  cfg.Redacted(),
+	})
+}
+
+// ListEntries returns a page of the entries matching filter from newest to oldest
+func (service *AuditService) ListEntries(
+	ctx context.Context,
+	filter models.AuditFilter,
+	offset, limit int64,
+) ([]models.AuditEntry, error) {
+	return service.db.ListAuditEntries(ctx, filter, offset, limit)
+}
+
+// Export streams the entries matching filter from oldest to newest to writer as NDJSON
+func (service *AuditService) Export(ctx context.Context, writer io.Writer, filter models.AuditFilter) error {
+	encoder := json.NewEncoder(writer)
+	return service.db.ForEachAuditEntry(ctx, filter, func(entry models.AuditEntry) error {
+		return encoder.Encode(entry)
+	})
+}
+
+// record stores an audit entry for each record attributed to the actor of ctx. Records without
+// changes are skipped.
+func (service *AuditService) record(ctx context.Context, records ...auditRecord) {
+	entries := newAuditEntries(ctx, time.Now(), records)
+	if len(entries) == 0 {
+		return
+	}
+
+	if err := service.db.InsertAuditEntries(ctx, entries); err != nil {
+		slog.ErrorContext(ctx, "Failed recording audit entries", "action", entries[0].Action, "entries", len(entries), "error", err)
+	}
+}
+
+// newAuditEntries completes records with the source of ctx, skipping records without changes
+func newAuditEntries(ctx context.Context, now time.Time, records []auditRecord) []models.AuditEntry {
+	source := auditSourceFromContext(ctx)
+
+	entries := make([]models.AuditEntry, 0, len(records))
+	for _, record := range records {
+		changes := auditChanges(record.before, record.after)
+		if len(changes) == 0 {
+			continue
+		}
+
+		workspace := record.workspace
+		if workspace == "" {
+			workspace = source.actor.Workspace
+		}
+
+		entries = append(entries, models.AuditEntry{
+			Time:      now,
+			Workspace: workspace,
+			Actor:     source.actor,
+			Action:    record.action,
+			Target:    record.target,
+			Changes:   changes,
+			IP:        source.ip,
+			RequestID: source.requestID,
+		})
+	}
+
+	return entries
+}
+
+// linkTarget identifies a short link in the audit log by its short code, prefixed with its
+// custom domain if any
+func linkTarget(domain, shortCode string) models.AuditTarget {
+	id := shortCode
+	if domain != "" {
+		id = domain + "/" + shortCode
+	}
+
+	return models.AuditTarget{Type: models.AuditTargetLink, ID: id}
+}
+
+// auditChanges compares the JSON representations of before and after field by field, descending
+// into objects. A nil before or after stands for a target that did not or no longer exists.
+func auditChanges(before, after any) []models.AuditChange {
+	beforeFields := make(map[string]any)
+	flattenAuditFields("", toJSONValue(before), beforeFields)
+	afterFields := make(map[string]any)
+	flattenAuditFields("", toJSONValue(after), afterFields)
+
+	fields := slices.Collect(maps.Keys(beforeFields))
+	for field := range afterFields {
+		if _, ok := beforeFields[field]; !ok {
+			fields = append(fields, field)
+		}
+	}
+	slices.Sort(fields)
+
+	changes := []models.AuditChange{}
+	for _, field := range fields {
+		beforeValue, afterValue := beforeFields[field], afterFields[field]
+		if reflect.DeepEqual(beforeValue, afterValue) {
+			continue
+		}
+
+		changes = append(changes, models.AuditChange{Field: field, Before: beforeValue, After: afterValue})
+	}
+
+	return changes
+}
+
+// toJSONValue converts value into its generic JSON representation
+func toJSONValue(value any) any {
+	if value == nil {
+		return nil
+	}
+
+	data, err := json.Marshal(value)
+	if err != nil {
+		return nil
+	}
+
+	var decoded any
+	if err = json.Unmarshal(data, &decoded); err != nil {
+		return nil
+	}
+
+	return decoded
+}
+
+// flattenAuditFields adds the leaves of a JSON value to fields keyed by their dotted path.
+// Arrays are leaves, compared as a whole.
+func flattenAuditFields(prefix string, value any, fields map[string]any) {
+	object, ok := value.(map[string]any)
+	if !ok {
+		if prefix != "" && value != nil {
+			fields[prefix] = value
+		}
+		return
+	}
+
+	for key, child := range object {
+		if prefix != "" {
+			key = prefix + "." + key
+		}
+		flattenAuditFields(key, child, fields)
+	}
+}
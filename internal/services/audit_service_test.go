@@ -0,0 +1,190 @@
+package services
+
+import (
+	"context"
+	"github.com/aarondever/linko/internal/models"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestAuditChanges(t *testing.T) {
+	createdAt := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	link := models.URLMapping{ShortCode: "abc123", URL: "https://old.example", CreatedAt: createdAt}
+	moved := link
+	moved.URL = "https://new.example"
+	previewed := link
+	previewed.SocialPreview = &models.SocialPreview{Title: "Sale"}
+	retitled := link
+	retitled.SocialPreview = &models.SocialPreview{Title: "Spring sale"}
+
+	tests := []struct {
+		name    string
+		before  any
+		after   any
+		changes []models.AuditChange
+	}{
+		{
+			name:   "create records every field as added",
+			before: nil,
+			after:  models.SocialPreview{Title: "Sale", Image: "https://cdn.example/sale.png"},
+			changes: []models.AuditChange{
+				{Field: "image", After: "https://cdn.example/sale.png"},
+				{Field: "title", After: "Sale"},
+			},
+		},
+		{
+			name:   "delete records every field as removed",
+			before: models.SocialPreview{Title: "Sale"},
+			after:  nil,
+			changes: []models.AuditChange{
+				{Field: "title", Before: "Sale"},
+			},
+		},
+		{
+			name:   "update records only changed fields",
+			before: link,
+			after:  moved,
+			changes: []models.AuditChange{
+				{Field: "url", Before: "https://old.example", After: "https://new.example"},
+			},
+		},
+		{
+			name:   "nested fields are flattened",
+			before: previewed,
+			after:  retitled,
+			changes: []models.AuditChange{
+				{Field: "social_preview.title", Before: "Sale", After: "Spring sale"},
+			},
+		},
+		{
+			name:   "added nested object",
+			before: link,
+			after:  previewed,
+			changes: []models.AuditChange{
+				{Field: "social_preview.title", After: "Sale"},
+			},
+		},
+		{
+			name:   "arrays are compared as a whole",
+			before: models.Webhook{Events: []string{"link.created"}},
+			after:  models.Webhook{Events: []string{"link.created", "link.deleted"}},
+			changes: []models.AuditChange{
+				{Field: "events", Before: []any{"link.created"}, After: []any{"link.created", "link.deleted"}},
+			},
+		},
+		{
+			name:    "unchanged",
+			before:  link,
+			after:   link,
+			changes: []models.AuditChange{},
+		},
+		{
+			name:    "fields hidden from JSON are never recorded",
+			before:  models.APIKey{Name: "ci", KeyHash: "old"},
+			after:   models.APIKey{Name: "ci", KeyHash: "new"},
+			changes: []models.AuditChange{},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			changes := auditChanges(test.before, test.after)
+			if !reflect.DeepEqual(changes, test.changes) {
+				t.Errorf("changes = %+v, want %+v", changes, test.changes)
+			}
+		})
+	}
+}
+
+func TestNewAuditEntries(t *testing.T) {
+	now := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	apiKey := models.AuditActor{Type: models.AuditActorAPIKey, ID: "key-1", Name: "ci", Workspace: "acme"}
+	target := linkTarget("go.acme.example", "abc123")
+	created := auditRecord{
+		action: models.AuditActionLinkCreate,
+		target: target,
+		after:  models.SocialPreview{Title: "Sale"},
+	}
+
+	tests := []struct {
+		name      string
+		ctx       context.Context
+		record    auditRecord
+		actor     models.AuditActor
+		workspace string
+		ip        string
+		requestID string
+		recorded  bool
+	}{
+		{
+			name:      "request of an API key",
+			ctx:       WithAuditActor(context.Background(), apiKey, "203.0.113.7", "req-1"),
+			record:    created,
+			actor:     apiKey,
+			workspace: "acme",
+			ip:        "203.0.113.7",
+			requestID: "req-1",
+			recorded:  true,
+		},
+		{
+			name: "workspace of the target wins over the actor's",
+			ctx:  WithAuditActor(context.Background(), apiKey, "203.0.113.7", "req-1"),
+			record: auditRecord{
+				action:    models.AuditActionDomainVerify,
+				target:    models.AuditTarget{Type: models.AuditTargetDomain, ID: "go.other.example"},
+				workspace: "other",
+				after:     models.Domain{Hostname: "go.other.example"},
+			},
+			actor:     apiKey,
+			workspace: "other",
+			ip:        "203.0.113.7",
+			requestID: "req-1",
+			recorded:  true,
+		},
+		{
+			name:     "context without an actor is the system",
+			ctx:      context.Background(),
+			record:   created,
+			actor:    models.AuditActor{Type: models.AuditActorSystem},
+			recorded: true,
+		},
+		{
+			name: "record without changes is skipped",
+			ctx:  context.Background(),
+			record: auditRecord{
+				action: models.AuditActionLinkUpdate,
+				target: target,
+				before: models.SocialPreview{Title: "Sale"},
+				after:  models.SocialPreview{Title: "Sale"},
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			entries := newAuditEntries(test.ctx, now, []auditRecord{test.record})
+			if !test.recorded {
+				if len(entries) != 0 {
+					t.Errorf("entries = %+v, want none", entries)
+				}
+				return
+			}
+			if len(entries) != 1 {
+				t.Fatalf("got %d entries, want one", len(entries))
+			}
+
+			entry := entries[0]
+			if entry.Actor != test.actor || entry.Workspace != test.workspace || entry.IP != test.ip || entry.RequestID != test.requestID {
+				t.Errorf("actor, workspace, IP, request ID = %+v, %q, %q, %q, want %+v, %q, %q, %q", entry.Actor,
+					entry.Workspace, entry.IP, entry.RequestID, test.actor, test.workspace, test.ip, test.requestID)
+			}
+			if !entry.Time.Equal(now) || entry.Action != test.record.action || entry.Target != test.record.target {
+				t.Errorf("time, action, target = %v, %s, %+v", entry.Time, entry.Action, entry.Target)
+			}
+			if len(entry.Changes) == 0 {
+				t.Error("entry has no changes")
+			}
+		})
+	}
+}
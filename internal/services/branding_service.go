@@ -0,0 +1,120 @@
+package services
+
+import (
+	"cmp"
+	"context"
+	"github.com/aarondever/linko/internal/config"
+	"github.com/aarondever/linko/internal/database"
+	"github.com/aarondever/linko/internal/models"
+)
+
+var ErrBrandingNotFound = NewError(ErrorKindNotFound, "branding_not_found", "Workspace has no branding")
+
+// BrandingService manages the branding of the landing pages of workspaces. Workspaces without
+// branding, and fields they leave empty, use the branding of the configuration.
+type BrandingService struct {
+	db           *database.Database
+	cfg          *config.Config
+	auditService *AuditService
+}
+
+func NewBrandingService(db *database.Database, cfg *config.Config, auditService *AuditService) *BrandingService {
+	return &BrandingService{
+		db:           db,
+		cfg:          cfg,
+		auditService: auditService,
+	}
+}
+
+// GetBranding returns the effective branding of a workspace
+func (service *BrandingService) GetBranding(ctx context.Context, workspace string) (*models.Branding, error) {
+	branding, err := service.db.GetBranding(ctx, workspace)
+	if err != nil {
+		return nil, err
+	}
+
+	if branding == nil {
+		branding = &models.Branding{Workspace: workspace}
+	}
+
+	return service.withDefaults(branding), nil
+}
+
+// SetBranding replaces the branding of a workspace and returns its effective branding
+func (service *BrandingService) SetBranding(
+	ctx context.Context,
+	workspace string,
+	branding models.Branding,
+) (*models.Branding, error) {
+	branding.Workspace = workspace
+
+	previous, err := service.db.GetBranding(ctx, workspace)
+	if err != nil {
+		return nil, err
+	}
+
+	stored, err := service.db.ReplaceBranding(ctx, branding)
+	if err != nil {
+		return nil, err
+	}
+
+	service.auditService.record(ctx, auditRecord{
+		action:    models.AuditActionBrandingUpdate,
+		target:    models.AuditTarget{Type: models.AuditTargetBranding, ID: workspace},
+		workspace: workspace,
+		before:    previous,
+		after:     stored,
+	})
+
+	return service.withDefaults(stored), nil
+}
+
+// DeleteBranding deletes the branding of a workspace, which falls back to the configured branding
+func (service *BrandingService) DeleteBranding(ctx context.Context, workspace string) error {
+	deleted, err := service.db.DeleteBranding(ctx, workspace)
+	if err != nil {
+		return err
+	}
+	if deleted == nil {
+		return ErrBrandingNotFound
+	}
+
+	service.auditService.record(ctx, auditRecord{
+		action:    models.AuditActionBrandingDelete,
+		target:    models.AuditTarget{Type: models.AuditTargetBranding, ID: workspace},
+		workspace: workspace,
+		before:    deleted,
+	})
+
+	return nil
+}
+
+// DomainBranding returns the branding of the workspace owning a custom domain, or the configured
+// branding for the default host
+func (service *BrandingService) DomainBranding(ctx context.Context, domain string) (*models.Branding, error) {
+	if domain == "" {
+		return service.withDefaults(&models.Branding{}), nil
+	}
+
+	registered, err := service.db.GetVerifiedDomain(ctx, domain)
+	if err != nil {
+		return nil, err
+	}
+	if registered == nil {
+		return service.withDefaults(&models.Branding{}), nil
+	}
+
+	return service.GetBranding(ctx, registered.Workspace)
+}
+
+// withDefaults fills the empty fields of branding from the configured branding
+func (service *BrandingService) withDefaults(branding *models.Branding) *models.Branding {
+	defaults := service.cfg.Landing.Branding
+
+	branding.Name = cmp.Or(branding.Name, defaults.Name)
+	branding.LogoURL = cmp.Or(branding.LogoURL, defaults.LogoURL)
+	branding.AccentColor = cmp.Or(branding.AccentColor, defaults.AccentColor)
+	branding.SupportURL = cmp.Or(branding.SupportURL, defaults.SupportURL)
+
+	return branding
+}
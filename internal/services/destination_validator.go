@@ -0,0 +1,196 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"github.com/aarondever/linko/internal/config"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"slices"
+	"strings"
+	"sync/atomic"
+	"syscall"
+	"time"
+)
+
+// maxFetchRedirects bounds the redirects followed by clients from NewHTTPClient
+const maxFetchRedirects = 5
+
+// reservedPrefixes are special purpose ranges not covered by the netip.Addr predicates
+var reservedPrefixes = []netip.Prefix{
+	netip.MustParsePrefix("0.0.0.0/8"),       // "This" network
+	netip.MustParsePrefix("100.64.0.0/10"),   // Carrier-grade NAT
+	netip.MustParsePrefix("192.0.0.0/24"),    // IETF protocol assignments
+	netip.MustParsePrefix("198.18.0.0/15"),   // Benchmarking
+	netip.MustParsePrefix("240.0.0.0/4"),     // Reserved, including broadcast
+	netip.MustParsePrefix("64:ff9b::/96"),    // NAT64, may embed a private IPv4 address
+	netip.MustParsePrefix("64:ff9b:1::/48"),  // Local-use NAT64
+	netip.MustParsePrefix("2001:db8::/32"),   // Documentation
+	netip.MustParsePrefix("2002::/16"),       // 6to4, may embed a private IPv4 address
+	netip.MustParsePrefix("fec0::/10"),       // Deprecated site-local
+	netip.MustParsePrefix("::ffff:0:0:0/96"), // IPv4-translated
+}
+
+var ErrInvalidDestination = NewValidationError("invalid_destination", "Destination is not allowed")
+
+// invalidDestinationError reports why a destination was rejected; it matches ErrInvalidDestination
+func invalidDestinationError(format string, args ...any) error {
+	return Errorf(ErrorKindValidation, ErrInvalidDestination.Code, format, args...)
+}
+
+// Resolver looks up the IP addresses of a host; *net.Resolver implements it
+type Resolver interface {
+	LookupIPAddr(ctx context.Context, host string) ([]net.IPAddr, error)
+}
+
+// DestinationValidator enforces the destination policy of the configuration, rejecting URLs
+// that would make linko fetch internal resources or redirect to itself
+type DestinationValidator struct {
+	cfg      atomic.Pointer[config.Config]
+	resolver Resolver
+}
+
+func NewDestinationValidator(cfg *config.Config) *DestinationValidator {
+	validator := &DestinationValidator{resolver: net.DefaultResolver}
+	validator.cfg.Store(cfg)
+
+	return validator
+}
+
+// Reload switches to the destination policy of cfg
+func (validator *DestinationValidator) Reload(cfg *config.Config) {
+	validator.cfg.Store(cfg)
+}
+
+// SetResolver replaces the DNS resolver. It must be called before the validator is used.
+func (validator *DestinationValidator) SetResolver(resolver Resolver) {
+	validator.resolver = resolver
+}
+
+// Validate checks the scheme, userinfo and host of rawURL and that every address the host
+// resolves to is allowed
+func (validator *DestinationValidator) Validate(ctx context.Context, rawURL string) error {
+	parsed, err := url.Parse(rawURL)
+	if err != nil {
+		return invalidDestinationError("Destination is not a valid URL")
+	}
+
+	if err = validator.checkURL(parsed); err != nil {
+		return err
+	}
+
+	host := parsed.Hostname()
+	if addr, err := netip.ParseAddr(host); err == nil {
+		return validator.checkAddr(addr)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, validator.cfg.Load().Destination.ResolveTimeout)
+	defer cancel()
+
+	addrs, err := validator.resolver.LookupIPAddr(ctx, host)
+	if err != nil || len(addrs) == 0 {
+		return invalidDestinationError("Destination host %s could not be resolved", host)
+	}
+
+	for _, ipAddr := range addrs {
+		addr, ok := netip.AddrFromSlice(ipAddr.IP)
+		if !ok {
+			return invalidDestinationError("Destination host %s resolved to an invalid address", host)
+		}
+		if err = validator.checkAddr(addr); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// checkURL applies the checks that need no DNS lookup
+func (validator *DestinationValidator) checkURL(parsed *url.URL) error {
+	cfg := validator.cfg.Load()
+	policy := cfg.Destination
+
+	if !slices.Contains(policy.AllowedSchemes, strings.ToLower(parsed.Scheme)) {
+		return invalidDestinationError("Destination scheme must be one of %s", strings.Join(policy.AllowedSchemes, ", "))
+	}
+
+	if parsed.User != nil && !policy.AllowCredentials {
+		return invalidDestinationError("Destination must not contain credentials")
+	}
+
+	host := strings.TrimSuffix(strings.ToLower(parsed.Hostname()), ".")
+	if host == "" {
+		return invalidDestinationError("Destination must have a host")
+	}
+
+	for _, selfHost := range policy.SelfHosts {
+		if strings.EqualFold(host, strings.TrimSuffix(selfHost, ".")) {
+			return invalidDestinationError("Destination must not be a short link")
+		}
+	}
+
+	if baseURL, err := url.Parse(cfg.Server.BaseURL); err == nil && strings.EqualFold(host, baseURL.Hostname()) {
+		return invalidDestinationError("Destination must not be a short link")
+	}
+
+	return nil
+}
+
+// checkAddr rejects addresses outside the public internet unless private networks are allowed
+func (validator *DestinationValidator) checkAddr(addr netip.Addr) error {
+	if validator.cfg.Load().Destination.AllowPrivateNetworks || isPublicAddr(addr) {
+		return nil
+	}
+
+	return invalidDestinationError("Destination must not point at a private or reserved address")
+}
+
+// NewHTTPClient returns a client for fetching destinations. Every connection is checked against
+// the destination policy when it is dialed, so hosts that change their DNS records after
+// validation cannot reach internal addresses, and every redirect is checked like a destination.
+func (validator *DestinationValidator) NewHTTPClient(timeout time.Duration) *http.Client {
+	dialer := &net.Dialer{
+		Timeout: timeout,
+		Control: func(_, address string, _ syscall.RawConn) error {
+			addrPort, err := netip.ParseAddrPort(address)
+			if err != nil {
+				return err
+			}
+			return validator.checkAddr(addrPort.Addr())
+		},
+	}
+
+	return &http.Client{
+		Timeout: timeout,
+		Transport: &http.Transport{
+			Proxy:               nil, // A proxy would hide the dialed address from the policy
+			DialContext:         dialer.DialContext,
+			TLSHandshakeTimeout: timeout,
+			MaxIdleConnsPerHost: 2,
+			IdleConnTimeout:     30 * time.Second,
+		},
+		CheckRedirect: func(request *http.Request, via []*http.Request) error {
+			if len(via) >= maxFetchRedirects {
+				return fmt.Errorf("stopped after %d redirects", maxFetchRedirects)
+			}
+			return validator.checkURL(request.URL)
+		},
+	}
+}
+
+func isPublicAddr(addr netip.Addr) bool {
+	addr = addr.Unmap()
+	if !addr.IsGlobalUnicast() || addr.IsPrivate() {
+		return false
+	}
+
+	for _, prefix := range reservedPrefixes {
+		if prefix.Contains(addr) {
+			return false
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,422 @@
+package services
+
+import (
+	"container/list"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"github.com/aarondever/linko/internal/config"
+	"github.com/aarondever/linko/internal/database"
+	"github.com/aarondever/linko/internal/models"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"log/slog"
+	"net"
+	"net/url"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Verification record of a domain: a TXT record on the prefixed host holding the token
+const (
+	domainVerificationPrefix = "_linko-challenge."
+	domainVerificationValue  = "linko-verification="
+)
+
+var (
+	ErrDomainNotFound    = NewError(ErrorKindNotFound, "domain_not_found", "Domain not found")
+	ErrDomainTaken       = NewError(ErrorKindConflict, "domain_taken", "Domain is already registered")
+	ErrDomainVerified    = NewError(ErrorKindConflict, "domain_verified", "Domain has been verified by another workspace")
+	ErrDomainInUse       = NewError(ErrorKindConflict, "domain_in_use", "Domain still has links, delete them first")
+	ErrDomainNotVerified = NewValidationError("domain_not_verified", "Domain has not been verified yet")
+	ErrInvalidDomain     = NewValidationError("invalid_domain", "Domain cannot be used for short links")
+
+	ErrDomainVerificationFailed = NewValidationError("domain_verification_failed", "Domain verification failed")
+)
+
+// domainVerificationError reports why a domain could not be verified; it matches ErrDomainVerificationFailed
+func domainVerificationError(format string, args ...any) error {
+	return Errorf(ErrorKindValidation, ErrDomainVerificationFailed.Code, format, args...)
+}
+
+// TXTResolver looks up the TXT records of a host; *net.Resolver implements it
+type TXTResolver interface {
+	LookupTXT(ctx context.Context, name string) ([]string, error)
+}
+
+// domainCacheEntry is the custom domain served from a host, empty for the default host
+type domainCacheEntry struct {
+	host      string
+	domain    string
+	expiresAt time.Time
+}
+
+// DomainService manages the custom domains of workspaces, verifies their ownership through DNS
+// and maps request hosts to the domain whose links they serve
+type DomainService struct {
+	db           *database.Database
+	cfg          atomic.Pointer[config.Config]
+	resolver     TXTResolver
+	auditService *AuditService
+
+	mu         sync.Mutex
+	cache      map[string]*list.Element // Elements of cacheOrder by host
+	cacheOrder *list.List               // Cached entries, most recently used first
+}
+
+func NewDomainService(db *database.Database, cfg *config.Config, auditService *AuditService) *DomainService {
+	service := &DomainService{
+		db:           db,
+		resolver:     net.DefaultResolver,
+		auditService: auditService,
+		cache:        make(map[string]*list.Element),
+		cacheOrder:   list.New(),
+	}
+	service.cfg.Store(cfg)
+
+	return service
+}
+
+// Reload switches to the self hosts and host cache settings of cfg. Cached hosts are dropped
+// so the new settings apply to every host.
+func (service *DomainService) Reload(cfg *config.Config) {
+	service.cfg.Store(cfg)
+
+	service.mu.Lock()
+	clear(service.cache)
+	service.cacheOrder.Init()
+	service.mu.Unlock()
+}
+
+// SetResolver replaces the DNS resolver used for verification. It must be called before the
+// service is used.
+func (service *DomainService) SetResolver(resolver TXTResolver) {
+	service.resolver = resolver
+}
+
+// CreateDomain registers a custom domain for a workspace. It can serve links once verified.
+// Other workspaces may register the same hostname until one of them verifies it, so nobody can
+// hold on to a hostname they do not control.
+func (service *DomainService) CreateDomain(ctx context.Context, workspace, hostname string) (*models.Domain, error) {
+	hostname = normalizeHostname(hostname)
+	if service.isSelfHost(hostname) {
+		return nil, ErrInvalidDomain
+	}
+
+	verified, err := service.db.GetVerifiedDomain(ctx, hostname)
+	if err != nil {
+		return nil, err
+	}
+	if verified != nil {
+		if verified.Workspace == workspace {
+			return nil, ErrDomainTaken
+		}
+		return nil, ErrDomainVerified
+	}
+
+	token := make([]byte, 16)
+	if _, err := rand.Read(token); err != nil {
+		return nil, err
+	}
+
+	domain, err := service.db.CreateDomain(ctx, models.Domain{
+		Hostname:          hostname,
+		Workspace:         workspace,
+		VerificationToken: hex.EncodeToString(token),
+	})
+	if mongo.IsDuplicateKeyError(err) {
+		return nil, ErrDomainTaken
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	withVerification(domain)
+	service.auditService.record(ctx, auditRecord{
+		action:    models.AuditActionDomainCreate,
+		target:    domainTarget(domain.Hostname),
+		workspace: workspace,
+		after:     domain,
+	})
+
+	return domain, nil
+}
+
+// isSelfHost reports whether hostname serves the default host, either as the host of the base URL
+// or as one of the self hosts of destinations
+func (service *DomainService) isSelfHost(hostname string) bool {
+	cfg := service.cfg.Load()
+	if baseURL, err := url.Parse(cfg.Server.BaseURL); err == nil && normalizeHostname(baseURL.Hostname()) == hostname {
+		return true
+	}
+
+	return slices.ContainsFunc(cfg.Destination.SelfHosts, func(selfHost string) bool {
+		return normalizeHostname(selfHost) == hostname
+	})
+}
+
+// ListDomains returns the domains of a workspace
+func (service *DomainService) ListDomains(ctx context.Context, workspace string) ([]models.Domain, error) {
+	domains, err := service.db.ListDomains(ctx, workspace)
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range domains {
+		withVerification(&domains[i])
+	}
+
+	return domains, nil
+}
+
+// GetDomain returns a domain of a workspace, or ErrDomainNotFound. Domains of other workspaces
+// are reported as not found.
+func (service *DomainService) GetDomain(ctx context.Context, workspace, hostname string) (*models.Domain, error) {
+	domain, err := service.db.GetDomain(ctx, workspace, normalizeHostname(hostname))
+	if err != nil {
+		return nil, err
+	}
+
+	if domain == nil {
+		return nil, ErrDomainNotFound
+	}
+
+	return withVerification(domain), nil
+}
+
+// VerifyDomain checks that the verification record of a domain is published and marks the
+// domain as verified. The registrations of the hostname by other workspaces are dropped, or
+// ErrDomainVerified is returned if another workspace verified it first. Verifying a verified
+// domain checks the record again.
+func (service *DomainService) VerifyDomain(ctx context.Context, workspace, hostname string) (*models.Domain, error) {
+	domain, err := service.GetDomain(ctx, workspace, hostname)
+	if err != nil {
+		return nil, err
+	}
+
+	if err = service.checkVerificationRecord(ctx, domain); err != nil {
+		return nil, err
+	}
+
+	verified, err := service.db.MarkDomainVerified(ctx, workspace, domain.Hostname)
+	if mongo.IsDuplicateKeyError(err) {
+		return nil, ErrDomainVerified
+	}
+	if err != nil {
+		return nil, err
+	}
+	if verified == nil {
+		return nil, ErrDomainNotFound
+	}
+
+	dropped, err := service.db.DeleteUnverifiedDomains(ctx, verified.Hostname)
+	if err != nil {
+		return nil, err
+	}
+	if dropped > 0 {
+		slog.InfoContext(ctx, "Dropped registrations of a verified domain by other workspaces",
+			"hostname", verified.Hostname, "registrations", dropped)
+	}
+
+	service.forget(verified.Hostname)
+	withVerification(verified)
+	service.auditService.record(ctx, auditRecord{
+		action:    models.AuditActionDomainVerify,
+		target:    domainTarget(verified.Hostname),
+		workspace: workspace,
+		before:    domain,
+		after:     verified,
+	})
+
+	return verified, nil
+}
+
+// checkVerificationRecord returns an ErrDomainVerificationFailed error unless one of the TXT
+// records at the verification name of domain holds its verification value
+func (service *DomainService) checkVerificationRecord(ctx context.Context, domain *models.Domain) error {
+	records, err := service.resolver.LookupTXT(ctx, domain.Verification.Name)
+	if err != nil {
+		var dnsErr *net.DNSError
+		if errors.As(err, &dnsErr) && dnsErr.IsNotFound {
+			return domainVerificationError("No TXT record found at %s", domain.Verification.Name)
+		}
+		return domainVerificationError("Failed looking up TXT records of %s", domain.Verification.Name)
+	}
+
+	if !slices.Contains(records, domain.Verification.Value) {
+		return domainVerificationError("TXT record at %s does not contain %q",
+			domain.Verification.Name, domain.Verification.Value)
+	}
+
+	return nil
+}
+
+// DeleteDomain deletes a domain of a workspace that no longer has links
+func (service *DomainService) DeleteDomain(ctx context.Context, workspace, hostname string) error {
+	domain, err := service.GetDomain(ctx, workspace, hostname)
+	if err != nil {
+		return err
+	}
+
+	// Links on the hostname of an unverified domain belong to another workspace, if any
+	if domain.VerifiedAt != nil {
+		inUse, err := service.db.HasURLMappingsOnDomain(ctx, domain.Hostname)
+		if err != nil {
+			return err
+		}
+		if inUse {
+			return ErrDomainInUse
+		}
+	}
+
+	deleted, err := service.db.DeleteDomain(ctx, workspace, domain.Hostname)
+	if err != nil {
+		return err
+	}
+	if !deleted {
+		return ErrDomainNotFound
+	}
+
+	service.forget(domain.Hostname)
+	service.auditService.record(ctx, auditRecord{
+		action:    models.AuditActionDomainDelete,
+		target:    domainTarget(domain.Hostname),
+		workspace: workspace,
+		before:    domain,
+	})
+
+	return nil
+}
+
+// AuthorizeDomain returns the normalized hostname of a domain of the workspace, or
+// ErrDomainNotFound. With requireVerified it returns ErrDomainNotVerified for domains that
+// cannot serve links yet.
+func (service *DomainService) AuthorizeDomain(
+	ctx context.Context,
+	workspace, hostname string,
+	requireVerified bool,
+) (string, error) {
+	domain, err := service.GetDomain(ctx, workspace, hostname)
+	if err != nil {
+		return "", err
+	}
+
+	if domain.VerifiedAt == nil {
+		if requireVerified {
+			return "", ErrDomainNotVerified
+		}
+
+		// The links on a hostname belong to the workspace that verified it
+		verified, err := service.db.GetVerifiedDomain(ctx, domain.Hostname)
+		if err != nil {
+			return "", err
+		}
+		if verified != nil {
+			return "", ErrDomainNotFound
+		}
+	}
+
+	return domain.Hostname, nil
+}
+
+// ResolveHost returns the verified custom domain served from a request host, or an empty string
+// for any other host, which serves the links of the default host. Results are cached briefly,
+// evicting the least recently used hosts once the cache is full, so that requests for random
+// hosts cannot flush the domains in use.
+func (service *DomainService) ResolveHost(ctx context.Context, host string) (string, error) {
+	if hostname, _, err := net.SplitHostPort(host); err == nil {
+		host = hostname
+	}
+	host = normalizeHostname(host)
+
+	if entry, ok := service.cachedHost(host); ok {
+		return entry.domain, nil
+	}
+
+	domain, err := service.db.GetVerifiedDomain(ctx, host)
+	if err != nil {
+		return "", err
+	}
+
+	cacheConfig := service.cfg.Load().Domain
+	entry := &domainCacheEntry{host: host, expiresAt: time.Now().Add(cacheConfig.CacheTTL)}
+	if domain != nil {
+		entry.domain = domain.Hostname
+	}
+	service.cacheHost(entry, cacheConfig.CacheSize)
+
+	return entry.domain, nil
+}
+
+// cachedHost returns the unexpired cache entry of a host and marks it as recently used
+func (service *DomainService) cachedHost(host string) (*domainCacheEntry, bool) {
+	service.mu.Lock()
+	defer service.mu.Unlock()
+
+	element, ok := service.cache[host]
+	if !ok {
+		return nil, false
+	}
+
+	entry := element.Value.(*domainCacheEntry)
+	if !time.Now().Before(entry.expiresAt) {
+		return nil, false
+	}
+
+	service.cacheOrder.MoveToFront(element)
+	return entry, true
+}
+
+// cacheHost stores the cache entry of a host, evicting the least recently used entries beyond size
+func (service *DomainService) cacheHost(entry *domainCacheEntry, size int) {
+	service.mu.Lock()
+	defer service.mu.Unlock()
+
+	if element, ok := service.cache[entry.host]; ok {
+		element.Value = entry
+		service.cacheOrder.MoveToFront(element)
+	} else {
+		service.cache[entry.host] = service.cacheOrder.PushFront(entry)
+	}
+
+	for service.cacheOrder.Len() > size {
+		oldest := service.cacheOrder.Back()
+		service.cacheOrder.Remove(oldest)
+		delete(service.cache, oldest.Value.(*domainCacheEntry).host)
+	}
+}
+
+// forget drops the cached domain of a host after the domain changed
+func (service *DomainService) forget(hostname string) {
+	service.mu.Lock()
+	defer service.mu.Unlock()
+
+	if element, ok := service.cache[hostname]; ok {
+		service.cacheOrder.Remove(element)
+		delete(service.cache, hostname)
+	}
+}
+
+// withVerification fills in the DNS record proving ownership of domain
+func withVerification(domain *models.Domain) *models.Domain {
+	domain.Verification = models.DomainVerification{
+		Type:  "TXT",
+		Name:  domainVerificationPrefix + domain.Hostname,
+		Value: domainVerificationValue + domain.VerificationToken,
+	}
+
+	return domain
+}
+
+// domainTarget identifies a custom domain in the audit log by its host name
+func domainTarget(hostname string) models.AuditTarget {
+	return models.AuditTarget{Type: models.AuditTargetDomain, ID: hostname}
+}
+
+// normalizeHostname lower cases a hostname and strips the trailing dot of fully qualified names
+func normalizeHostname(hostname string) string {
+	return strings.TrimSuffix(strings.ToLower(hostname), ".")
+}
@@ -0,0 +1,153 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"github.com/aarondever/linko/internal/models"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+// stubTXTResolver returns fixed TXT records for the names it knows, or fails with err
+type stubTXTResolver struct {
+	records map[string][]string
+	err     error
+}
+
+func (resolver stubTXTResolver) LookupTXT(_ context.Context, name string) ([]string, error) {
+	if resolver.err != nil {
+		return nil, resolver.err
+	}
+
+	records, ok := resolver.records[name]
+	if !ok {
+		return nil, &net.DNSError{Err: "no such host", Name: name, IsNotFound: true}
+	}
+
+	return records, nil
+}
+
+func TestCheckVerificationRecord(t *testing.T) {
+	domain := withVerification(&models.Domain{Hostname: "go.example.com", VerificationToken: "abc123"})
+	name := "_linko-challenge.go.example.com"
+
+	tests := []struct {
+		name     string
+		resolver stubTXTResolver
+		err      string
+	}{
+		{
+			name:     "matching record",
+			resolver: stubTXTResolver{records: map[string][]string{name: {"linko-verification=abc123"}}},
+		},
+		{
+			name: "matching record among others",
+			resolver: stubTXTResolver{records: map[string][]string{
+				name: {"v=spf1 -all", "linko-verification=abc123", "linko-verification=old"},
+			}},
+		},
+		{
+			name:     "record with another token",
+			resolver: stubTXTResolver{records: map[string][]string{name: {"linko-verification=other"}}},
+			err:      "does not contain",
+		},
+		{
+			name:     "token without prefix",
+			resolver: stubTXTResolver{records: map[string][]string{name: {"abc123"}}},
+			err:      "does not contain",
+		},
+		{
+			name:     "record on the domain instead of the challenge name",
+			resolver: stubTXTResolver{records: map[string][]string{"go.example.com": {"linko-verification=abc123"}}},
+			err:      "No TXT record found",
+		},
+		{
+			name:     "lookup failure",
+			resolver: stubTXTResolver{err: &net.DNSError{Err: "server misbehaving", IsTemporary: true}},
+			err:      "Failed looking up",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			service := NewDomainService(nil, newTestConfig(t), nil)
+			service.SetResolver(test.resolver)
+
+			err := service.checkVerificationRecord(context.Background(), domain)
+			if test.err == "" {
+				if err != nil {
+					t.Errorf("unexpected error: %v", err)
+				}
+				return
+			}
+
+			if !errors.Is(err, ErrDomainVerificationFailed) || !strings.Contains(err.Error(), test.err) {
+				t.Errorf("error = %v, want ErrDomainVerificationFailed containing %q", err, test.err)
+			}
+		})
+	}
+}
+
+func TestIsSelfHost(t *testing.T) {
+	tests := []struct {
+		name     string
+		hostname string
+		want     bool
+	}{
+		{name: "host of the base URL", hostname: "sho.rt", want: true},
+		{name: "self host", hostname: "go.example.com", want: true},
+		{name: "self host configured in upper case", hostname: "links.example.com", want: true},
+		{name: "subdomain of the base URL", hostname: "www.sho.rt"},
+		{name: "other host", hostname: "brand.example"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			cfg := newTestConfig(t)
+			cfg.Server.BaseURL = "https://sho.rt:8443"
+			cfg.Destination.SelfHosts = []string{"go.example.com", "Links.Example.com"}
+			service := NewDomainService(nil, cfg, nil)
+
+			if got := service.isSelfHost(test.hostname); got != test.want {
+				t.Errorf("isSelfHost(%q) = %v, want %v", test.hostname, got, test.want)
+			}
+		})
+	}
+}
+
+func TestDomainCacheEvictsLeastRecentlyUsed(t *testing.T) {
+	service := NewDomainService(nil, newTestConfig(t), nil)
+	expiresAt := time.Now().Add(time.Hour)
+	cache := func(host string) {
+		service.cacheHost(&domainCacheEntry{host: host, domain: host, expiresAt: expiresAt}, 3)
+	}
+
+	cache("a.example")
+	cache("b.example")
+	cache("c.example")
+	service.cachedHost("a.example") // Used again, so b.example is now the least recently used
+	cache("d.example")
+
+	tests := []struct {
+		host   string
+		cached bool
+	}{
+		{"a.example", true},
+		{"b.example", false},
+		{"c.example", true},
+		{"d.example", true},
+	}
+
+	for _, test := range tests {
+		if _, cached := service.cachedHost(test.host); cached != test.cached {
+			t.Errorf("cachedHost(%q) cached = %t, want %t", test.host, cached, test.cached)
+		}
+	}
+
+	service.cacheHost(&domainCacheEntry{host: "e.example", expiresAt: time.Now().Add(-time.Second)}, 3)
+	if _, cached := service.cachedHost("e.example"); cached {
+		t.Error("expired entry of e.example is still cached")
+	}
+}
@@ -0,0 +1,84 @@
+package services
+
+import (
+	"fmt"
+	"github.com/aarondever/linko/internal/models"
+	"time"
+)
+
+// ErrorKind classifies domain errors so handlers can map them to responses
+type ErrorKind int
+
+const (
+	ErrorKindValidation ErrorKind = iota
+	ErrorKindNotFound
+	ErrorKindConflict
+	ErrorKindGone
+	ErrorKindUnauthorized
+	ErrorKindForbidden
+	ErrorKindTooLarge
+	ErrorKindRateLimited
+	ErrorKindInternal
+)
+
+// Error is a domain error whose code and message are safe to show to clients. Any other
+// error returned by a service is treated as internal and never exposed.
+type Error struct {
+	Kind       ErrorKind
+	Code       string              // Stable machine readable code, e.g. "url_not_found"
+	Message    string              // Human readable description
+	Fields     []models.FieldError // Field level details of validation errors
+	RetryAfter time.Duration       // When to retry rate limited requests
+}
+
+func (err *Error) Error() string {
+	return err.Message
+}
+
+// Is matches errors by code, so errors.Is(err, ErrURLNotFound) holds for any not found URL error
+func (err *Error) Is(target error) bool {
+	targetErr, ok := target.(*Error)
+	return ok && targetErr.Code == err.Code
+}
+
+// Domain errors shared by services
+var (
+	ErrURLNotFound    = NewError(ErrorKindNotFound, "url_not_found", "URL not found")
+	ErrURLDisabled    = NewError(ErrorKindGone, "url_disabled", "This short link has been disabled")
+	ErrURLFlagged     = NewError(ErrorKindGone, "url_flagged", "This short link was disabled as its destination was flagged as unsafe")
+	ErrAPIKeyNotFound = NewError(ErrorKindNotFound, "api_key_not_found", "No active API key with this ID")
+	ErrInvalidAPIKey  = NewError(ErrorKindUnauthorized, "invalid_api_key", "Invalid API key")
+)
+
+func NewError(kind ErrorKind, code, message string) *Error {
+	return &Error{
+		Kind:    kind,
+		Code:    code,
+		Message: message,
+	}
+}
+
+// NewValidationError creates a validation error with optional field level details
+func NewValidationError(code, message string, fields ...models.FieldError) *Error {
+	return &Error{
+		Kind:    ErrorKindValidation,
+		Code:    code,
+		Message: message,
+		Fields:  fields,
+	}
+}
+
+// NewRateLimitedError creates an error telling the client to retry after the given delay
+func NewRateLimitedError(message string, retryAfter time.Duration) *Error {
+	return &Error{
+		Kind:       ErrorKindRateLimited,
+		Code:       "rate_limited",
+		Message:    message,
+		RetryAfter: retryAfter,
+	}
+}
+
+// Errorf creates an error of the given kind with a formatted message
+func Errorf(kind ErrorKind, code, format string, args ...any) *Error {
+	return NewError(kind, code, fmt.Sprintf(format, args...))
+}
@@ -0,0 +1,306 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/aarondever/linko/internal/config"
+	"github.com/aarondever/linko/internal/database"
+	"github.com/aarondever/linko/internal/models"
+	"github.com/google/uuid"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+)
+
+// healthCheckPollInterval is how often the worker looks for links due for a check
+const healthCheckPollInterval = time.Minute
+
+// healthCheckLease is how long a claimed link is hidden from other workers while it is checked
+const healthCheckLease = 10 * time.Minute
+
+// healthCheckBodyLimit bounds how much of a GET response body is read before closing it
+const healthCheckBodyLimit = 64 * 1024
+
+const healthCheckUserAgent = "linko-health-check/1.0"
+
+// HealthCheckService periodically requests the destinations of stored links, records their
+// status and latency, and publishes an event when a link breaks or recovers
+type HealthCheckService struct {
+	db             *database.Database
+	cfg            *config.Config
+	client         *http.Client
+	hostLimiter    *hostLimiter
+	webhookService *WebhookService
+}
+
+func NewHealthCheckService(
+	db *database.Database,
+	cfg *config.Config,
+	destinationValidator *DestinationValidator,
+	webhookService *WebhookService,
+) *HealthCheckService {
+	return &HealthCheckService{
+		db:             db,
+		cfg:            cfg,
+		client:         destinationValidator.NewHTTPClient(cfg.HealthCheck.Timeout),
+		hostLimiter:    newHostLimiter(cfg.HealthCheck.HostDelay),
+		webhookService: webhookService,
+	}
+}
+
+// Run checks the links that are due until ctx is cancelled
+func (service *HealthCheckService) Run(ctx context.Context) {
+	if !service.cfg.HealthCheck.Enabled {
+		return
+	}
+
+	ticker := time.NewTicker(healthCheckPollInterval)
+	defer ticker.Stop()
+
+	for {
+		service.checkDue(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// checkDue claims and checks links until none is due, with a bounded number of concurrent checks
+func (service *HealthCheckService) checkDue(ctx context.Context) {
+	jobs := make(chan models.URLMapping)
+
+	var wg sync.WaitGroup
+	for range service.cfg.HealthCheck.Concurrency {
+		wg.Go(func() {
+			for mapping := range jobs {
+				service.checkLink(ctx, mapping)
+			}
+		})
+	}
+
+	for ctx.Err() == nil {
+		mapping, err := service.db.ClaimURLMappingForHealthCheck(ctx, healthCheckLease)
+		if err != nil || mapping == nil {
+			break
+		}
+
+		jobs <- *mapping
+	}
+
+	close(jobs)
+	wg.Wait()
+}
+
+// checkLink checks a single destination and records the outcome
+func (service *HealthCheckService) checkLink(ctx context.Context, mapping models.URLMapping) {
+	var statusCode int
+	err := service.hostLimiter.wait(ctx, mapping.URL)
+	invalid := err != nil && ctx.Err() == nil
+
+	start := time.Now()
+	if err == nil {
+		statusCode, err = service.probe(ctx, mapping.URL)
+	}
+	if ctx.Err() != nil {
+		return // Shutting down, the lease expires and the link is checked again later
+	}
+
+	health := models.LinkHealth{}
+	if mapping.Health != nil {
+		health = *mapping.Health
+	}
+	health.CheckedAt = time.Now()
+	health.LatencyMS = health.CheckedAt.Sub(start).Milliseconds()
+	health.StatusCode = statusCode
+	health.Error = ""
+
+	var nextCheckAt time.Time
+	var event string
+	switch {
+	case err == nil && statusCode < http.StatusBadRequest:
+		if health.BrokenSince != nil {
+			event = models.LinkEventRecovered
+		}
+		health.Healthy = true
+		health.ConsecutiveFailures = 0
+		health.BrokenSince = nil
+		nextCheckAt = health.CheckedAt.Add(service.cfg.HealthCheck.Interval)
+	case err == nil && (statusCode == http.StatusTooManyRequests ||
+		statusCode == http.StatusUnauthorized || statusCode == http.StatusForbidden):
+		// The host asks us to slow down or keeps the page from anonymous clients like the health
+		// checker, which says nothing about whether the link works for its visitors
+		nextCheckAt = health.CheckedAt.Add(service.backoff(health.ConsecutiveFailures + 1))
+	default:
+		if err != nil {
+			health.Error = err.Error()
+		}
+		health.Healthy = false
+		health.ConsecutiveFailures++
+		if invalid {
+			// A destination that cannot be parsed never works, so it is broken right away
+			health.ConsecutiveFailures = max(health.ConsecutiveFailures, service.cfg.HealthCheck.FailureThreshold)
+		}
+		if health.ConsecutiveFailures >= service.cfg.HealthCheck.FailureThreshold && health.BrokenSince == nil {
+			health.BrokenSince = &health.CheckedAt
+			event = models.LinkEventBroken
+		}
+		nextCheckAt = health.CheckedAt.Add(service.backoff(health.ConsecutiveFailures))
+	}
+
+	if err = service.db.UpdateURLHealth(ctx, mapping.Domain, mapping.ShortCode, health, nextCheckAt); err != nil {
+		return
+	}
+
+	if event != "" {
+		mapping.Health = &health
+		slog.InfoContext(ctx, "Link health changed", "short_code", mapping.ShortCode, "event", event, "status_code", statusCode)
+		service.webhookService.Publish(ctx, event, mapping)
+		service.notify(ctx, event, mapping)
+	}
+}
+
+// probe requests the destination with HEAD, falling back to GET for servers that do not
+// support HEAD, and returns the final status code after redirects
+func (service *HealthCheckService) probe(ctx context.Context, destination string) (int, error) {
+	statusCode, err := service.request(ctx, http.MethodHead, destination)
+	if err != nil || (statusCode != http.StatusMethodNotAllowed && statusCode != http.StatusNotImplemented) {
+		return statusCode, err
+	}
+
+	return service.request(ctx, http.MethodGet, destination)
+}
+
+func (service *HealthCheckService) request(ctx context.Context, method, destination string) (int, error) {
+	request, err := http.NewRequestWithContext(ctx, method, destination, nil)
+	if err != nil {
+		return 0, err
+	}
+	request.Header.Set("User-Agent", healthCheckUserAgent)
+
+	response, err := service.client.Do(request)
+	if err != nil {
+		return 0, err
+	}
+	defer response.Body.Close()
+
+	// Drain a bounded part of the body so the connection can be reused
+	io.CopyN(io.Discard, response.Body, healthCheckBodyLimit)
+
+	return response.StatusCode, nil
+}
+
+// backoff returns the delay before the next check after the given number of consecutive
+// failures, doubling from the retry backoff up to the check interval
+func (service *HealthCheckService) backoff(failures int) time.Duration {
+	delay := service.cfg.HealthCheck.RetryBackoff
+	for i := 1; i < failures && delay < service.cfg.HealthCheck.Interval; i++ {
+		delay *= 2
+	}
+
+	return min(delay, service.cfg.HealthCheck.Interval)
+}
+
+// notify posts a link event to the legacy health check webhook, unsigned and without retries,
+// with the client of the health checks so the webhook is held to the destination policy.
+// Failures are only logged.
+func (service *HealthCheckService) notify(ctx context.Context, event string, mapping models.URLMapping) {
+	webhookURL := service.cfg.HealthCheck.WebhookURL
+	if webhookURL == "" {
+		return
+	}
+
+	payload, err := json.Marshal(models.LinkEvent{
+		ID:         uuid.New().String(),
+		Event:      event,
+		OccurredAt: time.Now(),
+		Link:       mapping,
+	})
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed encoding link event", "event", event, "error", err)
+		return
+	}
+
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, webhookURL, bytes.NewReader(payload))
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed creating webhook request", "error", err)
+		return
+	}
+	request.Header.Set("Content-Type", "application/json")
+	request.Header.Set("User-Agent", healthCheckUserAgent)
+
+	response, err := service.client.Do(request)
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed delivering link event", "event", event, "error", err)
+		return
+	}
+	response.Body.Close()
+
+	if response.StatusCode >= http.StatusMultipleChoices {
+		slog.ErrorContext(ctx, "Webhook rejected link event", "event", event, "status_code", response.StatusCode)
+	}
+}
+
+// hostLimiterPruneInterval is how often hosts whose slots have passed are forgotten
+const hostLimiterPruneInterval = time.Minute
+
+// hostLimiter spaces out requests to the same host by a minimum delay
+type hostLimiter struct {
+	delay   time.Duration
+	mu      sync.Mutex
+	next    map[string]time.Time
+	pruneAt time.Time
+}
+
+func newHostLimiter(delay time.Duration) *hostLimiter {
+	return &hostLimiter{
+		delay: delay,
+		next:  make(map[string]time.Time),
+	}
+}
+
+// wait blocks until a request to the host of destination may be sent, reserving that slot
+func (limiter *hostLimiter) wait(ctx context.Context, destination string) error {
+	parsed, err := url.Parse(destination)
+	if err != nil {
+		return fmt.Errorf("invalid destination: %w", err)
+	}
+	host := parsed.Hostname()
+
+	limiter.mu.Lock()
+	now := time.Now()
+	slot := now
+	if next := limiter.next[host]; next.After(now) {
+		slot = next
+	}
+	limiter.next[host] = slot.Add(limiter.delay)
+
+	// Forget hosts whose slots have passed so the map does not grow without bound, scanning it
+	// only now and then rather than on every request
+	if now.After(limiter.pruneAt) {
+		for knownHost, next := range limiter.next {
+			if next.Before(now) {
+				delete(limiter.next, knownHost)
+			}
+		}
+		limiter.pruneAt = now.Add(hostLimiterPruneInterval)
+	}
+	limiter.mu.Unlock()
+
+	timer := time.NewTimer(slot.Sub(now))
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
@@ -0,0 +1,33 @@
+//go:build integration
+
+// Integration tests run against the MongoDB of the DB_* settings:
+//
+//	go test -tags integration ./internal/services
+
+package services
+
+import (
+	"context"
+	"fmt"
+	"github.com/aarondever/linko/internal/config"
+	"github.com/aarondever/linko/internal/database"
+	"testing"
+	"time"
+)
+
+// newTestDatabase connects to a fresh database, which is dropped and disconnected when the test
+// ends
+func newTestDatabase(t *testing.T, cfg *config.Config) *database.Database {
+	t.Helper()
+
+	cfg.Database.Name = fmt.Sprintf("linko_test_%d", time.Now().UnixNano())
+	db, err := database.InitializeDatabase(cfg)
+	if err != nil {
+		t.Fatalf("connecting to MongoDB: %v", err)
+	}
+
+	t.Cleanup(func() { db.Mongo.Disconnect(context.Background()) })
+	t.Cleanup(func() { db.Mongo.Database(cfg.Database.Name).Drop(context.Background()) })
+
+	return db
+}
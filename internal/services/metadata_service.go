@@ -0,0 +1,275 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/aarondever/linko/internal/config"
+	"github.com/aarondever/linko/internal/database"
+	"github.com/aarondever/linko/internal/models"
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+	"golang.org/x/net/html/charset"
+	"io"
+	"log/slog"
+	"mime"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+// maxMetadataFieldLength bounds the stored length of each metadata text field
+const maxMetadataFieldLength = 1000
+
+const metadataUserAgent = "linko-metadata/1.0 (link preview)"
+
+// metadataJob is a link waiting for its destination page to be fetched
+type metadataJob struct {
+	domain    string
+	shortCode string
+	url       string
+}
+
+// MetadataService fetches the title, description, canonical URL, favicon and Open Graph
+// properties of destination pages in the background and stores them on the link
+type MetadataService struct {
+	db     *database.Database
+	cfg    *config.Config
+	client *http.Client
+	queue  chan metadataJob
+}
+
+func NewMetadataService(db *database.Database, cfg *config.Config, destinationValidator *DestinationValidator) *MetadataService {
+	return &MetadataService{
+		db:     db,
+		cfg:    cfg,
+		client: destinationValidator.NewHTTPClient(cfg.Metadata.Timeout),
+		queue:  make(chan metadataJob, cfg.Metadata.QueueSize),
+	}
+}
+
+// Enqueue schedules fetching the metadata of a link without blocking. Links are dropped
+// when the queue is full; their metadata stays empty.
+func (service *MetadataService) Enqueue(domain, shortCode, url string) {
+	if !service.cfg.Metadata.Enabled {
+		return
+	}
+
+	select {
+	case service.queue <- metadataJob{domain: domain, shortCode: shortCode, url: url}:
+	default:
+		slog.Warn("Metadata queue full, skipping link", "short_code", shortCode)
+	}
+}
+
+// Run fetches queued links with a bounded number of concurrent fetches until ctx is cancelled
+func (service *MetadataService) Run(ctx context.Context) {
+	if !service.cfg.Metadata.Enabled {
+		return
+	}
+
+	var wg sync.WaitGroup
+	for range service.cfg.Metadata.Concurrency {
+		wg.Go(func() {
+			for {
+				select {
+				case <-ctx.Done():
+					return
+				case job := <-service.queue:
+					service.fetchAndStore(ctx, job)
+				}
+			}
+		})
+	}
+	wg.Wait()
+}
+
+func (service *MetadataService) fetchAndStore(ctx context.Context, job metadataJob) {
+	metadata, err := service.Fetch(ctx, job.url)
+	if ctx.Err() != nil {
+		return
+	}
+	if err != nil {
+		metadata = &models.LinkMetadata{Error: err.Error()}
+	}
+	metadata.FetchedAt = time.Now()
+
+	service.db.UpdateURLMetadata(ctx, job.domain, job.shortCode, job.url, *metadata)
+}
+
+// Fetch requests an HTML page and extracts its metadata, reading at most the configured number of bytes
+func (service *MetadataService) Fetch(ctx context.Context, pageURL string) (*models.LinkMetadata, error) {
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, pageURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	request.Header.Set("User-Agent", metadataUserAgent)
+	request.Header.Set("Accept", "text/html,application/xhtml+xml;q=0.9")
+
+	response, err := service.client.Do(request)
+	if err != nil {
+		return nil, err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode >= http.StatusBadRequest {
+		return nil, fmt.Errorf("destination responded with status %d", response.StatusCode)
+	}
+
+	contentType := response.Header.Get("Content-Type")
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	if mediaType != "" && mediaType != "text/html" && mediaType != "application/xhtml+xml" {
+		return nil, fmt.Errorf("destination is not an HTML page but %s", mediaType)
+	}
+
+	// Decode to UTF-8 using the header charset, a byte order mark or a <meta> charset declaration
+	body, err := charset.NewReader(io.LimitReader(response.Body, int64(service.cfg.Metadata.MaxBodyBytes)), contentType)
+	if err != nil {
+		return nil, fmt.Errorf("unsupported charset: %w", err)
+	}
+
+	// Relative URLs resolve against the final URL after redirects
+	return parseLinkMetadata(body, response.Request.URL)
+}
+
+// parseLinkMetadata extracts metadata from the head of an HTML document, stopping at the body
+func parseLinkMetadata(reader io.Reader, pageURL *url.URL) (*models.LinkMetadata, error) {
+	metadata := &models.LinkMetadata{}
+	openGraph := models.OpenGraph{}
+	baseURL := pageURL
+	var title strings.Builder
+	inTitle, titleDone := false, false
+
+	tokenizer := html.NewTokenizer(reader)
+tokens:
+	for {
+		tokenType := tokenizer.Next()
+		if tokenType == html.ErrorToken {
+			if err := tokenizer.Err(); !errors.Is(err, io.EOF) {
+				return nil, err
+			}
+			break
+		}
+
+		token := tokenizer.Token()
+		if tokenType == html.TextToken && inTitle {
+			title.WriteString(token.Data)
+			continue
+		}
+
+		if tokenType == html.EndTagToken {
+			switch token.DataAtom {
+			case atom.Title:
+				inTitle, titleDone = false, true
+			case atom.Head:
+				break tokens
+			}
+			continue
+		}
+
+		if tokenType != html.StartTagToken && tokenType != html.SelfClosingTagToken {
+			continue
+		}
+
+		attrs := make(map[string]string, len(token.Attr))
+		for _, attr := range token.Attr {
+			attrs[strings.ToLower(attr.Key)] = attr.Val
+		}
+
+		switch token.DataAtom {
+		case atom.Body:
+			break tokens
+		case atom.Title:
+			inTitle = !titleDone && tokenType == html.StartTagToken
+		case atom.Base:
+			if resolved := resolveURL(pageURL, attrs["href"]); resolved != "" {
+				baseURL, _ = url.Parse(resolved)
+			}
+		case atom.Meta:
+			content := cleanMetadataText(attrs["content"])
+			if strings.EqualFold(attrs["name"], "description") {
+				metadata.Description = content
+			}
+			switch strings.ToLower(attrs["property"]) {
+			case "og:title":
+				openGraph.Title = content
+			case "og:description":
+				openGraph.Description = content
+			case "og:image", "og:image:url":
+				if openGraph.Image == "" {
+					openGraph.Image = resolveURL(baseURL, content)
+				}
+			case "og:type":
+				openGraph.Type = content
+			case "og:site_name":
+				openGraph.SiteName = content
+			case "og:url":
+				openGraph.URL = resolveURL(baseURL, content)
+			}
+		case atom.Link:
+			rels := strings.Fields(strings.ToLower(attrs["rel"]))
+			for _, rel := range rels {
+				switch rel {
+				case "canonical":
+					metadata.CanonicalURL = resolveURL(baseURL, attrs["href"])
+				case "icon":
+					if metadata.FaviconURL == "" {
+						metadata.FaviconURL = resolveURL(baseURL, attrs["href"])
+					}
+				}
+			}
+		}
+	}
+
+	metadata.Title = cleanMetadataText(title.String())
+	if metadata.FaviconURL == "" {
+		metadata.FaviconURL = resolveURL(pageURL, "/favicon.ico")
+	}
+	if openGraph != (models.OpenGraph{}) {
+		metadata.OpenGraph = &openGraph
+	}
+
+	return metadata, nil
+}
+
+// resolveURL resolves a possibly relative reference against base, returning "" for
+// invalid references and anything but http and https URLs
+func resolveURL(base *url.URL, reference string) string {
+	reference = strings.TrimSpace(reference)
+	if reference == "" {
+		return ""
+	}
+
+	parsed, err := url.Parse(reference)
+	if err != nil {
+		return ""
+	}
+
+	resolved := base.ResolveReference(parsed)
+	if resolved.Scheme != "http" && resolved.Scheme != "https" {
+		return ""
+	}
+
+	return truncateMetadataText(resolved.String())
+}
+
+// cleanMetadataText collapses whitespace and bounds the length of a text field
+func cleanMetadataText(text string) string {
+	return truncateMetadataText(strings.Join(strings.Fields(text), " "))
+}
+
+func truncateMetadataText(text string) string {
+	if len(text) <= maxMetadataFieldLength {
+		return text
+	}
+
+	// Cut at a rune boundary
+	end := maxMetadataFieldLength
+	for end > 0 && !utf8.RuneStart(text[end]) {
+		end--
+	}
+	return text[:end]
+}
@@ -0,0 +1,156 @@
+package services
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestMetadataFetch(t *testing.T) {
+	tests := []struct {
+		name         string
+		contentType  string
+		status       int
+		body         string
+		maxBodyBytes int
+		title        string
+		err          string
+	}{
+		{
+			name:        "UTF-8 by default",
+			contentType: "text/html",
+			body:        "<html><head><title>Café  au\n lait</title></head></html>",
+			title:       "Café au lait",
+		},
+		{
+			name:        "charset of the Content-Type header",
+			contentType: "text/html; charset=ISO-8859-1",
+			body:        "<title>Caf\xe9</title>",
+			title:       "Café",
+		},
+		{
+			name:        "multi-byte charset of the Content-Type header",
+			contentType: "text/html; charset=Shift_JIS",
+			body:        "<title>\x93\xfa\x96\x7b</title>",
+			title:       "日本",
+		},
+		{
+			name:        "meta charset declaration",
+			contentType: "text/html",
+			body:        `<meta charset="windows-1252"><title>` + "\x93Hi\x94</title>",
+			title:       "“Hi”",
+		},
+		{
+			name:        "byte order mark wins over the header",
+			contentType: "text/html; charset=ISO-8859-1",
+			body:        "\xef\xbb\xbf<title>Café</title>",
+			title:       "Café",
+		},
+		{
+			name:         "nothing past the size limit is read",
+			contentType:  "text/html",
+			body:         "<head><!--" + strings.Repeat("x", 100) + "--><title>Too late</title></head>",
+			maxBodyBytes: 64,
+		},
+		{
+			name:         "title cut by the size limit",
+			contentType:  "text/html",
+			body:         "<title>" + strings.Repeat("a", 100) + "</title>",
+			maxBodyBytes: 64,
+			title:        strings.Repeat("a", 64-len("<title>")),
+		},
+		{
+			name:        "XHTML page",
+			contentType: "application/xhtml+xml",
+			body:        "<title>XHTML</title>",
+			title:       "XHTML",
+		},
+		{
+			name:        "not an HTML page",
+			contentType: "application/pdf",
+			body:        "%PDF-1.7",
+			err:         "destination is not an HTML page but application/pdf",
+		},
+		{
+			name:        "error status",
+			contentType: "text/html",
+			status:      http.StatusNotFound,
+			body:        "<title>Not found</title>",
+			err:         "destination responded with status 404",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(responseWriter http.ResponseWriter, request *http.Request) {
+				responseWriter.Header().Set("Content-Type", test.contentType)
+				if test.status != 0 {
+					responseWriter.WriteHeader(test.status)
+				}
+				responseWriter.Write([]byte(test.body))
+			}))
+			defer server.Close()
+
+			cfg := newTestConfig(t)
+			cfg.Destination.AllowPrivateNetworks = true
+			if test.maxBodyBytes != 0 {
+				cfg.Metadata.MaxBodyBytes = test.maxBodyBytes
+			}
+			service := NewMetadataService(nil, cfg, NewDestinationValidator(cfg))
+
+			metadata, err := service.Fetch(context.Background(), server.URL+"/page")
+			if test.err != "" {
+				if err == nil || err.Error() != test.err {
+					t.Fatalf("error = %v, want %q", err, test.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if metadata.Title != test.title {
+				t.Errorf("title = %q, want %q", metadata.Title, test.title)
+			}
+		})
+	}
+}
+
+func TestCleanMetadataText(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+		want string
+	}{
+		{
+			name: "whitespace is collapsed",
+			text: "  Linko \n\t short   links ",
+			want: "Linko short links",
+		},
+		{
+			name: "field at the length limit",
+			text: strings.Repeat("a", maxMetadataFieldLength),
+			want: strings.Repeat("a", maxMetadataFieldLength),
+		},
+		{
+			name: "field over the length limit",
+			text: strings.Repeat("a", maxMetadataFieldLength+1),
+			want: strings.Repeat("a", maxMetadataFieldLength),
+		},
+		{
+			name: "field cut at a rune boundary",
+			text: strings.Repeat("a", maxMetadataFieldLength-1) + "é",
+			want: strings.Repeat("a", maxMetadataFieldLength-1),
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := cleanMetadataText(test.text); got != test.want {
+				t.Errorf("got %q (%d bytes), want %q (%d bytes)", got, len(got), test.want, len(test.want))
+			}
+		})
+	}
+}
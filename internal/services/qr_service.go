@@ -0,0 +1,165 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"github.com/aarondever/linko/internal/config"
+	"github.com/aarondever/linko/internal/qrcode"
+	"image"
+	_ "image/gif"
+	_ "image/jpeg"
+	_ "image/png"
+	"io"
+	"net/http"
+	"time"
+)
+
+// Formats QR codes are rendered in
+const (
+	QRCodeFormatPNG = "png"
+	QRCodeFormatSVG = "svg"
+)
+
+// qrLogoTimeout bounds fetching a logo, including redirects
+const qrLogoTimeout = 5 * time.Second
+
+// Limits on logos, which are fetched from user supplied URLs
+const (
+	maxQRLogoBytes     = 1 << 20
+	maxQRLogoDimension = 2048
+)
+
+// qrLogoMediaTypes maps the image formats accepted as logos to their media types
+var qrLogoMediaTypes = map[string]string{
+	"png":  "image/png",
+	"jpeg": "image/jpeg",
+	"gif":  "image/gif",
+}
+
+var ErrInvalidLogo = NewValidationError("invalid_logo", "Logo could not be loaded")
+
+// invalidLogoError reports why a logo could not be used; it matches ErrInvalidLogo
+func invalidLogoError(format string, args ...any) error {
+	return Errorf(ErrorKindValidation, ErrInvalidLogo.Code, format, args...)
+}
+
+// QRCodeParams describe a QR code image
+type QRCodeParams struct {
+	Text    string // Content of the code, usually the short URL
+	Format  string // QRCodeFormatPNG or QRCodeFormatSVG
+	Options qrcode.Options
+	LogoURL string // Image drawn in the center of the code, if set
+}
+
+// ETag identifies the image rendered from params. Logos are identified by URL only, so a
+// changed logo at the same URL is picked up once cached images expire.
+func (params QRCodeParams) ETag() string {
+	hash := sha256.New()
+	fmt.Fprintf(hash, "%s\x00%s\x00%d\x00%s\x00%d\x00%v\x00%v\x00%s",
+		params.Text, params.Format, params.Options.Size, params.Options.Level, params.Options.Margin,
+		params.Options.Foreground, params.Options.Background, params.LogoURL)
+
+	return `"` + hex.EncodeToString(hash.Sum(nil)[:16]) + `"`
+}
+
+// QRCodeImage is a rendered QR code
+type QRCodeImage struct {
+	Data        []byte
+	ContentType string
+}
+
+// QRService renders QR codes for short links
+type QRService struct {
+	cfg                  *config.Config
+	destinationValidator *DestinationValidator
+	client               *http.Client
+}
+
+func NewQRService(cfg *config.Config, destinationValidator *DestinationValidator) *QRService {
+	return &QRService{
+		cfg:                  cfg,
+		destinationValidator: destinationValidator,
+		client:               destinationValidator.NewHTTPClient(qrLogoTimeout),
+	}
+}
+
+// GenerateQRCode renders the QR code described by params, fetching its logo if any
+func (service *QRService) GenerateQRCode(ctx context.Context, params QRCodeParams) (*QRCodeImage, error) {
+	options := params.Options
+	if params.LogoURL != "" {
+		logo, err := service.fetchLogo(ctx, params.LogoURL)
+		if err != nil {
+			return nil, err
+		}
+		options.Logo = logo
+	}
+
+	code, err := qrcode.Encode(params.Text, options)
+	if err != nil {
+		return nil, err
+	}
+
+	switch params.Format {
+	case QRCodeFormatSVG:
+		return &QRCodeImage{Data: code.SVG(), ContentType: "image/svg+xml"}, nil
+	default:
+		data, err := code.PNG()
+		if err != nil {
+			return nil, err
+		}
+		return &QRCodeImage{Data: data, ContentType: "image/png"}, nil
+	}
+}
+
+// fetchLogo downloads and decodes a PNG, JPEG or GIF logo, applying the destination policy to its URL
+func (service *QRService) fetchLogo(ctx context.Context, logoURL string) (*qrcode.Logo, error) {
+	if err := service.destinationValidator.Validate(ctx, logoURL); err != nil {
+		return nil, invalidLogoError("Logo URL is not allowed: %v", err)
+	}
+
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, logoURL, nil)
+	if err != nil {
+		return nil, invalidLogoError("Invalid logo URL")
+	}
+
+	response, err := service.client.Do(request)
+	if err != nil {
+		return nil, invalidLogoError("Failed fetching logo")
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		return nil, invalidLogoError("Fetching logo returned status %d", response.StatusCode)
+	}
+
+	data, err := io.ReadAll(io.LimitReader(response.Body, maxQRLogoBytes+1))
+	if err != nil {
+		return nil, invalidLogoError("Failed fetching logo")
+	}
+	if len(data) > maxQRLogoBytes {
+		return nil, invalidLogoError("Logo is larger than %d bytes", maxQRLogoBytes)
+	}
+
+	// Check the dimensions before decoding, so small files cannot expand into huge images
+	imageConfig, format, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, invalidLogoError("Logo must be a PNG, JPEG or GIF image")
+	}
+	mediaType, ok := qrLogoMediaTypes[format]
+	if !ok {
+		return nil, invalidLogoError("Logo must be a PNG, JPEG or GIF image")
+	}
+	if imageConfig.Width > maxQRLogoDimension || imageConfig.Height > maxQRLogoDimension {
+		return nil, invalidLogoError("Logo must be at most %dx%d pixels", maxQRLogoDimension, maxQRLogoDimension)
+	}
+
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, invalidLogoError("Logo must be a PNG, JPEG or GIF image")
+	}
+
+	return &qrcode.Logo{Image: img, Data: data, MediaType: mediaType}, nil
+}
@@ -0,0 +1,119 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"github.com/aarondever/linko/internal/qrcode"
+	"image"
+	"image/color"
+	"image/png"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestGenerateQRCode(t *testing.T) {
+	var logo bytes.Buffer
+	png.Encode(&logo, image.NewRGBA(image.Rect(0, 0, 16, 16)))
+	var hugeLogo bytes.Buffer
+	png.Encode(&hugeLogo, image.NewGray(image.Rect(0, 0, maxQRLogoDimension+1, 1)))
+
+	server := httptest.NewServer(http.HandlerFunc(func(responseWriter http.ResponseWriter, request *http.Request) {
+		switch request.URL.Path {
+		case "/logo.png":
+			responseWriter.Write(logo.Bytes())
+		case "/huge.png":
+			responseWriter.Write(hugeLogo.Bytes())
+		case "/logo.txt":
+			responseWriter.Write([]byte("not an image"))
+		default:
+			http.NotFound(responseWriter, request)
+		}
+	}))
+	defer server.Close()
+
+	tests := []struct {
+		name        string
+		format      string
+		logoPath    string
+		contentType string
+		err         string
+	}{
+		{name: "PNG", format: QRCodeFormatPNG, contentType: "image/png"},
+		{name: "SVG", format: QRCodeFormatSVG, contentType: "image/svg+xml"},
+		{name: "PNG with logo", format: QRCodeFormatPNG, logoPath: "/logo.png", contentType: "image/png"},
+		{name: "SVG with logo", format: QRCodeFormatSVG, logoPath: "/logo.png", contentType: "image/svg+xml"},
+		{name: "logo that is no image", format: QRCodeFormatPNG, logoPath: "/logo.txt", err: "Logo must be a PNG, JPEG or GIF image"},
+		{name: "logo too large", format: QRCodeFormatPNG, logoPath: "/huge.png", err: "Logo must be at most 2048x2048 pixels"},
+		{name: "missing logo", format: QRCodeFormatPNG, logoPath: "/missing.png", err: "Fetching logo returned status 404"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			cfg := newTestConfig(t)
+			cfg.Destination.AllowPrivateNetworks = true
+			service := NewQRService(cfg, NewDestinationValidator(cfg))
+
+			params := QRCodeParams{
+				Text:   "https://lnk.example/abc123",
+				Format: test.format,
+				Options: qrcode.Options{
+					Size:       128,
+					Level:      "H",
+					Margin:     4,
+					Foreground: color.RGBA{A: 0xff},
+					Background: color.RGBA{R: 0xff, G: 0xff, B: 0xff, A: 0xff},
+				},
+			}
+			if test.logoPath != "" {
+				params.LogoURL = server.URL + test.logoPath
+			}
+
+			qrCode, err := service.GenerateQRCode(context.Background(), params)
+			if test.err != "" {
+				if !errors.Is(err, ErrInvalidLogo) || err.Error() != test.err {
+					t.Fatalf("error = %v, want %q", err, test.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if qrCode.ContentType != test.contentType {
+				t.Errorf("content type = %s, want %s", qrCode.ContentType, test.contentType)
+			}
+			if detected := http.DetectContentType(qrCode.Data); test.format == QRCodeFormatPNG && detected != "image/png" {
+				t.Errorf("detected content type = %s, want image/png", detected)
+			}
+			if test.format == QRCodeFormatSVG && test.logoPath != "" && !strings.Contains(string(qrCode.Data), "data:image/png;base64,") {
+				t.Error("SVG does not embed the logo")
+			}
+		})
+	}
+}
+
+func TestQRCodeParamsETag(t *testing.T) {
+	base := QRCodeParams{
+		Text:    "https://lnk.example/abc123",
+		Format:  QRCodeFormatPNG,
+		Options: qrcode.Options{Size: 256, Level: "M", Margin: 4},
+	}
+	withLogo := base
+	withLogo.LogoURL = "https://cdn.example/logo.png"
+	otherSize := base
+	otherSize.Options.Size = 512
+	otherFormat := base
+	otherFormat.Format = QRCodeFormatSVG
+
+	if base.ETag() != base.ETag() {
+		t.Error("ETag is not stable")
+	}
+	for _, params := range []QRCodeParams{withLogo, otherSize, otherFormat} {
+		if params.ETag() == base.ETag() {
+			t.Errorf("ETag of %+v equals the ETag of %+v", params, base)
+		}
+	}
+}
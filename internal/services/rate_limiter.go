@@ -0,0 +1,180 @@
+package services
+
+import (
+	"context"
+	"github.com/aarondever/linko/internal/config"
+	"github.com/aarondever/linko/internal/database"
+	"log/slog"
+	"math"
+	"sync"
+	"time"
+)
+
+// memoryBucketIdleTTL is how long an untouched in-memory bucket is kept before it is swept
+const memoryBucketIdleTTL = 10 * time.Minute
+
+// RateLimitPolicy is a token bucket holding up to Burst requests, refilled at Rate requests per second
+type RateLimitPolicy struct {
+	Name  string
+	Rate  float64
+	Burst int
+}
+
+// NewRateLimitPolicy converts a configured policy into a token bucket policy
+func NewRateLimitPolicy(name string, policyConfig config.RateLimitPolicyConfig) RateLimitPolicy {
+	return RateLimitPolicy{
+		Name:  name,
+		Rate:  float64(policyConfig.RequestsPerMinute) / 60,
+		Burst: policyConfig.Burst,
+	}
+}
+
+// RateLimitResult describes the state of a bucket after a request was counted against it
+type RateLimitResult struct {
+	Allowed    bool
+	Limit      int
+	Remaining  int
+	ResetAfter time.Duration // Time until the bucket is full again
+	RetryAfter time.Duration // Time until the next request is allowed, zero when allowed
+}
+
+// RateLimiter counts requests against per-key token buckets
+type RateLimiter interface {
+	Allow(ctx context.Context, key string, policy RateLimitPolicy) (RateLimitResult, error)
+	// Check returns whether a request would be allowed without counting it
+	Check(ctx context.Context, key string, policy RateLimitPolicy) (RateLimitResult, error)
+}
+
+// NewRateLimiter returns the rate limiter of the configured backend
+func NewRateLimiter(db *database.Database, cfg *config.Config) RateLimiter {
+	if cfg.RateLimit.Backend == "mongo" {
+		return NewMongoRateLimiter(db)
+	}
+
+	return NewMemoryRateLimiter()
+}
+
+// newRateLimitResult builds the result for a bucket holding tokens after the request was counted
+func newRateLimitResult(policy RateLimitPolicy, tokens float64, allowed bool) RateLimitResult {
+	result := RateLimitResult{
+		Allowed:    allowed,
+		Limit:      policy.Burst,
+		Remaining:  int(math.Floor(tokens)),
+		ResetAfter: tokenDuration(float64(policy.Burst)-tokens, policy.Rate),
+	}
+	if !allowed {
+		result.RetryAfter = tokenDuration(1-tokens, policy.Rate)
+	}
+
+	return result
+}
+
+// tokenDuration returns how long refilling the given number of tokens takes
+func tokenDuration(tokens, rate float64) time.Duration {
+	if tokens <= 0 {
+		return 0
+	}
+
+	return time.Duration(tokens / rate * float64(time.Second))
+}
+
+type memoryBucket struct {
+	tokens    float64
+	updatedAt time.Time
+}
+
+// MemoryRateLimiter keeps buckets in process memory, so quotas are per replica
+type MemoryRateLimiter struct {
+	mu        sync.Mutex
+	buckets   map[string]*memoryBucket
+	lastSweep time.Time
+}
+
+func NewMemoryRateLimiter() *MemoryRateLimiter {
+	return &MemoryRateLimiter{
+		buckets:   make(map[string]*memoryBucket),
+		lastSweep: time.Now(),
+	}
+}
+
+func (limiter *MemoryRateLimiter) Allow(_ context.Context, key string, policy RateLimitPolicy) (RateLimitResult, error) {
+	return limiter.take(key, policy, 1), nil
+}
+
+func (limiter *MemoryRateLimiter) Check(_ context.Context, key string, policy RateLimitPolicy) (RateLimitResult, error) {
+	return limiter.take(key, policy, 0), nil
+}
+
+// take refills the bucket of key and takes cost tokens if at least one is available
+func (limiter *MemoryRateLimiter) take(key string, policy RateLimitPolicy, cost float64) RateLimitResult {
+	limiter.mu.Lock()
+	defer limiter.mu.Unlock()
+
+	now := time.Now()
+	limiter.sweep(now)
+
+	bucketKey := policy.Name + ":" + key
+	bucket, ok := limiter.buckets[bucketKey]
+	if !ok {
+		bucket = &memoryBucket{tokens: float64(policy.Burst), updatedAt: now}
+		limiter.buckets[bucketKey] = bucket
+	}
+
+	elapsed := now.Sub(bucket.updatedAt).Seconds()
+	bucket.tokens = math.Min(float64(policy.Burst), bucket.tokens+elapsed*policy.Rate)
+	bucket.updatedAt = now
+
+	allowed := bucket.tokens >= 1
+	if allowed {
+		bucket.tokens -= cost
+	}
+
+	return newRateLimitResult(policy, bucket.tokens, allowed)
+}
+
+// sweep drops buckets that have been idle long enough to be full again
+func (limiter *MemoryRateLimiter) sweep(now time.Time) {
+	if now.Sub(limiter.lastSweep) < memoryBucketIdleTTL {
+		return
+	}
+	limiter.lastSweep = now
+
+	for key, bucket := range limiter.buckets {
+		if now.Sub(bucket.updatedAt) >= memoryBucketIdleTTL {
+			delete(limiter.buckets, key)
+		}
+	}
+}
+
+// MongoRateLimiter keeps buckets in MongoDB so all replicas share the same quotas
+type MongoRateLimiter struct {
+	db *database.Database
+}
+
+func NewMongoRateLimiter(db *database.Database) *MongoRateLimiter {
+	return &MongoRateLimiter{db: db}
+}
+
+// Allow counts the request in the shared bucket. Requests are let through when the database
+// cannot be reached so an outage of the limiter does not take the API down with it.
+func (limiter *MongoRateLimiter) Allow(ctx context.Context, key string, policy RateLimitPolicy) (RateLimitResult, error) {
+	return limiter.take(ctx, key, policy, 1)
+}
+
+func (limiter *MongoRateLimiter) Check(ctx context.Context, key string, policy RateLimitPolicy) (RateLimitResult, error) {
+	return limiter.take(ctx, key, policy, 0)
+}
+
+// take refills the shared bucket of key and takes cost tokens if at least one is available
+func (limiter *MongoRateLimiter) take(ctx context.Context, key string, policy RateLimitPolicy, cost float64) (RateLimitResult, error) {
+	// Keep the bucket around at least as long as it takes to refill completely
+	ttl := max(tokenDuration(float64(policy.Burst), policy.Rate), time.Minute)
+
+	tokens, allowed, err := limiter.db.TakeRateLimitToken(ctx, policy.Name+":"+key, policy.Rate, policy.Burst, cost, ttl)
+	if err != nil {
+		slog.WarnContext(ctx, "Rate limiter unavailable, allowing request", "policy", policy.Name, "error", err)
+		return RateLimitResult{Allowed: true, Limit: policy.Burst, Remaining: policy.Burst}, nil
+	}
+
+	return newRateLimitResult(policy, tokens, allowed), nil
+}
@@ -0,0 +1,9 @@
+//go:build integration
+
+package services
+
+import "testing"
+
+func TestMongoRateLimiter(t *testing.T) {
+	testRateLimiter(t, NewMongoRateLimiter(newTestDatabase(t, newTestConfig(t))))
+}
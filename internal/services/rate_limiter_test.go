@@ -0,0 +1,164 @@
+package services
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestNewRateLimitResult(t *testing.T) {
+	policy := RateLimitPolicy{Name: "shorten", Rate: 0.5, Burst: 10}
+
+	tests := []struct {
+		name       string
+		tokens     float64
+		allowed    bool
+		remaining  int
+		resetAfter time.Duration
+		retryAfter time.Duration
+	}{
+		{
+			name:      "full bucket",
+			tokens:    10,
+			allowed:   true,
+			remaining: 10,
+		},
+		{
+			name:       "partial tokens are not remaining",
+			tokens:     4.5,
+			allowed:    true,
+			remaining:  4,
+			resetAfter: 11 * time.Second,
+		},
+		{
+			name:       "denied until a whole token refilled",
+			tokens:     0.25,
+			remaining:  0,
+			resetAfter: 19500 * time.Millisecond,
+			retryAfter: 1500 * time.Millisecond,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			result := newRateLimitResult(policy, test.tokens, test.allowed)
+			if result.Allowed != test.allowed || result.Limit != policy.Burst || result.Remaining != test.remaining {
+				t.Errorf("allowed, limit, remaining = %t, %d, %d, want %t, %d, %d", result.Allowed,
+					result.Limit, result.Remaining, test.allowed, policy.Burst, test.remaining)
+			}
+			if result.ResetAfter != test.resetAfter || result.RetryAfter != test.retryAfter {
+				t.Errorf("reset after, retry after = %v, %v, want %v, %v", result.ResetAfter,
+					result.RetryAfter, test.resetAfter, test.retryAfter)
+			}
+		})
+	}
+}
+
+func TestMemoryRateLimiter(t *testing.T) {
+	testRateLimiter(t, NewMemoryRateLimiter())
+}
+
+func TestMemoryRateLimiterSweepsIdleBuckets(t *testing.T) {
+	limiter := NewMemoryRateLimiter()
+	policy := RateLimitPolicy{Name: "redirect", Rate: 1, Burst: 1}
+	limiter.Allow(context.Background(), "idle", policy)
+	limiter.Allow(context.Background(), "active", policy)
+
+	idleSince := time.Now().Add(-memoryBucketIdleTTL)
+	limiter.buckets["redirect:idle"].updatedAt = idleSince
+	limiter.lastSweep = idleSince
+
+	limiter.Allow(context.Background(), "active", policy)
+	if _, ok := limiter.buckets["redirect:idle"]; ok {
+		t.Error("idle bucket was not swept")
+	}
+	if _, ok := limiter.buckets["redirect:active"]; !ok {
+		t.Error("active bucket was swept")
+	}
+}
+
+// rateLimitStep counts a request, or only checks the bucket, and describes the expected result
+type rateLimitStep struct {
+	key       string
+	policy    RateLimitPolicy
+	check     bool
+	sleep     time.Duration // Time to wait before the request
+	allowed   bool
+	remaining int
+}
+
+// testRateLimiter runs the token bucket cases shared by all rate limiter backends. Keys are
+// prefixed with the name of each case, so that cases never share a bucket.
+func testRateLimiter(t *testing.T, limiter RateLimiter) {
+	t.Helper()
+
+	// Refilling one token per hour, buckets do not noticeably refill during a case
+	slow := RateLimitPolicy{Name: "shorten", Rate: 1.0 / 3600, Burst: 3}
+	other := RateLimitPolicy{Name: "manage", Rate: 1.0 / 3600, Burst: 1}
+	fast := RateLimitPolicy{Name: "redirect", Rate: 20, Burst: 1}
+
+	tests := []struct {
+		name  string
+		steps []rateLimitStep
+	}{
+		{
+			name: "burst is allowed, then requests are denied",
+			steps: []rateLimitStep{
+				{key: "a", policy: slow, allowed: true, remaining: 2},
+				{key: "a", policy: slow, allowed: true, remaining: 1},
+				{key: "a", policy: slow, allowed: true, remaining: 0},
+				{key: "a", policy: slow, allowed: false, remaining: 0},
+			},
+		},
+		{
+			name: "check does not take a token",
+			steps: []rateLimitStep{
+				{key: "a", policy: slow, check: true, allowed: true, remaining: 3},
+				{key: "a", policy: slow, check: true, allowed: true, remaining: 3},
+				{key: "a", policy: slow, allowed: true, remaining: 2},
+			},
+		},
+		{
+			name: "keys and policies have separate buckets",
+			steps: []rateLimitStep{
+				{key: "a", policy: other, allowed: true, remaining: 0},
+				{key: "a", policy: other, allowed: false, remaining: 0},
+				{key: "b", policy: other, allowed: true, remaining: 0},
+				{key: "a", policy: slow, allowed: true, remaining: 2},
+			},
+		},
+		{
+			name: "tokens refill over time",
+			steps: []rateLimitStep{
+				{key: "a", policy: fast, allowed: true, remaining: 0},
+				{key: "a", policy: fast, allowed: false, remaining: 0},
+				{key: "a", policy: fast, sleep: 100 * time.Millisecond, allowed: true, remaining: 0},
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			for i, step := range test.steps {
+				time.Sleep(step.sleep)
+
+				take := limiter.Allow
+				if step.check {
+					take = limiter.Check
+				}
+				result, err := take(context.Background(), t.Name()+"/"+step.key, step.policy)
+				if err != nil {
+					t.Fatalf("step %d: unexpected error: %v", i, err)
+				}
+
+				if result.Allowed != step.allowed || result.Remaining != step.remaining {
+					t.Errorf("step %d: allowed, remaining = %t, %d, want %t, %d",
+						i, result.Allowed, result.Remaining, step.allowed, step.remaining)
+				}
+				if !result.Allowed && result.RetryAfter <= 0 {
+					t.Errorf("step %d: denied without a retry delay", i)
+				}
+			}
+		})
+	}
+}
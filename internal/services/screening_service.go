@@ -0,0 +1,343 @@
+package services
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"github.com/aarondever/linko/internal/config"
+	"github.com/aarondever/linko/internal/database"
+	"github.com/aarondever/linko/internal/models"
+	"io"
+	"log/slog"
+	"net/url"
+	"os"
+	"regexp"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// flaggedReasonPrefix starts the disabled reason of links disabled by screening
+const flaggedReasonPrefix = "Flagged as unsafe: "
+
+var ErrUnsafeURL = NewError(ErrorKindForbidden, "unsafe_url", "URL was flagged as unsafe")
+
+// unsafeURLError reports why a URL was flagged; it matches ErrUnsafeURL
+func unsafeURLError(reason string) error {
+	return Errorf(ErrorKindForbidden, ErrUnsafeURL.Code, "URL was flagged as unsafe: %s", reason)
+}
+
+// ScreeningVerdict is the outcome of screening a URL
+type ScreeningVerdict struct {
+	Flagged bool
+	Reason  string
+}
+
+// URLChecker is implemented by external reputation services. Checkers are consulted in the
+// order they were added for URLs that no local rule allows or blocks, and must be safe for
+// concurrent use.
+type URLChecker interface {
+	CheckURL(ctx context.Context, url string) (ScreeningVerdict, error)
+}
+
+// screeningRules is an immutable snapshot of the rules file, replaced as a whole on reload
+type screeningRules struct {
+	blocked  map[string]bool // Blocked domains, including their subdomains
+	allowed  map[string]bool // Allowed domains, including their subdomains, never flagged
+	patterns []*regexp.Regexp
+	filename string
+	modTime  time.Time
+}
+
+// ScreeningService screens destination URLs against local rules and external checkers
+// before they are shortened, and rescans existing links to disable those flagged since
+type ScreeningService struct {
+	db             *database.Database
+	cfg            atomic.Pointer[config.Config]
+	rules          atomic.Pointer[screeningRules]
+	checkers       []URLChecker
+	auditService   *AuditService
+	webhookService *WebhookService
+}
+
+func NewScreeningService(
+	db *database.Database,
+	cfg *config.Config,
+	auditService *AuditService,
+	webhookService *WebhookService,
+) *ScreeningService {
+	service := &ScreeningService{db: db, auditService: auditService, webhookService: webhookService}
+	service.cfg.Store(cfg)
+	service.rules.Store(&screeningRules{})
+
+	if err := service.reloadRules(); err != nil {
+		slog.Error("Failed loading screening rules", "file", cfg.Screening.RulesFile, "error", err)
+	}
+
+	return service
+}
+
+// Reload switches to the screening settings of cfg and loads its rules file right away. The
+// previous rules are kept when the new file cannot be loaded.
+func (service *ScreeningService) Reload(cfg *config.Config) {
+	service.cfg.Store(cfg)
+
+	if err := service.reloadRules(); err != nil {
+		slog.Error("Failed reloading screening rules, keeping previous rules",
+			"file", cfg.Screening.RulesFile,
+			"error", err)
+	}
+}
+
+// AddChecker registers an external reputation checker. It must be called before the service is used.
+func (service *ScreeningService) AddChecker(checker URLChecker) {
+	service.checkers = append(service.checkers, checker)
+}
+
+// Screen returns an ErrUnsafeURL error describing why rawURL was flagged, or nil if it may be shortened
+func (service *ScreeningService) Screen(ctx context.Context, rawURL string) error {
+	if !service.cfg.Load().Screening.Enabled {
+		return nil
+	}
+
+	if verdict := service.check(ctx, rawURL); verdict.Flagged {
+		return unsafeURLError(verdict.Reason)
+	}
+
+	return nil
+}
+
+// check applies the allowlist, blocklist, pattern rules and external checkers in that order.
+// Checkers that fail are skipped so an unavailable reputation service does not block shortening.
+func (service *ScreeningService) check(ctx context.Context, rawURL string) ScreeningVerdict {
+	rules := service.rules.Load()
+
+	var host string
+	if parsed, err := url.Parse(rawURL); err == nil {
+		host = strings.TrimSuffix(strings.ToLower(parsed.Hostname()), ".")
+	}
+
+	if matchDomain(rules.allowed, host) != "" {
+		return ScreeningVerdict{}
+	}
+
+	if domain := matchDomain(rules.blocked, host); domain != "" {
+		return ScreeningVerdict{Flagged: true, Reason: fmt.Sprintf("domain %s is blocklisted", domain)}
+	}
+
+	for _, pattern := range rules.patterns {
+		if pattern.MatchString(rawURL) {
+			return ScreeningVerdict{Flagged: true, Reason: fmt.Sprintf("matches pattern %s", pattern)}
+		}
+	}
+
+	for _, checker := range service.checkers {
+		verdict, err := checker.CheckURL(ctx, rawURL)
+		if err != nil {
+			slog.WarnContext(ctx, "URL checker failed, skipping", "checker", fmt.Sprintf("%T", checker), "error", err)
+			continue
+		}
+
+		if verdict.Flagged {
+			return verdict
+		}
+	}
+
+	return ScreeningVerdict{}
+}
+
+// Run reloads the rules file when it changes and periodically rescans existing links while
+// screening is enabled, until ctx is cancelled
+func (service *ScreeningService) Run(ctx context.Context) {
+	cfg := service.cfg.Load()
+
+	reloadTicker := time.NewTicker(cfg.Screening.ReloadInterval)
+	defer reloadTicker.Stop()
+
+	var rescan <-chan time.Time
+	if cfg.Screening.RescanInterval > 0 {
+		rescanTicker := time.NewTicker(cfg.Screening.RescanInterval)
+		defer rescanTicker.Stop()
+		rescan = rescanTicker.C
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-reloadTicker.C:
+			if err := service.reloadRules(); err != nil {
+				slog.ErrorContext(ctx, "Failed reloading screening rules, keeping previous rules",
+					"file", service.cfg.Load().Screening.RulesFile,
+					"error", err)
+			}
+		case <-rescan:
+			if !service.cfg.Load().Screening.Enabled {
+				continue
+			}
+			if _, err := service.Rescan(ctx); err != nil {
+				slog.ErrorContext(ctx, "Failed rescanning links", "error", err)
+			}
+		}
+	}
+}
+
+// Rescan screens every enabled link again and disables those that are flagged now,
+// returning how many links were disabled
+func (service *ScreeningService) Rescan(ctx context.Context) (int, error) {
+	disabled := 0
+	err := service.db.ForEachURLMapping(ctx, nil, func(mapping models.URLMapping) error {
+		if mapping.DisabledAt != nil {
+			return nil
+		}
+
+		verdict := service.check(ctx, mapping.URL)
+		if !verdict.Flagged {
+			return nil
+		}
+
+		changed, err := service.db.DisableURLMapping(ctx, mapping.Domain, mapping.ShortCode, flaggedReasonPrefix+verdict.Reason)
+		if err != nil {
+			return err
+		}
+
+		if changed {
+			disabled++
+			slog.WarnContext(ctx, "Disabled flagged link", "short_code", mapping.ShortCode, "reason", verdict.Reason)
+
+			flagged := mapping
+			disabledAt := time.Now()
+			flagged.DisabledAt = &disabledAt
+			flagged.DisabledReason = flaggedReasonPrefix + verdict.Reason
+			service.auditService.record(ctx, auditRecord{
+				action: models.AuditActionLinkDisable,
+				target: linkTarget(mapping.Domain, mapping.ShortCode),
+				before: mapping,
+				after:  flagged,
+			})
+			service.webhookService.Publish(ctx, models.LinkEventUpdated, flagged)
+		}
+		return nil
+	})
+
+	slog.InfoContext(ctx, "Rescanned links", "disabled", disabled)
+	return disabled, err
+}
+
+// reloadRules loads the rules file if it changed since it was last loaded, or drops the rules
+// when no rules file is configured any more
+func (service *ScreeningService) reloadRules() error {
+	filename := service.cfg.Load().Screening.RulesFile
+	if filename == "" {
+		if service.rules.Load().filename != "" {
+			service.rules.Store(&screeningRules{})
+		}
+		return nil
+	}
+
+	info, err := os.Stat(filename)
+	if err != nil {
+		return err
+	}
+
+	current := service.rules.Load()
+	if current.filename == filename && info.ModTime().Equal(current.modTime) {
+		return nil
+	}
+
+	file, err := os.Open(filename)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	rules, err := parseScreeningRules(file)
+	if err != nil {
+		return err
+	}
+	rules.filename = filename
+	rules.modTime = info.ModTime()
+
+	service.rules.Store(rules)
+	slog.Info("Loaded screening rules",
+		"file", filename,
+		"blocked", len(rules.blocked),
+		"allowed", len(rules.allowed),
+		"patterns", len(rules.patterns))
+
+	return nil
+}
+
+// parseScreeningRules parses a rules file with one rule per line:
+//
+//	# comment
+//	block evil.example       blocks the domain and its subdomains
+//	allow docs.example.com   never flags the domain and its subdomains
+//	pattern (?i)\.exe$       blocks URLs matching the regular expression
+//
+// A line with a bare domain is a block rule.
+func parseScreeningRules(reader io.Reader) (*screeningRules, error) {
+	rules := &screeningRules{
+		blocked: make(map[string]bool),
+		allowed: make(map[string]bool),
+	}
+
+	scanner := bufio.NewScanner(reader)
+	line := 0
+	for scanner.Scan() {
+		line++
+
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+
+		directive, value, found := strings.Cut(text, " ")
+		if !found {
+			directive, value = "block", directive
+		}
+		value = strings.TrimSpace(value)
+
+		switch directive {
+		case "block":
+			rules.blocked[normalizeDomain(value)] = true
+		case "allow":
+			rules.allowed[normalizeDomain(value)] = true
+		case "pattern":
+			pattern, err := regexp.Compile(value)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: invalid pattern: %w", line, err)
+			}
+			rules.patterns = append(rules.patterns, pattern)
+		default:
+			return nil, fmt.Errorf("line %d: unknown rule %q", line, directive)
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return rules, nil
+}
+
+func normalizeDomain(domain string) string {
+	return strings.TrimSuffix(strings.ToLower(domain), ".")
+}
+
+// matchDomain returns the domain of the set that host equals or is a subdomain of, or "" if none
+func matchDomain(domains map[string]bool, host string) string {
+	for host != "" {
+		if domains[host] {
+			return host
+		}
+
+		_, parent, found := strings.Cut(host, ".")
+		if !found {
+			break
+		}
+		host = parent
+	}
+
+	return ""
+}
@@ -0,0 +1,207 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"github.com/aarondever/linko/internal/config"
+	"io"
+	"maps"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestParseScreeningRules(t *testing.T) {
+	tests := []struct {
+		name     string
+		rules    string
+		blocked  []string
+		allowed  []string
+		patterns []string
+		err      string
+	}{
+		{
+			name:  "empty lines and comments",
+			rules: "\n# comment\n   \n  # indented comment\n",
+		},
+		{
+			name:    "block and allow rules",
+			rules:   "block evil.example\nallow docs.example.com\n",
+			blocked: []string{"evil.example"},
+			allowed: []string{"docs.example.com"},
+		},
+		{
+			name:    "bare domain is a block rule",
+			rules:   "evil.example",
+			blocked: []string{"evil.example"},
+		},
+		{
+			name:    "domains are normalized",
+			rules:   "block Evil.Example.\nallow  Docs.Example.com. ",
+			blocked: []string{"evil.example"},
+			allowed: []string{"docs.example.com"},
+		},
+		{
+			name:     "pattern rule",
+			rules:    `pattern (?i)\.exe$`,
+			patterns: []string{`(?i)\.exe$`},
+		},
+		{
+			name:  "invalid pattern",
+			rules: "block evil.example\npattern (unclosed",
+			err:   "line 2: invalid pattern",
+		},
+		{
+			name:  "unknown rule",
+			rules: "deny evil.example",
+			err:   `line 1: unknown rule "deny"`,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			rules, err := parseScreeningRules(strings.NewReader(test.rules))
+			if test.err != "" {
+				if err == nil || !strings.Contains(err.Error(), test.err) {
+					t.Fatalf("error = %v, want one containing %q", err, test.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if got := slices.Sorted(maps.Keys(rules.blocked)); !slices.Equal(got, test.blocked) {
+				t.Errorf("blocked = %v, want %v", got, test.blocked)
+			}
+			if got := slices.Sorted(maps.Keys(rules.allowed)); !slices.Equal(got, test.allowed) {
+				t.Errorf("allowed = %v, want %v", got, test.allowed)
+			}
+
+			var patterns []string
+			for _, pattern := range rules.patterns {
+				patterns = append(patterns, pattern.String())
+			}
+			if !slices.Equal(patterns, test.patterns) {
+				t.Errorf("patterns = %v, want %v", patterns, test.patterns)
+			}
+		})
+	}
+}
+
+func TestMatchDomain(t *testing.T) {
+	domains := map[string]bool{"evil.example": true, "bad.example.com": true}
+
+	tests := []struct {
+		host string
+		want string
+	}{
+		{"evil.example", "evil.example"},
+		{"www.evil.example", "evil.example"},
+		{"a.b.evil.example", "evil.example"},
+		{"bad.example.com", "bad.example.com"},
+		{"example.com", ""},
+		{"notevil.example", ""},
+		{"evil.example.org", ""},
+		{"example", ""},
+		{"", ""},
+	}
+
+	for _, test := range tests {
+		if got := matchDomain(domains, test.host); got != test.want {
+			t.Errorf("matchDomain(%q) = %q, want %q", test.host, got, test.want)
+		}
+	}
+}
+
+// stubChecker is a URLChecker returning a fixed verdict or error and recording its calls
+type stubChecker struct {
+	verdict ScreeningVerdict
+	err     error
+	calls   int
+}
+
+func (checker *stubChecker) CheckURL(context.Context, string) (ScreeningVerdict, error) {
+	checker.calls++
+	return checker.verdict, checker.err
+}
+
+func TestScreen(t *testing.T) {
+	rules, err := parseScreeningRules(strings.NewReader("block evil.example\nallow good.evil.example\npattern \\.exe$"))
+	if err != nil {
+		t.Fatalf("parsing rules: %v", err)
+	}
+
+	tests := []struct {
+		name       string
+		url        string
+		checker    *stubChecker
+		flagged    bool
+		checkerRan bool
+	}{
+		{
+			name:    "blocklisted subdomain",
+			url:     "https://www.evil.example/page",
+			checker: &stubChecker{},
+			flagged: true,
+		},
+		{
+			name:    "allowlist takes precedence over blocklist and checkers",
+			url:     "https://good.evil.example/setup.exe",
+			checker: &stubChecker{verdict: ScreeningVerdict{Flagged: true, Reason: "phishing"}},
+		},
+		{
+			name:    "pattern match",
+			url:     "https://downloads.example.com/setup.exe",
+			checker: &stubChecker{},
+			flagged: true,
+		},
+		{
+			name:       "flagged by checker",
+			url:        "https://example.com/",
+			checker:    &stubChecker{verdict: ScreeningVerdict{Flagged: true, Reason: "phishing"}},
+			flagged:    true,
+			checkerRan: true,
+		},
+		{
+			name:       "failing checker is skipped",
+			url:        "https://example.com/",
+			checker:    &stubChecker{err: errors.New("unavailable")},
+			checkerRan: true,
+		},
+		{
+			name:       "clean URL",
+			url:        "https://example.com/",
+			checker:    &stubChecker{},
+			checkerRan: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			service := NewScreeningService(nil, newTestConfig(t), nil, nil)
+			service.rules.Store(rules)
+			service.AddChecker(test.checker)
+
+			err := service.Screen(context.Background(), test.url)
+			if flagged := errors.Is(err, ErrUnsafeURL); flagged != test.flagged {
+				t.Errorf("Screen(%q) = %v, want flagged %t", test.url, err, test.flagged)
+			}
+			if checkerRan := test.checker.calls > 0; checkerRan != test.checkerRan {
+				t.Errorf("checker called %d times, want called %t", test.checker.calls, test.checkerRan)
+			}
+		})
+	}
+}
+
+// newTestConfig returns the default configuration, which has no rules file
+func newTestConfig(t *testing.T) *config.Config {
+	t.Helper()
+
+	cfg, err := config.LoadConfig("", io.Discard)
+	if err != nil {
+		t.Fatalf("loading default configuration: %v", err)
+	}
+
+	return cfg
+}
@@ -1,17 +1,88 @@
 package services
 
 import (
+	"context"
 	"github.com/aarondever/linko/internal/config"
 	"github.com/aarondever/linko/internal/database"
+	"sync"
 )
 
 type Services struct {
-	URLService *URLService
+	AuditService         *AuditService
+	URLService           *URLService
+	TransferService      *TransferService
+	APIKeyService        *APIKeyService
+	ScreeningService     *ScreeningService
+	HealthCheckService   *HealthCheckService
+	MetadataService      *MetadataService
+	DomainService        *DomainService
+	WebhookService       *WebhookService
+	BrandingService      *BrandingService
+	QRService            *QRService
+	ACMEService          *ACMEService
+	DestinationValidator *DestinationValidator
+	RateLimiter          RateLimiter
+
+	workers sync.WaitGroup
 }
 
 func InitializeServices(db *database.Database, cfg *config.Config) *Services {
+	auditService := NewAuditService(db, cfg)
+	destinationValidator := NewDestinationValidator(cfg)
+	webhookService := NewWebhookService(db, cfg, destinationValidator, auditService)
+	screeningService := NewScreeningService(db, cfg, auditService, webhookService)
+	metadataService := NewMetadataService(db, cfg, destinationValidator)
+	domainService := NewDomainService(db, cfg, auditService)
+	urlService := NewURLService(db, cfg, destinationValidator, screeningService, metadataService, domainService, auditService, webhookService)
+
 	// Initialize each service - add new services here
 	return &Services{
-		URLService: NewURLService(db, cfg),
+		AuditService:         auditService,
+		URLService:           urlService,
+		TransferService:      NewTransferService(db, cfg, urlService, domainService, auditService, webhookService),
+		APIKeyService:        NewAPIKeyService(db, cfg, auditService),
+		ScreeningService:     screeningService,
+		HealthCheckService:   NewHealthCheckService(db, cfg, destinationValidator, webhookService),
+		MetadataService:      metadataService,
+		DomainService:        domainService,
+		WebhookService:       webhookService,
+		BrandingService:      NewBrandingService(db, cfg, auditService),
+		QRService:            NewQRService(cfg, destinationValidator),
+		ACMEService:          NewACMEService(db, cfg, domainService),
+		DestinationValidator: destinationValidator,
+		RateLimiter:          NewRateLimiter(db, cfg),
+	}
+}
+
+// StartWorkers starts the background jobs of the services, which run until ctx is cancelled
+func (services *Services) StartWorkers(ctx context.Context) {
+	// Start each background job - add new jobs here
+	jobs := []func(ctx context.Context){
+		services.ScreeningService.Run,
+		services.HealthCheckService.Run,
+		services.MetadataService.Run,
+		services.WebhookService.Run,
+	}
+
+	for _, job := range jobs {
+		services.workers.Go(func() {
+			job(ctx)
+		})
+	}
+}
+
+// Reloadables returns the services applying reloaded configuration while running
+func (services *Services) Reloadables() []config.Reloadable {
+	// List each service implementing config.Reloadable - add new services here
+	return []config.Reloadable{
+		services.AuditService,
+		services.DestinationValidator,
+		services.DomainService,
+		services.ScreeningService,
 	}
 }
+
+// WaitWorkers blocks until all background jobs have returned
+func (services *Services) WaitWorkers() {
+	services.workers.Wait()
+}
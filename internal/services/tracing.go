@@ -0,0 +1,34 @@
+package services
+
+import (
+	"errors"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+var tracer = otel.Tracer("github.com/aarondever/linko/internal/services")
+
+// linkAttributes identifies the short link a span operates on
+func linkAttributes(domain, shortCode string) trace.SpanStartOption {
+	return trace.WithAttributes(
+		attribute.String("linko.domain", domain),
+		attribute.String("linko.short_code", shortCode))
+}
+
+// endSpan ends span, recording err. Domain errors are expected outcomes such as unknown links,
+// so only their code is recorded; any other error marks the span as failed.
+func endSpan(span trace.Span, err error) {
+	var domainErr *Error
+	switch {
+	case err == nil:
+	case errors.As(err, &domainErr) && domainErr.Kind != ErrorKindInternal:
+		span.SetAttributes(attribute.String("linko.error_code", domainErr.Code))
+	default:
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+
+	span.End()
+}
@@ -0,0 +1,593 @@
+package services
+
+import (
+	"bufio"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/aarondever/linko/internal/config"
+	"github.com/aarondever/linko/internal/database"
+	"github.com/aarondever/linko/internal/models"
+	"github.com/aarondever/linko/internal/utils"
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// importBatchSize is the number of records looked up and written per database round trip
+const importBatchSize = 500
+
+var shortCodePattern = regexp.MustCompile(models.ShortCodePattern)
+
+// linkKey identifies a link, as short codes are unique per domain
+type linkKey struct {
+	domain    string
+	shortCode string
+}
+
+var (
+	ErrUnsupportedTransferFormat = NewValidationError("unsupported_format", "Unsupported format, use ndjson or csv")
+	ErrUnsupportedOnConflict     = NewValidationError("unsupported_on_conflict", "Unsupported on_conflict, use skip or overwrite")
+)
+
+// invalidImportDataError reports import data that cannot be read at all
+func invalidImportDataError(format string, args ...any) error {
+	return Errorf(ErrorKindValidation, "invalid_import_data", format, args...)
+}
+
+// TransferService exports and imports link data in backend-neutral formats
+type TransferService struct {
+	db             *database.Database
+	cfg            *config.Config
+	urlService     *URLService
+	domainService  *DomainService
+	auditService   *AuditService
+	webhookService *WebhookService
+}
+
+func NewTransferService(
+	db *database.Database,
+	cfg *config.Config,
+	urlService *URLService,
+	domainService *DomainService,
+	auditService *AuditService,
+	webhookService *WebhookService,
+) *TransferService {
+	return &TransferService{
+		db:             db,
+		cfg:            cfg,
+		urlService:     urlService,
+		domainService:  domainService,
+		auditService:   auditService,
+		webhookService: webhookService,
+	}
+}
+
+// Export streams the links of a workspace to writer in the given format, optionally including click
+// stats. A workspace owns the links on its custom domains and shares those on the default host.
+func (service *TransferService) Export(
+	ctx context.Context,
+	writer io.Writer,
+	workspace, format string,
+	includeStats bool,
+) error {
+	if format != models.TransferFormatNDJSON && format != models.TransferFormatCSV {
+		return ErrUnsupportedTransferFormat
+	}
+
+	workspaceDomains, err := service.db.ListDomains(ctx, workspace)
+	if err != nil {
+		return err
+	}
+
+	domains := []string{""}
+	for _, domain := range workspaceDomains {
+		domains = append(domains, domain.Hostname)
+	}
+
+	switch format {
+	case models.TransferFormatNDJSON:
+		encoder := json.NewEncoder(writer)
+		return service.db.ForEachURLMapping(ctx, domains, func(mapping models.URLMapping) error {
+			return encoder.Encode(newTransferRecord(mapping, includeStats))
+		})
+	case models.TransferFormatCSV:
+		csvWriter := csv.NewWriter(writer)
+
+		header := []string{"short_code", "url", "created_at"}
+		if includeStats {
+			header = append(header, "click_count", "last_clicked_at")
+		}
+		header = append(header, "domain")
+		if err := csvWriter.Write(header); err != nil {
+			return err
+		}
+
+		err = service.db.ForEachURLMapping(ctx, domains, func(mapping models.URLMapping) error {
+			row := []string{mapping.ShortCode, mapping.URL, mapping.CreatedAt.Format(time.RFC3339)}
+			if includeStats {
+				lastClickedAt := ""
+				if mapping.LastClickedAt != nil {
+					lastClickedAt = mapping.LastClickedAt.Format(time.RFC3339)
+				}
+				row = append(row, strconv.FormatInt(mapping.ClickCount, 10), lastClickedAt)
+			}
+			row = append(row, mapping.Domain)
+
+			return csvWriter.Write(row)
+		})
+		if err != nil {
+			return err
+		}
+
+		csvWriter.Flush()
+		return csvWriter.Error()
+	default:
+		return ErrUnsupportedTransferFormat
+	}
+}
+
+// Import upserts the links read from reader into a workspace, keeping their original short codes
+// and creation times. Links on custom domains are only imported onto verified domains of the
+// workspace, and destinations are checked like those of new short links. Existing short codes
+// pointing at a different URL are reported as conflicts and, depending on onConflict, skipped or
+// overwritten. In dry-run mode nothing is written and the report describes what the import would
+// do.
+func (service *TransferService) Import(
+	ctx context.Context,
+	reader io.Reader,
+	workspace, format, onConflict string,
+	dryRun bool,
+) (*models.ImportReport, error) {
+	if onConflict != models.ImportConflictSkip && onConflict != models.ImportConflictOverwrite {
+		return nil, ErrUnsupportedOnConflict
+	}
+
+	var next func() (*models.TransferRecord, int, error)
+	switch format {
+	case models.TransferFormatNDJSON:
+		next = ndjsonRecordReader(reader)
+	case models.TransferFormatCSV:
+		next = csvRecordReader(reader)
+	default:
+		return nil, ErrUnsupportedTransferFormat
+	}
+
+	report := &models.ImportReport{
+		DryRun:     dryRun,
+		OnConflict: onConflict,
+		Conflicts:  []models.ImportConflict{},
+		Errors:     []models.ImportError{},
+	}
+
+	domainErrs := make(map[string]error) // Authorization result of each custom domain seen so far
+	var batch []models.TransferRecord
+	var batchLines []int
+	for {
+		record, line, err := next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+
+		var parseErr *recordParseError
+		if errors.As(err, &parseErr) {
+			report.Total++
+			report.Errors = append(report.Errors, models.ImportError{Line: line, Error: parseErr.Error()})
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		report.Total++
+		if err = validateTransferRecord(record); err != nil {
+			report.Errors = append(report.Errors, models.ImportError{
+				Line:      line,
+				ShortCode: record.ShortCode,
+				Error:     err.Error(),
+			})
+			continue
+		}
+
+		if record.Domain, err = service.authorizeImportDomain(ctx, workspace, record.Domain, domainErrs); err != nil {
+			var serviceErr *Error
+			if !errors.As(err, &serviceErr) {
+				return nil, err
+			}
+
+			report.Errors = append(report.Errors, models.ImportError{
+				Line:      line,
+				ShortCode: record.ShortCode,
+				Error:     err.Error(),
+			})
+			continue
+		}
+
+		batch = append(batch, *record)
+		batchLines = append(batchLines, line)
+		if len(batch) == importBatchSize {
+			if err = service.importBatch(ctx, batch, batchLines, dryRun, report); err != nil {
+				return nil, err
+			}
+			batch, batchLines = batch[:0], batchLines[:0]
+		}
+	}
+
+	if len(batch) > 0 {
+		if err := service.importBatch(ctx, batch, batchLines, dryRun, report); err != nil {
+			return nil, err
+		}
+	}
+
+	return report, nil
+}
+
+// authorizeImportDomain returns the normalized custom domain of an imported record, checking that
+// it is a verified domain of the workspace. Results are remembered in domainErrs for the rest of
+// the import.
+func (service *TransferService) authorizeImportDomain(
+	ctx context.Context,
+	workspace, domain string,
+	domainErrs map[string]error,
+) (string, error) {
+	if domain == "" {
+		return "", nil
+	}
+
+	domain = normalizeHostname(domain)
+	err, checked := domainErrs[domain]
+	if !checked {
+		_, err = service.domainService.AuthorizeDomain(ctx, workspace, domain, true)
+
+		// Only keep the outcome for the domain, database failures abort the import
+		var serviceErr *Error
+		if err != nil && !errors.As(err, &serviceErr) {
+			return "", err
+		}
+		domainErrs[domain] = err
+	}
+
+	return domain, err
+}
+
+// importBatch rejects the records of a batch whose destination fails the destination checks,
+// classifies the others against the stored links and upserts them unless dryRun is set
+func (service *TransferService) importBatch(
+	ctx context.Context,
+	records []models.TransferRecord,
+	lines []int,
+	dryRun bool,
+	report *models.ImportReport,
+) error {
+	urls := make([]string, len(records))
+	for i, record := range records {
+		urls[i] = record.URL
+	}
+
+	checkErrs := service.urlService.checkDestinations(ctx, urls)
+	accepted, acceptedLines := records[:0], lines[:0]
+	for i, record := range records {
+		err := checkErrs[i]
+		var domainErr *Error
+		if errors.As(err, &domainErr) {
+			report.Errors = append(report.Errors, models.ImportError{
+				Line:      lines[i],
+				ShortCode: record.ShortCode,
+				Error:     domainErr.Message,
+			})
+			continue
+		}
+		if err != nil {
+			return err
+		}
+
+		accepted = append(accepted, record)
+		acceptedLines = append(acceptedLines, lines[i])
+	}
+	records, lines = accepted, acceptedLines
+
+	shortCodes := make(map[string][]string)
+	for _, record := range records {
+		shortCodes[record.Domain] = append(shortCodes[record.Domain], record.ShortCode)
+	}
+
+	existing := make(map[linkKey]models.URLMapping, len(records))
+	for domain, domainShortCodes := range shortCodes {
+		mappings, err := service.db.GetURLMappingsByShortCodes(ctx, domain, domainShortCodes)
+		if err != nil {
+			return err
+		}
+
+		for shortCode, mapping := range mappings {
+			existing[linkKey{domain: domain, shortCode: shortCode}] = mapping
+		}
+	}
+
+	plan := planImport(records, lines, existing, report)
+	if dryRun || len(plan.mappings) == 0 {
+		return nil
+	}
+
+	if err := service.db.UpsertURLMappings(ctx, plan.mappings); err != nil {
+		return err
+	}
+
+	service.auditService.record(ctx, plan.audits...)
+	service.webhookService.Publish(ctx, models.LinkEventCreated, plan.created...)
+	service.webhookService.Publish(ctx, models.LinkEventUpdated, plan.updated...)
+	return nil
+}
+
+// importPlan holds the writes of an import batch with the audit records and webhook events
+// they cause
+type importPlan struct {
+	mappings []models.URLMapping
+	audits   []auditRecord
+	created  []models.URLMapping
+	updated  []models.URLMapping
+}
+
+// planImport classifies the records of a batch against the stored links in existing, counting
+// them and reporting conflicts in report, and returns the mappings to upsert
+func planImport(
+	records []models.TransferRecord,
+	lines []int,
+	existing map[linkKey]models.URLMapping,
+	report *models.ImportReport,
+) importPlan {
+	plan := importPlan{
+		mappings: make([]models.URLMapping, 0, len(records)),
+		audits:   make([]auditRecord, 0, len(records)),
+	}
+	for i, record := range records {
+		key := linkKey{domain: record.Domain, shortCode: record.ShortCode}
+		current, exists := existing[key]
+
+		// Records without a creation time keep the stored one, or start now
+		if record.CreatedAt.IsZero() {
+			record.CreatedAt = time.Now()
+			if exists {
+				record.CreatedAt = current.CreatedAt
+			}
+		}
+
+		mapping := models.URLMapping{
+			ShortCode:     record.ShortCode,
+			Domain:        record.Domain,
+			URL:           record.URL,
+			CreatedAt:     record.CreatedAt,
+			LastClickedAt: record.LastClickedAt,
+		}
+		if record.ClickCount != nil {
+			mapping.ClickCount = *record.ClickCount
+		}
+
+		switch {
+		case !exists:
+			report.Created++
+		case current.URL != record.URL:
+			overwrite := report.OnConflict == models.ImportConflictOverwrite
+			report.Conflicts = append(report.Conflicts, models.ImportConflict{
+				Line:        lines[i],
+				ShortCode:   record.ShortCode,
+				Domain:      record.Domain,
+				ExistingURL: current.URL,
+				ImportedURL: record.URL,
+				Overwritten: overwrite,
+			})
+			if !overwrite {
+				report.Skipped++
+				continue
+			}
+			report.Updated++
+		case current.CreatedAt.Equal(record.CreatedAt) && record.ClickCount == nil:
+			report.Unchanged++
+			continue
+		default:
+			report.Updated++
+		}
+
+		// Keep the stored click stats when the import does not carry any
+		if exists && record.ClickCount == nil {
+			mapping.ClickCount = current.ClickCount
+			mapping.LastClickedAt = current.LastClickedAt
+		}
+
+		// Upserts only overwrite the imported fields of existing links
+		stored := mapping
+		stored.ID = bson.NewObjectID()
+		audit := auditRecord{
+			action: models.AuditActionLinkCreate,
+			target: linkTarget(record.Domain, record.ShortCode),
+		}
+		if exists {
+			stored = current
+			stored.URL = mapping.URL
+			stored.CreatedAt = mapping.CreatedAt
+			stored.ClickCount = mapping.ClickCount
+			if mapping.LastClickedAt != nil {
+				stored.LastClickedAt = mapping.LastClickedAt
+			}
+
+			audit.action = models.AuditActionLinkUpdate
+			audit.before = current
+			plan.updated = append(plan.updated, stored)
+		} else {
+			plan.created = append(plan.created, stored)
+		}
+		audit.after = stored
+		plan.audits = append(plan.audits, audit)
+		mapping.ID = stored.ID
+
+		// The same short code may appear again later in the batch; the last record wins
+		existing[key] = stored
+		plan.mappings = append(plan.mappings, mapping)
+	}
+
+	return plan
+}
+
+// newTransferRecord converts a stored mapping into its export representation
+func newTransferRecord(mapping models.URLMapping, includeStats bool) models.TransferRecord {
+	record := models.TransferRecord{
+		ShortCode: mapping.ShortCode,
+		Domain:    mapping.Domain,
+		URL:       mapping.URL,
+		CreatedAt: mapping.CreatedAt,
+	}
+
+	if includeStats {
+		record.ClickCount = &mapping.ClickCount
+		record.LastClickedAt = mapping.LastClickedAt
+	}
+
+	return record
+}
+
+func validateTransferRecord(record *models.TransferRecord) error {
+	if !shortCodePattern.MatchString(record.ShortCode) {
+		return errors.New("short code must be 1 to 64 letters, digits, hyphens or underscores")
+	}
+
+	if err := utils.ValidateStruct(models.ShortenURLRequest{URL: record.URL}); err != nil {
+		return errors.New("invalid URL")
+	}
+
+	if record.Domain != "" {
+		if err := utils.ValidateStruct(models.CreateDomainRequest{Hostname: record.Domain}); err != nil {
+			return errors.New("domain must be a fully qualified host name")
+		}
+	}
+
+	if !strings.HasPrefix(record.URL, "http://") && !strings.HasPrefix(record.URL, "https://") {
+		return errors.New("URL must start with http:// or https://")
+	}
+
+	return nil
+}
+
+// recordParseError marks a single malformed import record that can be skipped
+type recordParseError struct {
+	err error
+}
+
+func (err *recordParseError) Error() string {
+	return err.err.Error()
+}
+
+// ndjsonRecordReader returns a function yielding one record per non-empty line
+func ndjsonRecordReader(reader io.Reader) func() (*models.TransferRecord, int, error) {
+	scanner := bufio.NewScanner(reader)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	line := 0
+
+	return func() (*models.TransferRecord, int, error) {
+		for scanner.Scan() {
+			line++
+
+			data := strings.TrimSpace(scanner.Text())
+			if data == "" {
+				continue
+			}
+
+			var record models.TransferRecord
+			if err := json.Unmarshal([]byte(data), &record); err != nil {
+				return nil, line, &recordParseError{err: fmt.Errorf("invalid JSON: %w", err)}
+			}
+
+			return &record, line, nil
+		}
+
+		if err := scanner.Err(); err != nil {
+			return nil, line, invalidImportDataError("Failed reading line %d: %v", line+1, err)
+		}
+
+		return nil, line, io.EOF
+	}
+}
+
+// csvRecordReader returns a function yielding one record per CSV row. The first row must be a
+// header naming the columns, using the same names as the CSV export.
+func csvRecordReader(reader io.Reader) func() (*models.TransferRecord, int, error) {
+	csvReader := csv.NewReader(reader)
+	csvReader.FieldsPerRecord = -1
+	csvReader.TrimLeadingSpace = true
+
+	var columns map[string]int
+
+	return func() (*models.TransferRecord, int, error) {
+		if columns == nil {
+			header, err := csvReader.Read()
+			if errors.Is(err, io.EOF) {
+				return nil, 1, err
+			}
+			if err != nil {
+				return nil, 1, invalidImportDataError("Invalid CSV header: %v", err)
+			}
+
+			columns = make(map[string]int, len(header))
+			for i, name := range header {
+				columns[strings.ToLower(strings.TrimSpace(name))] = i
+			}
+
+			for _, required := range []string{"short_code", "url"} {
+				if _, ok := columns[required]; !ok {
+					return nil, 1, invalidImportDataError("Missing %q column in CSV header", required)
+				}
+			}
+		}
+
+		row, err := csvReader.Read()
+		if err != nil {
+			var csvErr *csv.ParseError
+			if errors.As(err, &csvErr) {
+				return nil, csvErr.Line, &recordParseError{err: err}
+			}
+			return nil, 0, err
+		}
+		line, _ := csvReader.FieldPos(0)
+
+		field := func(name string) string {
+			index, ok := columns[name]
+			if !ok || index >= len(row) {
+				return ""
+			}
+			return strings.TrimSpace(row[index])
+		}
+
+		record := &models.TransferRecord{
+			ShortCode: field("short_code"),
+			Domain:    field("domain"),
+			URL:       field("url"),
+		}
+
+		if value := field("created_at"); value != "" {
+			if record.CreatedAt, err = time.Parse(time.RFC3339, value); err != nil {
+				return nil, line, &recordParseError{err: errors.New("created_at must be an RFC 3339 timestamp")}
+			}
+		}
+
+		if value := field("click_count"); value != "" {
+			clickCount, err := strconv.ParseInt(value, 10, 64)
+			if err != nil || clickCount < 0 {
+				return nil, line, &recordParseError{err: errors.New("click_count must be a non-negative integer")}
+			}
+			record.ClickCount = &clickCount
+		}
+
+		if value := field("last_clicked_at"); value != "" {
+			lastClickedAt, err := time.Parse(time.RFC3339, value)
+			if err != nil {
+				return nil, line, &recordParseError{err: errors.New("last_clicked_at must be an RFC 3339 timestamp")}
+			}
+			record.LastClickedAt = &lastClickedAt
+		}
+
+		return record, line, nil
+	}
+}
@@ -0,0 +1,160 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"github.com/aarondever/linko/internal/models"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPlanImport(t *testing.T) {
+	createdAt := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	clicks := int64(42)
+	stored := models.URLMapping{
+		ShortCode:  "abc123",
+		URL:        "https://old.example",
+		CreatedAt:  createdAt,
+		ClickCount: 7,
+	}
+
+	tests := []struct {
+		name       string
+		onConflict string
+		record     models.TransferRecord
+		created    int
+		updated    int
+		unchanged  int
+		skipped    int
+		conflict   bool
+		written    string // URL of the written mapping, empty when nothing is written
+		clickCount int64
+	}{
+		{
+			name:       "new short code is created",
+			onConflict: models.ImportConflictSkip,
+			record:     models.TransferRecord{ShortCode: "new123", URL: "https://new.example", CreatedAt: createdAt},
+			created:    1,
+			written:    "https://new.example",
+		},
+		{
+			name:       "different URL is skipped",
+			onConflict: models.ImportConflictSkip,
+			record:     models.TransferRecord{ShortCode: "abc123", URL: "https://new.example", CreatedAt: createdAt},
+			skipped:    1,
+			conflict:   true,
+		},
+		{
+			name:       "different URL is overwritten",
+			onConflict: models.ImportConflictOverwrite,
+			record:     models.TransferRecord{ShortCode: "abc123", URL: "https://new.example", CreatedAt: createdAt},
+			updated:    1,
+			conflict:   true,
+			written:    "https://new.example",
+			clickCount: 7,
+		},
+		{
+			name:       "identical record is unchanged",
+			onConflict: models.ImportConflictOverwrite,
+			record:     models.TransferRecord{ShortCode: "abc123", URL: "https://old.example", CreatedAt: createdAt},
+			unchanged:  1,
+		},
+		{
+			name:       "record without a creation time is unchanged",
+			onConflict: models.ImportConflictSkip,
+			record:     models.TransferRecord{ShortCode: "abc123", URL: "https://old.example"},
+			unchanged:  1,
+		},
+		{
+			name:       "same URL with click stats is updated without a conflict",
+			onConflict: models.ImportConflictSkip,
+			record:     models.TransferRecord{ShortCode: "abc123", URL: "https://old.example", CreatedAt: createdAt, ClickCount: &clicks},
+			updated:    1,
+			written:    "https://old.example",
+			clickCount: 42,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			existing := map[linkKey]models.URLMapping{{shortCode: stored.ShortCode}: stored}
+			report := &models.ImportReport{OnConflict: test.onConflict}
+
+			plan := planImport([]models.TransferRecord{test.record}, []int{3}, existing, report)
+
+			if report.Created != test.created || report.Updated != test.updated ||
+				report.Unchanged != test.unchanged || report.Skipped != test.skipped {
+				t.Errorf("created, updated, unchanged, skipped = %d, %d, %d, %d, want %d, %d, %d, %d",
+					report.Created, report.Updated, report.Unchanged, report.Skipped,
+					test.created, test.updated, test.unchanged, test.skipped)
+			}
+
+			if !test.conflict {
+				if len(report.Conflicts) != 0 {
+					t.Errorf("conflicts = %+v, want none", report.Conflicts)
+				}
+			} else if len(report.Conflicts) != 1 {
+				t.Errorf("conflicts = %+v, want one", report.Conflicts)
+			} else {
+				conflict := report.Conflicts[0]
+				overwrite := test.onConflict == models.ImportConflictOverwrite
+				if conflict.Line != 3 || conflict.ExistingURL != stored.URL ||
+					conflict.ImportedURL != test.record.URL || conflict.Overwritten != overwrite {
+					t.Errorf("conflict = %+v", conflict)
+				}
+			}
+
+			if test.written == "" {
+				if len(plan.mappings) != 0 {
+					t.Errorf("mappings = %+v, want none", plan.mappings)
+				}
+				return
+			}
+			if len(plan.mappings) != 1 || len(plan.audits) != 1 {
+				t.Fatalf("got %d mappings and %d audit records, want one each", len(plan.mappings), len(plan.audits))
+			}
+
+			mapping := plan.mappings[0]
+			if mapping.URL != test.written || mapping.ClickCount != test.clickCount || !mapping.CreatedAt.Equal(createdAt) {
+				t.Errorf("mapping = %+v, want URL %s with %d clicks created at %v",
+					mapping, test.written, test.clickCount, createdAt)
+			}
+			if test.created == 1 && len(plan.created) != 1 || test.updated == 1 && len(plan.updated) != 1 {
+				t.Errorf("created events = %d, updated events = %d", len(plan.created), len(plan.updated))
+			}
+		})
+	}
+}
+
+func TestPlanImportLastRecordWins(t *testing.T) {
+	records := []models.TransferRecord{
+		{ShortCode: "abc123", URL: "https://first.example"},
+		{ShortCode: "abc123", URL: "https://second.example"},
+	}
+	report := &models.ImportReport{OnConflict: models.ImportConflictSkip}
+
+	plan := planImport(records, []int{1, 2}, map[linkKey]models.URLMapping{}, report)
+
+	// The second record conflicts with the first one of the same batch
+	if report.Created != 1 || report.Skipped != 1 || len(report.Conflicts) != 1 {
+		t.Fatalf("report = %+v, want one created and one skipped conflict", report)
+	}
+	if conflict := report.Conflicts[0]; conflict.Line != 2 || conflict.ExistingURL != "https://first.example" {
+		t.Errorf("conflict = %+v, want line 2 against the first record", conflict)
+	}
+	if len(plan.mappings) != 1 || plan.mappings[0].URL != "https://first.example" {
+		t.Errorf("mappings = %+v, want only the first record", plan.mappings)
+	}
+}
+
+func TestImportRejectsUnsupportedOnConflict(t *testing.T) {
+	service := NewTransferService(nil, newTestConfig(t), nil, nil, nil, nil)
+
+	for _, onConflict := range []string{"", "replace", "Skip"} {
+		_, err := service.Import(context.Background(), strings.NewReader(""), "", models.TransferFormatNDJSON, onConflict, true)
+		if !errors.Is(err, ErrUnsupportedOnConflict) {
+			t.Errorf("on_conflict %q: error = %v, want %v", onConflict, err, ErrUnsupportedOnConflict)
+		}
+	}
+}
@@ -2,32 +2,82 @@ package services
 
 import (
 	"context"
+	"errors"
 	"github.com/aarondever/linko/internal/config"
 	"github.com/aarondever/linko/internal/database"
 	"github.com/aarondever/linko/internal/models"
 	"github.com/google/uuid"
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
 )
 
+// maxBulkInsertAttempts bounds how often a bulk item is retried after a short code collision
+const maxBulkInsertAttempts = 5
+
+// maxDestinationChecks bounds the destinations of a bulk request checked concurrently
+const maxDestinationChecks = 16
+
+// duplicateKeyErrorCode is the MongoDB server error code for unique index violations
+const duplicateKeyErrorCode = 11000
+
 type URLService struct {
-	db  *database.Database
-	cfg *config.Config
+	db                   *database.Database
+	cfg                  *config.Config
+	destinationValidator *DestinationValidator
+	screeningService     *ScreeningService
+	metadataService      *MetadataService
+	domainService        *DomainService
+	auditService         *AuditService
+	webhookService       *WebhookService
 }
 
-func NewURLService(db *database.Database, cfg *config.Config) *URLService {
+func NewURLService(
+	db *database.Database,
+	cfg *config.Config,
+	destinationValidator *DestinationValidator,
+	screeningService *ScreeningService,
+	metadataService *MetadataService,
+	domainService *DomainService,
+	auditService *AuditService,
+	webhookService *WebhookService,
+) *URLService {
 	return &URLService{
-		db:  db,
-		cfg: cfg,
+		db:                   db,
+		cfg:                  cfg,
+		destinationValidator: destinationValidator,
+		screeningService:     screeningService,
+		metadataService:      metadataService,
+		domainService:        domainService,
+		auditService:         auditService,
+		webhookService:       webhookService,
 	}
 }
 
-func (service *URLService) ShortenURL(ctx context.Context, url string) (string, error) {
-	// Generate UUID and take first 8 characters
-	uuidStr := uuid.New().String()
-	shortCode := uuidStr[:8]
+// ShortenURL creates a short link to url served from a custom domain, or from the default host
+// when domain is empty
+func (service *URLService) ShortenURL(ctx context.Context, domain, url string) (_ string, err error) {
+	ctx, span := tracer.Start(ctx, "URLService.ShortenURL",
+		trace.WithAttributes(attribute.String("linko.domain", domain)))
+	defer func() { endSpan(span, err) }()
+
+	if err := service.checkServingDomain(ctx, domain); err != nil {
+		return "", err
+	}
+
+	if err := service.checkDestination(ctx, url); err != nil {
+		return "", err
+	}
+
+	shortCode := generateShortCode()
 
 	// Check if short code already exists (handle collision)
 	for {
-		exists, err := service.db.IsURLShortCodeExists(ctx, shortCode)
+		exists, err := service.db.IsURLShortCodeExists(ctx, domain, shortCode)
 		if err != nil {
 			return "", err
 		}
@@ -38,28 +88,403 @@ func (service *URLService) ShortenURL(ctx context.Context, url string) (string,
 		}
 
 		// Collision detected, generate new short code
-		uuidStr = uuid.New().String()
-		shortCode = uuidStr[:8]
+		shortCode = generateShortCode()
 	}
 
 	urlMapping := models.URLMapping{
 		ShortCode: shortCode,
 		URL:       url,
+		Domain:    domain,
 	}
 
-	_, err := service.db.CreateURLShortCode(ctx, urlMapping)
+	created, err := service.db.CreateURLShortCode(ctx, urlMapping)
 	if err != nil {
 		return "", err
 	}
 
+	service.metadataService.Enqueue(domain, shortCode, url)
+	service.auditService.record(ctx, auditRecord{
+		action: models.AuditActionLinkCreate,
+		target: linkTarget(domain, shortCode),
+		after:  created,
+	})
+	service.webhookService.Publish(ctx, models.LinkEventCreated, *created)
+
 	return shortCode, nil
 }
 
-func (service *URLService) GetURL(ctx context.Context, shortCode string) (string, error) {
-	url, err := service.db.GetURL(ctx, shortCode)
+// ShortenURLs shortens a batch of URLs on a domain, returning one result per input URL in the same order.
+// Items that collide on their generated short code are retried with a fresh code; any other
+// per-item failure, including rejected destinations, is reported in its result without
+// failing the rest of the batch.
+func (service *URLService) ShortenURLs(ctx context.Context, domain string, urls []string) (_ []models.BulkShortenResult, err error) {
+	ctx, span := tracer.Start(ctx, "URLService.ShortenURLs",
+		trace.WithAttributes(attribute.String("linko.domain", domain), attribute.Int("linko.urls", len(urls))))
+	defer func() { endSpan(span, err) }()
+
+	if err := service.checkServingDomain(ctx, domain); err != nil {
+		return nil, err
+	}
+
+	results := make([]models.BulkShortenResult, len(urls))
+	checkErrs := service.checkDestinations(ctx, urls)
+
+	// Indexes into results that still need to be inserted
+	pending := make([]int, 0, len(urls))
+	var audits []auditRecord
+	var created []models.URLMapping
+	for i, url := range urls {
+		results[i] = models.BulkShortenResult{Index: i, URL: url}
+
+		err := checkErrs[i]
+		var domainErr *Error
+		if errors.As(err, &domainErr) {
+			results[i].Error = domainErr.Message
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		pending = append(pending, i)
+	}
+
+	for attempt := 1; len(pending) > 0; attempt++ {
+		usedCodes := make(map[string]bool, len(pending))
+		mappings := make([]models.URLMapping, len(pending))
+		for i, resultIndex := range pending {
+			shortCode := generateShortCode()
+			for usedCodes[shortCode] {
+				shortCode = generateShortCode()
+			}
+			usedCodes[shortCode] = true
+
+			results[resultIndex].ShortCode = shortCode
+			mappings[i] = models.URLMapping{
+				ShortCode: shortCode,
+				URL:       results[resultIndex].URL,
+				Domain:    domain,
+			}
+		}
+
+		writeErrors, err := service.db.CreateURLMappings(ctx, mappings)
+		if err != nil {
+			return nil, err
+		}
+
+		var retry []int
+		for i, resultIndex := range pending {
+			writeErr, failed := writeErrors[i]
+			if !failed {
+				service.metadataService.Enqueue(domain, mappings[i].ShortCode, mappings[i].URL)
+				audits = append(audits, auditRecord{
+					action: models.AuditActionLinkCreate,
+					target: linkTarget(domain, mappings[i].ShortCode),
+					after:  mappings[i],
+				})
+				created = append(created, mappings[i])
+				continue
+			}
+
+			results[resultIndex].ShortCode = ""
+			if writeErr.Code == duplicateKeyErrorCode && attempt < maxBulkInsertAttempts {
+				retry = append(retry, resultIndex)
+				continue
+			}
+
+			results[resultIndex].Error = "Failed to store URL"
+		}
+
+		pending = retry
+	}
+
+	service.auditService.record(ctx, audits...)
+	service.webhookService.Publish(ctx, models.LinkEventCreated, created...)
+
+	return results, nil
+}
+
+// GetURL returns the URL a short code on a domain points at, or ErrURLNotFound
+func (service *URLService) GetURL(ctx context.Context, domain, shortCode string) (string, error) {
+	url, err := service.db.GetURL(ctx, domain, shortCode)
 	if err != nil {
 		return "", err
 	}
 
+	if url == "" {
+		return "", ErrURLNotFound
+	}
+
 	return url, nil
 }
+
+// GetURLMapping returns the mapping of a short code on a domain, or ErrURLNotFound
+func (service *URLService) GetURLMapping(ctx context.Context, domain, shortCode string) (_ *models.URLMapping, err error) {
+	ctx, span := tracer.Start(ctx, "URLService.GetURLMapping", linkAttributes(domain, shortCode))
+	defer func() { endSpan(span, err) }()
+
+	mapping, err := service.db.GetURLMappingByShortCode(ctx, domain, shortCode)
+	if err != nil {
+		return nil, err
+	}
+
+	if mapping == nil {
+		return nil, ErrURLNotFound
+	}
+
+	return mapping, nil
+}
+
+// ListURLMappings returns a page of mappings matching filter from newest to oldest
+func (service *URLService) ListURLMappings(
+	ctx context.Context,
+	filter models.URLFilter,
+	offset, limit int64,
+) ([]models.URLMapping, error) {
+	return service.db.ListURLMappings(ctx, filter, offset, limit)
+}
+
+// UpdateURL applies the given changes to a short link on a domain, or returns ErrURLNotFound
+func (service *URLService) UpdateURL(
+	ctx context.Context,
+	domain, shortCode string,
+	params models.UpdateURLRequest,
+) (_ *models.URLMapping, err error) {
+	ctx, span := tracer.Start(ctx, "URLService.UpdateURL", linkAttributes(domain, shortCode))
+	defer func() { endSpan(span, err) }()
+
+	set := bson.M{}
+	unset := bson.M{}
+	if params.URL != nil {
+		if err := service.checkDestination(ctx, *params.URL); err != nil {
+			return nil, err
+		}
+		set["url"] = *params.URL
+
+		// The new destination is checked and described from scratch
+		unset["health"] = ""
+		unset["next_health_check_at"] = ""
+		unset["metadata"] = ""
+	}
+	if params.Disabled != nil {
+		if *params.Disabled {
+			set["disabled_at"] = time.Now()
+			set["disabled_reason"] = "Disabled manually"
+		} else {
+			unset["disabled_at"] = ""
+			unset["disabled_reason"] = ""
+		}
+	}
+
+	if params.SocialPreview != nil {
+		if *params.SocialPreview == (models.SocialPreview{}) {
+			unset["social_preview"] = ""
+		} else {
+			set["social_preview"] = *params.SocialPreview
+		}
+	}
+
+	update := bson.M{}
+	if len(set) > 0 {
+		update["$set"] = set
+	}
+	if len(unset) > 0 {
+		update["$unset"] = unset
+	}
+
+	// The previous state is only needed for the audit log
+	previous, err := service.GetURLMapping(ctx, domain, shortCode)
+	if err != nil || len(update) == 0 {
+		return previous, err
+	}
+
+	mapping, err := service.db.UpdateURLMapping(ctx, domain, shortCode, update)
+	if err != nil {
+		return nil, err
+	}
+
+	if mapping == nil {
+		return nil, ErrURLNotFound
+	}
+
+	service.auditService.record(ctx, auditRecord{
+		action: models.AuditActionLinkUpdate,
+		target: linkTarget(domain, shortCode),
+		before: previous,
+		after:  mapping,
+	})
+	service.webhookService.Publish(ctx, models.LinkEventUpdated, *mapping)
+
+	if params.URL != nil {
+		service.metadataService.Enqueue(mapping.Domain, mapping.ShortCode, mapping.URL)
+	}
+
+	return mapping, nil
+}
+
+// DeleteURL deletes a short code on a domain, or returns ErrURLNotFound
+func (service *URLService) DeleteURL(ctx context.Context, domain, shortCode string) (err error) {
+	ctx, span := tracer.Start(ctx, "URLService.DeleteURL", linkAttributes(domain, shortCode))
+	defer func() { endSpan(span, err) }()
+
+	deleted, err := service.db.DeleteURLMapping(ctx, domain, shortCode)
+	if err != nil {
+		return err
+	}
+
+	if deleted == nil {
+		return ErrURLNotFound
+	}
+
+	service.auditService.record(ctx, auditRecord{
+		action: models.AuditActionLinkDelete,
+		target: linkTarget(domain, shortCode),
+		before: deleted,
+	})
+	service.webhookService.Publish(ctx, models.LinkEventDeleted, *deleted)
+
+	return nil
+}
+
+// ResolveShortCode returns the URL a short code on a domain redirects to and records the click,
+// or returns ErrURLNotFound, ErrURLDisabled or ErrURLFlagged
+func (service *URLService) ResolveShortCode(ctx context.Context, domain, shortCode string) (_ string, err error) {
+	ctx, span := tracer.Start(ctx, "URLService.ResolveShortCode", linkAttributes(domain, shortCode))
+	defer func() { endSpan(span, err) }()
+
+	mapping, err := service.db.RecordURLClick(ctx, domain, shortCode)
+	if err != nil {
+		return "", err
+	}
+
+	if mapping == nil {
+		// Tell disabled links apart from unknown ones
+		mapping, err = service.GetURLMapping(ctx, domain, shortCode)
+		if err != nil {
+			return "", err
+		}
+		return "", disabledError(mapping)
+	}
+
+	service.webhookService.Publish(ctx, models.LinkEventClicked, *mapping)
+
+	return mapping.URL, nil
+}
+
+// checkServingDomain returns ErrDomainNotVerified unless domain is empty or a verified custom domain
+func (service *URLService) checkServingDomain(ctx context.Context, domain string) error {
+	if domain == "" {
+		return nil
+	}
+
+	resolved, err := service.domainService.ResolveHost(ctx, domain)
+	if err != nil {
+		return err
+	}
+	if resolved != domain {
+		return ErrDomainNotVerified
+	}
+
+	return nil
+}
+
+// checkDestination rejects destinations violating the destination policy, pointing at a custom
+// domain, or flagged by screening
+func (service *URLService) checkDestination(ctx context.Context, rawURL string) (err error) {
+	ctx, span := tracer.Start(ctx, "URLService.checkDestination")
+	defer func() { endSpan(span, err) }()
+
+	if err := service.destinationValidator.Validate(ctx, rawURL); err != nil {
+		return err
+	}
+
+	// Custom domains serve short links, so pointing at one could loop like a self host
+	parsed, err := url.Parse(rawURL)
+	if err != nil {
+		return invalidDestinationError("Invalid URL")
+	}
+	domain, err := service.domainService.ResolveHost(ctx, parsed.Host)
+	if err != nil {
+		return err
+	}
+	if domain != "" {
+		return invalidDestinationError("Destination must not point at a short link domain")
+	}
+
+	return service.screeningService.Screen(ctx, rawURL)
+}
+
+// checkDestinations checks a batch of destinations concurrently, since each check may need a
+// DNS lookup, and returns the error of each destination by index
+func (service *URLService) checkDestinations(ctx context.Context, urls []string) []error {
+	errs := make([]error, len(urls))
+	semaphore := make(chan struct{}, maxDestinationChecks)
+
+	var wg sync.WaitGroup
+	for i, url := range urls {
+		semaphore <- struct{}{}
+		wg.Go(func() {
+			defer func() { <-semaphore }()
+			errs[i] = service.checkDestination(ctx, url)
+		})
+	}
+	wg.Wait()
+
+	return errs
+}
+
+// GetPreviewMapping returns the mapping of a short code on a domain for a crawler without
+// recording a click, or returns ErrURLNotFound, ErrURLDisabled or ErrURLFlagged
+func (service *URLService) GetPreviewMapping(ctx context.Context, domain, shortCode string) (_ *models.URLMapping, err error) {
+	ctx, span := tracer.Start(ctx, "URLService.GetPreviewMapping", linkAttributes(domain, shortCode))
+	defer func() { endSpan(span, err) }()
+
+	mapping, err := service.GetURLMapping(ctx, domain, shortCode)
+	if err != nil {
+		return nil, err
+	}
+
+	if mapping.DisabledAt != nil {
+		return nil, disabledError(mapping)
+	}
+
+	return mapping, nil
+}
+
+// disabledError returns ErrURLFlagged for links disabled by screening and ErrURLDisabled otherwise
+func disabledError(mapping *models.URLMapping) error {
+	if strings.HasPrefix(mapping.DisabledReason, flaggedReasonPrefix) {
+		return ErrURLFlagged
+	}
+
+	return ErrURLDisabled
+}
+
+// ShortURL builds the public short URL of a link. Links on the default host are served from
+// the configured base URL, or from origin (scheme and host of the request) when none is
+// configured; links on a custom domain are served from that domain. It returns an empty string
+// when neither a base URL nor an origin is known.
+func (service *URLService) ShortURL(origin, domain, shortCode string) string {
+	if service.cfg.Server.BaseURL != "" {
+		origin = service.cfg.Server.BaseURL
+	}
+
+	if domain != "" {
+		scheme := "https"
+		if parsed, err := url.Parse(origin); err == nil && parsed.Scheme != "" {
+			scheme = parsed.Scheme
+		}
+		origin = scheme + "://" + domain
+	}
+
+	if origin == "" {
+		return ""
+	}
+
+	return strings.TrimSuffix(origin, "/") + service.cfg.Server.ShortLinkPrefix() + "/" + url.PathEscape(shortCode)
+}
+
+// generateShortCode takes the first 8 characters of a random UUID
+func generateShortCode() string {
+	return uuid.New().String()[:8]
+}
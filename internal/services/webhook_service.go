@@ -0,0 +1,515 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"github.com/aarondever/linko/internal/config"
+	"github.com/aarondever/linko/internal/database"
+	"github.com/aarondever/linko/internal/models"
+	"github.com/google/uuid"
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"io"
+	"log/slog"
+	"net/http"
+	"slices"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// webhookSecretPrefix marks webhook signing secrets so they are easy to recognize
+const webhookSecretPrefix = "whsec_"
+
+// webhookBodyLimit bounds how much of a response body is read before closing it
+const webhookBodyLimit = 64 * 1024
+
+const webhookUserAgent = "linko-webhook/1.0"
+
+// Headers of a webhook delivery
+const (
+	WebhookEventHeader     = "X-Linko-Event"
+	WebhookDeliveryHeader  = "X-Linko-Delivery"
+	WebhookSignatureHeader = "X-Linko-Signature"
+)
+
+var (
+	ErrWebhookNotFound         = NewError(ErrorKindNotFound, "webhook_not_found", "Webhook not found")
+	ErrWebhookDeliveryNotFound = NewError(ErrorKindNotFound, "webhook_delivery_not_found", "Webhook delivery not found")
+)
+
+// WebhookService manages the webhooks of workspaces and delivers the link events they subscribed
+// to. Events of links on a custom domain go to the workspace of the domain, events of links on the
+// default host to the default workspace. Events are stored as deliveries when they occur and sent
+// by a background dispatcher, signed with the secret of the webhook and retried with exponential
+// backoff until they succeed or max_attempts is reached, after which they are kept as dead letters.
+type WebhookService struct {
+	db                   *database.Database
+	cfg                  *config.Config
+	client               *http.Client
+	destinationValidator *DestinationValidator
+	auditService         *AuditService
+
+	// Registered webhooks of all workspaces, refreshed by the dispatcher so publishing an event
+	// without subscribers costs no query
+	webhooks atomic.Pointer[[]models.Webhook]
+}
+
+func NewWebhookService(
+	db *database.Database,
+	cfg *config.Config,
+	destinationValidator *DestinationValidator,
+	auditService *AuditService,
+) *WebhookService {
+	return &WebhookService{
+		db:                   db,
+		cfg:                  cfg,
+		client:               destinationValidator.NewHTTPClient(cfg.Webhook.Timeout),
+		destinationValidator: destinationValidator,
+		auditService:         auditService,
+	}
+}
+
+// CreateWebhook registers a webhook for a workspace. The signing secret is only returned here.
+func (service *WebhookService) CreateWebhook(
+	ctx context.Context,
+	workspace string,
+	params models.CreateWebhookRequest,
+) (*models.CreateWebhookResponse, error) {
+	if err := service.destinationValidator.Validate(ctx, params.URL); err != nil {
+		return nil, err
+	}
+
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return nil, err
+	}
+
+	webhook, err := service.db.CreateWebhook(ctx, models.Webhook{
+		Workspace:   workspace,
+		URL:         params.URL,
+		Events:      slices.Compact(slices.Sorted(slices.Values(params.Events))),
+		Description: params.Description,
+		Secret:      webhookSecretPrefix + hex.EncodeToString(secret),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	service.refresh(ctx)
+	service.auditService.record(ctx, auditRecord{
+		action:    models.AuditActionWebhookCreate,
+		target:    webhookTarget(webhook),
+		workspace: workspace,
+		after:     webhook,
+	})
+
+	return &models.CreateWebhookResponse{
+		Webhook: *webhook,
+		Secret:  webhook.Secret,
+	}, nil
+}
+
+func (service *WebhookService) ListWebhooks(ctx context.Context, workspace string) ([]models.Webhook, error) {
+	return service.db.ListWebhooks(ctx, workspace)
+}
+
+// GetWebhook returns a webhook of a workspace, or ErrWebhookNotFound
+func (service *WebhookService) GetWebhook(ctx context.Context, workspace, id string) (*models.Webhook, error) {
+	objectID, err := bson.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, ErrWebhookNotFound
+	}
+
+	webhook, err := service.db.GetWebhook(ctx, workspace, objectID)
+	if err != nil {
+		return nil, err
+	}
+
+	if webhook == nil {
+		return nil, ErrWebhookNotFound
+	}
+
+	return webhook, nil
+}
+
+// DeleteWebhook deletes a webhook of a workspace and its delivery log, or returns ErrWebhookNotFound.
+// Deliveries in flight are not sent again.
+func (service *WebhookService) DeleteWebhook(ctx context.Context, workspace, id string) error {
+	objectID, err := bson.ObjectIDFromHex(id)
+	if err != nil {
+		return ErrWebhookNotFound
+	}
+
+	webhook, err := service.db.DeleteWebhook(ctx, workspace, objectID)
+	if err != nil {
+		return err
+	}
+
+	if webhook == nil {
+		return ErrWebhookNotFound
+	}
+
+	service.refresh(ctx)
+	service.auditService.record(ctx, auditRecord{
+		action:    models.AuditActionWebhookDelete,
+		target:    webhookTarget(webhook),
+		workspace: workspace,
+		before:    webhook,
+	})
+
+	return nil
+}
+
+// ListDeliveries returns a page of the deliveries of a webhook of a workspace from newest to
+// oldest, only those in the given status unless it is empty, or returns ErrWebhookNotFound
+func (service *WebhookService) ListDeliveries(
+	ctx context.Context,
+	workspace, id, status string,
+	offset, limit int64,
+) ([]models.WebhookDelivery, error) {
+	webhook, err := service.GetWebhook(ctx, workspace, id)
+	if err != nil {
+		return nil, err
+	}
+
+	return service.db.ListWebhookDeliveries(ctx, webhook.ID, status, offset, limit)
+}
+
+// Redeliver schedules sending a delivery of a webhook of a workspace again, with the same event
+// ID and payload, and returns the new delivery. It returns ErrWebhookNotFound or
+// ErrWebhookDeliveryNotFound if either does not exist.
+func (service *WebhookService) Redeliver(
+	ctx context.Context,
+	workspace, id, deliveryID string,
+) (*models.WebhookDelivery, error) {
+	webhook, err := service.GetWebhook(ctx, workspace, id)
+	if err != nil {
+		return nil, err
+	}
+
+	deliveryObjectID, err := bson.ObjectIDFromHex(deliveryID)
+	if err != nil {
+		return nil, ErrWebhookDeliveryNotFound
+	}
+
+	original, err := service.db.GetWebhookDelivery(ctx, webhook.ID, deliveryObjectID)
+	if err != nil {
+		return nil, err
+	}
+
+	if original == nil {
+		return nil, ErrWebhookDeliveryNotFound
+	}
+
+	now := time.Now()
+	deliveries := []models.WebhookDelivery{{
+		WebhookID:     webhook.ID,
+		EventID:       original.EventID,
+		Event:         original.Event,
+		Payload:       original.Payload,
+		Status:        models.WebhookDeliveryPending,
+		NextAttemptAt: &now,
+		RedeliveryOf:  &original.ID,
+		CreatedAt:     now,
+	}}
+	if err = service.db.InsertWebhookDeliveries(ctx, deliveries); err != nil {
+		return nil, err
+	}
+
+	return &deliveries[0], nil
+}
+
+// Publish schedules delivering an event about each link to the webhooks subscribed to it.
+// Failures are only logged, the change the event describes has already happened.
+func (service *WebhookService) Publish(ctx context.Context, event string, mappings ...models.URLMapping) {
+	if !service.cfg.Webhook.Enabled || len(mappings) == 0 {
+		return
+	}
+
+	subscribers := slices.DeleteFunc(slices.Clone(service.snapshot(ctx)), func(webhook models.Webhook) bool {
+		return !slices.Contains(webhook.Events, event)
+	})
+	if len(subscribers) == 0 {
+		return
+	}
+
+	workspaces := make(map[string]*string) // Workspace of each domain, nil for unregistered domains
+	now := time.Now()
+
+	var deliveries []models.WebhookDelivery
+	for _, mapping := range mappings {
+		workspace, ok := workspaces[mapping.Domain]
+		if !ok {
+			workspace = service.domainWorkspace(ctx, mapping.Domain)
+			workspaces[mapping.Domain] = workspace
+		}
+		if workspace == nil {
+			continue
+		}
+
+		var targets []models.Webhook
+		for _, webhook := range subscribers {
+			if webhook.Workspace == *workspace {
+				targets = append(targets, webhook)
+			}
+		}
+		if len(targets) == 0 {
+			continue
+		}
+
+		eventID := uuid.New().String()
+		payload, err := json.Marshal(models.LinkEvent{
+			ID:         eventID,
+			Event:      event,
+			OccurredAt: now,
+			Link:       mapping,
+		})
+		if err != nil {
+			slog.ErrorContext(ctx, "Failed encoding link event", "event", event, "error", err)
+			continue
+		}
+
+		for _, webhook := range targets {
+			deliveries = append(deliveries, models.WebhookDelivery{
+				WebhookID:     webhook.ID,
+				EventID:       eventID,
+				Event:         event,
+				Payload:       string(payload),
+				Status:        models.WebhookDeliveryPending,
+				NextAttemptAt: &now,
+				CreatedAt:     now,
+			})
+		}
+	}
+
+	if len(deliveries) == 0 {
+		return
+	}
+
+	if err := service.db.InsertWebhookDeliveries(ctx, deliveries); err != nil {
+		slog.ErrorContext(ctx, "Failed scheduling webhook deliveries", "event", event, "deliveries", len(deliveries), "error", err)
+	}
+}
+
+// domainWorkspace returns the workspace owning the links of a domain, or nil if no workspace has
+// verified the domain
+func (service *WebhookService) domainWorkspace(ctx context.Context, hostname string) *string {
+	if hostname == "" {
+		return new(string)
+	}
+
+	domain, err := service.db.GetVerifiedDomain(ctx, hostname)
+	if err != nil || domain == nil {
+		return nil
+	}
+
+	return &domain.Workspace
+}
+
+// snapshot returns the registered webhooks, loading them on first use
+func (service *WebhookService) snapshot(ctx context.Context) []models.Webhook {
+	if webhooks := service.webhooks.Load(); webhooks != nil {
+		return *webhooks
+	}
+
+	service.refresh(ctx)
+	if webhooks := service.webhooks.Load(); webhooks != nil {
+		return *webhooks
+	}
+
+	return nil
+}
+
+// refresh reloads the registered webhooks, keeping the previous ones if that fails
+func (service *WebhookService) refresh(ctx context.Context) {
+	webhooks, err := service.db.ListAllWebhooks(ctx)
+	if err != nil {
+		return
+	}
+
+	service.webhooks.Store(&webhooks)
+}
+
+// Run sends the deliveries that are due until ctx is cancelled
+func (service *WebhookService) Run(ctx context.Context) {
+	if !service.cfg.Webhook.Enabled {
+		return
+	}
+
+	ticker := time.NewTicker(service.cfg.Webhook.PollInterval)
+	defer ticker.Stop()
+
+	for {
+		service.refresh(ctx)
+		service.sendDue(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// sendDue claims and sends deliveries until none is due, with a bounded number of concurrent
+// deliveries
+func (service *WebhookService) sendDue(ctx context.Context) {
+	jobs := make(chan models.WebhookDelivery)
+
+	var wg sync.WaitGroup
+	for range service.cfg.Webhook.Concurrency {
+		wg.Go(func() {
+			for delivery := range jobs {
+				service.send(ctx, delivery)
+			}
+		})
+	}
+
+	// Claimed deliveries are hidden from other replicas until their attempt timed out
+	lease := service.cfg.Webhook.Timeout + time.Minute
+	for ctx.Err() == nil {
+		delivery, err := service.db.ClaimWebhookDelivery(ctx, lease)
+		if err != nil || delivery == nil {
+			break
+		}
+
+		jobs <- *delivery
+	}
+
+	close(jobs)
+	wg.Wait()
+}
+
+// send attempts a single delivery and records the outcome
+func (service *WebhookService) send(ctx context.Context, delivery models.WebhookDelivery) {
+	webhook := service.webhook(delivery.WebhookID)
+	if webhook == nil {
+		// The webhook may have been registered on another replica since the last refresh
+		service.refresh(ctx)
+		webhook = service.webhook(delivery.WebhookID)
+	}
+
+	start := time.Now()
+	attempt := models.WebhookAttempt{At: start}
+	if webhook == nil {
+		// Deleted since the event occurred, normally together with its deliveries
+		attempt.Error = "webhook no longer exists"
+	} else {
+		statusCode, err := service.post(ctx, *webhook, delivery)
+		if ctx.Err() != nil {
+			return // Shutting down, the lease expires and the delivery is sent again later
+		}
+
+		attempt.StatusCode = statusCode
+		switch {
+		case err != nil:
+			attempt.Error = err.Error()
+		case statusCode < http.StatusOK || statusCode >= http.StatusMultipleChoices:
+			attempt.Error = fmt.Sprintf("unexpected status %d", statusCode)
+		}
+	}
+	attempt.DurationMS = time.Since(start).Milliseconds()
+
+	attempts := delivery.Attempts + 1
+	status, nextAttemptAt := service.nextDeliveryState(attempt, attempts, webhook != nil)
+	if status == models.WebhookDeliveryDead {
+		slog.WarnContext(ctx, "Webhook delivery failed permanently",
+			"delivery_id", delivery.ID.Hex(), "event", delivery.Event, "attempts", attempts, "error", attempt.Error)
+	}
+
+	service.db.RecordWebhookAttempt(ctx, delivery.ID, attempt, status, nextAttemptAt)
+}
+
+// nextDeliveryState returns the status of a delivery after its given number of attempts ended
+// with attempt, and when a pending delivery is attempted next
+func (service *WebhookService) nextDeliveryState(
+	attempt models.WebhookAttempt,
+	attempts int,
+	webhookExists bool,
+) (string, time.Time) {
+	switch {
+	case attempt.Error == "":
+		return models.WebhookDeliverySucceeded, time.Time{}
+	case !webhookExists || attempts >= service.cfg.Webhook.MaxAttempts:
+		return models.WebhookDeliveryDead, time.Time{}
+	default:
+		return models.WebhookDeliveryPending, attempt.At.Add(service.backoff(attempts))
+	}
+}
+
+// webhook returns the registered webhook with the given ID, or nil if it does not exist
+func (service *WebhookService) webhook(id bson.ObjectID) *models.Webhook {
+	webhooks := service.webhooks.Load()
+	if webhooks == nil {
+		return nil
+	}
+
+	index := slices.IndexFunc(*webhooks, func(webhook models.Webhook) bool {
+		return webhook.ID == id
+	})
+	if index < 0 {
+		return nil
+	}
+
+	return &(*webhooks)[index]
+}
+
+// post sends a delivery to its webhook and returns the response status code
+func (service *WebhookService) post(ctx context.Context, webhook models.Webhook, delivery models.WebhookDelivery) (int, error) {
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, webhook.URL, bytes.NewReader([]byte(delivery.Payload)))
+	if err != nil {
+		return 0, err
+	}
+	request.Header.Set("Content-Type", "application/json")
+	request.Header.Set("User-Agent", webhookUserAgent)
+	request.Header.Set(WebhookEventHeader, delivery.Event)
+	request.Header.Set(WebhookDeliveryHeader, delivery.ID.Hex())
+	request.Header.Set(WebhookSignatureHeader, SignWebhookPayload(webhook.Secret, time.Now(), []byte(delivery.Payload)))
+
+	response, err := service.client.Do(request)
+	if err != nil {
+		return 0, err
+	}
+	defer response.Body.Close()
+
+	// Drain a bounded part of the body so the connection can be reused
+	io.CopyN(io.Discard, response.Body, webhookBodyLimit)
+
+	return response.StatusCode, nil
+}
+
+// backoff returns the delay before the next attempt after the given number of failed attempts,
+// doubling from the retry backoff up to the max retry backoff
+func (service *WebhookService) backoff(attempts int) time.Duration {
+	delay := service.cfg.Webhook.RetryBackoff
+	for i := 1; i < attempts && delay < service.cfg.Webhook.MaxRetryBackoff; i++ {
+		delay *= 2
+	}
+
+	return min(delay, service.cfg.Webhook.MaxRetryBackoff)
+}
+
+// SignWebhookPayload returns the signature header of a payload sent at the given time:
+// "t=<unix seconds>,v1=<hex HMAC-SHA256 of "<unix seconds>.<payload>" keyed by the secret>".
+// Receivers recompute the HMAC and reject old timestamps to prevent replays.
+func SignWebhookPayload(secret string, at time.Time, payload []byte) string {
+	timestamp := strconv.FormatInt(at.Unix(), 10)
+
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp + "."))
+	mac.Write(payload)
+
+	return "t=" + timestamp + ",v1=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// webhookTarget identifies a webhook in the audit log by its ID
+func webhookTarget(webhook *models.Webhook) models.AuditTarget {
+	return models.AuditTarget{Type: models.AuditTargetWebhook, ID: webhook.ID.Hex()}
+}
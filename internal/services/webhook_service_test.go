@@ -0,0 +1,179 @@
+package services
+
+import (
+	"context"
+	"github.com/aarondever/linko/internal/models"
+	"github.com/aarondever/linko/pkg/client"
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestSignWebhookPayload(t *testing.T) {
+	at := time.Unix(1700000000, 0)
+
+	tests := []struct {
+		name      string
+		secret    string
+		payload   string
+		signature string
+	}{
+		{
+			name:      "event payload",
+			secret:    "whsec_test",
+			payload:   `{"event":"link.created"}`,
+			signature: "t=1700000000,v1=157c90f250cb20ef0f8f798ef6b985d7bf78bcf43128ad5325212589883c33e8",
+		},
+		{
+			name:      "empty payload",
+			secret:    "whsec_test",
+			payload:   "",
+			signature: "t=1700000000,v1=5967f3c560522fa40cf2876ebc3c3a08551dd6959aaade3b413460591895bdcc",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if signature := SignWebhookPayload(test.secret, at, []byte(test.payload)); signature != test.signature {
+				t.Errorf("signature = %s, want %s", signature, test.signature)
+			}
+		})
+	}
+}
+
+func TestWebhookBackoff(t *testing.T) {
+	cfg := newTestConfig(t)
+	cfg.Webhook.RetryBackoff = 30 * time.Second
+	cfg.Webhook.MaxRetryBackoff = 5 * time.Minute
+	service := &WebhookService{cfg: cfg}
+
+	tests := []struct {
+		attempts int
+		delay    time.Duration
+	}{
+		{1, 30 * time.Second},
+		{2, time.Minute},
+		{3, 2 * time.Minute},
+		{4, 4 * time.Minute},
+		{5, 5 * time.Minute},
+		{100, 5 * time.Minute},
+	}
+
+	for _, test := range tests {
+		if delay := service.backoff(test.attempts); delay != test.delay {
+			t.Errorf("backoff after %d attempts = %v, want %v", test.attempts, delay, test.delay)
+		}
+	}
+}
+
+func TestNextDeliveryState(t *testing.T) {
+	cfg := newTestConfig(t)
+	cfg.Webhook.MaxAttempts = 3
+	cfg.Webhook.RetryBackoff = 30 * time.Second
+	cfg.Webhook.MaxRetryBackoff = time.Hour
+	service := &WebhookService{cfg: cfg}
+	at := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name          string
+		err           string
+		attempts      int
+		webhookExists bool
+		status        string
+		nextAttemptAt time.Time
+	}{
+		{
+			name:          "success",
+			attempts:      1,
+			webhookExists: true,
+			status:        models.WebhookDeliverySucceeded,
+		},
+		{
+			name:          "first failure is retried",
+			err:           "unexpected status 503",
+			attempts:      1,
+			webhookExists: true,
+			status:        models.WebhookDeliveryPending,
+			nextAttemptAt: at.Add(30 * time.Second),
+		},
+		{
+			name:          "later failure is retried later",
+			err:           "unexpected status 503",
+			attempts:      2,
+			webhookExists: true,
+			status:        models.WebhookDeliveryPending,
+			nextAttemptAt: at.Add(time.Minute),
+		},
+		{
+			name:          "last attempt",
+			err:           "unexpected status 503",
+			attempts:      3,
+			webhookExists: true,
+			status:        models.WebhookDeliveryDead,
+		},
+		{
+			name:     "deleted webhook",
+			err:      "webhook no longer exists",
+			attempts: 1,
+			status:   models.WebhookDeliveryDead,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			attempt := models.WebhookAttempt{At: at, Error: test.err}
+			status, nextAttemptAt := service.nextDeliveryState(attempt, test.attempts, test.webhookExists)
+			if status != test.status || !nextAttemptAt.Equal(test.nextAttemptAt) {
+				t.Errorf("status, next attempt = %s, %v, want %s, %v", status, nextAttemptAt, test.status, test.nextAttemptAt)
+			}
+		})
+	}
+}
+
+func TestWebhookPost(t *testing.T) {
+	const payload = `{"event":"link.created"}`
+
+	var request *http.Request
+	var body []byte
+	server := httptest.NewServer(http.HandlerFunc(func(responseWriter http.ResponseWriter, received *http.Request) {
+		request = received
+		body, _ = io.ReadAll(received.Body)
+		responseWriter.WriteHeader(http.StatusAccepted)
+	}))
+	defer server.Close()
+
+	cfg := newTestConfig(t)
+	cfg.Destination.AllowPrivateNetworks = true
+	service := NewWebhookService(nil, cfg, NewDestinationValidator(cfg), nil)
+
+	webhook := models.Webhook{URL: server.URL, Secret: "whsec_test"}
+	delivery := models.WebhookDelivery{ID: bson.NewObjectID(), Event: models.LinkEventCreated, Payload: payload}
+	statusCode, err := service.post(context.Background(), webhook, delivery)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if statusCode != http.StatusAccepted {
+		t.Errorf("status code = %d, want %d", statusCode, http.StatusAccepted)
+	}
+
+	if string(body) != payload {
+		t.Errorf("body = %s, want %s", body, payload)
+	}
+	for name, want := range map[string]string{
+		"Content-Type":        "application/json",
+		WebhookEventHeader:    models.LinkEventCreated,
+		WebhookDeliveryHeader: delivery.ID.Hex(),
+	} {
+		if got := request.Header.Get(name); got != want {
+			t.Errorf("%s = %q, want %q", name, got, want)
+		}
+	}
+	// Receivers verify deliveries with the client package
+	signature := request.Header.Get(WebhookSignatureHeader)
+	if err = client.VerifyWebhookSignature(webhook.Secret, signature, body, time.Minute); err != nil {
+		t.Errorf("signature %q does not verify: %v", signature, err)
+	}
+}
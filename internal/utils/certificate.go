@@ -0,0 +1,83 @@
+package utils
+
+import (
+	"context"
+	"crypto/tls"
+	"log/slog"
+	"os"
+	"sync/atomic"
+	"time"
+)
+
+// CertificateReloader serves a TLS certificate loaded from PEM files and loads it again when
+// the files change, so that renewed certificates are served without a restart
+type CertificateReloader struct {
+	certFile    string
+	keyFile     string
+	certificate atomic.Pointer[tls.Certificate]
+	modTime     time.Time // Latest modification time of the files loaded, only used by Run
+}
+
+// NewCertificateReloader loads the certificate of certFile and keyFile
+func NewCertificateReloader(certFile, keyFile string) (*CertificateReloader, error) {
+	reloader := &CertificateReloader{certFile: certFile, keyFile: keyFile}
+	if err := reloader.reload(); err != nil {
+		return nil, err
+	}
+
+	return reloader, nil
+}
+
+// GetCertificate returns the current certificate, for use as tls.Config.GetCertificate
+func (reloader *CertificateReloader) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	return reloader.certificate.Load(), nil
+}
+
+// Run checks the files for changes every interval until ctx is cancelled. A certificate that
+// fails to load is logged and the previous one is kept.
+func (reloader *CertificateReloader) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := reloader.reload(); err != nil {
+				slog.Error("Failed reloading TLS certificate, keeping previous certificate",
+					"cert_file", reloader.certFile,
+					"error", err)
+			}
+		}
+	}
+}
+
+// reload loads the certificate if either file changed since it was last loaded
+func (reloader *CertificateReloader) reload() error {
+	var modTime time.Time
+	for _, filename := range []string{reloader.certFile, reloader.keyFile} {
+		info, err := os.Stat(filename)
+		if err != nil {
+			return err
+		}
+		if info.ModTime().After(modTime) {
+			modTime = info.ModTime()
+		}
+	}
+
+	if modTime.Equal(reloader.modTime) {
+		return nil
+	}
+
+	certificate, err := tls.LoadX509KeyPair(reloader.certFile, reloader.keyFile)
+	if err != nil {
+		return err
+	}
+
+	reloader.certificate.Store(&certificate)
+	reloader.modTime = modTime
+	slog.Info("Loaded TLS certificate", "cert_file", reloader.certFile)
+
+	return nil
+}
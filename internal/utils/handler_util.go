@@ -2,44 +2,57 @@ package utils
 
 import (
 	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/aarondever/linko/internal/models"
 	"github.com/go-playground/validator/v10"
 	"log/slog"
 	"net/http"
+	"reflect"
+	"strings"
 )
 
+var validate = newValidator()
+
 // RespondWithJSON marshals the given payload to JSON and writes it to the response writer
 func RespondWithJSON(responseWriter http.ResponseWriter, payload any, statusCode int) {
+	respondWithBody(responseWriter, payload, statusCode, "application/json")
+}
+
+// RespondWithProblem writes an RFC 7807 problem details response
+func RespondWithProblem(responseWriter http.ResponseWriter, problem models.Problem) {
+	respondWithBody(responseWriter, problem, problem.Status, "application/problem+json")
+}
+
+func respondWithBody(responseWriter http.ResponseWriter, payload any, statusCode int, contentType string) {
 	data, err := json.Marshal(payload)
 	if err != nil {
 		slog.Error("Failed to marshal JSON response", "error", err, "payload", payload)
-		RespondWithError(responseWriter, err.Error(), http.StatusInternalServerError)
+
+		responseWriter.Header().Set("Content-Type", "application/problem+json")
+		responseWriter.WriteHeader(http.StatusInternalServerError)
+		fmt.Fprintf(responseWriter, `{"type":"about:blank","title":%q,"status":%d,"code":"internal_error"}`,
+			http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
 		return
 	}
 
-	responseWriter.Header().Set("Content-Type", "application/json")
+	responseWriter.Header().Set("Content-Type", contentType)
 	responseWriter.WriteHeader(statusCode)
 	responseWriter.Write(data)
 }
 
-// RespondWithError sends a standardized error response in JSON format
-func RespondWithError(responseWriter http.ResponseWriter, err string, statusCode int) {
-	slog.Error("Responding with error", "error", err, "status_code", statusCode)
-
-	type errorResponse struct {
-		Error string `json:"error"`
-	}
-
-	RespondWithJSON(responseWriter, errorResponse{err}, statusCode)
-}
-
 func DecodeRequestBody(request *http.Request, params any) error {
 	decoder := json.NewDecoder(request.Body)
 	if err := decoder.Decode(params); err != nil {
-		slog.Error("Error decoding request body", "error", err)
+		slog.ErrorContext(request.Context(), "Error decoding request body", "error", err)
 		return err
 	}
 
-	var validate = validator.New()
+	return ValidateStruct(params)
+}
+
+// ValidateStruct validates params against its `validate` struct tags
+func ValidateStruct(params any) error {
 	if err := validate.Struct(params); err != nil {
 		slog.Error("Validation error", "error", err)
 		return err
@@ -47,3 +60,63 @@ func DecodeRequestBody(request *http.Request, params any) error {
 
 	return nil
 }
+
+// FieldErrors converts the validation errors returned by ValidateStruct into field level
+// details named after the JSON fields. It returns nil for any other error.
+func FieldErrors(err error) []models.FieldError {
+	var validationErrors validator.ValidationErrors
+	if !errors.As(err, &validationErrors) {
+		return nil
+	}
+
+	fields := make([]models.FieldError, len(validationErrors))
+	for i, fieldErr := range validationErrors {
+		fields[i] = models.FieldError{
+			Field:   fieldErr.Field(),
+			Rule:    fieldErr.Tag(),
+			Message: validationMessage(fieldErr),
+		}
+	}
+
+	return fields
+}
+
+func validationMessage(fieldErr validator.FieldError) string {
+	switch fieldErr.Tag() {
+	case "required":
+		return "is required"
+	case "url":
+		return "must be a valid URL"
+	case "http_url":
+		return "must be a valid HTTP or HTTPS URL"
+	case "fqdn":
+		return "must be a fully qualified host name"
+	case "hexcolor":
+		return "must be a hex color like #2563eb"
+	case "min":
+		return fmt.Sprintf("must be at least %s", fieldErr.Param())
+	case "max":
+		return fmt.Sprintf("must be at most %s", fieldErr.Param())
+	case "oneof":
+		return fmt.Sprintf("must be one of %s", fieldErr.Param())
+	default:
+		return fmt.Sprintf("failed the %q rule", fieldErr.Tag())
+	}
+}
+
+// newValidator creates a validator reporting fields by their JSON names
+func newValidator() *validator.Validate {
+	instance := validator.New()
+	instance.RegisterTagNameFunc(func(field reflect.StructField) string {
+		name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if name == "-" {
+			return ""
+		}
+		if name == "" {
+			return field.Name
+		}
+		return name
+	})
+
+	return instance
+}
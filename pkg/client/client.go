@@ -0,0 +1,408 @@
+// Package client is a Go client for the linko HTTP API.
+//
+// Request and response types are shared with the server, so the client always matches
+// the API it is built with:
+//
+//	c, err := client.New("https://lnk.example", client.WithAPIKey(os.Getenv("LINKO_API_KEY")))
+//	if err != nil {
+//		return err
+//	}
+//	shortened, err := c.Shorten(ctx, "https://example.com/some/long/path")
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Default retry policy, see WithRetry
+const (
+	DefaultMaxRetries     = 3
+	DefaultMinRetryDelay  = 200 * time.Millisecond
+	DefaultMaxRetryDelay  = 5 * time.Second
+	defaultRequestTimeout = 30 * time.Second
+)
+
+// Client calls the linko API. It is safe for concurrent use.
+type Client struct {
+	baseURL       *url.URL
+	httpClient    *http.Client
+	apiKey        string
+	userAgent     string
+	maxRetries    int
+	minRetryDelay time.Duration
+	maxRetryDelay time.Duration
+}
+
+// Option configures a Client
+type Option func(client *Client)
+
+// WithAPIKey authenticates every request with the given API key
+func WithAPIKey(apiKey string) Option {
+	return func(client *Client) {
+		client.apiKey = apiKey
+	}
+}
+
+// WithHTTPClient sets the HTTP client used to send requests
+func WithHTTPClient(httpClient *http.Client) Option {
+	return func(client *Client) {
+		client.httpClient = httpClient
+	}
+}
+
+// WithUserAgent sets the User-Agent header sent with every request
+func WithUserAgent(userAgent string) Option {
+	return func(client *Client) {
+		client.userAgent = userAgent
+	}
+}
+
+// WithRetry configures how requests are retried after a 429 response, or a 5xx response or a
+// network error for idempotent methods (GET, HEAD, PUT and DELETE). Delays grow exponentially
+// from minDelay up to maxDelay with jitter, unless the server sends a Retry-After header. Set
+// maxRetries to 0 to disable retries.
+func WithRetry(maxRetries int, minDelay, maxDelay time.Duration) Option {
+	return func(client *Client) {
+		client.maxRetries = maxRetries
+		client.minRetryDelay = minDelay
+		client.maxRetryDelay = maxDelay
+	}
+}
+
+// New creates a client for the linko instance at baseURL, e.g. "https://lnk.example"
+func New(baseURL string, opts ...Option) (*Client, error) {
+	parsed, err := url.Parse(baseURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid base URL: %w", err)
+	}
+	if parsed.Scheme != "http" && parsed.Scheme != "https" {
+		return nil, fmt.Errorf("invalid base URL %q: scheme must be http or https", baseURL)
+	}
+	parsed.Path = strings.TrimSuffix(parsed.Path, "/")
+
+	client := &Client{
+		baseURL:       parsed,
+		httpClient:    &http.Client{Timeout: defaultRequestTimeout},
+		userAgent:     "linko-go-client",
+		maxRetries:    DefaultMaxRetries,
+		minRetryDelay: DefaultMinRetryDelay,
+		maxRetryDelay: DefaultMaxRetryDelay,
+	}
+
+	for _, opt := range opts {
+		opt(client)
+	}
+
+	return client, nil
+}
+
+// Shorten creates a short link for longURL
+func (client *Client) Shorten(ctx context.Context, longURL string) (*ShortenURLResponse, error) {
+	var response ShortenURLResponse
+	err := client.do(ctx, http.MethodPost, "/api/v1/url/shorten", nil, ShortenURLRequest{URL: longURL}, &response)
+	if err != nil {
+		return nil, err
+	}
+
+	return &response, nil
+}
+
+// BulkShorten creates short links for a batch of URLs. Individual failures are reported in the
+// per-item results rather than as an error, unless every item failed, which the API reports with
+// a 422 status returned as an *APIError.
+func (client *Client) BulkShorten(ctx context.Context, longURLs []string) (*BulkShortenResponse, error) {
+	params := make([]ShortenURLRequest, len(longURLs))
+	for i, longURL := range longURLs {
+		params[i] = ShortenURLRequest{URL: longURL}
+	}
+
+	var response BulkShortenResponse
+	if err := client.do(ctx, http.MethodPost, "/api/v1/urls/bulk", nil, params, &response); err != nil {
+		return nil, err
+	}
+
+	return &response, nil
+}
+
+// Get returns the short link with the given short code
+func (client *Client) Get(ctx context.Context, shortCode string) (*URLMapping, error) {
+	var response URLMapping
+	if err := client.do(ctx, http.MethodGet, urlPath(shortCode), nil, nil, &response); err != nil {
+		return nil, err
+	}
+
+	return &response, nil
+}
+
+// ListOptions paginates and filters List. Zero values use the server defaults.
+type ListOptions struct {
+	Offset int64
+	Limit  int64
+	Broken bool   // Only list links whose destination is reported broken
+	Domain string // Only list links on this custom domain instead of the default host
+}
+
+// List returns a page of short links, newest first
+func (client *Client) List(ctx context.Context, opts ListOptions) (*ListURLsResponse, error) {
+	query := url.Values{}
+	if opts.Offset > 0 {
+		query.Set("offset", strconv.FormatInt(opts.Offset, 10))
+	}
+	if opts.Limit > 0 {
+		query.Set("limit", strconv.FormatInt(opts.Limit, 10))
+	}
+	if opts.Broken {
+		query.Set("broken", "true")
+	}
+	if opts.Domain != "" {
+		query.Set("domain", opts.Domain)
+	}
+
+	var response ListURLsResponse
+	if err := client.do(ctx, http.MethodGet, "/api/v1/urls", query, nil, &response); err != nil {
+		return nil, err
+	}
+
+	return &response, nil
+}
+
+// Update changes the short link with the given short code and returns the updated link
+func (client *Client) Update(ctx context.Context, shortCode string, params UpdateURLRequest) (*URLMapping, error) {
+	var response URLMapping
+	if err := client.do(ctx, http.MethodPatch, urlPath(shortCode), nil, params, &response); err != nil {
+		return nil, err
+	}
+
+	return &response, nil
+}
+
+// Delete deletes the short link with the given short code
+func (client *Client) Delete(ctx context.Context, shortCode string) error {
+	return client.do(ctx, http.MethodDelete, urlPath(shortCode), nil, nil, nil)
+}
+
+// Stats returns the click stats of the short link with the given short code
+func (client *Client) Stats(ctx context.Context, shortCode string) (*URLStatsResponse, error) {
+	var response URLStatsResponse
+	if err := client.do(ctx, http.MethodGet, urlPath(shortCode)+"/stats", nil, nil, &response); err != nil {
+		return nil, err
+	}
+
+	return &response, nil
+}
+
+// do sends a request, retrying it according to the retry policy, and decodes the JSON response
+// into result if it is not nil
+func (client *Client) do(ctx context.Context, method, path string, query url.Values, body, result any) error {
+	var payload []byte
+	if body != nil {
+		var err error
+		if payload, err = json.Marshal(body); err != nil {
+			return fmt.Errorf("encoding request body: %w", err)
+		}
+	}
+
+	endpoint := *client.baseURL
+	endpoint.Path += path
+	endpoint.RawQuery = query.Encode()
+
+	for attempt := 0; ; attempt++ {
+		response, err := client.send(ctx, method, endpoint.String(), payload)
+		if err != nil {
+			// The request may have reached the server, so only idempotent requests are sent again
+			if ctx.Err() != nil || attempt >= client.maxRetries || !isIdempotent(method) {
+				return err
+			}
+			if err = client.wait(ctx, attempt, nil); err != nil {
+				return err
+			}
+			continue
+		}
+
+		if response.StatusCode < 300 {
+			return decodeResponse(response, result)
+		}
+
+		apiErr := newAPIError(response)
+		if attempt >= client.maxRetries || !isRetryable(method, response.StatusCode) {
+			return apiErr
+		}
+		if err = client.wait(ctx, attempt, response); err != nil {
+			return err
+		}
+	}
+}
+
+func (client *Client) send(ctx context.Context, method, endpoint string, payload []byte) (*http.Response, error) {
+	var body io.Reader
+	if payload != nil {
+		body = bytes.NewReader(payload)
+	}
+
+	request, err := http.NewRequestWithContext(ctx, method, endpoint, body)
+	if err != nil {
+		return nil, err
+	}
+
+	request.Header.Set("Accept", "application/json")
+	request.Header.Set("User-Agent", client.userAgent)
+	if payload != nil {
+		request.Header.Set("Content-Type", "application/json")
+	}
+	if client.apiKey != "" {
+		request.Header.Set("Authorization", "Bearer "+client.apiKey)
+	}
+
+	return client.httpClient.Do(request)
+}
+
+// wait sleeps before the next attempt, honoring the Retry-After header of response if present
+func (client *Client) wait(ctx context.Context, attempt int, response *http.Response) error {
+	delay := client.minRetryDelay << attempt
+	if delay > client.maxRetryDelay || delay <= 0 {
+		delay = client.maxRetryDelay
+	}
+	// Full jitter spreads retries of concurrent clients
+	delay = time.Duration(rand.Int64N(int64(delay) + 1))
+
+	if response != nil {
+		if retryAfter, ok := parseRetryAfter(response.Header.Get("Retry-After")); ok {
+			delay = retryAfter
+		}
+	}
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// decodeResponse decodes the JSON body of a successful response into result if it is not nil,
+// and closes the body
+func decodeResponse(response *http.Response, result any) error {
+	defer response.Body.Close()
+
+	if result == nil || response.StatusCode == http.StatusNoContent {
+		return nil
+	}
+	if err := json.NewDecoder(response.Body).Decode(result); err != nil {
+		return fmt.Errorf("decoding response body: %w", err)
+	}
+
+	return nil
+}
+
+// isRetryable reports whether a failed request may be sent again. Rate limited requests were not
+// processed and are always retried; server errors are only retried for idempotent methods so that
+// a request is never applied twice.
+func isRetryable(method string, statusCode int) bool {
+	if statusCode == http.StatusTooManyRequests {
+		return true
+	}
+
+	if statusCode < 500 || statusCode == http.StatusNotImplemented {
+		return false
+	}
+
+	return isIdempotent(method)
+}
+
+// isIdempotent reports whether sending a request with method more than once has the same effect
+// as sending it once
+func isIdempotent(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodPut, http.MethodDelete:
+		return true
+	default:
+		return false
+	}
+}
+
+func parseRetryAfter(value string) (time.Duration, bool) {
+	if value == "" {
+		return 0, false
+	}
+
+	if seconds, err := strconv.Atoi(value); err == nil && seconds >= 0 {
+		return time.Duration(seconds) * time.Second, true
+	}
+
+	if date, err := http.ParseTime(value); err == nil {
+		return max(time.Until(date), 0), true
+	}
+
+	return 0, false
+}
+
+func urlPath(shortCode string) string {
+	return "/api/v1/urls/" + url.PathEscape(shortCode)
+}
+
+// APIError is returned when the API responds with an error status. Code is the stable error
+// code of the problem details body, e.g. "url_not_found".
+type APIError struct {
+	StatusCode int
+	Code       string
+	Message    string
+	RequestID  string
+	Fields     []FieldError
+}
+
+func (err *APIError) Error() string {
+	message := fmt.Sprintf("linko: %d %s", err.StatusCode, http.StatusText(err.StatusCode))
+	if err.Code != "" {
+		message += " (" + err.Code + ")"
+	}
+	if err.Message != "" {
+		message += ": " + err.Message
+	}
+
+	return message
+}
+
+// IsNotFound reports whether err is an API error for a missing resource
+func IsNotFound(err error) bool {
+	var apiErr *APIError
+	return errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusNotFound
+}
+
+// newAPIError reads an error response and closes its body
+func newAPIError(response *http.Response) *APIError {
+	defer response.Body.Close()
+
+	apiErr := &APIError{
+		StatusCode: response.StatusCode,
+		RequestID:  response.Header.Get("X-Request-Id"),
+	}
+
+	var problem Problem
+	data, _ := io.ReadAll(io.LimitReader(response.Body, 64*1024))
+	if json.Unmarshal(data, &problem) == nil && problem.Code != "" {
+		apiErr.Code = problem.Code
+		apiErr.Message = problem.Detail
+		apiErr.Fields = problem.Errors
+		if problem.RequestID != "" {
+			apiErr.RequestID = problem.RequestID
+		}
+	} else {
+		apiErr.Message = strings.TrimSpace(string(data))
+	}
+
+	return apiErr
+}
@@ -0,0 +1,172 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestClientRetries(t *testing.T) {
+	shorten := func(client *Client) error {
+		_, err := client.Shorten(context.Background(), "https://example.com")
+		return err
+	}
+	update := func(client *Client) error {
+		_, err := client.Update(context.Background(), "abc123", UpdateURLRequest{})
+		return err
+	}
+	get := func(client *Client) error {
+		_, err := client.Get(context.Background(), "abc123")
+		return err
+	}
+	deleteLink := func(client *Client) error {
+		return client.Delete(context.Background(), "abc123")
+	}
+
+	// A status of 0 closes the connection without a response
+	tests := []struct {
+		name     string
+		call     func(client *Client) error
+		statuses []int
+		attempts int
+		status   int // Status of the returned API error, 0 for success or a network error
+		err      bool
+	}{
+		{
+			name:     "GET is retried after a server error",
+			call:     get,
+			statuses: []int{http.StatusServiceUnavailable, http.StatusOK},
+			attempts: 2,
+		},
+		{
+			name:     "DELETE is retried after a network error",
+			call:     deleteLink,
+			statuses: []int{0, http.StatusNoContent},
+			attempts: 2,
+		},
+		{
+			name:     "GET gives up after the maximum retries",
+			call:     get,
+			statuses: []int{http.StatusBadGateway},
+			attempts: 3,
+			status:   http.StatusBadGateway,
+			err:      true,
+		},
+		{
+			name:     "POST is not retried after a server error",
+			call:     shorten,
+			statuses: []int{http.StatusServiceUnavailable, http.StatusCreated},
+			attempts: 1,
+			status:   http.StatusServiceUnavailable,
+			err:      true,
+		},
+		{
+			name:     "POST is not retried after a network error",
+			call:     shorten,
+			statuses: []int{0, http.StatusCreated},
+			attempts: 1,
+			err:      true,
+		},
+		{
+			name:     "PATCH is not retried after a server error",
+			call:     update,
+			statuses: []int{http.StatusInternalServerError, http.StatusOK},
+			attempts: 1,
+			status:   http.StatusInternalServerError,
+			err:      true,
+		},
+		{
+			name:     "POST is retried after a 429",
+			call:     shorten,
+			statuses: []int{http.StatusTooManyRequests, http.StatusCreated},
+			attempts: 2,
+		},
+		{
+			name:     "501 is not retried",
+			call:     get,
+			statuses: []int{http.StatusNotImplemented, http.StatusOK},
+			attempts: 1,
+			status:   http.StatusNotImplemented,
+			err:      true,
+		},
+		{
+			name:     "client errors are not retried",
+			call:     get,
+			statuses: []int{http.StatusNotFound, http.StatusOK},
+			attempts: 1,
+			status:   http.StatusNotFound,
+			err:      true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var attempts atomic.Int32
+			server := httptest.NewServer(http.HandlerFunc(func(responseWriter http.ResponseWriter, request *http.Request) {
+				attempt := int(attempts.Add(1)) - 1
+				status := test.statuses[min(attempt, len(test.statuses)-1)]
+				if status == 0 {
+					connection, _, _ := responseWriter.(http.Hijacker).Hijack()
+					connection.Close()
+					return
+				}
+
+				responseWriter.Header().Set("Content-Type", "application/json")
+				responseWriter.WriteHeader(status)
+				responseWriter.Write([]byte("{}"))
+			}))
+			defer server.Close()
+
+			client, err := New(server.URL, WithRetry(2, time.Millisecond, time.Millisecond))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			err = test.call(client)
+			if got := int(attempts.Load()); got != test.attempts {
+				t.Errorf("attempts = %d, want %d", got, test.attempts)
+			}
+
+			if !test.err {
+				if err != nil {
+					t.Errorf("unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil {
+				t.Fatal("expected an error")
+			}
+
+			var apiErr *APIError
+			if errors.As(err, &apiErr) != (test.status != 0) || (apiErr != nil && apiErr.StatusCode != test.status) {
+				t.Errorf("error = %v, want status %d", err, test.status)
+			}
+		})
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	tests := []struct {
+		value string
+		delay time.Duration
+		ok    bool
+	}{
+		{"", 0, false},
+		{"3", 3 * time.Second, true},
+		{"0", 0, true},
+		{"-1", 0, false},
+		{"soon", 0, false},
+		{"Mon, 02 Jan 2006 15:04:05 GMT", 0, true},
+	}
+
+	for _, test := range tests {
+		delay, ok := parseRetryAfter(test.value)
+		if delay != test.delay || ok != test.ok {
+			t.Errorf("parseRetryAfter(%q) = %v, %t, want %v, %t", test.value, delay, ok, test.delay, test.ok)
+		}
+	}
+}
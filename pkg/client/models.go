@@ -0,0 +1,21 @@
+package client
+
+import "github.com/aarondever/linko/internal/models"
+
+// Request and response types shared with the server
+type (
+	ShortenURLRequest   = models.ShortenURLRequest
+	ShortenURLResponse  = models.ShortenURLResponse
+	BulkShortenResult   = models.BulkShortenResult
+	BulkShortenResponse = models.BulkShortenResponse
+	UpdateURLRequest    = models.UpdateURLRequest
+	ListURLsResponse    = models.ListURLsResponse
+	URLStatsResponse    = models.URLStatsResponse
+	URLMapping          = models.URLMapping
+	LinkHealth          = models.LinkHealth
+	LinkEvent           = models.LinkEvent
+	Domain              = models.Domain
+	DomainVerification  = models.DomainVerification
+	Problem             = models.Problem
+	FieldError          = models.FieldError
+)
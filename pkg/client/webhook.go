@@ -0,0 +1,80 @@
+package client
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Headers of a webhook delivery
+const (
+	WebhookEventHeader     = "X-Linko-Event"
+	WebhookDeliveryHeader  = "X-Linko-Delivery"
+	WebhookSignatureHeader = "X-Linko-Signature"
+)
+
+// DefaultWebhookTolerance is how old a webhook signature may be before it is rejected as a replay
+const DefaultWebhookTolerance = 5 * time.Minute
+
+// ErrInvalidWebhookSignature is returned when a webhook delivery is not signed with the
+// expected secret or its signature is too old
+var ErrInvalidWebhookSignature = errors.New("linko: invalid webhook signature")
+
+// VerifyWebhookSignature checks the X-Linko-Signature header of a webhook delivery against its
+// body and the secret returned when the webhook was registered. Signatures older than tolerance
+// are rejected to prevent replays.
+func VerifyWebhookSignature(secret, header string, body []byte, tolerance time.Duration) error {
+	var timestamp, signature string
+	for part := range strings.SplitSeq(header, ",") {
+		key, value, _ := strings.Cut(strings.TrimSpace(part), "=")
+		switch key {
+		case "t":
+			timestamp = value
+		case "v1":
+			signature = value
+		}
+	}
+
+	seconds, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return ErrInvalidWebhookSignature
+	}
+	if age := time.Since(time.Unix(seconds, 0)); age > tolerance || age < -tolerance {
+		return ErrInvalidWebhookSignature
+	}
+
+	expected, err := hex.DecodeString(signature)
+	if err != nil {
+		return ErrInvalidWebhookSignature
+	}
+
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp + "."))
+	mac.Write(body)
+	if !hmac.Equal(mac.Sum(nil), expected) {
+		return ErrInvalidWebhookSignature
+	}
+
+	return nil
+}
+
+// ParseWebhookEvent verifies a webhook delivery with DefaultWebhookTolerance and decodes its
+// link event. Deliveries of the same event, e.g. redeliveries, share the event ID.
+func ParseWebhookEvent(secret string, header http.Header, body []byte) (*LinkEvent, error) {
+	if err := VerifyWebhookSignature(secret, header.Get(WebhookSignatureHeader), body, DefaultWebhookTolerance); err != nil {
+		return nil, err
+	}
+
+	var event LinkEvent
+	if err := json.Unmarshal(body, &event); err != nil {
+		return nil, err
+	}
+
+	return &event, nil
+}